@@ -0,0 +1,130 @@
+package jqyaml_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithAckCallback(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("range(.)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("flushes the writer before acknowledging", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := bufio.NewWriterSize(&buf, 4096)
+		var written []string
+		err := p.Execute(context.Background(), 3,
+			jqyaml.WithWriter(w, jqyaml.FormatJSON),
+			jqyaml.WithAckCallback(func(index int) error {
+				written = append(written, buf.String())
+				return nil
+			}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"0\n", "0\n1\n", "0\n1\n2\n"}
+		if diff := cmp.Diff(want, written); diff != "" {
+			t.Errorf("output at acknowledgment mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		var events []string
+		err := p.Execute(context.Background(), 2,
+			jqyaml.WithCallback(func(v interface{}) error {
+				events = append(events, "result")
+				return nil
+			}),
+			jqyaml.WithAckCallback(func(index int) error {
+				events = append(events, "ack "+strconv.Itoa(index))
+				return nil
+			}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"result", "ack 0", "result", "ack 1"}
+		if diff := cmp.Diff(want, events); diff != "" {
+			t.Errorf("events mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		var events []interface{}
+		err := p.Execute(context.Background(), 3,
+			jqyaml.WithBatchCallback(2, func(batch []interface{}) error {
+				events = append(events, batch)
+				return nil
+			}),
+			jqyaml.WithAckCallback(func(index int) error {
+				events = append(events, index)
+				return nil
+			}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []interface{}{[]interface{}{0, 1}, 0, 1, []interface{}{2}, 2}
+		if diff := cmp.Diff(want, events); diff != "" {
+			t.Errorf("events mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestWithAckCallbackError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("range(.)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCommit := errors.New("commit failed")
+	results := 0
+	err = p.Execute(context.Background(), 5,
+		jqyaml.WithCallback(func(interface{}) error {
+			results++
+			return nil
+		}),
+		jqyaml.WithAckCallback(func(index int) error {
+			if index == 1 {
+				return errCommit
+			}
+			return nil
+		}))
+	if !errors.Is(err, errCommit) || results != 2 {
+		t.Errorf("got %v after %d results, want the ack error after 2 results", err, results)
+	}
+
+	err = p.Execute(context.Background(), 1, jqyaml.WithCallback(func(interface{}) error { return nil }), jqyaml.WithAckCallback(nil))
+	if err == nil {
+		t.Error("expected an error for a nil ack callback")
+	}
+}
+
+// failingFlusher accepts writes but fails to flush them
+type failingFlusher struct{ bytes.Buffer }
+
+func (*failingFlusher) Flush() error { return errors.New("disk full") }
+
+func TestWithAckCallbackFlushError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acked := false
+	err = p.Execute(context.Background(), 1,
+		jqyaml.WithWriter(&failingFlusher{}, jqyaml.FormatJSON),
+		jqyaml.WithAckCallback(func(int) error {
+			acked = true
+			return nil
+		}))
+	var writeErr *jqyaml.WriteError
+	if !errors.As(err, &writeErr) || acked {
+		t.Errorf("got %v (acked=%v), want a WriteError without acknowledgment", err, acked)
+	}
+}
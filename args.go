@@ -0,0 +1,58 @@
+package jqyaml
+
+import "fmt"
+
+// withTypedArgs returns converted with the variables of WithArgString,
+// WithArgJSON and WithArgRaw added; they are jq values already and take
+// precedence over variables of the same name
+func withTypedArgs(converted map[string]interface{}, args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return converted
+	}
+	merged := make(map[string]interface{}, len(converted)+len(args))
+	for k, v := range converted {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return merged
+}
+
+// withArgsVariable binds $ARGS in converted, the converted variables, as the
+// jq command does: $ARGS.named holds the variables set by execute options and
+// $ARGS.positional the arguments of WithPositionalArgs. A variable named ARGS
+// takes precedence.
+func (p *pipeline) withArgsVariable(converted map[string]interface{}, cfg *executeConfig, marshaler InputMarshaler) (map[string]interface{}, error) {
+	if _, ok := converted["ARGS"]; ok {
+		return converted, nil
+	}
+	named := make(map[string]interface{}, len(cfg.variables)+len(cfg.typedArgs))
+	for k := range cfg.variables {
+		named[k] = converted[k]
+	}
+	for k := range cfg.typedArgs {
+		named[k] = converted[k]
+	}
+	positional := make([]interface{}, len(cfg.positionalArgs))
+	for i, v := range cfg.positionalArgs {
+		arg, err := marshaler.Marshal(v)
+		if err == nil && p.inputMarshaler != nil {
+			err = validateJQValue(arg)
+		}
+		if err != nil {
+			return nil, &ConversionError{
+				Value: v,
+				Type:  fmt.Sprintf("positional argument %d", i),
+				Err:   err,
+			}
+		}
+		positional[i] = arg
+	}
+	merged := make(map[string]interface{}, len(converted)+1)
+	for k, v := range converted {
+		merged[k] = v
+	}
+	merged["ARGS"] = map[string]interface{}{"named": named, "positional": positional}
+	return merged, nil
+}
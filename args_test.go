@@ -0,0 +1,87 @@
+package jqyaml_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestArgs(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`$ARGS`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type item struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name string
+		opts []jqyaml.ExecuteOption
+		want interface{}
+	}{
+		{
+			name: "empty",
+			want: map[string]interface{}{"named": map[string]interface{}{}, "positional": []interface{}{}},
+		},
+		{
+			name: "named and positional",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithVariables(map[string]interface{}{"env": "prod", "item": item{Name: "a"}}),
+				jqyaml.WithPositionalArgs("x", 1),
+				jqyaml.WithPositionalArgs(item{Name: "b"}),
+			},
+			want: map[string]interface{}{
+				"named":      map[string]interface{}{"env": "prod", "item": map[string]interface{}{"name": "a"}},
+				"positional": []interface{}{"x", 1, map[string]interface{}{"name": "b"}},
+			},
+		},
+		{
+			name: "variable named ARGS",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithVariables(map[string]interface{}{"ARGS": "mine"}),
+				jqyaml.WithPositionalArgs("ignored"),
+			},
+			want: "mine",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(t, p, nil, tt.opts...)
+			if diff := cmp.Diff([]interface{}{tt.want}, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTypedArgs(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`[$s, $j, $raw, $ARGS.named.s]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, _ := new(big.Int).SetString("12345678901234567890", 10)
+
+	got := collect(t, p, nil,
+		jqyaml.WithVariables(map[string]interface{}{"s": "overridden"}),
+		jqyaml.WithArgString("s", "42"),
+		jqyaml.WithArgJSON("j", `{"n": 1, "large": 12345678901234567890, "f": 1.5}`),
+		jqyaml.WithArgRaw("raw", []byte("line 1\nline 2\n")))
+	want := []interface{}{[]interface{}{
+		"42",
+		map[string]interface{}{"n": 1, "large": large, "f": 1.5},
+		"line 1\nline 2\n",
+		"42",
+	}}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b *big.Int) bool { return a.Cmp(b) == 0 })); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	err = p.Execute(context.Background(), nil, jqyaml.WithArgJSON("j", "{"), jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,87 @@
+package jqyaml
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Handle controls an execution started by ExecuteAsync
+type Handle struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	start   time.Time
+	emitted atomic.Int64
+	result  *ExecuteResult // Set before done is closed
+}
+
+// Progress is a snapshot of a running execution
+type Progress struct {
+	Emitted int           // Number of results passed to the output so far
+	Elapsed time.Duration // Time since the execution started
+	Done    bool          // Whether the execution has finished
+}
+
+// ExecuteAsync starts the pipeline in a new goroutine and returns a Handle to
+// wait for, cancel, or observe it. Invalid options are reported immediately.
+// The output applies back-pressure as in Execute: evaluation waits while
+// the writer or callback is busy.
+func (p *pipeline) ExecuteAsync(ctx context.Context, input interface{}, opts ...ExecuteOption) (*Handle, error) {
+	cfg := p.newExecuteConfig(opts...)
+	// The execution closes the channel once started; close it when it fails
+	// to start
+	if err := cfg.validate(); err != nil {
+		closeChannel(cfg.channel)
+		return nil, err
+	}
+	body, err := p.inputBody(cfg, input)
+	if err != nil {
+		closeChannel(cfg.channel)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	h := &Handle{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		start:  time.Now(),
+	}
+	cfg.onEmit = func() {
+		h.emitted.Add(1)
+	}
+	go func() {
+		defer close(h.done)
+		defer cancel()
+		h.result = p.executeResult(ctx, cfg, body)
+	}()
+	return h, nil
+}
+
+// Wait blocks until the execution finishes and returns its result
+func (h *Handle) Wait() *ExecuteResult {
+	<-h.done
+	return h.result
+}
+
+// Done returns a channel that is closed when the execution finishes
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Cancel stops the execution; Wait still has to be called to get its result
+func (h *Handle) Cancel() {
+	h.cancel()
+}
+
+// Progress returns a snapshot of the execution
+func (h *Handle) Progress() Progress {
+	progress := Progress{Emitted: int(h.emitted.Load())}
+	select {
+	case <-h.done:
+		progress.Done = true
+		progress.Elapsed = h.result.Duration
+	default:
+		progress.Elapsed = time.Since(h.start)
+	}
+	return progress
+}
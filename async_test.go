@@ -0,0 +1,76 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestExecuteAsync(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`range(1e9)`), jqyaml.WithNoTimeout())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h, err := p.ExecuteAsync(context.Background(), nil, jqyaml.WithCallback(func(v interface{}) error {
+		if v == 2 {
+			close(started)
+			<-release
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-started
+	// The callback is blocked on the third result, so exactly two were emitted
+	if progress := h.Progress(); progress.Emitted != 2 || progress.Done {
+		t.Errorf("unexpected progress while running: %+v", progress)
+	}
+
+	h.Cancel()
+	close(release)
+	result := h.Wait()
+	if !errors.Is(result.Err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", result.Err)
+	}
+	progress := h.Progress()
+	if !progress.Done || progress.Emitted != result.Emitted || progress.Elapsed != result.Duration {
+		t.Errorf("final progress %+v does not match result %+v", progress, result)
+	}
+	select {
+	case <-h.Done():
+	default:
+		t.Error("Done channel not closed after Wait")
+	}
+}
+
+func TestExecuteAsyncComplete(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`.[]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := p.ExecuteAsync(context.Background(), []int{1, 2, 3}, jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-h.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not finish")
+	}
+	if result := h.Wait(); result.Err != nil || result.Emitted != 3 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// Invalid options are reported without starting
+	if _, err := p.ExecuteAsync(context.Background(), nil); err == nil {
+		t.Error("expected error without output method")
+	}
+}
@@ -0,0 +1,254 @@
+// Package avro reads and writes Avro object container files, registering
+// them as the "avro" input and output format of jqyaml when imported:
+//
+//	import _ "github.com/apstndb/go-jq-yamlformat/avro"
+//
+//	err := jqyaml.Transform(ctx, os.Stdout, jqyaml.FormatJSON, f, avro.Format,
+//		jqyaml.WithQuery(`select(.status == "failed")`))
+//
+// Records are decoded as jq values following the writer's schema: records and
+// maps become objects, enums their symbol, unions the value of their branch,
+// bytes and fixed values []byte (see jqyaml.WithBinaryInput), and logical
+// types their underlying type. Results are written with a schema inferred
+// from them, or with one given in EncoderOptions.
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// Format is the name the avro format is registered under
+const Format jqyaml.Format = "avro"
+
+// DefaultBlockRecords is the number of records written per block when
+// EncoderOptions does not set one
+const DefaultBlockRecords = 1000
+
+func init() {
+	jqyaml.RegisterInputProvider(Format, Provider{})
+	jqyaml.RegisterOutputProvider(Format, Provider{})
+}
+
+// Provider implements jqyaml.InputProvider and jqyaml.OutputProvider.
+// Registering a Provider with its own Options under another name writes
+// results with a fixed schema or codec:
+//
+//	jqyaml.RegisterOutputProvider("avro-events", avro.Provider{Options: avro.EncoderOptions{Schema: eventSchema}})
+type Provider struct {
+	Options EncoderOptions
+}
+
+// NewDecoder implements jqyaml.InputProvider
+func (Provider) NewDecoder(r io.Reader) (jqyaml.Decoder, error) {
+	return NewDecoder(r)
+}
+
+// NewEncoder implements jqyaml.OutputProvider
+func (p Provider) NewEncoder(w io.Writer) (jqyaml.Encoder, error) {
+	return NewEncoder(w, p.Options)
+}
+
+var magic = []byte("Obj\x01")
+
+// Decoder reads the records of an Avro object container file
+type Decoder struct {
+	r      *blockReader
+	schema *schema
+	text   string
+	codec  string
+	sync   [16]byte
+	block  *bytes.Reader
+	count  int64 // Records left in the current block
+}
+
+// NewDecoder reads the header of the container file in r
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	br := newBlockReader(r)
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, header); err != nil || !bytes.Equal(header, magic) {
+		return nil, errors.New("avro: not an object container file")
+	}
+	meta, err := br.readMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("avro: invalid header: %w", err)
+	}
+	d := &Decoder{r: br, text: string(meta["avro.schema"]), codec: string(meta["avro.codec"])}
+	if d.codec == "" {
+		d.codec = "null"
+	}
+	if d.codec != "null" && d.codec != "deflate" {
+		return nil, fmt.Errorf("avro: unsupported codec %q", d.codec)
+	}
+	if d.schema, err = parseSchema(d.text); err != nil {
+		return nil, fmt.Errorf("avro: %w", err)
+	}
+	if _, err := io.ReadFull(br, d.sync[:]); err != nil {
+		return nil, fmt.Errorf("avro: invalid header: %w", err)
+	}
+	return d, nil
+}
+
+// Schema returns the JSON text of the writer's schema
+func (d *Decoder) Schema() string {
+	return d.text
+}
+
+// Decode returns the next record, or io.EOF after the last one
+func (d *Decoder) Decode() (interface{}, error) {
+	for d.count == 0 {
+		if err := d.nextBlock(); err != nil {
+			return nil, err
+		}
+	}
+	v, err := decodeValue(d.block, d.schema)
+	if err != nil {
+		return nil, fmt.Errorf("avro: %w", err)
+	}
+	d.count--
+	return v, nil
+}
+
+func (d *Decoder) nextBlock() error {
+	count, err := d.r.readLong()
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("avro: invalid block: %w", err)
+	}
+	size, err := d.r.readLong()
+	if err != nil || count < 0 || size < 0 {
+		return fmt.Errorf("avro: invalid block")
+	}
+	data, err := d.r.readN(size)
+	if err != nil {
+		return fmt.Errorf("avro: invalid block: %w", err)
+	}
+	var sync [16]byte
+	if _, err := io.ReadFull(d.r, sync[:]); err != nil || sync != d.sync {
+		return errors.New("avro: invalid block: sync marker mismatch")
+	}
+	if d.codec == "deflate" {
+		if data, err = io.ReadAll(flate.NewReader(bytes.NewReader(data))); err != nil {
+			return fmt.Errorf("avro: invalid block: %w", err)
+		}
+	}
+	d.block = bytes.NewReader(data)
+	d.count = count
+	return nil
+}
+
+// EncoderOptions configure an Encoder
+type EncoderOptions struct {
+	// Schema is the JSON text of the schema to write records with. If empty,
+	// the schema is inferred from the records of the first block, and later
+	// records must fit it.
+	Schema string
+	// Codec is "null" (the default) or "deflate"
+	Codec string
+	// BlockRecords is the number of records per block, DefaultBlockRecords if zero
+	BlockRecords int
+}
+
+// Encoder writes records to an Avro object container file. Close must be
+// called after the last record to write the last block.
+type Encoder struct {
+	w       io.Writer
+	opts    EncoderOptions
+	schema  *schema
+	pending []interface{} // Records waiting for an inferred schema
+	block   []byte        // Records encoded since the last block was written
+	count   int           // Records in block
+	sync    [16]byte
+	header  bool // Whether the header has been written
+	err     error
+}
+
+// NewEncoder returns an Encoder writing to w
+func NewEncoder(w io.Writer, opts EncoderOptions) (*Encoder, error) {
+	if opts.Codec == "" {
+		opts.Codec = "null"
+	}
+	if opts.Codec != "null" && opts.Codec != "deflate" {
+		return nil, fmt.Errorf("avro: unsupported codec %q", opts.Codec)
+	}
+	if opts.BlockRecords <= 0 {
+		opts.BlockRecords = DefaultBlockRecords
+	}
+	e := &Encoder{w: w, opts: opts}
+	if opts.Schema != "" {
+		s, err := parseSchema(opts.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("avro: %w", err)
+		}
+		e.schema = s
+	}
+	if _, err := rand.Read(e.sync[:]); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Encode writes v as a record
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.schema == nil {
+		e.pending = append(e.pending, v)
+		if len(e.pending) < e.opts.BlockRecords {
+			return nil
+		}
+		e.err = e.inferSchema()
+		return e.err
+	}
+	if !e.header {
+		if e.err = e.writeHeader(); e.err != nil {
+			return e.err
+		}
+	}
+	e.err = e.encodeRecord(v)
+	return e.err
+}
+
+// Close writes the records not written yet; it does not close the writer
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.schema == nil {
+		e.err = e.inferSchema()
+	} else if !e.header {
+		e.err = e.writeHeader()
+	}
+	if e.err == nil {
+		e.err = e.writeBlock()
+	}
+	if e.err != nil {
+		return e.err
+	}
+	e.err = errors.New("avro: encoder closed")
+	return nil
+}
+
+// inferSchema infers the schema from the pending records and writes them
+func (e *Encoder) inferSchema() error {
+	e.schema = inferSchema(e.pending, "Record")
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	for _, v := range e.pending {
+		if err := e.encodeRecord(v); err != nil {
+			return err
+		}
+	}
+	e.pending = nil
+	return nil
+}
@@ -0,0 +1,232 @@
+package avro_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/apstndb/go-jq-yamlformat/avro"
+	"github.com/google/go-cmp/cmp"
+)
+
+func decodeAll(t *testing.T, data []byte) []interface{} {
+	t.Helper()
+	dec, err := avro.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []interface{}
+	for {
+		v, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, v)
+	}
+}
+
+func TestDecodeSpecExample(t *testing.T) {
+	// The record {"a": 27, "b": "foo"} encodes to 36 06 66 6f 6f, as in the specification
+	schema := `{"type": "record", "name": "test", "fields": [{"name": "a", "type": "long"}, {"name": "b", "type": "string"}]}`
+	sync := bytes.Repeat([]byte{0xab}, 16)
+	var file []byte
+	file = append(file, "Obj\x01"...)
+	file = append(file, 0x02, byte(len("avro.schema")*2))
+	file = append(file, "avro.schema"...)
+	file = append(file, byte(len(schema)*2%128|0x80), byte(len(schema)*2/128))
+	file = append(file, schema...)
+	file = append(file, 0x00)
+	file = append(file, sync...)
+	file = append(file, 0x02, 0x0a, 0x36, 0x06, 0x66, 0x6f, 0x6f)
+	file = append(file, sync...)
+
+	want := []interface{}{map[string]interface{}{"a": 27, "b": "foo"}}
+	if diff := cmp.Diff(want, decodeAll(t, file)); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+}
+
+const eventSchema = `{
+  "type": "record", "name": "Event", "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "int"},
+    {"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["CREATE", "DELETE"]}},
+    {"name": "score", "type": "float"},
+    {"name": "note", "type": ["null", "string"], "default": null},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "labels", "type": {"type": "map", "values": "long"}},
+    {"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}},
+    {"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "parent", "type": ["null", "Event"], "default": null}
+  ]
+}`
+
+func TestSchemaRoundTrip(t *testing.T) {
+	records := []interface{}{
+		map[string]interface{}{
+			"id": 1, "kind": "CREATE", "score": 0.5, "note": "first", "tags": []interface{}{"a", "b"},
+			"labels": map[string]interface{}{"x": 1}, "hash": "ab", "at": 1700000000000,
+			"parent": nil,
+		},
+		map[string]interface{}{
+			"id": 2, "kind": "DELETE", "score": 2.0, "tags": []interface{}{}, "labels": map[string]interface{}{},
+			"hash": "cd", "at": 1700000000001,
+			"parent": map[string]interface{}{
+				"id": 1, "kind": "CREATE", "score": 1.0, "tags": []interface{}{}, "labels": map[string]interface{}{},
+				"hash": "ab", "at": 0,
+			},
+		},
+		map[string]interface{}{
+			"id": 3, "kind": "CREATE", "score": -1.0, "note": nil, "tags": []interface{}{"c"},
+			"labels": map[string]interface{}{}, "hash": "ef", "at": 5,
+		},
+	}
+	for _, codec := range []string{"null", "deflate"} {
+		t.Run(codec, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := avro.NewEncoder(&buf, avro.EncoderOptions{Schema: eventSchema, Codec: codec, BlockRecords: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range records {
+				if err := enc.Encode(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := decodeAll(t, buf.Bytes())
+			if len(got) != 3 {
+				t.Fatalf("got %d records", len(got))
+			}
+			second := got[1].(map[string]interface{})
+			if second["note"] != nil || string(second["hash"].([]byte)) != "cd" || second["kind"] != "DELETE" {
+				t.Errorf("got %v", second)
+			}
+			if parent := second["parent"].(map[string]interface{}); parent["id"] != 1 || parent["score"] != 1.0 {
+				t.Errorf("got parent %v", parent)
+			}
+			if first := got[0].(map[string]interface{}); first["at"] != 1700000000000 || first["score"] != 0.5 ||
+				!cmp.Equal(first["labels"], map[string]interface{}{"x": 1}) {
+				t.Errorf("got %v", first)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	ctx := context.Background()
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{
+		map[string]interface{}{"name": "a", "size": 1, "meta": map[string]interface{}{"ok": true}},
+		map[string]interface{}{"name": "b", "size": 2.5, "tags": []interface{}{"x"}},
+	}
+	var file bytes.Buffer
+	if err := p.Execute(ctx, input, jqyaml.WithWriter(&file, avro.Format)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = jqyaml.Transform(ctx, &out, jqyaml.FormatJSON, &file, avro.Format,
+		jqyaml.WithQuery("[.name, .size, .meta.ok, .tags]"),
+		jqyaml.WithDefaultExecuteOptions(jqyaml.WithCompactJSONOutput()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[\"a\",1,true,null]\n[\"b\",2.5,null,[\"x\"]]\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestInferredSchema(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := avro.NewEncoder(&buf, avro.EncoderOptions{BlockRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []interface{}{
+		map[string]interface{}{"n": 1, "labels": map[string]interface{}{"app.kubernetes.io/name": "web"}},
+		map[string]interface{}{"n": 2, "labels": map[string]interface{}{}},
+	} {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The schema is fixed by the first block
+	err = enc.Encode(map[string]interface{}{"n": "three", "labels": map[string]interface{}{}})
+	if err == nil || !strings.Contains(err.Error(), "cannot encode string at .n as long") {
+		t.Errorf("got %v", err)
+	}
+
+	dec, err := avro.NewDecoder(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"fields":[{"name":"labels","type":{"type":"map","values":"string"}},{"name":"n","type":"long"}],"name":"Record","type":"record"}`
+	if dec.Schema() != want {
+		t.Errorf("got schema %s", dec.Schema())
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		record interface{}
+		want   string
+	}{
+		{"missing field", map[string]interface{}{"id": 1}, "missing field .kind"},
+		{"unknown symbol", map[string]interface{}{"id": 1, "kind": "UPDATE"}, "cannot encode string at .kind as enum com.example.Kind"},
+		{"int range", map[string]interface{}{"id": 1 << 40}, "cannot encode number at .id as int"},
+		{"not a record", []interface{}{}, "cannot encode array at . as record com.example.Event"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := avro.NewEncoder(io.Discard, avro.EncoderOptions{Schema: eventSchema})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.Encode(tt.record); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := avro.NewDecoder(strings.NewReader(`{"not": "avro"}`)); err == nil {
+		t.Error("expected an error for a file without the magic bytes")
+	}
+
+	var buf bytes.Buffer
+	enc, err := avro.NewEncoder(&buf, avro.EncoderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(map[string]interface{}{"s": "text"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	truncated := buf.Bytes()[:buf.Len()-20]
+	dec, err := avro.NewDecoder(bytes.NewReader(truncated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("got %v, want an error for a truncated block", err)
+	}
+}
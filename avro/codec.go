@@ -0,0 +1,558 @@
+package avro
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// maxBlockBytes bounds the memory a corrupt block size can make the decoder allocate
+const maxBlockBytes = 1 << 30
+
+// blockReader reads the framing of a container file
+type blockReader struct {
+	*bufio.Reader
+}
+
+func newBlockReader(r io.Reader) *blockReader {
+	return &blockReader{bufio.NewReader(r)}
+}
+
+// readLong reads a zig-zag varint, returning io.EOF only at the end of the input
+func (r *blockReader) readLong() (int64, error) {
+	return readLong(r)
+}
+
+func (r *blockReader) readN(n int64) ([]byte, error) {
+	if n > maxBlockBytes {
+		return nil, fmt.Errorf("block of %d bytes exceeds %d bytes", n, maxBlockBytes)
+	}
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
+// readMetadata reads the metadata map of the file header
+func (r *blockReader) readMetadata() (map[string][]byte, error) {
+	meta := make(map[string][]byte)
+	for {
+		count, err := r.readLong()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if count == 0 {
+			return meta, nil
+		}
+		if count < 0 {
+			count = -count
+			if _, err := r.readLong(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+		}
+		for ; count > 0; count-- {
+			key, err := r.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			value, err := r.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			meta[string(key)] = value
+		}
+	}
+}
+
+func (r *blockReader) readBytes() ([]byte, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if n < 0 {
+		return nil, errors.New("negative length")
+	}
+	return r.readN(n)
+}
+
+func readLong(r io.ByteReader) (int64, error) {
+	u, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decodeValue decodes a value of schema s from a block
+func decodeValue(r *bytes.Reader, s *schema) (interface{}, error) {
+	switch s.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return b != 0, nil
+	case "int", "long":
+		n, err := readLong(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return int(n), nil
+	case "float":
+		var b [4]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b[:]))), nil
+	case "double":
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+	case "bytes", "string":
+		n, err := readLong(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		b, err := readBlockBytes(r, n)
+		if err != nil {
+			return nil, err
+		}
+		if s.typ == "string" {
+			return string(b), nil
+		}
+		return b, nil
+	case "fixed":
+		return readBlockBytes(r, int64(s.size))
+	case "enum":
+		i, err := readLong(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if i < 0 || i >= int64(len(s.symbols)) {
+			return nil, fmt.Errorf("enum %q has no symbol %d", s.name, i)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := readLong(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if i < 0 || i >= int64(len(s.branches)) {
+			return nil, fmt.Errorf("union has no branch %d", i)
+		}
+		return decodeValue(r, s.branches[i])
+	case "record":
+		obj := make(map[string]interface{}, len(s.fields))
+		for _, f := range s.fields {
+			v, err := decodeValue(r, f.typ)
+			if err != nil {
+				return nil, err
+			}
+			obj[f.name] = v
+		}
+		return obj, nil
+	case "array":
+		arr := []interface{}{}
+		err := readItems(r, func() error {
+			v, err := decodeValue(r, s.items)
+			arr = append(arr, v)
+			return err
+		})
+		return arr, err
+	case "map":
+		obj := map[string]interface{}{}
+		err := readItems(r, func() error {
+			n, err := readLong(r)
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			key, err := readBlockBytes(r, n)
+			if err != nil {
+				return err
+			}
+			v, err := decodeValue(r, s.items)
+			obj[string(key)] = v
+			return err
+		})
+		return obj, err
+	default:
+		return nil, fmt.Errorf("unsupported type %q", s.typ)
+	}
+}
+
+func readBlockBytes(r *bytes.Reader, n int64) ([]byte, error) {
+	if n < 0 || n > int64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+// readItems calls item for each item of the blocks of an array or map
+func readItems(r *bytes.Reader, item func() error) error {
+	for {
+		count, err := readLong(r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// The block size follows negative counts
+			count = -count
+			if _, err := readLong(r); err != nil {
+				return unexpectedEOF(err)
+			}
+		}
+		if count > maxBlockBytes {
+			return fmt.Errorf("block of %d items exceeds %d items", count, maxBlockBytes)
+		}
+		for ; count > 0; count-- {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func appendLong(b []byte, n int64) []byte {
+	return binary.AppendUvarint(b, uint64(n<<1)^uint64(n>>63))
+}
+
+func appendBytes(b, data []byte) []byte {
+	return append(appendLong(b, int64(len(data))), data...)
+}
+
+func (e *Encoder) writeHeader() error {
+	text := e.opts.Schema
+	if text == "" {
+		b, err := json.Marshal(e.schema.json())
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	}
+	b := append([]byte{}, magic...)
+	b = appendLong(b, 2)
+	b = appendBytes(b, []byte("avro.schema"))
+	b = appendBytes(b, []byte(text))
+	b = appendBytes(b, []byte("avro.codec"))
+	b = appendBytes(b, []byte(e.opts.Codec))
+	b = appendLong(b, 0)
+	b = append(b, e.sync[:]...)
+	e.header = true
+	_, err := e.w.Write(b)
+	return err
+}
+
+// encodeRecord adds v to the current block, writing the block once full
+func (e *Encoder) encodeRecord(v interface{}) error {
+	b, err := appendValue(e.block, e.schema, v, ".")
+	if err != nil {
+		return fmt.Errorf("avro: %w", err)
+	}
+	e.block = b
+	e.count++
+	if e.count >= e.opts.BlockRecords {
+		return e.writeBlock()
+	}
+	return nil
+}
+
+func (e *Encoder) writeBlock() error {
+	if e.count == 0 {
+		return nil
+	}
+	data := e.block
+	if e.opts.Codec == "deflate" {
+		var buf bytes.Buffer
+		zw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	b := appendLong(nil, int64(e.count))
+	b = appendBytes(b, data)
+	b = append(b, e.sync[:]...)
+	e.block = e.block[:0]
+	e.count = 0
+	_, err := e.w.Write(b)
+	return err
+}
+
+// appendValue appends the encoding of v with schema s to b; path locates v in
+// the record for errors
+func appendValue(b []byte, s *schema, v interface{}, path string) ([]byte, error) {
+	mismatch := func() ([]byte, error) {
+		return nil, fmt.Errorf("cannot encode %s at %s as %s", typeName(v), path, s.describe())
+	}
+	switch s.typ {
+	case "null":
+		if v != nil {
+			return mismatch()
+		}
+		return b, nil
+	case "boolean":
+		t, ok := v.(bool)
+		if !ok {
+			return mismatch()
+		}
+		if t {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case "int", "long":
+		n, ok := integer(v)
+		if !ok || s.typ == "int" && (n < math.MinInt32 || n > math.MaxInt32) {
+			return mismatch()
+		}
+		return appendLong(b, n), nil
+	case "float", "double":
+		f, ok := float(v)
+		if !ok {
+			return mismatch()
+		}
+		if s.typ == "float" {
+			return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case "bytes", "string", "fixed":
+		var data []byte
+		switch v := v.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			return mismatch()
+		}
+		if s.typ == "fixed" {
+			if len(data) != s.size {
+				return mismatch()
+			}
+			return append(b, data...), nil
+		}
+		return appendBytes(b, data), nil
+	case "enum":
+		sym, _ := v.(string)
+		for i, symbol := range s.symbols {
+			if sym == symbol {
+				return appendLong(b, int64(i)), nil
+			}
+		}
+		return mismatch()
+	case "union":
+		for i, branch := range s.branches {
+			if !branch.accepts(v) {
+				continue
+			}
+			return appendValue(appendLong(b, int64(i)), branch, v, path)
+		}
+		return mismatch()
+	case "record":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for _, f := range s.fields {
+			fv, ok := obj[f.name]
+			if !ok {
+				if !f.hasDefault {
+					return nil, fmt.Errorf("missing field %s%s", fieldPath(path), f.name)
+				}
+				fv = f.def
+			}
+			var err error
+			if b, err = appendValue(b, f.typ, fv, fieldPath(path)+f.name); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		if len(arr) > 0 {
+			b = appendLong(b, int64(len(arr)))
+			for i, item := range arr {
+				var err error
+				if b, err = appendValue(b, s.items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(b, 0), nil
+	case "map":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			b = appendLong(b, int64(len(keys)))
+			for _, k := range keys {
+				b = appendBytes(b, []byte(k))
+				var err error
+				if b, err = appendValue(b, s.items, obj[k], fieldPath(path)+k); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(b, 0), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %q", s.typ)
+	}
+}
+
+func fieldPath(path string) string {
+	if path == "." {
+		return path
+	}
+	return path + "."
+}
+
+// accepts reports whether v can be encoded as a value of s, choosing a union branch
+func (s *schema) accepts(v interface{}) bool {
+	switch s.typ {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "int", "long":
+		_, ok := integer(v)
+		return ok
+	case "float", "double":
+		_, ok := float(v)
+		return ok
+	case "bytes", "string":
+		switch v.(type) {
+		case string, []byte:
+			return true
+		}
+		return false
+	case "fixed":
+		switch v := v.(type) {
+		case string:
+			return len(v) == s.size
+		case []byte:
+			return len(v) == s.size
+		}
+		return false
+	case "enum":
+		sym, ok := v.(string)
+		if ok {
+			for _, symbol := range s.symbols {
+				if sym == symbol {
+					return true
+				}
+			}
+		}
+		return false
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "map", "record":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func (s *schema) describe() string {
+	if s.name != "" {
+		return s.typ + " " + s.name
+	}
+	return s.typ
+}
+
+// integer returns v as an int64 if it is an integral number in range
+func integer(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), true
+		}
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64(), true
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func float(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int64, float64, *big.Int, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []byte:
+		return "bytes"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
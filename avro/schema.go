@@ -0,0 +1,295 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// schema is a parsed Avro schema
+type schema struct {
+	typ      string // A primitive type, or record, enum, array, map, fixed or union
+	name     string // Full name of named types
+	fields   []field
+	symbols  []string  // Enum symbols
+	items    *schema   // Array items or map values
+	size     int       // Size of fixed values
+	branches []*schema // Union branches
+}
+
+type field struct {
+	name       string
+	typ        *schema
+	def        interface{} // Default value, as decoded from JSON
+	hasDefault bool
+}
+
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseSchema parses the JSON text of an Avro schema
+func parseSchema(text string) (*schema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	p := &schemaParser{names: make(map[string]*schema)}
+	s, err := p.parse(v, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return s, nil
+}
+
+type schemaParser struct {
+	names map[string]*schema // Named types defined so far, by full name
+}
+
+func (p *schemaParser) parse(v interface{}, namespace string) (*schema, error) {
+	switch v := v.(type) {
+	case string:
+		if primitives[v] {
+			return &schema{typ: v}, nil
+		}
+		if s, ok := p.names[fullName(v, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := p.names[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", v)
+	case []interface{}:
+		union := &schema{typ: "union"}
+		for _, branch := range v {
+			s, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			if s.typ == "union" {
+				return nil, fmt.Errorf("unions must not contain unions")
+			}
+			union.branches = append(union.branches, s)
+		}
+		return union, nil
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+	default:
+		return nil, fmt.Errorf("unexpected schema %v", v)
+	}
+}
+
+func (p *schemaParser) parseComplex(v map[string]interface{}, namespace string) (*schema, error) {
+	typ, ok := v["type"].(string)
+	if !ok {
+		// {"type": {...}} wraps another schema
+		return p.parse(v["type"], namespace)
+	}
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := v["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("%s without a name", typ)
+		}
+		if ns, ok := v["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		s := &schema{typ: typ, name: fullName(name, namespace)}
+		if i := strings.LastIndexByte(s.name, '.'); i >= 0 {
+			namespace = s.name[:i]
+		}
+		if _, dup := p.names[s.name]; dup {
+			return nil, fmt.Errorf("type %q defined twice", s.name)
+		}
+		// Registered before the fields, so that records may refer to themselves
+		p.names[s.name] = s
+		switch typ {
+		case "record", "error":
+			s.typ = "record"
+			fields, _ := v["fields"].([]interface{})
+			for _, f := range fields {
+				f, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid field of record %q", s.name)
+				}
+				name, _ := f["name"].(string)
+				ft, err := p.parse(f["type"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %w", name, err)
+				}
+				def, hasDefault := f["default"]
+				s.fields = append(s.fields, field{name: name, typ: ft, def: def, hasDefault: hasDefault})
+			}
+		case "enum":
+			symbols, _ := v["symbols"].([]interface{})
+			for _, sym := range symbols {
+				sym, ok := sym.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid symbol of enum %q", s.name)
+				}
+				s.symbols = append(s.symbols, sym)
+			}
+		case "fixed":
+			size, ok := v["size"].(float64)
+			if !ok || size < 0 {
+				return nil, fmt.Errorf("fixed %q without a valid size", s.name)
+			}
+			s.size = int(size)
+		}
+		return s, nil
+	case "array", "map":
+		key := "items"
+		if typ == "map" {
+			key = "values"
+		}
+		items, err := p.parse(v[key], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{typ: typ, items: items}, nil
+	default:
+		// Primitives, possibly annotated with a logical type, which is decoded
+		// as its underlying type
+		return p.parse(typ, namespace)
+	}
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// json returns the schema in the JSON form stored in container files
+func (s *schema) json() interface{} {
+	switch s.typ {
+	case "record":
+		fields := make([]interface{}, len(s.fields))
+		for i, f := range s.fields {
+			m := map[string]interface{}{"name": f.name, "type": f.typ.json()}
+			if f.hasDefault {
+				m["default"] = f.def
+			}
+			fields[i] = m
+		}
+		return map[string]interface{}{"type": "record", "name": s.name, "fields": fields}
+	case "array":
+		return map[string]interface{}{"type": "array", "items": s.items.json()}
+	case "map":
+		return map[string]interface{}{"type": "map", "values": s.items.json()}
+	case "union":
+		branches := make([]interface{}, len(s.branches))
+		for i, b := range s.branches {
+			branches[i] = b.json()
+		}
+		return branches
+	default:
+		// Inferred schemas contain no enums, fixed types or repeated names
+		return s.typ
+	}
+}
+
+// inferSchema returns a schema for values, the results of a query. Objects
+// become records named after their path from name, unless some of their keys
+// are not valid Avro names, and values of several types become unions.
+// Record fields missing from some objects are nullable with a null default.
+func inferSchema(values []interface{}, name string) *schema {
+	var branches []*schema
+	var nulls, bools, ints, floats, strs, arrays, objects bool
+	var elems []interface{}
+	var objs []map[string]interface{}
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			nulls = true
+		case bool:
+			bools = true
+		case int, int64, *big.Int:
+			ints = true
+		case float64:
+			floats = true
+		case json.Number:
+			if _, err := v.Int64(); err == nil {
+				ints = true
+			} else {
+				floats = true
+			}
+		case string:
+			strs = true
+		case []interface{}:
+			arrays = true
+			elems = append(elems, v...)
+		case map[string]interface{}:
+			objects = true
+			objs = append(objs, v)
+		}
+	}
+	if nulls {
+		// Null comes first, so that it can be the default of nullable fields
+		branches = append(branches, &schema{typ: "null"})
+	}
+	if bools {
+		branches = append(branches, &schema{typ: "boolean"})
+	}
+	switch {
+	case floats:
+		branches = append(branches, &schema{typ: "double"})
+	case ints:
+		branches = append(branches, &schema{typ: "long"})
+	}
+	if strs {
+		branches = append(branches, &schema{typ: "string"})
+	}
+	if arrays {
+		branches = append(branches, &schema{typ: "array", items: inferSchema(elems, name+"_item")})
+	}
+	if objects {
+		branches = append(branches, inferObjects(objs, name))
+	}
+	switch len(branches) {
+	case 0:
+		return &schema{typ: "null"}
+	case 1:
+		return branches[0]
+	default:
+		return &schema{typ: "union", branches: branches}
+	}
+}
+
+func inferObjects(objs []map[string]interface{}, name string) *schema {
+	keys := make(map[string][]interface{})
+	for _, obj := range objs {
+		for k, v := range obj {
+			keys[k] = append(keys[k], v)
+		}
+	}
+	for k := range keys {
+		if !namePattern.MatchString(k) {
+			var values []interface{}
+			for _, vs := range keys {
+				values = append(values, vs...)
+			}
+			return &schema{typ: "map", items: inferSchema(values, name+"_value")}
+		}
+	}
+	s := &schema{typ: "record", name: name}
+	for k, values := range keys {
+		if len(values) < len(objs) {
+			values = append(values, nil)
+		}
+		f := field{name: k, typ: inferSchema(values, name+"_"+k)}
+		if f.typ.typ == "null" || f.typ.typ == "union" && f.typ.branches[0].typ == "null" {
+			f.hasDefault = true
+		}
+		s.fields = append(s.fields, f)
+	}
+	sort.Slice(s.fields, func(i, j int) bool { return s.fields[i].name < s.fields[j].name })
+	return s
+}
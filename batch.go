@@ -0,0 +1,97 @@
+package jqyaml
+
+import (
+	"sync"
+	"time"
+)
+
+// batchOutput groups results for the callback set by WithBatchCallback.
+// With a flush interval, a timer passes on batches that stay partially
+// filled for that long; the callback is never called concurrently.
+type batchOutput struct {
+	size     int
+	fn       func([]interface{}) error
+	interval time.Duration // Set by WithFlushInterval; 0 disables timed flushes
+	ack      func(index int) error
+
+	mu    sync.Mutex
+	batch []interface{}
+	timer *time.Timer
+	gen   int   // Incremented when the timer is stopped, to ignore stale timers
+	err   error // Error of a timed flush, reported by the next call
+	acked int   // Number of results acknowledged so far
+}
+
+// add appends v to the current batch and passes the batch on once it is full
+func (b *batchOutput) add(v interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.batch = append(b.batch, v)
+	if len(b.batch) >= b.size {
+		return b.flushLocked()
+	}
+	if b.interval > 0 && b.timer == nil {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.interval, func() { b.timedFlush(gen) })
+	}
+	return nil
+}
+
+// flush passes on the partially filled batch, if any
+func (b *batchOutput) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	return b.flushLocked()
+}
+
+// stop cancels a pending timed flush
+func (b *batchOutput) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopTimerLocked()
+}
+
+func (b *batchOutput) timedFlush(gen int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen || b.err != nil {
+		return
+	}
+	b.err = b.flushLocked()
+}
+
+func (b *batchOutput) stopTimerLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+		b.gen++
+	}
+}
+
+func (b *batchOutput) flushLocked() error {
+	b.stopTimerLocked()
+	if len(b.batch) == 0 {
+		return nil
+	}
+	batch := b.batch
+	// The callback may retain the slice, so each batch gets a new one
+	b.batch = make([]interface{}, 0, b.size)
+	if err := b.fn(batch); err != nil {
+		return err
+	}
+	if b.ack != nil {
+		for range batch {
+			if err := b.ack(b.acked); err != nil {
+				return err
+			}
+			b.acked++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithBatchCallback(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("range(.)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		n    int
+		size int
+		want [][]interface{}
+	}{
+		{name: "partial last batch", n: 5, size: 2, want: [][]interface{}{{0, 1}, {2, 3}, {4}}},
+		{name: "full batches", n: 4, size: 2, want: [][]interface{}{{0, 1}, {2, 3}}},
+		{name: "no results", n: 0, size: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]interface{}
+			err := p.Execute(context.Background(), tt.n, jqyaml.WithBatchCallback(tt.size, func(batch []interface{}) error {
+				got = append(got, batch)
+				return nil
+			}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("batches mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithBatchCallbackError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("range(.)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errBulk := errors.New("bulk insert failed")
+	calls := 0
+	err = p.Execute(context.Background(), 5, jqyaml.WithBatchCallback(2, func([]interface{}) error {
+		calls++
+		return errBulk
+	}))
+	if !errors.Is(err, errBulk) || calls != 1 {
+		t.Errorf("got %v after %d calls, want the callback error after 1 call", err, calls)
+	}
+
+	if err := p.Execute(context.Background(), 1, jqyaml.WithBatchCallback(0, func([]interface{}) error { return nil })); err == nil {
+		t.Error("expected error for zero batch size")
+	}
+}
+
+func TestWithFlushInterval(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last result arrives well after the flush interval
+	slowLast := func(_ context.Context, v interface{}) (interface{}, error) {
+		if v == 3 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return v, nil
+	}
+
+	var mu sync.Mutex
+	var got [][]interface{}
+	err = p.Execute(context.Background(), []int{1, 2, 3},
+		jqyaml.WithEnricher(slowLast, 1),
+		jqyaml.WithFlushInterval(10*time.Millisecond),
+		jqyaml.WithBatchCallback(10, func(batch []interface{}) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, batch)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([][]interface{}{{1, 2}, {3}}, got); diff != "" {
+		t.Errorf("batches mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithFlushIntervalError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowLast := func(_ context.Context, v interface{}) (interface{}, error) {
+		if v == 2 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return v, nil
+	}
+	errBulk := errors.New("bulk insert failed")
+	err = p.Execute(context.Background(), []int{1, 2},
+		jqyaml.WithEnricher(slowLast, 1),
+		jqyaml.WithFlushInterval(10*time.Millisecond),
+		jqyaml.WithBatchCallback(10, func([]interface{}) error { return errBulk }),
+	)
+	if !errors.Is(err, errBulk) {
+		t.Errorf("expected the timed flush error, got %v", err)
+	}
+}
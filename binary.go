@@ -0,0 +1,108 @@
+package jqyaml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// BinaryPolicy selects how WithBinaryInput converts []byte and io.Reader
+// values in the input and variables
+type BinaryPolicy int
+
+const (
+	// BinaryString converts the bytes to a string; readers are read fully
+	BinaryString BinaryPolicy = iota + 1
+	// BinaryBase64 converts the bytes to a standard base64 string; readers are read fully
+	BinaryBase64
+	// BinarySkip omits the value from its object, or replaces it with null in an array
+	BinarySkip
+)
+
+func (p BinaryPolicy) String() string {
+	switch p {
+	case BinaryString:
+		return "string"
+	case BinaryBase64:
+		return "base64"
+	case BinarySkip:
+		return "skip"
+	default:
+		return fmt.Sprintf("BinaryPolicy(%d)", int(p))
+	}
+}
+
+var (
+	bytesType  = reflect.TypeOf([]byte(nil))
+	readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// skippedValue is returned by convert for values removed by BinarySkip
+type skippedValue struct{}
+
+// convert applies the policy to v and reports whether v is binary
+func (p BinaryPolicy) convert(v interface{}) (interface{}, bool, error) {
+	var data []byte
+	switch v := v.(type) {
+	case []byte:
+		data = v
+	case io.Reader:
+		if p != BinarySkip {
+			b, err := io.ReadAll(v)
+			if err != nil {
+				return nil, true, fmt.Errorf("failed to read %T: %w", v, err)
+			}
+			data = b
+		}
+	default:
+		return nil, false, nil
+	}
+	switch p {
+	case BinaryString:
+		return string(data), true, nil
+	case BinaryBase64:
+		return base64.StdEncoding.EncodeToString(data), true, nil
+	default:
+		return skippedValue{}, true, nil
+	}
+}
+
+// containsBinary reports whether rv holds a []byte or io.Reader value
+func containsBinary(rv reflect.Value, depth int) bool {
+	if !rv.IsValid() || depth > maxResolveDepth {
+		return false
+	}
+	if rv.Type() == bytesType || (rv.Kind() != reflect.Interface && rv.Type().Implements(readerType)) {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil() && containsBinary(rv.Elem(), depth+1)
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			if (rt.Field(i).IsExported() || rt.Field(i).Anonymous) && containsBinary(rv.Field(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if containsBinary(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() <= reflect.Complex128 || rv.Type().Elem().Kind() == reflect.String {
+			// Elements of basic types cannot hold binary values
+			return false
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if containsBinary(rv.Index(i), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
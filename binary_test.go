@@ -0,0 +1,72 @@
+package jqyaml_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+type apiResponse struct {
+	Status int       `json:"status"`
+	Body   io.Reader `json:"body"`
+	Raw    []byte    `json:"raw"`
+}
+
+func TestWithBinaryInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy jqyaml.BinaryPolicy
+		want   interface{}
+	}{
+		{
+			name:   "string",
+			policy: jqyaml.BinaryString,
+			want:   map[string]interface{}{"status": 200, "body": `{"ok":true}`, "raw": "hi"},
+		},
+		{
+			name:   "base64",
+			policy: jqyaml.BinaryBase64,
+			want:   map[string]interface{}{"status": 200, "body": "eyJvayI6dHJ1ZX0=", "raw": "aGk="},
+		},
+		{
+			name:   "skip",
+			policy: jqyaml.BinarySkip,
+			want:   map[string]interface{}{"status": 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery("."), jqyaml.WithBinaryInput(tt.policy))
+			if err != nil {
+				t.Fatalf("failed to create pipeline: %v", err)
+			}
+			input := apiResponse{Status: 200, Body: strings.NewReader(`{"ok":true}`), Raw: []byte("hi")}
+			got := collect(t, p, input)
+			if diff := cmp.Diff([]interface{}{tt.want}, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithBinaryInputNested(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("[$files[], .]"), jqyaml.WithBinaryInput(jqyaml.BinarySkip))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	got := collect(t, p, []byte("x"), jqyaml.WithVariables(map[string]interface{}{
+		"files": []interface{}{"a", []byte("b")},
+	}))
+	if diff := cmp.Diff([]interface{}{[]interface{}{"a", nil, nil}}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithBinaryInputValidation(t *testing.T) {
+	if _, err := jqyaml.New(jqyaml.WithBinaryInput(0)); err == nil {
+		t.Error("expected error for invalid policy")
+	}
+}
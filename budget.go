@@ -0,0 +1,89 @@
+package jqyaml
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// cpuStepsPerSecond converts WithMaxCPU budgets to gojq instructions, at
+// about the rate of one core evaluating typical filters
+const cpuStepsPerSecond = 20_000_000
+
+// cpuBudget counts the instructions evaluated in an execution and cancels it
+// once the budget runs out. It is the context the queries run with: gojq
+// calls Done before each instruction, so the count does not depend on the
+// wall clock or the load of the host.
+type cpuBudget struct {
+	context.Context
+	limit  time.Duration
+	steps  atomic.Int64 // Instructions left
+	cancel context.CancelCauseFunc
+}
+
+func newCPUBudget(ctx context.Context, limit time.Duration, cancel context.CancelCauseFunc) *cpuBudget {
+	steps := int64(limit.Seconds() * cpuStepsPerSecond)
+	if steps < 1 {
+		steps = 1
+	}
+	b := &cpuBudget{Context: ctx, limit: limit, cancel: cancel}
+	b.steps.Store(steps)
+	return b
+}
+
+// Done charges one instruction to the budget
+func (b *cpuBudget) Done() <-chan struct{} {
+	if b.steps.Add(-1) == 0 {
+		b.cancel(&CPULimitError{Limit: b.limit})
+	}
+	return b.Context.Done()
+}
+
+// evalContext returns the context the queries of ex run with
+func (ex *execution) evalContext() context.Context {
+	if ex.budget != nil {
+		return ex.budget
+	}
+	return ex.ctx
+}
+
+// next advances iter, yielding the processor every WithYieldEvery steps
+func (ex *execution) next(iter gojq.Iter) (interface{}, bool) {
+	n := ex.cfg.yieldEvery
+	if n == 0 {
+		n = defaultYieldEvery
+	}
+	if n > 0 {
+		ex.steps++
+		if ex.steps%n == 0 {
+			runtime.Gosched()
+			if err := ex.ctx.Err(); err != nil {
+				return err, true
+			}
+		}
+	}
+	return iter.Next()
+}
+
+// contextError converts an error caused by the end of the execution context,
+// possibly wrapped by a callback or encoder, to TimeoutError or CPULimitError;
+// it returns nil for other errors
+func (ex *execution) contextError(err error) error {
+	if ex.ctx.Err() == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutError{Duration: ex.cfg.timeout}
+	}
+	if errors.Is(err, context.Canceled) {
+		var cpuErr *CPULimitError
+		if errors.As(context.Cause(ex.ctx), &cpuErr) {
+			return cpuErr
+		}
+	}
+	return nil
+}
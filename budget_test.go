@@ -0,0 +1,125 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestWithMaxCPU(t *testing.T) {
+	t.Run("runaway filter", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`last(range(1e12))`), jqyaml.WithNoTimeout())
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		err = p.Execute(context.Background(), nil,
+			jqyaml.WithMaxCPU(50*time.Millisecond),
+			jqyaml.WithCallback(func(interface{}) error { return nil }),
+		)
+		var cpuErr *jqyaml.CPULimitError
+		if !errors.As(err, &cpuErr) {
+			t.Fatalf("expected CPULimitError, got %T: %v", err, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("budget not enforced promptly: %v", elapsed)
+		}
+	})
+
+	t.Run("slow output does not count", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`range(3)`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []interface{}
+		err = p.Execute(context.Background(), nil,
+			jqyaml.WithMaxCPU(20*time.Millisecond),
+			jqyaml.WithCallback(func(v interface{}) error {
+				time.Sleep(20 * time.Millisecond)
+				got = append(got, v)
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 3 {
+			t.Errorf("expected 3 results, got %v", got)
+		}
+	})
+
+	t.Run("independent of the wall clock", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`range(1e9)`), jqyaml.WithNoTimeout())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The budget runs out at the same result however long each result takes
+		var counts []int
+		for _, delay := range []time.Duration{0, 100 * time.Microsecond, 0} {
+			count := 0
+			err := p.Execute(context.Background(), nil,
+				jqyaml.WithMaxCPU(100*time.Microsecond),
+				jqyaml.WithCallback(func(interface{}) error {
+					count++
+					time.Sleep(delay)
+					return nil
+				}),
+			)
+			var cpuErr *jqyaml.CPULimitError
+			if !errors.As(err, &cpuErr) {
+				t.Fatalf("expected CPULimitError, got %T: %v", err, err)
+			}
+			counts = append(counts, count)
+		}
+		if counts[0] == 0 || counts[0] != counts[1] || counts[0] != counts[2] {
+			t.Errorf("expected the same number of results in each run, got %v", counts)
+		}
+	})
+
+	t.Run("stage queries count", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`.[]`), jqyaml.WithNoTimeout())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Execute(context.Background(), []int{1, 2},
+			jqyaml.WithMaxCPU(50*time.Millisecond),
+			jqyaml.WithSortBy(`last(range(1e12))`, false),
+			jqyaml.WithCallback(func(interface{}) error { return nil }),
+		)
+		var cpuErr *jqyaml.CPULimitError
+		if !errors.As(err, &cpuErr) {
+			t.Fatalf("expected CPULimitError, got %T: %v", err, err)
+		}
+	})
+}
+
+func TestWithYieldEvery(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`range(1e9)`), jqyaml.WithNoTimeout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 0
+	err = p.Execute(ctx, nil,
+		jqyaml.WithYieldEvery(10),
+		jqyaml.WithCallback(func(interface{}) error {
+			count++
+			if count == 25 {
+				cancel()
+			}
+			return nil
+		}),
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	// Cancellation is observed at the next check point at the latest
+	if count > 30 {
+		t.Errorf("expected the execution to stop by the 30th result, got %d", count)
+	}
+}
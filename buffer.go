@@ -0,0 +1,71 @@
+package jqyaml
+
+// bufferedOutput passes results to the output in a separate goroutine
+type bufferedOutput struct {
+	ex     *execution
+	output func(interface{}) error
+	ch     chan interface{}
+	done   chan struct{}
+	err    error // Output error, set before done is closed
+}
+
+func newBufferedOutput(ex *execution, size int, output func(interface{}) error) *bufferedOutput {
+	return &bufferedOutput{
+		ex:     ex,
+		output: output,
+		ch:     make(chan interface{}, size),
+		done:   make(chan struct{}),
+	}
+}
+
+// start runs the output goroutine
+func (b *bufferedOutput) start() {
+	go func() {
+		defer close(b.done)
+		for v := range b.ch {
+			// Stop on cancellation, as synchronous output would
+			if err := b.ex.ctx.Err(); err != nil {
+				b.err = b.ex.canceled()
+				return
+			}
+			if err := b.output(v); err != nil {
+				b.err = err
+				return
+			}
+		}
+	}()
+}
+
+// send queues v for output, blocking while the buffer is full. It returns the
+// output error once the output goroutine has failed.
+func (b *bufferedOutput) send(v interface{}) error {
+	select {
+	case <-b.done:
+		return b.err
+	default:
+	}
+	select {
+	case b.ch <- v:
+		return nil
+	case <-b.done:
+		return b.err
+	case <-b.ex.ctx.Done():
+		return b.ex.canceled()
+	}
+}
+
+// canceled returns the error reporting the end of the execution context
+func (ex *execution) canceled() error {
+	err := ex.ctx.Err()
+	if ctxErr := ex.contextError(err); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// close waits until the queued results have been output
+func (b *bufferedOutput) close() error {
+	close(b.ch)
+	<-b.done
+	return b.err
+}
@@ -0,0 +1,80 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// slowWriter delays every write
+type slowWriter struct {
+	bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.Buffer.Write(p)
+}
+
+func TestWithPipelineBuffer(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`.[] | {id: .}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []int{1, 2, 3, 4, 5}
+
+	var want bytes.Buffer
+	if err := p.Execute(context.Background(), input,
+		jqyaml.WithWriter(&want, jqyaml.FormatJSON),
+		jqyaml.WithCompactJSONOutput(),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &slowWriter{delay: time.Millisecond}
+	result := p.ExecuteR(context.Background(), input,
+		jqyaml.WithWriter(w, jqyaml.FormatJSON),
+		jqyaml.WithCompactJSONOutput(),
+		jqyaml.WithPipelineBuffer(2),
+	)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if diff := cmp.Diff(want.String(), w.String()); diff != "" {
+		t.Errorf("output mismatch (-want +got):\n%s", diff)
+	}
+	if result.Emitted != len(input) {
+		t.Errorf("expected %d emitted results, got %d", len(input), result.Emitted)
+	}
+}
+
+func TestWithPipelineBufferOutputError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`range(1e9)`), jqyaml.WithNoTimeout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	count := 0
+	err = p.Execute(context.Background(), nil,
+		jqyaml.WithPipelineBuffer(4),
+		jqyaml.WithCallback(func(interface{}) error {
+			count++
+			if count == 10 {
+				return errStop
+			}
+			return nil
+		}),
+	)
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	if count != 10 {
+		t.Errorf("expected no output after the error, got %d calls", count)
+	}
+}
@@ -0,0 +1,162 @@
+package jqyaml
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ResultCache stores the results of executions for WithResultCache.
+// Implementations must be safe for concurrent use.
+type ResultCache interface {
+	Get(key string) (interface{}, bool)
+	Add(key string, value interface{})
+	Remove(key string)
+}
+
+// LRUResultCache is a ResultCache holding a bounded number of entries,
+// evicting the least recently used one first
+type LRUResultCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Front is the most recently used
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// NewLRUResultCache creates an LRUResultCache holding up to size entries
+func NewLRUResultCache(size int) *LRUResultCache {
+	if size < 1 {
+		size = 1
+	}
+	return &LRUResultCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUResultCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+func (c *LRUResultCache) Add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*lruEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *LRUResultCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of entries in the cache
+func (c *LRUResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// resultCache applies the TTL of WithResultCache to a ResultCache
+type resultCache struct {
+	cache ResultCache
+	ttl   time.Duration
+}
+
+// cachedResults is the value stored in the ResultCache
+type cachedResults struct {
+	results []interface{}
+	expires time.Time // Zero if the entry doesn't expire
+}
+
+// cacheable reports whether the results of this execution may be cached.
+// Custom functions may depend on state other than the input and variables,
+// and the metadata variables differ between executions.
+func (ex *execution) cacheable() bool {
+	p := ex.pipeline
+	return p.resultCache != nil && p.query != "" && !p.metadata && p.http == nil &&
+		len(p.compilerOptions) == 0 && len(ex.compilerOptions) == 0
+}
+
+// cacheKey hashes the query, the converted input and the variables
+func (ex *execution) cacheKey(data interface{}) (string, error) {
+	b, err := json.Marshal([]interface{}{ex.pipeline.query, data, ex.variables})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cachedProcess serves data from the result cache, or runs the query and caches its results
+func (ex *execution) cachedProcess(data interface{}) error {
+	key, err := ex.cacheKey(data)
+	if err != nil {
+		// Values that cannot be hashed are not cached
+		return ex.streamingProcess(data)
+	}
+	c := ex.pipeline.resultCache
+	now := ex.pipeline.now()
+	if v, ok := c.cache.Get(key); ok {
+		entry := v.(*cachedResults)
+		if entry.expires.IsZero() || now.Before(entry.expires) {
+			ex.cacheHits++
+			for _, r := range entry.results {
+				if err := ex.emit(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.cache.Remove(key)
+	}
+	ex.cacheMisses++
+
+	results := []interface{}{}
+	emit := ex.emit
+	ex.emit = func(v interface{}) error {
+		results = append(results, v)
+		return emit(v)
+	}
+	err = ex.streamingProcess(data)
+	ex.emit = emit
+	if err != nil {
+		return err
+	}
+
+	entry := &cachedResults{results: results}
+	if c.ttl > 0 {
+		entry.expires = now.Add(c.ttl)
+	}
+	c.cache.Add(key, entry)
+	return nil
+}
@@ -0,0 +1,135 @@
+package jqyaml_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithResultCache(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache := jqyaml.NewLRUResultCache(8)
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(".items[] | . * $n"),
+		jqyaml.WithResultCache(cache, time.Minute),
+		jqyaml.WithClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{"items": []interface{}{1, 2}}
+	run := func(n int, input interface{}) ([]interface{}, *jqyaml.ExecuteResult) {
+		t.Helper()
+		var got []interface{}
+		result := p.ExecuteR(context.Background(), input,
+			jqyaml.WithVariables(map[string]interface{}{"n": n}),
+			jqyaml.WithCallback(func(v interface{}) error {
+				got = append(got, v)
+				return nil
+			}))
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		return got, result
+	}
+
+	tests := []struct {
+		desc         string
+		advance      time.Duration
+		n            int
+		input        interface{}
+		want         []interface{}
+		hits, misses int
+	}{
+		{"first execution", 0, 2, input, []interface{}{2, 4}, 0, 1},
+		{"identical execution", 0, 2, input, []interface{}{2, 4}, 1, 0},
+		{"different variables", 0, 3, input, []interface{}{3, 6}, 0, 1},
+		{"different input", 0, 2, map[string]interface{}{"items": []interface{}{5}}, []interface{}{10}, 0, 1},
+		{"expired", 2 * time.Minute, 2, input, []interface{}{2, 4}, 0, 1},
+		{"cached again", 0, 2, input, []interface{}{2, 4}, 1, 0},
+	}
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		got, result := run(tt.n, tt.input)
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+		if result.CacheHits != tt.hits || result.CacheMisses != tt.misses {
+			t.Errorf("%s: expected %d hits and %d misses, got %d and %d",
+				tt.desc, tt.hits, tt.misses, result.CacheHits, result.CacheMisses)
+		}
+		if result.Emitted != len(tt.want) {
+			t.Errorf("%s: expected %d emitted, got %d", tt.desc, len(tt.want), result.Emitted)
+		}
+	}
+}
+
+func TestResultCacheSkipsFunctions(t *testing.T) {
+	cache := jqyaml.NewLRUResultCache(8)
+	p, err := jqyaml.New(jqyaml.WithQuery("counter"), jqyaml.WithResultCache(cache, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	counter := jqyaml.WithExecFunction("counter", 0, 0, func(interface{}, []interface{}) interface{} {
+		calls++
+		return calls
+	})
+	for i := 1; i <= 2; i++ {
+		got := collect(t, p, nil, counter)
+		if diff := cmp.Diff([]interface{}{i}, got); diff != "" {
+			t.Errorf("execution %d: mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected no cached entries, got %d", cache.Len())
+	}
+}
+
+func TestResultCacheSkipsErrors(t *testing.T) {
+	cache := jqyaml.NewLRUResultCache(8)
+	p, err := jqyaml.New(jqyaml.WithQuery(`1, error("boom")`), jqyaml.WithResultCache(cache, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err := p.Execute(context.Background(), nil, jqyaml.WithCallback(func(interface{}) error { return nil }))
+		if err == nil {
+			t.Fatalf("execution %d: expected error", i)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected no cached entries, got %d", cache.Len())
+	}
+}
+
+func TestLRUResultCache(t *testing.T) {
+	cache := jqyaml.NewLRUResultCache(2)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	cache.Add("c", 3) // Evicts b, the least recently used
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+	cache.Remove("a")
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", cache.Len())
+	}
+}
+
+func TestWithResultCacheNil(t *testing.T) {
+	if _, err := jqyaml.New(jqyaml.WithResultCache(nil, 0)); err == nil {
+		t.Error("expected error for nil cache")
+	}
+}
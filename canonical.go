@@ -0,0 +1,195 @@
+package jqyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml"
+)
+
+// canonicalEncoder writes each value in the JSON Canonicalization Scheme of
+// RFC 8785, enabled by WithCanonicalJSON
+type canonicalEncoder struct {
+	writer    io.Writer
+	separator *string
+}
+
+func (e *canonicalEncoder) Encode(v interface{}) error {
+	b, err := appendCanonical(nil, v)
+	if err != nil {
+		return err
+	}
+	if e.separator == nil {
+		b = append(b, '\n')
+	} else {
+		b = append(b, *e.separator...)
+	}
+	_, err = e.writer.Write(b)
+	return err
+}
+
+// appendCanonical appends the canonical JSON of v to b: object members sorted
+// by the UTF-16 code units of their names, numbers as IEEE 754 doubles in the
+// shortest form of ECMAScript, and strings with only the required escapes
+func appendCanonical(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case string:
+		return appendCanonicalString(b, v)
+	case int:
+		return appendCanonicalNumber(b, float64(v))
+	case int64:
+		return appendCanonicalNumber(b, float64(v))
+	case float64:
+		return appendCanonicalNumber(b, v)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return appendCanonicalNumber(b, f)
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("canonical JSON: invalid number %s", v)
+		}
+		return appendCanonicalNumber(b, f)
+	case []interface{}:
+		b = append(b, '[')
+		for i, e := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = appendCanonical(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case map[string]interface{}:
+		return appendCanonicalObject(b, v)
+	case yaml.MapSlice:
+		// Ordered values are objects whose later duplicate keys win
+		m := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			m[fmt.Sprint(item.Key)] = item.Value
+		}
+		return appendCanonicalObject(b, m)
+	default:
+		// Canonicalize other values from their JSON encoding
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("canonical JSON: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var decoded interface{}
+		if err := dec.Decode(&decoded); err != nil {
+			return nil, fmt.Errorf("canonical JSON: %w", err)
+		}
+		return appendCanonical(b, decoded)
+	}
+}
+
+func appendCanonicalObject(b []byte, m map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+	b = append(b, '{')
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		var err error
+		if b, err = appendCanonicalString(b, k); err != nil {
+			return nil, err
+		}
+		b = append(b, ':')
+		if b, err = appendCanonical(b, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, which differs from
+// byte order for characters outside the Basic Multilingual Plane
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// appendCanonicalNumber formats f as ECMAScript's Number.prototype.toString
+// does, which is also how encoding/json formats float64 values
+func appendCanonicalNumber(b []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("canonical JSON: unsupported number %v", f)
+	}
+	if f == 0 {
+		// Including negative zero
+		return append(b, '0'), nil
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	start := len(b)
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Write e-7 rather than e-07
+		if n := len(b) - start; n >= 4 && b[len(b)-4] == 'e' && b[len(b)-3] == '-' && b[len(b)-2] == '0' {
+			b[len(b)-2] = b[len(b)-1]
+			b = b[:len(b)-1]
+		}
+	}
+	return b, nil
+}
+
+// appendCanonicalString escapes only quotes, backslashes and control
+// characters, using the short escapes where JSON has them
+func appendCanonicalString(b []byte, s string) ([]byte, error) {
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("canonical JSON: invalid UTF-8 in string %q", s)
+	}
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"', '\\':
+			b = append(b, '\\', c)
+		case '\b':
+			b = append(b, '\\', 'b')
+		case '\f':
+			b = append(b, '\\', 'f')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case '\t':
+			b = append(b, '\\', 't')
+		default:
+			if c < 0x20 {
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				b = append(b, c)
+			}
+		}
+	}
+	return append(b, '"'), nil
+}
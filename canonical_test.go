@@ -0,0 +1,88 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		input string
+		want  string
+	}{
+		{
+			// The example of RFC 8785 section 3.2.2
+			name:  "rfc example",
+			query: "",
+			input: `{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+				"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+				"literals": [null, true, false]}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}` + "\n",
+		},
+		{
+			// Members are sorted by UTF-16 code units, as in RFC 8785 section 3.2.3
+			name:  "key order",
+			query: ".",
+			input: `{"\u20ac": 1, "\r": 2, "\ufb33": 3, "1": 4, "\ud83d\ude00": 5, "\u0080": 6, "\u00f6": 7}`,
+			want:  "{\"\\r\":2,\"1\":4,\"\u0080\":6,\"\u00f6\":7,\"\u20ac\":1,\"\U0001f600\":5,\"\ufb33\":3}\n",
+		},
+		{
+			name:  "html is not escaped",
+			query: `{b: "<a&b>", a: "\u2028"}`,
+			input: `null`,
+			want:  "{\"a\":\"\u2028\",\"b\":\"<a&b>\"}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			err = p.ExecuteReader(context.Background(), strings.NewReader(tt.input), jqyaml.FormatJSON,
+				jqyaml.WithWriter(&buf, jqyaml.FormatJSON), jqyaml.WithCanonicalJSON())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("numbers", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("0, -0, 1e21, 1e-7, 0.000001, 100, 9007199254740993, 123456789012345678901234567890"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := p.Execute(context.Background(), nil, jqyaml.WithWriter(&buf, jqyaml.FormatJSON), jqyaml.WithCanonicalJSON()); err != nil {
+			t.Fatal(err)
+		}
+		want := "0\n0\n1e+21\n1e-7\n0.000001\n100\n9007199254740992\n1.2345678901234568e+29\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("requires JSON output", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("."))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := p.Execute(context.Background(), 1, jqyaml.WithWriter(&buf, jqyaml.FormatYAML), jqyaml.WithCanonicalJSON()); err == nil {
+			t.Error("expected an error for YAML output")
+		}
+		if err := p.Execute(context.Background(), 1, jqyaml.WithWriter(&buf, jqyaml.FormatJSON), jqyaml.WithRawJSONOutput(), jqyaml.WithCanonicalJSON()); err == nil {
+			t.Error("expected an error for raw output")
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package jqyaml
+
+import (
+	"context"
+	"sync"
+)
+
+// channelOutput sends results to the channel of WithChannelOutput
+type channelOutput struct {
+	ch   chan<- interface{}
+	ctx  context.Context // Set by run to the execution context
+	once sync.Once
+}
+
+// closeChannel closes the channel of o, if any, once. Entry points defer it
+// right after building their config, so that receivers ranging over the
+// channel stop however the execution ends, also when it fails before running.
+func closeChannel(o *channelOutput) {
+	if o != nil {
+		o.once.Do(func() { close(o.ch) })
+	}
+}
+
+// send blocks until the receiver takes v or the execution ends
+func (o *channelOutput) send(v interface{}) error {
+	select {
+	case o.ch <- v:
+		return nil
+	case <-o.ctx.Done():
+		return o.ctx.Err()
+	}
+}
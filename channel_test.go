@@ -0,0 +1,152 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithChannelOutput(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("range(.)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{})
+	var got []interface{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := range ch {
+			got = append(got, v)
+		}
+	}()
+	if err := p.Execute(context.Background(), 3, jqyaml.WithChannelOutput(ch)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wg.Wait()
+	if diff := cmp.Diff([]interface{}{0, 1, 2}, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithChannelOutputClosesOnError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`1, error("boom")`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan interface{}, 10)
+	err = p.Execute(context.Background(), nil, jqyaml.WithChannelOutput(ch))
+	var queryErr *jqyaml.QueryError
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("expected QueryError, got %v", err)
+	}
+	var got []interface{}
+	for v := range ch {
+		got = append(got, v)
+	}
+	if diff := cmp.Diff([]interface{}{1}, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithChannelOutputClosesBeforeRunning(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entryPoints := []struct {
+		name string
+		run  func(input interface{}, opts ...jqyaml.ExecuteOption) error
+	}{
+		{name: "Execute", run: func(input interface{}, opts ...jqyaml.ExecuteOption) error {
+			return p.Execute(context.Background(), input, opts...)
+		}},
+		{name: "ExecuteR", run: func(input interface{}, opts ...jqyaml.ExecuteOption) error {
+			return p.ExecuteR(context.Background(), input, opts...).Err
+		}},
+		{name: "ExecuteAsync", run: func(input interface{}, opts ...jqyaml.ExecuteOption) error {
+			h, err := p.ExecuteAsync(context.Background(), input, opts...)
+			if err != nil {
+				return err
+			}
+			return h.Wait().Err
+		}},
+		{name: "ExecutePage", run: func(input interface{}, opts ...jqyaml.ExecuteOption) error {
+			_, err := p.ExecutePage(context.Background(), input, 0, 10, opts...)
+			return err
+		}},
+	}
+	failures := []struct {
+		name  string
+		input interface{}
+		opt   jqyaml.ExecuteOption
+	}{
+		{name: "invalid option", opt: jqyaml.WithBatchCallback(0, nil)},
+		{name: "invalid input", input: 1, opt: jqyaml.WithNullInput()},
+	}
+	for _, ep := range entryPoints {
+		for _, f := range failures {
+			t.Run(ep.name+"/"+f.name, func(t *testing.T) {
+				ch := make(chan interface{})
+				if err := ep.run(f.input, jqyaml.WithChannelOutput(ch), f.opt); err == nil {
+					t.Fatal("expected an error")
+				}
+				select {
+				case _, ok := <-ch:
+					if ok {
+						t.Error("unexpected result")
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("channel not closed")
+				}
+			})
+		}
+	}
+}
+
+func TestWithChannelOutputCancellation(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("range(.)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan interface{})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Execute(ctx, 100, jqyaml.WithChannelOutput(ch))
+	}()
+
+	if v := <-ch; v != 0 {
+		t.Errorf("got %v, want 0", v)
+	}
+	// Nobody receives the next result; cancellation must unblock the send
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Execute did not return after cancellation")
+	}
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed")
+	}
+}
+
+func TestWithChannelOutputNil(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Execute(context.Background(), 1, jqyaml.WithChannelOutput(nil)); err == nil {
+		t.Error("expected an error for a nil channel")
+	}
+}
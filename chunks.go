@@ -0,0 +1,48 @@
+package jqyaml
+
+import (
+	"reflect"
+
+	"github.com/itchyny/gojq"
+)
+
+// isIterRooted reports whether q has the shape `.[] | ...`, so that running it
+// on an array produces the same results as running it on consecutive chunks
+// of that array
+func isIterRooted(q *gojq.Query) bool {
+	for q.Op == gojq.OpPipe {
+		q = q.Left
+	}
+	t := q.Term
+	if q.Op != 0 || t == nil || t.Type != gojq.TermTypeIdentity || len(t.SuffixList) == 0 || !t.SuffixList[0].Iter {
+		return false
+	}
+	for _, s := range t.SuffixList {
+		// In `.[] as $x | f`, f runs on the whole input rather than on each element
+		if s.Bind != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// processChunks runs the query on consecutive chunks of size elements of the
+// slice input, so that only one chunk is converted at a time. It reports false
+// if input is not a slice or the query is not `.[]`-shaped, and for nil
+// slices, which convert to null rather than an empty array.
+func (ex *execution) processChunks(input interface{}, size int) (bool, error) {
+	rv := reflect.ValueOf(input)
+	if !ex.pipeline.iterRooted || rv.Kind() != reflect.Slice || rv.IsNil() || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return false, nil
+	}
+	for i := 0; i < rv.Len(); i += size {
+		end := i + size
+		if end > rv.Len() {
+			end = rv.Len()
+		}
+		if err := ex.processRecord(rv.Slice(i, end).Interface()); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,113 @@
+package jqyaml_test
+
+import (
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// countingMarshaler records the number of elements of each slice it converts
+type countingMarshaler struct {
+	sizes []int
+}
+
+func (m *countingMarshaler) Marshal(v interface{}) (interface{}, error) {
+	items, ok := v.([]int)
+	if !ok {
+		return v, nil
+	}
+	m.sizes = append(m.sizes, len(items))
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	return result, nil
+}
+
+func TestWithInputChunks(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6, 7}
+
+	tests := []struct {
+		name      string
+		query     string
+		want      []interface{}
+		wantSizes []int
+	}{
+		{name: "iterate", query: ".[]", want: []interface{}{1, 2, 3, 4, 5, 6, 7}, wantSizes: []int{3, 3, 1}},
+		{name: "iterate and pipe", query: ".[] | select(. % 2 == 0) | . * 10", want: []interface{}{20, 40, 60}, wantSizes: []int{3, 3, 1}},
+		{name: "whole input", query: "length", want: []interface{}{7}, wantSizes: []int{7}},
+		{name: "binding sees whole input", query: ".[] as $x | length", want: []interface{}{7, 7, 7, 7, 7, 7, 7}, wantSizes: []int{7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &countingMarshaler{}
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query), jqyaml.WithInputMarshaler(m))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(t, p, input, jqyaml.WithInputChunks(3))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantSizes, m.sizes); diff != "" {
+				t.Errorf("converted chunks mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Chunked and unchunked executions agree, including on errors
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, string) {
+		var got []interface{}
+		err := p.Execute(context.Background(), input, append(opts, jqyaml.WithCallback(func(v interface{}) error {
+			got = append(got, v)
+			return nil
+		}))...)
+		return got, errorText(err)
+	}
+	for name, input := range map[string][]int{"values": {1, 2, 3, 4}, "empty": {}, "nil": nil} {
+		t.Run(name, func(t *testing.T) {
+			want, wantErr := run(input)
+			got, gotErr := run(input, jqyaml.WithInputChunks(3))
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("results mismatch (-unchunked +chunked):\n%s", diff)
+			}
+			if gotErr != wantErr {
+				t.Errorf("got error %q, want %q", gotErr, wantErr)
+			}
+		})
+	}
+}
+
+func TestWithInputChunksEntryPoints(t *testing.T) {
+	for _, ep := range entryPoints {
+		t.Run(ep.name, func(t *testing.T) {
+			m := &countingMarshaler{}
+			p, err := jqyaml.New(jqyaml.WithQuery(".[] | select(. == 2)"), jqyaml.WithInputMarshaler(m))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ep.run(p, []int{1, 2, 3}, jqyaml.WithInputChunks(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, wantSizes := []interface{}{2}, []int{1, 1, 1}
+			if ep.paths {
+				// Paths index into the whole input
+				want, wantSizes = []interface{}{[]interface{}{1}}, []int{3}
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(wantSizes, m.sizes); diff != "" {
+				t.Errorf("chunk sizes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
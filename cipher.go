@@ -0,0 +1,42 @@
+package jqyaml
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// EncryptFunc encrypts the encoded bytes of one result for WithRecordCipher,
+// e.g. with a data key wrapped by a KMS, and returns the bytes written in
+// their place. The returned bytes must delimit the record for readers, such
+// as a line of base64. record is cleared after the call.
+type EncryptFunc func(ctx context.Context, record []byte) ([]byte, error)
+
+// recordWriter collects what the encoder writes for one result, so that it
+// is encrypted and written as a record
+type recordWriter struct {
+	w       io.Writer
+	encrypt EncryptFunc
+	buf     bytes.Buffer
+}
+
+func (r *recordWriter) Write(b []byte) (int, error) {
+	return r.buf.Write(b)
+}
+
+// flush encrypts and writes the collected record, if any
+func (r *recordWriter) flush(ctx context.Context) error {
+	if r.buf.Len() == 0 {
+		return nil
+	}
+	ciphertext, err := r.encrypt(ctx, r.buf.Bytes())
+	// Don't leave the plaintext in the reused buffer
+	clear(r.buf.Bytes())
+	r.buf.Reset()
+	if err != nil {
+		return fmt.Errorf("failed to encrypt record: %w", err)
+	}
+	_, err = r.w.Write(ciphertext)
+	return err
+}
@@ -0,0 +1,89 @@
+package jqyaml_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestRecordCipher(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each record is a line of base64 of the nonce and the sealed record
+	encrypt := func(_ context.Context, record []byte) ([]byte, error) {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		sealed := aead.Seal(nonce, nonce, record, nil)
+		return []byte(base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{map[string]interface{}{"secret": "a"}, map[string]interface{}{"secret": "b"}}
+
+	t.Run("records", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, jqyaml.FormatYAML), jqyaml.WithRecordCipher(encrypt)); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "secret") {
+			t.Fatalf("output contains plaintext: %q", buf.String())
+		}
+		var records []string
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			sealed, err := base64.StdEncoding.DecodeString(scanner.Text())
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := aead.NonceSize()
+			record, err := aead.Open(nil, sealed[:n], sealed[n:], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, string(record))
+		}
+		want := []string{"secret: a\n", "secret: b\n"}
+		if strings.Join(records, "|") != strings.Join(want, "|") {
+			t.Errorf("got records %q, want %q", records, want)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var buf bytes.Buffer
+		failing := func(context.Context, []byte) ([]byte, error) { return nil, errors.New("key unavailable") }
+		err := p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, jqyaml.FormatJSON), jqyaml.WithRecordCipher(failing))
+		if err == nil || !strings.Contains(err.Error(), "failed to encrypt record: key unavailable") {
+			t.Errorf("got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("got output %q", buf.String())
+		}
+		if err := p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, jqyaml.FormatJSON), jqyaml.WithRecordCipher(nil)); err == nil {
+			t.Error("expected an error for a nil cipher")
+		}
+		callback := jqyaml.WithCallback(func(interface{}) error { return nil })
+		if err := p.Execute(context.Background(), input, callback, jqyaml.WithRecordCipher(encrypt)); err == nil {
+			t.Error("expected an error without a writer")
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package jqyaml
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/itchyny/gojq"
+)
+
+// codeCache holds a bounded number of compiled queries keyed by string,
+// evicting the least recently used one first. It is safe for concurrent use.
+type codeCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Front is the most recently used
+	entries map[string]*list.Element
+}
+
+type codeEntry struct {
+	key  string
+	code *gojq.Code
+}
+
+func newCodeCache(size int) *codeCache {
+	if size < 1 {
+		size = 1
+	}
+	return &codeCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *codeCache) get(key string) (*gojq.Code, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*codeEntry).code, true
+}
+
+func (c *codeCache) add(key string, code *gojq.Code) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*codeEntry).code = code
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&codeEntry{key: key, code: code})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*codeEntry).key)
+	}
+}
+
+func (c *codeCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,98 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCollectErrors(t *testing.T) {
+	// Documents 2 and 4 fail the query, document 5 has a duplicate key
+	input := `{"a": 1} {"a": "x"} {"a": 2} {"a": "y"} {"a": 3, "a": 4} {"a": 5}`
+
+	tests := []struct {
+		name     string
+		max      int
+		want     []interface{}
+		wantDocs []int
+	}{
+		{
+			name:     "all errors",
+			max:      0,
+			want:     []interface{}{2, 3, 6},
+			wantDocs: []int{2, 4, 5},
+		},
+		{
+			name:     "stops at max",
+			max:      2,
+			want:     []interface{}{2, 3},
+			wantDocs: []int{2, 4},
+		},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery(".a + 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []interface{}
+			err := p.ExecuteReader(context.Background(), strings.NewReader(input), jqyaml.FormatJSON,
+				jqyaml.WithStrictInput(),
+				jqyaml.WithCollectErrors(tt.max),
+				jqyaml.WithCallback(func(v interface{}) error {
+					got = append(got, v)
+					return nil
+				}),
+			)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+
+			joined, ok := err.(interface{ Unwrap() []error })
+			if !ok {
+				t.Fatalf("expected joined error, got %T: %v", err, err)
+			}
+			var docs []int
+			for _, e := range joined.Unwrap() {
+				var inputErr *jqyaml.InputError
+				var decodeErr *jqyaml.DecodeError
+				switch {
+				case errors.As(e, &inputErr):
+					docs = append(docs, inputErr.Document)
+				case errors.As(e, &decodeErr):
+					docs = append(docs, decodeErr.Document)
+				default:
+					t.Errorf("unexpected error %T: %v", e, e)
+				}
+			}
+			if diff := cmp.Diff(tt.wantDocs, docs); diff != "" {
+				t.Errorf("failed documents mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCollectErrorsFatal(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".a + 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A syntax error leaves the rest of the stream unreadable
+	err = p.ExecuteReader(context.Background(), strings.NewReader(`{"a": "x"} {"a": } {"a": 1}`), jqyaml.FormatJSON,
+		jqyaml.WithCollectErrors(0),
+		jqyaml.WithCallback(func(interface{}) error { return nil }),
+	)
+	var inputErr *jqyaml.InputError
+	var decodeErr *jqyaml.DecodeError
+	if !errors.As(err, &inputErr) || !errors.As(err, &decodeErr) {
+		t.Fatalf("expected both the collected and the fatal error, got: %v", err)
+	}
+	if decodeErr.Document != 2 {
+		t.Errorf("expected decode error in document 2, got %d", decodeErr.Document)
+	}
+}
@@ -0,0 +1,61 @@
+package jqyaml
+
+import (
+	"encoding/json"
+
+	"github.com/itchyny/gojq"
+)
+
+// Compare orders a and b as jq's sort and comparison operators do, returning
+// -1, 0 or 1: null < false < true < numbers < strings < arrays < objects, with
+// arrays compared element by element and objects by their sorted keys and then
+// their values. a and b are jq values, such as those returned by ToJQValue;
+// the number types custom input marshalers may return are accepted too.
+func Compare(a, b interface{}) int {
+	return gojq.Compare(jqNumbers(a), jqNumbers(b))
+}
+
+// Equal reports whether a and b are equal under jq's == operator, e.g. 1 and
+// 1.0 are equal
+func Equal(a, b interface{}) bool {
+	return Compare(a, b) == 0
+}
+
+// jqNumbers returns v with its numbers converted to the types gojq compares,
+// copying containers instead of modifying them
+func jqNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case uint:
+		return normalizeNumbers(uint64(v))
+	case uint8:
+		return int(v)
+	case uint16:
+		return int(v)
+	case uint32:
+		return normalizeNumbers(uint64(v))
+	case float32:
+		return float64(v)
+	case int64, uint64, json.Number:
+		return normalizeNumbers(v)
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, e := range v {
+			arr[i] = jqNumbers(e)
+		}
+		return arr
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			obj[k] = jqNumbers(e)
+		}
+		return obj
+	default:
+		return v
+	}
+}
@@ -0,0 +1,78 @@
+package jqyaml_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompare(t *testing.T) {
+	large, _ := new(big.Int).SetString("12345678901234567890", 10)
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{nil, false, -1},
+		{false, true, -1},
+		{true, 0, -1},
+		{1, 1.0, 0},
+		{1, 1.5, -1},
+		{large, uint64(12345678901234567890), 0},
+		{int64(3), json.Number("2"), 1},
+		{float32(0.5), 0.5, 0},
+		{100, "1", -1},
+		{"a", "b", -1},
+		{"z", []interface{}{}, -1},
+		{[]interface{}{1, 2}, []interface{}{1, 3}, -1},
+		{[]interface{}{1}, []interface{}{1, 0}, -1},
+		{[]interface{}{}, map[string]interface{}{}, -1},
+		{map[string]interface{}{"a": 2}, map[string]interface{}{"b": 1}, -1},
+		{map[string]interface{}{"a": 2}, map[string]interface{}{"a": int64(1)}, 1},
+	}
+	for _, tt := range tests {
+		if got := jqyaml.Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := jqyaml.Compare(tt.b, tt.a); got != -tt.want {
+			t.Errorf("Compare(%v, %v) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+		if got := jqyaml.Equal(tt.a, tt.b); got != (tt.want == 0) {
+			t.Errorf("Equal(%v, %v) = %v", tt.a, tt.b, got)
+		}
+	}
+
+	t.Run("nan", func(t *testing.T) {
+		// As in jq, nan is less than any number, itself included, so it is not equal to itself
+		nan := math.NaN()
+		if jqyaml.Compare(nan, 0) != -1 || jqyaml.Compare(nan, nan) != -1 || jqyaml.Equal(nan, nan) {
+			t.Error("nan should be less than any number")
+		}
+	})
+
+	t.Run("matches sort", func(t *testing.T) {
+		values := []interface{}{"b", map[string]interface{}{"a": 1}, 2, nil, []interface{}{1}, true, 1.5, "a", false}
+		p, err := jqyaml.New(jqyaml.WithQuery("sort"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := collect(t, p, values)[0]
+		got := append([]interface{}{}, values...)
+		sort.SliceStable(got, func(i, j int) bool { return jqyaml.Compare(got[i], got[j]) < 0 })
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("values are not modified", func(t *testing.T) {
+		v := map[string]interface{}{"n": int64(1)}
+		jqyaml.Equal(v, map[string]interface{}{"n": 1})
+		if _, ok := v["n"].(int64); !ok {
+			t.Errorf("got %T", v["n"])
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package jqyaml
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompiledQueries(t *testing.T) {
+	pl, err := New(WithQuery(".a + $n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pl.(*pipeline)
+	ctx := context.Background()
+	discard := WithWriter(io.Discard, FormatJSON)
+	input := map[string]interface{}{"a": 1}
+
+	for i := 0; i < 3; i++ {
+		if err := p.Execute(ctx, input, discard, WithVariables(map[string]interface{}{"n": i})); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Execute(ctx, input, discard, WithVariables(map[string]interface{}{"n": i, "m": i})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.compiled.len(); n != 2 {
+		t.Errorf("got %d compilations, want one per set of variable names", n)
+	}
+
+	// Execution functions are compiled for their execution only
+	f := WithExecFunction("f", 0, 0, func(interface{}, []interface{}) interface{} { return nil })
+	n := WithVariables(map[string]interface{}{"n": 1})
+	if err := p.Execute(ctx, input, discard, n, f); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ExecuteReader(ctx, strings.NewReader(`{"a": 1} {"a": 2}`), FormatJSON, discard, n); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.compiled.len(); n != 2 {
+		t.Errorf("got %d compilations after executions with their own functions", n)
+	}
+
+	// Compilations are bounded however many sets of variable names are used,
+	// evicting the least recently used one
+	for i := 0; i < 2*maxCompiledQueries; i++ {
+		vars := WithVariables(map[string]interface{}{"n": 1, fmt.Sprintf("v%d", i): i})
+		if err := p.Execute(ctx, input, discard, vars); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Execute(ctx, input, discard, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.compiled.len(); n != maxCompiledQueries {
+		t.Errorf("got %d compilations, want at most %d", n, maxCompiledQueries)
+	}
+	for key, want := range map[string]bool{
+		"$ARGS,$n":     true,
+		"$ARGS,$m,$n":  false,
+		"$ARGS,$n,$v0": false,
+		fmt.Sprintf("$ARGS,$n,$v%d", maxCompiledQueries):     false,
+		fmt.Sprintf("$ARGS,$n,$v%d", maxCompiledQueries+1):   true,
+		fmt.Sprintf("$ARGS,$n,$v%d", 2*maxCompiledQueries-1): true,
+	} {
+		if _, ok := p.compiled.get(key); ok != want {
+			t.Errorf("compilation for %s kept: %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func BenchmarkExecute(b *testing.B) {
+	p, err := New(WithQuery(`.items | map(select(.n > 1) | .name) | join(",")`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	input := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"name": "a", "n": 1},
+		map[string]interface{}{"name": "b", "n": 2},
+	}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := p.Execute(context.Background(), input, WithWriter(io.Discard, FormatJSON)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
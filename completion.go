@@ -0,0 +1,90 @@
+package jqyaml
+
+import (
+	"context"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// CompletionCandidates suggests the field names that can follow partialQuery,
+// which is usually the text before the cursor in an editor. It evaluates the
+// path being typed (e.g. ".items[].na" in ".items[] | .na" resolves to the
+// keys of each item starting with "na") against input and returns the
+// matching keys in sorted order. The analysis is best effort: when the
+// query before the cursor cannot be evaluated on its own, paths are
+// resolved against the input itself. It returns nil when the cursor is
+// not in a path.
+func CompletionCandidates(input interface{}, partialQuery string) ([]string, error) {
+	token := trailingPath(partialQuery)
+	if !strings.HasPrefix(token, ".") {
+		return nil, nil
+	}
+	dot := strings.LastIndex(token, ".")
+	path, partial := token[:dot], token[dot+1:]
+	if path == "" {
+		path = "."
+	}
+
+	base := completionContext(partialQuery[:len(partialQuery)-len(token)])
+	if _, err := gojq.Parse(base); err != nil {
+		base = "."
+	}
+	query := "[(" + base + ") | (" + path + ")? | objects | keys[]] | unique"
+	p, err := New(WithQuery(query))
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	err = p.Execute(context.Background(), input, WithCallback(func(v interface{}) error {
+		for _, key := range v.([]interface{}) {
+			if k := key.(string); strings.HasPrefix(k, partial) {
+				candidates = append(candidates, k)
+			}
+		}
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// trailingPath returns the path expression at the end of query, such as ".a[0].b"
+func trailingPath(query string) string {
+	i := len(query)
+	for i > 0 && isPathByte(query[i-1]) {
+		i--
+	}
+	return query[i:]
+}
+
+func isPathByte(c byte) bool {
+	return c == '.' || c == '[' || c == ']' || c == '_' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// completionContext returns the query producing the input of the path that
+// follows prefix: the text before the last "|" outside of brackets, or "."
+func completionContext(prefix string) string {
+	depth := 0
+	for i := len(prefix) - 1; i >= 0; i-- {
+		switch prefix[i] {
+		case ')', ']', '}':
+			depth++
+		case '(', '[', '{':
+			if depth > 0 {
+				depth--
+			}
+		case '|':
+			if depth == 0 {
+				if base := strings.TrimSpace(prefix[:i]); base != "" {
+					return base
+				}
+				return "."
+			}
+		}
+	}
+	return "."
+}
@@ -0,0 +1,46 @@
+package jqyaml_test
+
+import (
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompletionCandidates(t *testing.T) {
+	input := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "namespace": "x", "id": 1},
+			map[string]interface{}{"name": "b", "nested": map[string]interface{}{"deep": true}},
+		},
+		"metadata": map[string]interface{}{"count": 2},
+		"misc":     "text",
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: ".", want: []string{"items", "metadata", "misc"}},
+		{query: ".m", want: []string{"metadata", "misc"}},
+		{query: ".metadata.", want: []string{"count"}},
+		{query: ".items[].na", want: []string{"name", "namespace"}},
+		{query: ".items[0].", want: []string{"id", "name", "namespace"}},
+		{query: ".items[] | .ne", want: []string{"nested"}},
+		{query: ".items[] | select(.nested).nested.", want: []string{"deep"}},
+		{query: ".items[] | select(.na", want: []string{"name", "namespace"}},
+		{query: ".misc.", want: nil},
+		{query: ".unknown.", want: nil},
+		{query: "length", want: nil},
+	}
+	for _, tt := range tests {
+		got, err := jqyaml.CompletionCandidates(input, tt.query)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%q: mismatch (-want +got):\n%s", tt.query, diff)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
+)
+
+// TestConcurrentExecute shares one pipeline between goroutines executing it
+// with their own inputs, variables and options; run with -race
+func TestConcurrentExecute(t *testing.T) {
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(`.items | map(select(.n >= $min)) | {names: map(.name), total: (map(.n) | add), id: $id}`),
+		jqyaml.WithDefaultEncodeOptions(yaml.Indent(2)),
+		jqyaml.WithDefaultEncodeOptions(yaml.IndentSequence(true)),
+		jqyaml.WithDefaultEncodeOptions(yaml.UseLiteralStyleIfMultiline(true)),
+		jqyaml.WithExecutionMetadata(),
+		jqyaml.WithDefaultExecuteOptions(jqyaml.WithVariables(map[string]interface{}{"min": 0})),
+		jqyaml.WithQueryCache(jqyaml.NewQueryCache(4)),
+		jqyaml.WithResultCache(jqyaml.NewLRUResultCache(16), 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"name": "a", "n": 1},
+		map[string]interface{}{"name": "b", "n": 2},
+	}}
+
+	const goroutines = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				id := fmt.Sprintf("%d-%d", i, j)
+				vars := jqyaml.WithVariables(map[string]interface{}{"min": i % 3, "id": id})
+				var buf bytes.Buffer
+				var err error
+				switch j % 4 {
+				case 0:
+					err = p.Execute(context.Background(), input, vars, jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+						jqyaml.WithEncodeOptions(yaml.Flow(j%8 == 0)))
+				case 1:
+					err = p.Execute(context.Background(), input, vars, jqyaml.WithWriter(&buf, jqyaml.FormatJSON), jqyaml.WithCompactJSONOutput())
+				case 2:
+					err = p.ExecuteReader(context.Background(), strings.NewReader(`{"items": [{"name": "a", "n": 1}, {"name": "b", "n": 2}]}`),
+						jqyaml.FormatJSON, vars, jqyaml.WithWriter(&buf, jqyaml.FormatJSON), jqyaml.WithCompactJSONOutput())
+				case 3:
+					err = p.Execute(context.Background(), input, vars, jqyaml.WithCallback(func(v interface{}) error {
+						fmt.Fprint(&buf, v.(map[string]interface{})["id"])
+						return nil
+					}))
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !strings.Contains(buf.String(), id) {
+					errs <- fmt.Errorf("execution %s got %q", id, buf.String())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestConcurrentPathsAndStages runs ExecutePaths and result stages of one
+// pipeline from several goroutines; run with -race
+func TestConcurrentPathsAndStages(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".items[] | select(.n >= $min)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"name": "a", "n": 1},
+		map[string]interface{}{"name": "b", "n": 2},
+		map[string]interface{}{"name": "c", "n": 2},
+	}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(min int) {
+			defer wg.Done()
+			vars := jqyaml.WithVariables(map[string]interface{}{"min": min})
+			for j := 0; j < 20; j++ {
+				paths, err := p.ExecutePaths(context.Background(), input, vars)
+				if err != nil {
+					errs <- err
+					return
+				}
+				var names []interface{}
+				err = p.Execute(context.Background(), input, vars, jqyaml.WithSortBy(".name", true), jqyaml.WithDedup(".n"),
+					jqyaml.WithCallback(func(v interface{}) error {
+						names = append(names, v.(map[string]interface{})["name"])
+						return nil
+					}))
+				if err != nil {
+					errs <- err
+					return
+				}
+				// The items have n of 1, 2 and 2, and the dedup stage keeps one of each n
+				if want := 4 - min; len(paths) != want || len(names) != want-1 {
+					errs <- fmt.Errorf("min %d: got paths %v and names %v", min, paths, names)
+					return
+				}
+			}
+		}(1 + i%2)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,198 @@
+package jqyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+// converts reports whether ExecuteReader should convert its input to the
+// output format as is. Without a query, an input marshaler, or result stages
+// other than those accepting ordered values, documents written by the
+// built-in encoders keep their key order, and JSON numbers keep their literal
+// form.
+func (p *pipeline) converts(cfg *executeConfig) bool {
+	for _, stage := range cfg.stages {
+		if !stage.ordered {
+			return false
+		}
+	}
+	return p.query == "" && p.inputMarshaler == nil &&
+		cfg.writer != nil && cfg.encoder == nil && cfg.callback == nil && cfg.format.IsValid()
+}
+
+// ToJQValue converts v to the types gojq operates on, as Execute converts its
+// input: custom marshalers, proto messages and the other input options given
+// in opts apply. It lets code calling gojq directly share the conversion.
+func ToJQValue(v interface{}, opts ...Option) (interface{}, error) {
+	pl, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	p := pl.(*pipeline)
+	converted, err := p.marshaler(p.defaultEncodeOptions).Marshal(v)
+	if err == nil && p.inputMarshaler != nil {
+		err = validateJQValue(converted)
+	}
+	if err != nil {
+		return nil, &ConversionError{Value: v, Type: "jq-compatible", Err: err}
+	}
+	return converted, nil
+}
+
+// marshaler returns the input marshaler of p, or the default marshaler
+// applying encodeOptions
+func (p *pipeline) marshaler(encodeOptions []yaml.EncodeOption) InputMarshaler {
+	if p.inputMarshaler != nil {
+		return p.inputMarshaler
+	}
+	return &defaultInputMarshaler{encodeOptions: encodeOptions, resolver: p.valueResolver, binary: p.binaryPolicy}
+}
+
+// numberLiteral writes json.Number values in YAML and JSON output as written in the input
+var numberLiteral = yaml.CustomMarshaler[json.Number](func(n json.Number) ([]byte, error) {
+	return []byte(n), nil
+})
+
+// decodeOrderedJSON decodes a single JSON value, representing objects as
+// yaml.MapSlice and numbers as json.Number
+func decodeOrderedJSON(data []byte, strict bool) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeOrderedJSONValue(dec, strict)
+}
+
+func decodeOrderedJSONValue(dec *json.Decoder, strict bool) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var obj yaml.MapSlice
+		index := map[string]int{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			value, err := decodeOrderedJSONValue(dec, strict)
+			if err != nil {
+				return nil, err
+			}
+			if i, ok := index[key]; ok {
+				if strict {
+					return nil, fmt.Errorf("duplicate key %q", key)
+				}
+				// The last value wins at the position of the first, as in jq
+				obj[i].Value = value
+				continue
+			}
+			index[key] = len(obj)
+			obj = append(obj, yaml.MapItem{Key: key, Value: value})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			obj = yaml.MapSlice{}
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrderedJSONValue(dec, strict)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	default:
+		return tok, nil
+	}
+}
+
+// normalizeOrderedYAML converts the keys of the mappings in v, decoded with
+// yaml.UseOrderedMap, to strings and merges duplicate keys as in jq
+func normalizeOrderedYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		obj := make(yaml.MapSlice, 0, len(v))
+		index := map[string]int{}
+		for _, item := range v {
+			key, ok := item.Key.(string)
+			if !ok {
+				key = fmt.Sprint(item.Key)
+			}
+			value := normalizeOrderedYAML(item.Value)
+			if i, ok := index[key]; ok {
+				obj[i].Value = value
+				continue
+			}
+			index[key] = len(obj)
+			obj = append(obj, yaml.MapItem{Key: key, Value: value})
+		}
+		return obj
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeOrderedYAML(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// orderedJSON prepares v for encoding/json, which would encode yaml.MapSlice as an array
+func orderedJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		return orderedObject(v)
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, e := range v {
+			arr[i] = orderedJSON(e)
+		}
+		return arr
+	default:
+		return v
+	}
+}
+
+// orderedObject is a yaml.MapSlice encoded as a JSON object
+type orderedObject yaml.MapSlice
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, item := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSON(&buf, item.Key); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(':')
+		if err := writeJSON(&buf, orderedJSON(item.Value)); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
@@ -0,0 +1,172 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+	"github.com/itchyny/gojq"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFormatConversion(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		srcFormat jqyaml.Format
+		dstFormat jqyaml.Format
+		opts      []jqyaml.ExecuteOption
+		want      string
+	}{
+		{
+			name:      "json to yaml keeps key order and numbers",
+			src:       `{"z": 1.50, "a": [{"y": 1e100, "b": 12345678901234567890}], "m": {}}`,
+			srcFormat: jqyaml.FormatJSON,
+			dstFormat: jqyaml.FormatYAML,
+			want:      "z: 1.50\na:\n- \"y\": 1e100\n  b: 12345678901234567890\nm: {}\n",
+		},
+		{
+			name:      "yaml to json keeps key order",
+			src:       "z: 1\na:\n  y: true\n  b: null\n",
+			srcFormat: jqyaml.FormatYAML,
+			dstFormat: jqyaml.FormatJSON,
+			want:      "{\"z\": 1, \"a\": {\"y\": true, \"b\": null}}\n",
+		},
+		{
+			name:      "json to jsonl",
+			src:       "{\"z\": 1.0, \"a\": [2, {\"y\": -0}]}\n{\"b\": \"<s>\"}",
+			srcFormat: jqyaml.FormatJSON,
+			dstFormat: jqyaml.FormatJSON,
+			opts:      []jqyaml.ExecuteOption{jqyaml.WithCompactJSONOutput()},
+			want:      "{\"z\":1.0,\"a\":[2,{\"y\":-0}]}\n{\"b\":\"\\u003cs\\u003e\"}\n",
+		},
+		{
+			name:      "pretty json",
+			src:       `{"z": 1, "a": 2}`,
+			srcFormat: jqyaml.FormatJSON,
+			dstFormat: jqyaml.FormatJSON,
+			opts:      []jqyaml.ExecuteOption{jqyaml.WithPrettyJSONOutput()},
+			want:      "{\n  \"z\": 1,\n  \"a\": 2\n}\n",
+		},
+		{
+			name:      "duplicate json keys",
+			src:       `{"a": 1, "b": 2, "a": 3}`,
+			srcFormat: jqyaml.FormatJSON,
+			dstFormat: jqyaml.FormatJSON,
+			opts:      []jqyaml.ExecuteOption{jqyaml.WithCompactJSONOutput()},
+			want:      "{\"a\":3,\"b\":2}\n",
+		},
+		{
+			name:      "duplicate and non-string yaml keys",
+			src:       "b: 1\n1: x\nb: 2\n",
+			srcFormat: jqyaml.FormatYAML,
+			dstFormat: jqyaml.FormatJSON,
+			opts:      []jqyaml.ExecuteOption{jqyaml.WithCompactJSONOutput()},
+			want:      "{\"b\":2,\"1\":\"x\"}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New()
+			if err != nil {
+				t.Fatalf("failed to create pipeline: %v", err)
+			}
+			var buf bytes.Buffer
+			opts := append(tt.opts, jqyaml.WithWriter(&buf, tt.dstFormat))
+			if err := p.ExecuteReader(context.Background(), strings.NewReader(tt.src), tt.srcFormat, opts...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatConversionStrictInput(t *testing.T) {
+	p, err := jqyaml.New()
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	var buf bytes.Buffer
+	err = p.ExecuteReader(context.Background(), strings.NewReader(`{"a": 1, "a": 2}`), jqyaml.FormatJSON,
+		jqyaml.WithStrictInput(), jqyaml.WithWriter(&buf, jqyaml.FormatYAML))
+	if err == nil || !strings.Contains(err.Error(), `duplicate key "a"`) {
+		t.Errorf("expected duplicate key error, got %v", err)
+	}
+}
+
+func TestFormatConversionWithQuery(t *testing.T) {
+	// A query sees ordinary maps, whose keys are sorted in the output
+	var buf bytes.Buffer
+	err := jqyaml.Transform(context.Background(), &buf, jqyaml.FormatJSON, strings.NewReader(`{"z": 1, "a": 2}`), jqyaml.FormatJSON,
+		jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := buf.String(), "{\"a\": 2, \"z\": 1}\n"; got != want {
+		t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestToJQValue(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Count int    `json:"count,omitempty"`
+	}
+
+	t.Run("default conversion", func(t *testing.T) {
+		v, err := jqyaml.ToJQValue([]item{{Name: "a", Count: 1}, {Name: "b"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, err := gojq.Parse("map(.count)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := query.Run(v).Next()
+		want := []interface{}{1, nil}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("proto messages", func(t *testing.T) {
+		got, err := jqyaml.ToJQValue(map[string]interface{}{"at": timestamppb.New(time.Unix(1, 0))}, jqyaml.WithProtojsonInput())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string]interface{}{"at": "1970-01-01T00:00:01Z"}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("custom marshaler", func(t *testing.T) {
+		got, err := jqyaml.ToJQValue(map[string]interface{}{"s": "x"}, jqyaml.WithInputMarshaler(&prefixMarshaler{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string]interface{}{"s": "PREFIX:x"}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var convErr *jqyaml.ConversionError
+		if _, err := jqyaml.ToJQValue(func() {}); !errors.As(err, &convErr) {
+			t.Errorf("got %v, want a ConversionError", err)
+		}
+		// Values of custom marshalers are checked as for Execute
+		if _, err := jqyaml.ToJQValue(struct{}{}, jqyaml.WithInputMarshaler(identityMarshaler{})); !errors.As(err, &convErr) {
+			t.Errorf("got %v, want a ConversionError", err)
+		}
+		if _, err := jqyaml.ToJQValue(1, jqyaml.WithInputMarshaler(nil)); err == nil {
+			t.Error("expected an error for an invalid option")
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package jqyaml
+
+import (
+	"fmt"
+
+	"github.com/apstndb/go-yamlformat"
+	"github.com/goccy/go-yaml"
+)
+
+// decodeTarget implements the callback installed by WithDecodeInto
+type decodeTarget struct {
+	factory func() interface{}
+	handle  func(interface{}) error
+	opts    []yaml.DecodeOption // Decode options of the pipeline and the execution, set by run
+}
+
+func (d *decodeTarget) decode(v interface{}) error {
+	data, err := yamlformat.MarshalJSON(v)
+	if err != nil {
+		return &ConversionError{Value: v, Type: "JSON", Err: err}
+	}
+	dst := d.factory()
+	if err := yamlformat.Unmarshal(data, dst, d.opts...); err != nil {
+		return &ConversionError{Value: v, Type: fmt.Sprintf("%T", dst), Err: err}
+	}
+	return d.handle(dst)
+}
@@ -0,0 +1,71 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+)
+
+type decodedUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestWithDecodeInto(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[] | {name, age}"))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	input := []map[string]interface{}{
+		{"name": "alice", "age": 30},
+		{"name": "bob", "age": 25},
+	}
+
+	var got []*decodedUser
+	err = p.Execute(context.Background(), input, jqyaml.WithDecodeInto(
+		func() interface{} { return &decodedUser{} },
+		func(v interface{}) error {
+			got = append(got, v.(*decodedUser))
+			return nil
+		},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*decodedUser{{Name: "alice", Age: 30}, {Name: "bob", Age: 25}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithDecodeIntoDecodeOptions(t *testing.T) {
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(`{name: "alice", extra: true}`),
+		jqyaml.WithDefaultDecodeOptions(yaml.DisallowUnknownField()),
+	)
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	err = p.Execute(context.Background(), nil, jqyaml.WithDecodeInto(
+		func() interface{} { return &decodedUser{} },
+		func(interface{}) error { return nil },
+	))
+	var convErr *jqyaml.ConversionError
+	if !errors.As(err, &convErr) {
+		t.Fatalf("expected ConversionError, got %v", err)
+	}
+}
+
+func TestWithDecodeIntoValidation(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	if err := p.Execute(context.Background(), 1, jqyaml.WithDecodeInto(nil, nil)); err == nil {
+		t.Error("expected error for nil factory and handler")
+	}
+}
@@ -0,0 +1,154 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestDefaultWriter(t *testing.T) {
+	data := map[string]interface{}{"name": "test"}
+
+	t.Run("used when no output is specified", func(t *testing.T) {
+		var buf bytes.Buffer
+		p, err := jqyaml.New(
+			jqyaml.WithQuery("."),
+			jqyaml.WithDefaultWriter(&buf, jqyaml.FormatYAML),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Execute(context.Background(), data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "name: test\n"; got != want {
+			t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
+	t.Run("overridden by WithWriter", func(t *testing.T) {
+		var defaultBuf, buf bytes.Buffer
+		p, err := jqyaml.New(
+			jqyaml.WithQuery(".name"),
+			jqyaml.WithDefaultWriter(&defaultBuf, jqyaml.FormatYAML),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = p.Execute(context.Background(), data,
+			jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+			jqyaml.WithCompactJSONOutput(),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), `"test"`+"\n"; got != want {
+			t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+		}
+		if defaultBuf.Len() != 0 {
+			t.Errorf("default writer should not be used, got %q", defaultBuf.String())
+		}
+	})
+
+	t.Run("overridden by WithCallback", func(t *testing.T) {
+		var defaultBuf bytes.Buffer
+		p, err := jqyaml.New(
+			jqyaml.WithQuery(".name"),
+			jqyaml.WithDefaultWriter(&defaultBuf, jqyaml.FormatYAML),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var results []interface{}
+		err = p.Execute(context.Background(), data,
+			jqyaml.WithCallback(func(v interface{}) error {
+				results = append(results, v)
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 1 || results[0] != "test" {
+			t.Errorf("unexpected results: %v", results)
+		}
+		if defaultBuf.Len() != 0 {
+			t.Errorf("default writer should not be used, got %q", defaultBuf.String())
+		}
+	})
+
+	t.Run("nil writer", func(t *testing.T) {
+		_, err := jqyaml.New(jqyaml.WithDefaultWriter(nil, jqyaml.FormatYAML))
+		if err == nil {
+			t.Fatal("expected error for nil default writer")
+		}
+	})
+}
+
+func TestDefaultEncoder(t *testing.T) {
+	data := map[string]interface{}{"name": "test"}
+
+	var got []interface{}
+	enc := jqyaml.EncoderFunc(func(v interface{}) error {
+		got = append(got, v)
+		return nil
+	})
+	p, err := jqyaml.New(jqyaml.WithQuery(".name"), jqyaml.WithDefaultEncoder(enc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("used when no output is specified", func(t *testing.T) {
+		got = nil
+		if err := p.Execute(context.Background(), data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0] != "test" {
+			t.Errorf("unexpected results: %v", got)
+		}
+	})
+
+	t.Run("overridden by execute options", func(t *testing.T) {
+		got = nil
+		var buf bytes.Buffer
+		if err := p.Execute(context.Background(), data, jqyaml.WithWriter(&buf, jqyaml.FormatJSON)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf.String() != "\"test\"\n" {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+		if len(got) != 0 {
+			t.Errorf("default encoder should not be used, got %v", got)
+		}
+	})
+
+	t.Run("replaces the default writer", func(t *testing.T) {
+		got = nil
+		var buf bytes.Buffer
+		p, err := jqyaml.New(
+			jqyaml.WithQuery(".name"),
+			jqyaml.WithDefaultWriter(&buf, jqyaml.FormatJSON),
+			jqyaml.WithDefaultEncoder(enc),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Execute(context.Background(), data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf.Len() != 0 || len(got) != 1 {
+			t.Errorf("got output %q and results %v", buf.String(), got)
+		}
+	})
+
+	t.Run("nil encoder", func(t *testing.T) {
+		if _, err := jqyaml.New(jqyaml.WithDefaultEncoder(nil)); err == nil {
+			t.Fatal("expected error for nil default encoder")
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package jqyaml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExecuteConfigString renders the effective configuration produced by opts.
+// Options are merged in the same order as Execute applies them, starting from
+// the package defaults, so the result shows which of several layered options won.
+func ExecuteConfigString(opts ...ExecuteOption) string {
+	p := &pipeline{timeout: DefaultTimeout}
+	return p.newExecuteConfig(opts...).String()
+}
+
+// String renders the configuration as one "key: value" line per setting
+func (c *executeConfig) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "output: %s\n", c.outputKind())
+	if len(c.replacedOutputs) > 0 {
+		fmt.Fprintf(&b, "replaced outputs: %s\n", strings.Join(c.replacedOutputs, ", "))
+	}
+	if c.writer != nil {
+		fmt.Fprintf(&b, "format: %s\n", c.format)
+		if c.format == FormatJSON {
+			fmt.Fprintf(&b, "json style: %s\n", c.jsonStyle())
+		}
+		if c.format == FormatYAML && c.multilineStyle != 0 {
+			fmt.Fprintf(&b, "multiline style: %s\n", c.multilineStyle)
+		}
+		if c.format == FormatYAML {
+			var styles []string
+			if c.rawYAML {
+				style := "raw strings"
+				if c.separator != nil {
+					style += fmt.Sprintf(", separator %q", *c.separator)
+				}
+				styles = append(styles, style)
+			}
+			styles = append(styles, c.yamlStyle.names()...)
+			if len(styles) > 0 {
+				fmt.Fprintf(&b, "yaml style: %s\n", strings.Join(styles, ", "))
+			}
+		}
+		if c.indent > 0 {
+			fmt.Fprintf(&b, "indent: %d\n", c.indent)
+		}
+		if c.newline != NewlineLF {
+			fmt.Fprintf(&b, "newline: %s\n", c.newline)
+		}
+		if c.bom {
+			b.WriteString("bom: true\n")
+		}
+	}
+	if c.timeout > 0 {
+		fmt.Fprintf(&b, "timeout: %s\n", c.timeout)
+	} else {
+		b.WriteString("timeout: none\n")
+	}
+	if c.maxCPU > 0 {
+		fmt.Fprintf(&b, "cpu budget: %s\n", c.maxCPU)
+	}
+	if c.outputBuffer > 0 {
+		fmt.Fprintf(&b, "output buffer: %d\n", c.outputBuffer)
+	}
+	if c.yieldEvery > 0 {
+		fmt.Fprintf(&b, "yield every: %d steps\n", c.yieldEvery)
+	}
+	if len(c.variables) > 0 {
+		names := make([]string, 0, len(c.variables))
+		for k := range c.variables {
+			if _, ok := c.secrets[k]; ok {
+				names = append(names, "$"+k+" (secret)")
+				continue
+			}
+			names = append(names, "$"+k)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "variables: %s\n", strings.Join(names, ", "))
+	}
+	if len(c.stages) > 0 {
+		names := make([]string, len(c.stages))
+		for i, stage := range c.stages {
+			names[i] = stage.name
+		}
+		fmt.Fprintf(&b, "stages: %s\n", strings.Join(names, " | "))
+	}
+	if c.collectErrors {
+		if c.maxErrors > 0 {
+			fmt.Fprintf(&b, "errors: collect up to %d\n", c.maxErrors)
+		} else {
+			b.WriteString("errors: collect all\n")
+		}
+	}
+	if len(c.encodeOptions) > 0 {
+		fmt.Fprintf(&b, "encode options: %d\n", len(c.encodeOptions))
+	}
+
+	return b.String()
+}
+
+// outputKind describes which output method is configured
+func (c *executeConfig) outputKind() string {
+	switch {
+	case c.encoder != nil && c.callback != nil:
+		return "encoder and callback (conflict)"
+	case c.encoder != nil:
+		return "encoder"
+	case c.callback != nil:
+		return "callback"
+	case c.writer != nil:
+		return "writer"
+	default:
+		return "none"
+	}
+}
+
+// jsonStyle describes how JSON output will be rendered by WithWriter
+func (c *executeConfig) jsonStyle() string {
+	var style string
+	switch {
+	case !c.compactOutputSet:
+		style = "default"
+	case c.compactOutput:
+		style = "compact"
+	default:
+		style = "pretty"
+	}
+	if c.rawOutput {
+		style += ", raw strings"
+	}
+	if c.separator != nil {
+		style += fmt.Sprintf(", separator %q", *c.separator)
+	}
+	return style
+}
@@ -0,0 +1,113 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExecuteConfigString(t *testing.T) {
+	var buf bytes.Buffer
+
+	tests := []struct {
+		name string
+		opts []jqyaml.ExecuteOption
+		want string
+	}{
+		{
+			name: "defaults",
+			want: "output: none\ntimeout: 30s\n",
+		},
+		{
+			name: "later option wins",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+				jqyaml.WithCompactJSONOutput(),
+				jqyaml.WithPrettyJSONOutput(),
+				jqyaml.WithRawJSONOutput(),
+				jqyaml.WithTimeout(5 * time.Second),
+			},
+			want: "output: writer\nformat: json\njson style: pretty, raw strings\ntimeout: 5s\n",
+		},
+		{
+			name: "yaml writer with variables and no timeout",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+				jqyaml.WithTimeout(0),
+				jqyaml.WithMaxCPU(time.Second),
+				jqyaml.WithYieldEvery(100),
+				jqyaml.WithVariables(map[string]interface{}{"b": 1, "a": 2}),
+			},
+			want: "output: writer\nformat: yaml\ntimeout: none\ncpu budget: 1s\nyield every: 100 steps\nvariables: $a, $b\n",
+		},
+		{
+			name: "output separator",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+				jqyaml.WithRawJSONOutput(),
+				jqyaml.WithOutputSeparator("\x00"),
+				jqyaml.WithNewlineStyle(jqyaml.NewlineCRLF),
+			},
+			want: "output: writer\nformat: json\njson style: default, raw strings, separator \"\\x00\"\nnewline: crlf\ntimeout: 30s\n",
+		},
+		{
+			name: "raw yaml output",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+				jqyaml.WithRawYAMLOutput(),
+			},
+			want: "output: writer\nformat: yaml\nyaml style: raw strings\ntimeout: 30s\n",
+		},
+		{
+			name: "yaml presets",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+				jqyaml.WithYAMLEmptyMapNull(),
+				jqyaml.WithYAMLIndentSequence(),
+			},
+			want: "output: writer\nformat: yaml\nyaml style: indented sequences, empty maps as null\ntimeout: 30s\n",
+		},
+		{
+			name: "callback",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithCallback(func(interface{}) error { return nil }),
+			},
+			want: "output: callback\ntimeout: 30s\n",
+		},
+		{
+			name: "stages",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithDedup(".id"),
+				jqyaml.WithDedup(""),
+			},
+			want: "output: none\ntimeout: 30s\nstages: dedup(.id) | dedup()\n",
+		},
+		{
+			name: "secret variables",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithVariables(map[string]interface{}{"user": "alice"}),
+				jqyaml.WithSecretVariables(map[string]string{"token": "s3cr3t"}),
+			},
+			want: "output: none\ntimeout: 30s\nvariables: $token (secret), $user\n",
+		},
+		{
+			name: "collect errors",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithCollectErrors(10),
+			},
+			want: "output: none\ntimeout: 30s\nerrors: collect up to 10\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jqyaml.ExecuteConfigString(tt.opts...)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("config mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
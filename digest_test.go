@@ -0,0 +1,67 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestDigest(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{map[string]interface{}{"a": 1}, "b"}
+
+	t.Run("output", func(t *testing.T) {
+		var buf bytes.Buffer
+		var digest []byte
+		err := p.Execute(context.Background(), input,
+			jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+			jqyaml.WithNewlineStyle(jqyaml.NewlineCRLF),
+			jqyaml.WithDigest(crypto.SHA256, &digest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := sha256.Sum256(buf.Bytes()); !bytes.Equal(digest, want[:]) {
+			t.Errorf("got digest %x, want %x of %q", digest, want, buf.String())
+		}
+	})
+
+	t.Run("partial output", func(t *testing.T) {
+		w := &failingWriter{limit: 10, err: errors.New("disk full")}
+		var digest []byte
+		err := p.Execute(context.Background(), input,
+			jqyaml.WithWriter(w, jqyaml.FormatJSON),
+			jqyaml.WithDigest(crypto.SHA256, &digest))
+		var writeErr *jqyaml.WriteError
+		if !errors.As(err, &writeErr) {
+			t.Fatalf("got %v, want a WriteError", err)
+		}
+		if w.buf.Len() == 0 {
+			t.Fatal("expected the first result to be written")
+		}
+		if want := sha256.Sum256(w.buf.Bytes()); !bytes.Equal(digest, want[:]) {
+			t.Errorf("got digest %x, want %x of %q", digest, want, w.buf.String())
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var digest []byte
+		callback := jqyaml.WithCallback(func(interface{}) error { return nil })
+		if err := p.Execute(context.Background(), input, callback, jqyaml.WithDigest(crypto.SHA256, &digest)); err == nil {
+			t.Error("expected an error without a writer")
+		}
+		if err := p.Execute(context.Background(), input, callback, jqyaml.WithDigest(crypto.SHA256, nil)); err == nil {
+			t.Error("expected an error for a nil output")
+		}
+		if err := p.Execute(context.Background(), input, callback, jqyaml.WithDigest(crypto.MD4, &digest)); err == nil {
+			t.Error("expected an error for an unavailable hash")
+		}
+	})
+}
@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package jqyaml
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// EncodeAll writes values to w in format with the encoding Execute uses for
+// query results, so values that did not come from a query get the same
+// document separators and compact, raw and indentation rules. Execute options
+// such as WithCompactJSONOutput or WithRawJSONOutput apply; output options are
+// replaced by w. Values are converted like Execute input, and there is no
+// timeout unless WithTimeout is given.
+func EncodeAll(w io.Writer, format Format, values iter.Seq[interface{}], opts ...ExecuteOption) error {
+	p := &pipeline{compiled: newCodeCache(maxCompiledQueries)}
+	cfg := p.newExecuteConfig(append(opts, WithWriter(w, format))...)
+	defer closeChannel(cfg.channel)
+	cfg.encoder, cfg.callback, cfg.decodeTarget, cfg.batch, cfg.channel = nil, nil, nil, nil, nil
+	cfg.reader, cfg.nullInput, cfg.streamInput = nil, false, false
+	return p.run(context.Background(), cfg, func(ex *execution) error {
+		for v := range values {
+			if err := ex.processRecord(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+//go:build go1.23
+
+package jqyaml_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestEncodeAll(t *testing.T) {
+	values := []interface{}{map[string]interface{}{"a": []int{1, 2}}, "text", nil}
+	tests := []struct {
+		name   string
+		format jqyaml.Format
+		opts   []jqyaml.ExecuteOption
+		want   string
+	}{
+		{
+			name:   "json",
+			format: jqyaml.FormatJSON,
+			want:   "{\"a\": [1, 2]}\n\"text\"\nnull\n",
+		},
+		{
+			name:   "raw json",
+			format: jqyaml.FormatJSON,
+			opts:   []jqyaml.ExecuteOption{jqyaml.WithRawJSONOutput()},
+			want:   "{\"a\":[1,2]}\ntext\nnull\n",
+		},
+		{
+			name:   "jsonl",
+			format: jqyaml.FormatJSONL,
+			want:   "{\"a\":[1,2]}\n\"text\"\nnull\n",
+		},
+		{
+			name:   "yaml",
+			format: jqyaml.FormatYAML,
+			want:   "a:\n- 1\n- 2\ntext\nnull\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := jqyaml.EncodeAll(&buf, tt.format, slices.Values(values), tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("output options are replaced", func(t *testing.T) {
+		var buf bytes.Buffer
+		called := false
+		err := jqyaml.EncodeAll(&buf, jqyaml.FormatJSON, slices.Values([]interface{}{1}), jqyaml.WithCallback(func(interface{}) error {
+			called = true
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if called || buf.String() != "1\n" {
+			t.Errorf("got %q, callback called: %v", buf.String(), called)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var convErr *jqyaml.ConversionError
+		err := jqyaml.EncodeAll(&bytes.Buffer{}, jqyaml.FormatJSON, slices.Values([]interface{}{make(chan int)}))
+		if !errors.As(err, &convErr) {
+			t.Errorf("got %v, want a ConversionError", err)
+		}
+		var writeErr *jqyaml.WriteError
+		err = jqyaml.EncodeAll(&failingWriter{limit: 0, err: errors.New("disk full")}, jqyaml.FormatJSON, slices.Values(values))
+		if !errors.As(err, &writeErr) {
+			t.Errorf("got %v, want a WriteError", err)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package jqyaml
+
+import "github.com/goccy/go-yaml"
+
+// EncoderFunc adapts an ordinary function to the Encoder interface
+type EncoderFunc func(v interface{}) error
+
+// Encode calls f(v)
+func (f EncoderFunc) Encode(v interface{}) error {
+	return f(v)
+}
+
+// Chain returns an Encoder that applies transforms in order to each value
+// before passing it to enc. Encode options set by the pipeline are
+// forwarded to enc when it accepts them.
+func Chain(enc Encoder, transforms ...func(interface{}) (interface{}, error)) Encoder {
+	return &chainEncoder{enc: enc, transforms: transforms}
+}
+
+type chainEncoder struct {
+	enc        Encoder
+	transforms []func(interface{}) (interface{}, error)
+}
+
+func (c *chainEncoder) Encode(v interface{}) error {
+	for _, transform := range c.transforms {
+		var err error
+		if v, err = transform(v); err != nil {
+			return err
+		}
+	}
+	return c.enc.Encode(v)
+}
+
+func (c *chainEncoder) SetOptions(opts ...yaml.EncodeOption) {
+	if setter, ok := c.enc.(interface {
+		SetOptions(...yaml.EncodeOption)
+	}); ok {
+		setter.SetOptions(opts...)
+	}
+}
@@ -0,0 +1,73 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEncoderFunc(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []interface{}
+	err = p.Execute(context.Background(), []interface{}{1, 2},
+		jqyaml.WithEncoder(jqyaml.EncoderFunc(func(v interface{}) error {
+			got = append(got, v)
+			return nil
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]interface{}{1, 2}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestChain(t *testing.T) {
+	upper := func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return strings.ToUpper(s), nil
+		}
+		return v, nil
+	}
+	wrap := func(v interface{}) (interface{}, error) {
+		return map[string]interface{}{"value": map[string]interface{}{"v": v}}, nil
+	}
+
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(".[]"),
+		jqyaml.WithDefaultEncodeOptions(yaml.Indent(4)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Transforms apply in order and the pipeline's encode options reach the wrapped encoder
+	var buf bytes.Buffer
+	err = p.Execute(context.Background(), []interface{}{"a", 1},
+		jqyaml.WithEncoder(jqyaml.Chain(jqyaml.NewStreamingYAMLEncoder(&buf), upper, wrap)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "value:\n    v: A\n---\nvalue:\n    v: 1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// Transform errors stop the execution
+	boom := errors.New("boom")
+	err = p.Execute(context.Background(), []interface{}{1},
+		jqyaml.WithEncoder(jqyaml.Chain(jqyaml.EncoderFunc(func(interface{}) error { return nil }),
+			func(interface{}) (interface{}, error) { return nil, boom })))
+	if !errors.Is(err, boom) {
+		t.Errorf("expected %v, got %v", boom, err)
+	}
+}
@@ -0,0 +1,221 @@
+package jqyaml
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// InputEncoding is the character encoding of ExecuteReader input
+type InputEncoding int
+
+const (
+	// EncodingAuto detects the encoding from a byte order mark, or from the
+	// pattern of zero bytes at the start of the input (as described in
+	// RFC 4627), falling back to UTF-8 (default)
+	EncodingAuto InputEncoding = iota
+	EncodingUTF8
+	EncodingUTF16LE
+	EncodingUTF16BE
+	EncodingUTF32LE
+	EncodingUTF32BE
+)
+
+func (e InputEncoding) String() string {
+	switch e {
+	case EncodingAuto:
+		return "auto"
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingUTF16LE:
+		return "UTF-16LE"
+	case EncodingUTF16BE:
+		return "UTF-16BE"
+	case EncodingUTF32LE:
+		return "UTF-32LE"
+	case EncodingUTF32BE:
+		return "UTF-32BE"
+	default:
+		return fmt.Sprintf("InputEncoding(%d)", int(e))
+	}
+}
+
+// utf8BOM is the UTF-8 encoding of U+FEFF, which some editors and tools
+// (Excel, Notepad) write at the start of text files
+const utf8BOM = "\xef\xbb\xbf"
+
+// byteOrderMarks lists the byte order mark of each encoding. UTF-32LE comes
+// before UTF-16LE because its mark starts with the UTF-16LE one.
+var byteOrderMarks = []struct {
+	encoding InputEncoding
+	bom      string
+}{
+	{EncodingUTF8, utf8BOM},
+	{EncodingUTF32LE, "\xff\xfe\x00\x00"},
+	{EncodingUTF32BE, "\x00\x00\xfe\xff"},
+	{EncodingUTF16LE, "\xff\xfe"},
+	{EncodingUTF16BE, "\xfe\xff"},
+}
+
+// newInputReader returns r transcoded to UTF-8 without a byte order mark.
+// Read errors while detecting the encoding are returned by the next Read.
+func newInputReader(r io.Reader, encoding InputEncoding) io.Reader {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(4)
+
+	bomLen := 0
+	for _, m := range byteOrderMarks {
+		if (encoding == EncodingAuto || encoding == m.encoding) && len(head) >= len(m.bom) && string(head[:len(m.bom)]) == m.bom {
+			encoding, bomLen = m.encoding, len(m.bom)
+			break
+		}
+	}
+	if encoding == EncodingAuto {
+		encoding = detectEncoding(head)
+	}
+	_, _ = br.Discard(bomLen)
+
+	switch encoding {
+	case EncodingUTF16LE, EncodingUTF16BE, EncodingUTF32LE, EncodingUTF32BE:
+		return &transcoder{r: br, encoding: encoding}
+	default:
+		return br
+	}
+}
+
+// detectEncoding guesses the encoding of input without a byte order mark
+// from its first four bytes, assuming the input starts with an ASCII character
+func detectEncoding(head []byte) InputEncoding {
+	if len(head) < 4 {
+		if len(head) >= 2 && head[0] == 0 && head[1] != 0 {
+			return EncodingUTF16BE
+		}
+		if len(head) >= 2 && head[0] != 0 && head[1] == 0 {
+			return EncodingUTF16LE
+		}
+		return EncodingUTF8
+	}
+	switch {
+	case head[0] == 0 && head[1] == 0 && head[2] == 0 && head[3] != 0:
+		return EncodingUTF32BE
+	case head[0] != 0 && head[1] == 0 && head[2] == 0 && head[3] == 0:
+		return EncodingUTF32LE
+	case head[0] == 0 && head[1] != 0:
+		return EncodingUTF16BE
+	case head[0] != 0 && head[1] == 0:
+		return EncodingUTF16LE
+	default:
+		return EncodingUTF8
+	}
+}
+
+// transcoder converts UTF-16 or UTF-32 input to UTF-8. Invalid code units
+// are replaced with U+FFFD.
+type transcoder struct {
+	r        *bufio.Reader
+	encoding InputEncoding
+	buf      [4]byte
+	pending  rune // Code unit read after an unpaired surrogate
+	hasPend  bool // Whether pending holds a code unit
+	out      []byte
+	err      error
+}
+
+func (t *transcoder) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		t.fill()
+	}
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// fill transcodes a batch of characters into out: at least one, then those
+// already buffered, so that the characters of a stream arriving through a
+// pipe or socket are returned without waiting for more
+func (t *transcoder) fill() {
+	unit := 2
+	if t.encoding == EncodingUTF32LE || t.encoding == EncodingUTF32BE {
+		unit = 4
+	}
+	t.out = t.out[:0]
+	for len(t.out) < 4096 {
+		if len(t.out) > 0 && !t.hasPend && t.r.Buffered() < unit {
+			return
+		}
+		r, err := t.readRune()
+		if err != nil {
+			t.err = err
+			return
+		}
+		t.out = utf8.AppendRune(t.out, r)
+	}
+}
+
+func (t *transcoder) readRune() (rune, error) {
+	switch t.encoding {
+	case EncodingUTF32LE, EncodingUTF32BE:
+		u, err := t.readUnit(4)
+		if err != nil {
+			return 0, err
+		}
+		if !utf8.ValidRune(u) {
+			return utf8.RuneError, nil
+		}
+		return u, nil
+	default:
+		u, err := t.readUTF16Unit()
+		if err != nil {
+			return 0, err
+		}
+		if !utf16.IsSurrogate(u) {
+			return u, nil
+		}
+		low, err := t.readUTF16Unit()
+		if err == io.EOF {
+			return utf8.RuneError, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if r := utf16.DecodeRune(u, low); r != utf8.RuneError {
+			return r, nil
+		}
+		// Not a surrogate pair; decode the second unit on its own next time
+		t.pending, t.hasPend = low, true
+		return utf8.RuneError, nil
+	}
+}
+
+func (t *transcoder) readUTF16Unit() (rune, error) {
+	if t.hasPend {
+		t.hasPend = false
+		return t.pending, nil
+	}
+	return t.readUnit(2)
+}
+
+// readUnit reads one code unit of size bytes in the transcoder's byte order
+func (t *transcoder) readUnit(size int) (rune, error) {
+	b := t.buf[:size]
+	if _, err := io.ReadFull(t.r, b); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, fmt.Errorf("truncated %s input", t.encoding)
+		}
+		return 0, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if t.encoding == EncodingUTF16BE || t.encoding == EncodingUTF32BE {
+		order = binary.BigEndian
+	}
+	if size == 2 {
+		return rune(order.Uint16(b)), nil
+	}
+	return rune(order.Uint32(b)), nil
+}
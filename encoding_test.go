@@ -0,0 +1,125 @@
+package jqyaml_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// encode returns s in the given UTF-16 or UTF-32 encoding, optionally with a byte order mark
+func encode(s string, size int, order binary.AppendByteOrder, bom bool) string {
+	if bom {
+		s = "\ufeff" + s
+	}
+	var b []byte
+	if size == 2 {
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = order.AppendUint16(b, u)
+		}
+	} else {
+		for _, r := range s {
+			b = order.AppendUint32(b, uint32(r))
+		}
+	}
+	return string(b)
+}
+
+func TestInputEncodingDetection(t *testing.T) {
+	const doc = `{"name": "日本語 🎉"}`
+	want := []interface{}{"日本語 🎉"}
+
+	tests := []struct {
+		name   string
+		input  string
+		format jqyaml.Format
+	}{
+		{name: "utf-16le with bom", input: encode(doc, 2, binary.LittleEndian, true), format: jqyaml.FormatJSON},
+		{name: "utf-16be with bom", input: encode(doc, 2, binary.BigEndian, true), format: jqyaml.FormatJSON},
+		{name: "utf-32le with bom", input: encode(doc, 4, binary.LittleEndian, true), format: jqyaml.FormatJSON},
+		{name: "utf-32be with bom", input: encode(doc, 4, binary.BigEndian, true), format: jqyaml.FormatJSON},
+		{name: "utf-16le without bom", input: encode(doc, 2, binary.LittleEndian, false), format: jqyaml.FormatJSON},
+		{name: "utf-32be without bom", input: encode(doc, 4, binary.BigEndian, false), format: jqyaml.FormatJSON},
+		{name: "utf-16le yaml", input: encode("name: 日本語 🎉\n", 2, binary.LittleEndian, true), format: jqyaml.FormatYAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(".name"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collectReader(t, p, tt.input, tt.format)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithInputEncoding(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a byte order mark or ASCII characters, detection falls back to UTF-8
+	got := collectReader(t, p, encode("日本", 2, binary.BigEndian, false), jqyaml.FormatYAML,
+		jqyaml.WithInputEncoding(jqyaml.EncodingUTF16BE))
+	if diff := cmp.Diff([]interface{}{"日本"}, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+
+	// Truncated input is reported
+	err = p.ExecuteReader(context.Background(), strings.NewReader(encode("1", 2, binary.BigEndian, true)+"\x00"), jqyaml.FormatJSON,
+		jqyaml.WithInputEncoding(jqyaml.EncodingUTF16BE),
+		jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err == nil || !strings.Contains(err.Error(), "truncated UTF-16BE input") {
+		t.Errorf("expected truncation error, got %v", err)
+	}
+}
+
+func TestInputEncodingStreaming(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w := io.Pipe()
+	results := make(chan interface{})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.ExecuteReader(context.Background(), r, jqyaml.FormatJSONL,
+			jqyaml.WithInputEncoding(jqyaml.EncodingUTF16LE),
+			jqyaml.WithCallback(func(v interface{}) error {
+				results <- v
+				return nil
+			}))
+	}()
+
+	// Each line is processed as it arrives rather than once the input fills a
+	// buffer or ends
+	for i := 1; i <= 2; i++ {
+		if _, err := io.WriteString(w, encode(fmt.Sprintf("{\"n\": %d}\n", i), 2, binary.LittleEndian, false)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case v := <-results:
+			if v != i {
+				t.Errorf("got %v, want %d", v, i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("line %d was not processed before the input ended", i)
+		}
+	}
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
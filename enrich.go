@@ -0,0 +1,102 @@
+package jqyaml
+
+import (
+	"context"
+	"fmt"
+)
+
+// Enricher augments a result, e.g. with data looked up from a database,
+// using the execution context
+type Enricher func(ctx context.Context, v interface{}) (interface{}, error)
+
+// enrichStage runs the enricher on up to concurrency results at a time and
+// passes the enriched results on in their original order
+type enrichStage struct {
+	ex          *execution
+	enrich      Enricher
+	concurrency int
+	pending     []*enrichment // Results being enriched, oldest first
+}
+
+// enrichment is the outcome of enriching one result
+type enrichment struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newEnrichStage(enrich Enricher, concurrency int) stageSpec {
+	return stageSpec{
+		name: fmt.Sprintf("enrich(%d)", concurrency),
+		build: func(ex *execution) (resultStage, error) {
+			if enrich == nil {
+				return nil, fmt.Errorf("enricher must not be nil")
+			}
+			if concurrency <= 0 {
+				return nil, fmt.Errorf("enricher concurrency must be positive: %d", concurrency)
+			}
+			return &enrichStage{ex: ex, enrich: enrich, concurrency: concurrency}, nil
+		},
+	}
+}
+
+func (s *enrichStage) emit(v interface{}, next func(interface{}) error) error {
+	if s.concurrency == 1 {
+		e := &enrichment{}
+		e.value, e.err = s.run(v)
+		return s.forward(e, next)
+	}
+	if len(s.pending) == s.concurrency {
+		if err := s.forwardOldest(next); err != nil {
+			return err
+		}
+	}
+	e := &enrichment{done: make(chan struct{})}
+	s.pending = append(s.pending, e)
+	go func() {
+		defer close(e.done)
+		e.value, e.err = s.run(v)
+	}()
+	return nil
+}
+
+func (s *enrichStage) flush(next func(interface{}) error) error {
+	for len(s.pending) > 0 {
+		if err := s.forwardOldest(next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run enriches v and converts the result with the input marshaler, so that
+// later stages and the output see jq-compatible values
+func (s *enrichStage) run(v interface{}) (interface{}, error) {
+	enriched, err := s.enrich(s.ex.ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := s.ex.marshaler.Marshal(enriched)
+	if err != nil {
+		return nil, &ConversionError{Value: enriched, Type: "enriched result", Err: err}
+	}
+	return normalizeNumbers(converted), nil
+}
+
+// forwardOldest waits for the oldest pending result and passes it on
+func (s *enrichStage) forwardOldest(next func(interface{}) error) error {
+	e := s.pending[0]
+	s.pending = s.pending[1:]
+	<-e.done
+	return s.forward(e, next)
+}
+
+func (s *enrichStage) forward(e *enrichment, next func(interface{}) error) error {
+	if e.err != nil {
+		if ctxErr := s.ex.contextError(e.err); ctxErr != nil {
+			return ctxErr
+		}
+		return e.err
+	}
+	return next(e.value)
+}
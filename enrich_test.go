@@ -0,0 +1,80 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithEnricher(t *testing.T) {
+	owners := map[int]string{1: "alice", 2: "bob", 3: "carol", 4: "dave"}
+
+	for _, concurrency := range []int{1, 3} {
+		var running, maxRunning int32
+		enrich := func(ctx context.Context, v interface{}) (interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			id := v.(int)
+			// Later results finish first to check that the order is kept
+			time.Sleep(time.Duration(5-id) * time.Millisecond)
+			return map[string]interface{}{"id": id, "owner": owners[id]}, nil
+		}
+
+		p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, []int{1, 2, 3, 4}, jqyaml.WithEnricher(enrich, concurrency))
+		want := []interface{}{
+			map[string]interface{}{"id": 1, "owner": "alice"},
+			map[string]interface{}{"id": 2, "owner": "bob"},
+			map[string]interface{}{"id": 3, "owner": "carol"},
+			map[string]interface{}{"id": 4, "owner": "dave"},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("concurrency %d: results mismatch (-want +got):\n%s", concurrency, diff)
+		}
+		if m := atomic.LoadInt32(&maxRunning); int(m) > concurrency {
+			t.Errorf("concurrency %d: %d enrichers ran at once", concurrency, m)
+		}
+	}
+}
+
+func TestWithEnricherError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errLookup := errors.New("lookup failed")
+	err = p.Execute(context.Background(), []int{1, 2, 3},
+		jqyaml.WithEnricher(func(_ context.Context, v interface{}) (interface{}, error) {
+			if v == 2 {
+				return nil, errLookup
+			}
+			return v, nil
+		}, 2),
+		jqyaml.WithCallback(func(interface{}) error { return nil }),
+	)
+	if !errors.Is(err, errLookup) {
+		t.Errorf("expected enricher error, got %v", err)
+	}
+
+	err = p.Execute(context.Background(), []int{1},
+		jqyaml.WithEnricher(func(_ context.Context, v interface{}) (interface{}, error) { return v, nil }, 0),
+		jqyaml.WithCallback(func(interface{}) error { return nil }),
+	)
+	if err == nil {
+		t.Error("expected error for zero concurrency")
+	}
+}
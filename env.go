@@ -0,0 +1,36 @@
+package jqyaml
+
+import (
+	"os"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// envDefs define env as $ENV, so that both see the converted environment
+// rather than gojq's own snapshot
+var envDefs = mustParseFuncDefs(`def env: $ENV;`)
+
+// withEnvDefs returns a copy of parsed with envDefs prepended
+func withEnvDefs(parsed *gojq.Query) *gojq.Query {
+	q := *parsed
+	q.FuncDefs = append(append([]*gojq.FuncDef{}, envDefs...), parsed.FuncDefs...)
+	return &q
+}
+
+// withEnvVariable returns vars with $ENV bound to a snapshot of the process
+// environment
+func withEnvVariable(vars map[string]interface{}) map[string]interface{} {
+	env := make(map[string]interface{})
+	for _, kv := range os.Environ() {
+		// Windows lists per-drive directories as "=C:=C:\dir"
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	merged := map[string]interface{}{"ENV": env}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,45 @@
+package jqyaml_test
+
+import (
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnvAccess(t *testing.T) {
+	t.Setenv("JQYAML_TEST_HOME", "/home/test")
+
+	t.Run("enabled", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`[$ENV.JQYAML_TEST_HOME, env.JQYAML_TEST_HOME]`), jqyaml.WithEnvAccess())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, nil)
+		if diff := cmp.Diff([]interface{}{[]interface{}{"/home/test", "/home/test"}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("variables take precedence", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`[$ENV.JQYAML_TEST_HOME, env.JQYAML_TEST_HOME]`), jqyaml.WithEnvAccess())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, nil, jqyaml.WithVariables(map[string]interface{}{"ENV": map[string]interface{}{"JQYAML_TEST_HOME": "/override"}}))
+		if diff := cmp.Diff([]interface{}{[]interface{}{"/override", "/override"}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`[$ENV.JQYAML_TEST_HOME, env.JQYAML_TEST_HOME]`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, nil)
+		if diff := cmp.Diff([]interface{}{[]interface{}{nil, nil}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
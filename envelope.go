@@ -0,0 +1,82 @@
+package jqyaml
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+// Signer signs the envelopes of WithSignedResults, e.g. with a key held by
+// a KMS or an HSM
+type Signer interface {
+	// KeyID identifies the key to verifiers; it may be empty
+	KeyID() string
+	// Sign returns the signature of message
+	Sign(ctx context.Context, message []byte) ([]byte, error)
+}
+
+// DefaultPayloadType is the payload type of envelopes when none is given
+const DefaultPayloadType = "application/json"
+
+// EnvelopeMessage returns the bytes a Signer signs for an envelope: the
+// DSSE pre-authentication encoding of the payload type and the payload
+func EnvelopeMessage(payloadType string, payload []byte) []byte {
+	b := []byte("DSSEv1 ")
+	b = strconv.AppendInt(b, int64(len(payloadType)), 10)
+	b = append(b, ' ')
+	b = append(b, payloadType...)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(len(payload)), 10)
+	b = append(b, ' ')
+	return append(b, payload...)
+}
+
+// signStage replaces each result with a signed envelope of it
+type signStage struct {
+	ex          *execution
+	signer      Signer
+	payloadType string
+}
+
+func newSignStage(signer Signer, payloadType string) stageSpec {
+	return stageSpec{
+		name: "sign",
+		build: func(ex *execution) (resultStage, error) {
+			if signer == nil {
+				return nil, fmt.Errorf("signer must not be nil")
+			}
+			if payloadType == "" {
+				payloadType = DefaultPayloadType
+			}
+			return &signStage{ex: ex, signer: signer, payloadType: payloadType}, nil
+		},
+	}
+}
+
+func (s *signStage) emit(v interface{}, next func(interface{}) error) error {
+	payload, err := appendCanonical(nil, v)
+	if err != nil {
+		return err
+	}
+	sig, err := s.signer.Sign(s.ex.ctx, EnvelopeMessage(s.payloadType, payload))
+	if err != nil {
+		if ctxErr := s.ex.contextError(err); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("failed to sign result: %w", err)
+	}
+	signature := map[string]interface{}{"sig": base64.StdEncoding.EncodeToString(sig)}
+	if keyID := s.signer.KeyID(); keyID != "" {
+		signature["keyid"] = keyID
+	}
+	return next(map[string]interface{}{
+		"payloadType": s.payloadType,
+		"payload":     base64.StdEncoding.EncodeToString(payload),
+		"signatures":  []interface{}{signature},
+	})
+}
+
+func (s *signStage) flush(next func(interface{}) error) error {
+	return nil
+}
@@ -0,0 +1,106 @@
+package jqyaml_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+	id  string
+}
+
+func (s ed25519Signer) KeyID() string { return s.id }
+
+func (s ed25519Signer) Sign(_ context.Context, message []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, message), nil
+}
+
+type failingSigner struct{}
+
+func (failingSigner) KeyID() string { return "" }
+
+func (failingSigner) Sign(context.Context, []byte) ([]byte, error) {
+	return nil, errors.New("key revoked")
+}
+
+func TestSignedResults(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{map[string]interface{}{"b": 1, "a": "x"}, 2}
+
+	t.Run("envelopes", func(t *testing.T) {
+		got := collect(t, p, input, jqyaml.WithSignedResults(ed25519Signer{key: key, id: "release"}, "application/vnd.in-toto+json"))
+		wantPayloads := []string{`{"a":"x","b":1}`, `2`}
+		if len(got) != len(wantPayloads) {
+			t.Fatalf("got %d results, want %d", len(got), len(wantPayloads))
+		}
+		for i, v := range got {
+			env := v.(map[string]interface{})
+			payload, err := base64.StdEncoding.DecodeString(env["payload"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(wantPayloads[i], string(payload)); diff != "" {
+				t.Errorf("payload mismatch (-want +got):\n%s", diff)
+			}
+			if env["payloadType"] != "application/vnd.in-toto+json" {
+				t.Errorf("got payload type %v", env["payloadType"])
+			}
+			signature := env["signatures"].([]interface{})[0].(map[string]interface{})
+			if signature["keyid"] != "release" {
+				t.Errorf("got key id %v", signature["keyid"])
+			}
+			sig, err := base64.StdEncoding.DecodeString(signature["sig"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ed25519.Verify(pub, jqyaml.EnvelopeMessage(env["payloadType"].(string), payload), sig) {
+				t.Errorf("signature of result %d does not verify", i)
+			}
+		}
+	})
+
+	t.Run("default payload type", func(t *testing.T) {
+		got := collect(t, p, input, jqyaml.WithSignedResults(ed25519Signer{key: key}, ""))
+		env := got[0].(map[string]interface{})
+		if env["payloadType"] != jqyaml.DefaultPayloadType {
+			t.Errorf("got payload type %v", env["payloadType"])
+		}
+		if _, ok := env["signatures"].([]interface{})[0].(map[string]interface{})["keyid"]; ok {
+			t.Error("want no key id for an empty KeyID")
+		}
+	})
+
+	t.Run("message", func(t *testing.T) {
+		got := string(jqyaml.EnvelopeMessage("http://example.com/HelloWorld", []byte("hello world")))
+		if want := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("signer errors", func(t *testing.T) {
+		err := p.Execute(context.Background(), input, jqyaml.WithSignedResults(failingSigner{}, ""),
+			jqyaml.WithCallback(func(interface{}) error { return nil }))
+		if err == nil || err.Error() != "failed to sign result: key revoked" {
+			t.Errorf("got %v", err)
+		}
+		err = p.Execute(context.Background(), input, jqyaml.WithSignedResults(nil, ""),
+			jqyaml.WithCallback(func(interface{}) error { return nil }))
+		if err == nil {
+			t.Error("expected an error for a nil signer")
+		}
+	})
+}
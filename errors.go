@@ -1,6 +1,8 @@
 package jqyaml
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -35,6 +37,18 @@ func (e *ConversionError) Unwrap() error {
 	return e.Err
 }
 
+// InvalidValueError reports a value that gojq cannot handle, such as a func,
+// a channel, a complex number or a typed slice, returned by a custom input
+// marshaler. Path holds the object keys and array indices leading to it.
+type InvalidValueError struct {
+	Path  []interface{}
+	Value interface{}
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("unsupported type %T at %s", e.Value, formatPath(e.Path))
+}
+
 // TimeoutError represents execution timeout
 type TimeoutError struct {
 	Duration time.Duration
@@ -43,3 +57,124 @@ type TimeoutError struct {
 func (e *TimeoutError) Error() string {
 	return fmt.Sprintf("execution timeout after %s", e.Duration)
 }
+
+// Unwrap returns context.DeadlineExceeded, so that errors.Is recognizes timeouts
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// CPULimitError represents query evaluation exceeding the WithMaxCPU budget
+type CPULimitError struct {
+	Limit time.Duration
+}
+
+func (e *CPULimitError) Error() string {
+	return fmt.Sprintf("query evaluation exceeded CPU budget of %s", e.Limit)
+}
+
+// WriteError represents a failure of the output writer
+type WriteError struct {
+	BytesWritten int64
+	Err          error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("failed to write output after %d bytes: %v", e.BytesWritten, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// ResultSizeError represents a result exceeding the configured size limit
+type ResultSizeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *ResultSizeError) Error() string {
+	return fmt.Sprintf("result size %d bytes exceeds limit of %d bytes", e.Size, e.Limit)
+}
+
+// InvalidUTF8Error represents a result string that is not valid UTF-8
+type InvalidUTF8Error struct {
+	Offset int // Byte offset of the first invalid sequence in the string
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("result contains invalid UTF-8 at byte offset %d", e.Offset)
+}
+
+// DecodeError represents a failure to decode an input document
+type DecodeError struct {
+	Format   Format
+	Document int      // 1-based index of the document in the input stream
+	Position Position // Location of the document or syntax error; zero if unknown
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Position.Line > 0 {
+		return fmt.Sprintf("failed to decode %s input document %d at %s: %v", e.Format, e.Document, e.Position, e.Err)
+	}
+	return fmt.Sprintf("failed to decode %s input document %d: %v", e.Format, e.Document, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// InputError annotates an error raised while processing a document read by
+// ExecuteReader with the location of the document
+type InputError struct {
+	Document int      // 1-based index of the document in the input stream
+	Position Position // Start of the document; zero if unknown
+	Err      error
+}
+
+func (e *InputError) Error() string {
+	if e.Position.Line > 0 {
+		return fmt.Sprintf("input document %d at %s: %v", e.Document, e.Position, e.Err)
+	}
+	return fmt.Sprintf("input document %d: %v", e.Document, e.Err)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// RegexLimitError represents a regular expression builtin call exceeding WithRegexLimits
+type RegexLimitError struct {
+	Kind  string // "pattern" or "input"
+	Size  int
+	Limit int
+}
+
+func (e *RegexLimitError) Error() string {
+	return fmt.Sprintf("regular expression %s of %d bytes exceeds limit of %d bytes", e.Kind, e.Size, e.Limit)
+}
+
+// Errors wrapped by the EvaluateBool, EvaluateString and EvaluateNumber
+// errors for a single result of the wrong type
+var (
+	ErrNotABool   = errors.New("query result is not a boolean")
+	ErrNotAString = errors.New("query result is not a string")
+	ErrNotANumber = errors.New("query result is not a number")
+)
+
+// ExternalJQError represents a failure of the jq command of WithExternalJQ
+type ExternalJQError struct {
+	Err    error  // Typically an *exec.ExitError
+	Stderr string // What the command wrote to stderr
+}
+
+func (e *ExternalJQError) Error() string {
+	if e.Stderr == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %s", e.Err, e.Stderr)
+}
+
+func (e *ExternalJQError) Unwrap() error {
+	return e.Err
+}
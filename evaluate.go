@@ -0,0 +1,93 @@
+package jqyaml
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/itchyny/gojq"
+)
+
+// EvaluateBool runs the query as a predicate, such as `.user.beta and .region == "eu"`,
+// and returns its result. The query must produce exactly one boolean; any
+// other result is an error, so that a typo cannot pass as false. Output
+// options and result stages are ignored.
+func (p *pipeline) EvaluateBool(ctx context.Context, input interface{}, opts ...ExecuteOption) (bool, error) {
+	v, err := p.evaluate(ctx, input, opts)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: got %s", ErrNotABool, gojq.TypeOf(v))
+	}
+	return b, nil
+}
+
+// EvaluateString returns the single string the query produces, such as a
+// token or an id extracted from an API response. Other results are an error
+// wrapping ErrNotAString; see EvaluateBool.
+func (p *pipeline) EvaluateString(ctx context.Context, input interface{}, opts ...ExecuteOption) (string, error) {
+	v, err := p.evaluate(ctx, input, opts)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: got %s", ErrNotAString, gojq.TypeOf(v))
+	}
+	return s, nil
+}
+
+// EvaluateNumber returns the single number the query produces, such as a
+// count. Integers beyond the precision of float64 are rounded. Other results
+// are an error wrapping ErrNotANumber; see EvaluateBool.
+func (p *pipeline) EvaluateNumber(ctx context.Context, input interface{}, opts ...ExecuteOption) (float64, error) {
+	v, err := p.evaluate(ctx, input, opts)
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, nil
+	default:
+		return 0, fmt.Errorf("%w: got %s", ErrNotANumber, gojq.TypeOf(v))
+	}
+}
+
+// evaluate runs the query and returns its only result
+func (p *pipeline) evaluate(ctx context.Context, input interface{}, opts []ExecuteOption) (interface{}, error) {
+	if p.query == "" {
+		return nil, fmt.Errorf("no query specified")
+	}
+	cfg := p.newExecuteConfig(opts...)
+	defer closeChannel(cfg.channel)
+	cfg.writer, cfg.encoder, cfg.decodeTarget, cfg.batch, cfg.stages, cfg.channel = nil, nil, nil, nil, nil, nil
+	var result interface{}
+	n := 0
+	cfg.callback = func(v interface{}) error {
+		n++
+		if n > 1 {
+			// Stop at the second result
+			return fmt.Errorf("query must produce exactly one result, got more than one")
+		}
+		result = v
+		return nil
+	}
+	body, err := p.inputBody(cfg, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.run(ctx, cfg, body); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("query must produce exactly one result, got none")
+	}
+	return result, nil
+}
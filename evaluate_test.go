@@ -0,0 +1,120 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestEvaluateBool(t *testing.T) {
+	input := map[string]interface{}{"user": map[string]interface{}{"beta": true, "region": "eu"}}
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr string
+	}{
+		{query: `.user.beta and .user.region == "eu"`, want: true},
+		{query: `.user.region == "us"`, want: false},
+		{query: `.user.beta`, want: true},
+		{query: `.user.missing`, wantErr: "got null"},
+		{query: `.user.region`, wantErr: "got string"},
+		{query: `empty`, wantErr: "got none"},
+		{query: `true, false`, wantErr: "more than one"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.EvaluateBool(context.Background(), input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateBoolVariables(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`.plan as $p | $allowed | index($p) != null`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.EvaluateBool(context.Background(), map[string]interface{}{"plan": "pro"},
+		jqyaml.WithVariables(map[string]interface{}{"allowed": []string{"pro", "enterprise"}}))
+	if err != nil || !got {
+		t.Errorf("got %v, %v; want true", got, err)
+	}
+
+	p, err = jqyaml.New(jqyaml.WithQuery(".plan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.EvaluateBool(context.Background(), map[string]interface{}{"plan": "pro"}); !errors.Is(err, jqyaml.ErrNotABool) {
+		t.Errorf("got %v, want ErrNotABool", err)
+	}
+}
+
+func TestEvaluateString(t *testing.T) {
+	input := map[string]interface{}{"auth": map[string]interface{}{"token": "abc", "expires": 3600}}
+	p, err := jqyaml.New(jqyaml.WithQuery(".auth.token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.EvaluateString(context.Background(), input)
+	if err != nil || got != "abc" {
+		t.Errorf("got %q, %v; want abc", got, err)
+	}
+
+	p, err = jqyaml.New(jqyaml.WithQuery(".auth.expires"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.EvaluateString(context.Background(), input); !errors.Is(err, jqyaml.ErrNotAString) {
+		t.Errorf("got %v, want ErrNotAString", err)
+	}
+}
+
+func TestEvaluateNumber(t *testing.T) {
+	input := map[string]interface{}{"items": []interface{}{1, 2, 3}, "ratio": 0.5, "name": "x"}
+	tests := []struct {
+		query string
+		want  float64
+	}{
+		{query: ".items | length", want: 3},
+		{query: ".ratio", want: 0.5},
+		{query: "pow(2; 64) | floor", want: 18446744073709551616},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.EvaluateNumber(context.Background(), input)
+			if err != nil || got != tt.want {
+				t.Errorf("got %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery(".name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.EvaluateNumber(context.Background(), input); !errors.Is(err, jqyaml.ErrNotANumber) {
+		t.Errorf("got %v, want ErrNotANumber", err)
+	}
+}
@@ -49,16 +49,14 @@ func main() {
 	}
 
 	count := 0
-	encoder := yamlformat.NewEncoder(os.Stdout,
+	encoder := jqyaml.NewStreamingYAMLEncoder(os.Stdout,
 		yaml.Indent(2),
 	)
 
 	err = p1.Execute(context.Background(), data,
 		jqyaml.WithCallback(func(item interface{}) error {
 			if count < 5 { // Only show first 5 items
-				if count > 0 {
-					fmt.Println("---") // YAML document separator
-				}
+				// The encoder separates documents with "---"
 				if err := encoder.Encode(item); err != nil {
 					return err
 				}
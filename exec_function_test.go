@@ -0,0 +1,73 @@
+package jqyaml_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+	"github.com/itchyny/gojq"
+)
+
+func TestWithExecFunction(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`.ids[] | {id: ., name: name_of(.)}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each request resolves names through its own tenant-scoped lookup
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(tenant int) {
+			defer wg.Done()
+			nameOf := func(_ interface{}, args []interface{}) interface{} {
+				return fmt.Sprintf("tenant%d-user%v", tenant, args[0])
+			}
+			var got []interface{}
+			err := p.Execute(context.Background(), map[string]interface{}{"ids": []int{1, 2}},
+				jqyaml.WithExecFunction("name_of", 1, 1, nameOf),
+				jqyaml.WithCallback(func(v interface{}) error {
+					got = append(got, v)
+					return nil
+				}),
+			)
+			if err != nil {
+				t.Errorf("tenant %d: %v", tenant, err)
+				return
+			}
+			want := []interface{}{
+				map[string]interface{}{"id": 1, "name": fmt.Sprintf("tenant%d-user1", tenant)},
+				map[string]interface{}{"id": 2, "name": fmt.Sprintf("tenant%d-user2", tenant)},
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("tenant %d mismatch (-want +got):\n%s", tenant, diff)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The function is not defined without the option
+	err = p.Execute(context.Background(), map[string]interface{}{"ids": []int{1}},
+		jqyaml.WithCallback(func(interface{}) error { return nil }),
+	)
+	if err == nil {
+		t.Error("expected compile error without WithExecFunction")
+	}
+}
+
+func TestWithExecIterFunction(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`[members]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := func(interface{}, []interface{}) gojq.Iter {
+		return gojq.NewIter("alice", "bob")
+	}
+	got := collect(t, p, nil, jqyaml.WithExecIterFunction("members", 0, 0, members))
+	if diff := cmp.Diff([]interface{}{[]interface{}{"alice", "bob"}}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
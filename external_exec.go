@@ -0,0 +1,133 @@
+//go:build !js && !wasip1
+
+package jqyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// runExternal runs the query on data with the jq command of WithExternalJQ.
+// The variables and then data are passed as JSON on stdin rather than as
+// arguments, which other processes can read, e.g. with ps.
+func (ex *execution) runExternal(data interface{}) gojq.Iter {
+	p := ex.pipeline
+	if len(p.compilerOptions) > 0 || len(ex.compilerOptions) > 0 || p.regexLimits != nil {
+		return &errorIter{err: errors.New("functions registered with the pipeline are not available to an external jq")}
+	}
+	args, vars, err := ex.externalArgs()
+	if err != nil {
+		return &errorIter{err: err}
+	}
+	input, err := json.Marshal(data)
+	if err != nil {
+		return &errorIter{err: fmt.Errorf("failed to encode input for external jq: %w", err)}
+	}
+	stdin := append(append(vars, '\n'), input...)
+
+	cmd := exec.CommandContext(ex.ctx, p.externalJQ.path, args...)
+	if !p.envAccess {
+		// Untrusted filters get an empty environment
+		cmd.Env = []string{}
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	hooks := p.externalJQHooks
+	if hooks.BeforeStart != nil {
+		if err := hooks.BeforeStart(ex.ctx, cmd); err != nil {
+			return &errorIter{err: fmt.Errorf("external jq hook: %w", err)}
+		}
+	}
+	err = cmd.Run()
+	if err != nil {
+		if ctxErr := ex.contextError(err); ctxErr != nil {
+			err = ctxErr
+		} else {
+			msg := strings.TrimSpace(stderr.String())
+			if len(ex.cfg.secrets) > 0 {
+				msg = ex.secretReplacer().Replace(msg)
+			}
+			err = &QueryError{
+				Query:   p.query,
+				Message: "external jq failed",
+				Err:     &ExternalJQError{Err: err, Stderr: msg},
+			}
+		}
+	}
+	if hooks.AfterExit != nil {
+		if hookErr := hooks.AfterExit(ex.ctx, cmd); hookErr != nil && err == nil {
+			err = fmt.Errorf("external jq hook: %w", hookErr)
+		}
+	}
+	if err != nil {
+		return &errorIter{err: err}
+	}
+	dec := json.NewDecoder(&stdout)
+	dec.UseNumber()
+	return &externalIter{dec: dec}
+}
+
+// externalArgs returns the arguments of the jq command and the variables to
+// write to its stdin. The arguments are those of WithExternalJQ and the query,
+// wrapped to bind the variables, $ARGS included, from the first input and to
+// run on the second.
+func (ex *execution) externalArgs() ([]string, []byte, error) {
+	p := ex.pipeline
+	args := append([]string{}, p.externalJQ.args...)
+	args = append(args, "-c", "-n")
+	names := make([]string, 0, len(ex.variables))
+	for name := range ex.variables {
+		// jq defines $ENV itself
+		if name != "ENV" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	vars := make(map[string]interface{}, len(names))
+	var query strings.Builder
+	// $ARGS is always bound, so the object pattern is never empty
+	query.WriteString("input as {")
+	for i, name := range names {
+		if !variableNamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("invalid variable name %q for external jq", name)
+		}
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "%q: $%s", name, name)
+		vars[name] = ex.variables[name]
+	}
+	// The newlines keep a trailing comment in the query from hiding the parenthesis
+	query.WriteString("} | input | (\n" + p.query + "\n)")
+	b, err := json.Marshal(vars)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode variables for external jq: %w", err)
+	}
+	return append(args, query.String()), b, nil
+}
+
+// externalIter yields the results written by the jq command
+type externalIter struct {
+	dec *json.Decoder
+}
+
+func (it *externalIter) Next() (interface{}, bool) {
+	var v interface{}
+	if err := it.dec.Decode(&v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, false
+		}
+		return fmt.Errorf("failed to decode external jq output: %w", err), true
+	}
+	return normalizeNumbers(v), true
+}
@@ -0,0 +1,168 @@
+//go:build !js && !wasip1
+
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExternalJQ(t *testing.T) {
+	path, err := exec.LookPath("jq")
+	if err != nil {
+		t.Skip("jq is not installed")
+	}
+	t.Setenv("JQYAML_TEST_SECRET", "s3cr3t")
+
+	tests := []struct {
+		name  string
+		query string
+		input interface{}
+		opts  []jqyaml.ExecuteOption
+		want  []interface{}
+	}{
+		{
+			name:  "input and variables",
+			query: `.items[] | {name, env: $env}`,
+			input: map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}}},
+			opts:  []jqyaml.ExecuteOption{jqyaml.WithVariables(map[string]interface{}{"env": "prod"})},
+			want: []interface{}{
+				map[string]interface{}{"name": "a", "env": "prod"},
+				map[string]interface{}{"name": "b", "env": "prod"},
+			},
+		},
+		{
+			name:  "positional arguments",
+			query: `$ARGS.positional`,
+			opts:  []jqyaml.ExecuteOption{jqyaml.WithPositionalArgs("x", 1)},
+			want:  []interface{}{[]interface{}{"x", 1}},
+		},
+		{
+			name:  "query starting with a minus",
+			query: `-1`,
+			want:  []interface{}{-1},
+		},
+		{
+			name:  "empty environment",
+			query: `$ENV.JQYAML_TEST_SECRET`,
+			want:  []interface{}{nil},
+		},
+		{
+			name:  "numbers",
+			query: `[., 1.5]`,
+			input: 3,
+			want:  []interface{}{[]interface{}{3, 1.5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query), jqyaml.WithExternalJQ(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(t, p, tt.input, tt.opts...)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(`error("boom")`), jqyaml.WithExternalJQ(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Execute(context.Background(), nil, jqyaml.WithCallback(func(interface{}) error { return nil }))
+		var jqErr *jqyaml.ExternalJQError
+		if !errors.As(err, &jqErr) || !strings.Contains(jqErr.Stderr, "boom") {
+			t.Errorf("got %v, want an ExternalJQError reporting boom", err)
+		}
+		if _, err := jqyaml.New(jqyaml.WithExternalJQ("")); err == nil {
+			t.Error("expected an error for an empty path")
+		}
+	})
+}
+
+func TestExternalJQSecrets(t *testing.T) {
+	path, err := exec.LookPath("jq")
+	if err != nil {
+		t.Skip("jq is not installed")
+	}
+	var args []string
+	hooks := jqyaml.ExternalJQHooks{BeforeStart: func(_ context.Context, cmd *exec.Cmd) error {
+		args = cmd.Args
+		return nil
+	}}
+	p, err := jqyaml.New(jqyaml.WithQuery(`if . then $token else error("bad token " + $token) end`),
+		jqyaml.WithExternalJQ(path), jqyaml.WithExternalJQHooks(hooks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := jqyaml.WithSecretVariables(map[string]string{"token": "s3cr3t"})
+
+	got := collect(t, p, true, secrets)
+	if diff := cmp.Diff([]interface{}{"s3cr3t"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	// Variables reach jq on stdin, not in its arguments
+	if strings.Contains(strings.Join(args, " "), "s3cr3t") {
+		t.Errorf("secret in the arguments %q", args)
+	}
+
+	err = p.Execute(context.Background(), false, secrets, jqyaml.WithCallback(func(interface{}) error { return nil }))
+	var jqErr *jqyaml.ExternalJQError
+	if !errors.As(err, &jqErr) || !strings.Contains(jqErr.Stderr, "bad token [REDACTED]") || strings.Contains(jqErr.Stderr, "s3cr3t") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestExternalJQHooks(t *testing.T) {
+	path, err := exec.LookPath("jq")
+	if err != nil {
+		t.Skip("jq is not installed")
+	}
+	var events []string
+	hooks := jqyaml.ExternalJQHooks{
+		BeforeStart: func(_ context.Context, cmd *exec.Cmd) error {
+			events = append(events, "start "+cmd.Args[len(cmd.Args)-1])
+			return nil
+		},
+		AfterExit: func(_ context.Context, cmd *exec.Cmd) error {
+			events = append(events, "exit "+cmd.ProcessState.String())
+			return nil
+		},
+	}
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"), jqyaml.WithExternalJQ(path), jqyaml.WithExternalJQHooks(hooks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, p, []interface{}{1, 2})
+	if diff := cmp.Diff([]interface{}{1, 2}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"start input as {\"ARGS\": $ARGS} | input | (\n.[]\n)", "exit exit status 0"}, events); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		errNoCgroup := errors.New("no cgroup")
+		denied := jqyaml.ExternalJQHooks{BeforeStart: func(context.Context, *exec.Cmd) error { return errNoCgroup }}
+		p, err := jqyaml.New(jqyaml.WithQuery("."), jqyaml.WithExternalJQ(path), jqyaml.WithExternalJQHooks(denied))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Execute(context.Background(), 1, jqyaml.WithCallback(func(interface{}) error { return nil }))
+		if !errors.Is(err, errNoCgroup) {
+			t.Errorf("got %v", err)
+		}
+		if _, err := jqyaml.New(jqyaml.WithExternalJQHooks(denied)); err == nil {
+			t.Error("expected an error without WithExternalJQ")
+		}
+	})
+}
@@ -0,0 +1,15 @@
+//go:build js || wasip1
+
+package jqyaml
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/itchyny/gojq"
+)
+
+// runExternal fails, as these platforms cannot start processes
+func (ex *execution) runExternal(interface{}) gojq.Iter {
+	return &errorIter{err: fmt.Errorf("WithExternalJQ is not supported on %s", runtime.GOOS)}
+}
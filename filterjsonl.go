@@ -0,0 +1,139 @@
+package jqyaml
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FilterJSONL runs query on each line of the JSON Lines stream src and writes
+// the results to dst as compact JSON, one per line. Blank lines are skipped.
+// Unlike ExecuteReader, lines are decoded straight into jq values without the
+// YAML decoder and the input marshaler round trip, which makes it the faster
+// choice for log filtering. Options are applied as for New; a custom
+// WithInputMarshaler still receives each decoded line. Invalid lines are
+// reported as DecodeError whose Document counts non-blank lines.
+func FilterJSONL(ctx context.Context, dst io.Writer, src io.Reader, query string, opts ...Option) error {
+	if query == "" {
+		query = "."
+	}
+	p, err := New(append([]Option{WithQuery(query)}, opts...)...)
+	if err != nil {
+		return err
+	}
+	pl := p.(*pipeline)
+	cfg := pl.newExecuteConfig(WithWriter(dst, FormatJSON), WithCompactJSONOutput())
+
+	dec := newJSONLDecoder(newInputReader(src, cfg.inputEncoding))
+	defer dec.release()
+	return pl.run(ctx, cfg, func(ex *execution) error {
+		return ex.processDocuments(dec, FormatJSON, ex.processJQValue)
+	})
+}
+
+// processJQValue runs the query on v, which is already a valid jq value
+func (ex *execution) processJQValue(v interface{}) error {
+	if ex.pipeline.inputMarshaler != nil {
+		return ex.process(v)
+	}
+	if ex.cacheable() {
+		return ex.cachedProcess(v)
+	}
+	return ex.streamingProcess(v)
+}
+
+var (
+	jsonlReaderPool = sync.Pool{New: func() interface{} { return bufio.NewReaderSize(nil, 64<<10) }}
+	jsonlLinePool   = sync.Pool{New: func() interface{} { return new([]byte) }}
+)
+
+// jsonlDecoder decodes one JSON value per line, using pooled buffers
+type jsonlDecoder struct {
+	r    *bufio.Reader
+	line *[]byte // Holds lines longer than the reader's buffer
+	n    int     // Number of lines read so far
+	pos  Position
+}
+
+func newJSONLDecoder(r io.Reader) *jsonlDecoder {
+	br := jsonlReaderPool.Get().(*bufio.Reader)
+	br.Reset(r)
+	return &jsonlDecoder{r: br, line: jsonlLinePool.Get().(*[]byte)}
+}
+
+// release returns the buffers to their pools
+func (d *jsonlDecoder) release() {
+	d.r.Reset(nil)
+	jsonlReaderPool.Put(d.r)
+	*d.line = (*d.line)[:0]
+	jsonlLinePool.Put(d.line)
+}
+
+func (d *jsonlDecoder) decode() (interface{}, error) {
+	for {
+		line, err := d.readLine()
+		if len(line) == 0 && err != nil {
+			d.pos = Position{}
+			return nil, err
+		}
+		d.n++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		d.pos = Position{Line: d.n, Column: 1}
+		v, err := decodeJSONLine(line)
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) && syntaxErr.Offset > 0 {
+				d.pos.Column = int(syntaxErr.Offset)
+			}
+			return nil, &documentError{err: err}
+		}
+		return v, nil
+	}
+}
+
+// readLine returns the next line without its line ending. The line is only
+// valid until the next call.
+func (d *jsonlDecoder) readLine() ([]byte, error) {
+	line, err := d.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		buf := append((*d.line)[:0], line...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			line, err = d.r.ReadSlice('\n')
+			buf = append(buf, line...)
+		}
+		*d.line = buf
+		line = buf
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	return line, err
+}
+
+func (d *jsonlDecoder) position() Position {
+	return d.pos
+}
+
+// decodeJSONLine decodes the single JSON value of line, keeping numbers as
+// json.Number so that gojq preserves large integers
+func decodeJSONLine(line []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected data after the JSON value at offset %d", dec.InputOffset())
+	}
+	return v, nil
+}
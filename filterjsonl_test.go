@@ -0,0 +1,138 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestFilterJSONL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		query string
+		opts  []jqyaml.Option
+		want  string
+	}{
+		{
+			name:  "select",
+			input: "{\"level\":\"info\",\"msg\":\"a\"}\n{\"level\":\"error\",\"msg\":\"b\"}\n",
+			query: `select(.level == "error") | .msg`,
+			want:  "\"b\"\n",
+		},
+		{
+			name:  "blank lines, CRLF and no final newline",
+			input: "1\r\n\n  \n2",
+			query: ". * 10",
+			want:  "10\n20\n",
+		},
+		{
+			name:  "large integers keep their precision",
+			input: `{"id":12345678901234567890}`,
+			query: ".id",
+			want:  "12345678901234567890\n",
+		},
+		{
+			name:  "empty query",
+			input: "{\"b\":1,\"a\":[1, 2]}\n",
+			want:  "{\"a\":[1,2],\"b\":1}\n",
+		},
+		{
+			name:  "variables",
+			input: "1\n5\n",
+			query: "select(. > $min)",
+			opts:  []jqyaml.Option{jqyaml.WithDefaultExecuteOptions(jqyaml.WithVariables(map[string]interface{}{"min": 2}))},
+			want:  "5\n",
+		},
+		{
+			name:  "long lines",
+			input: fmt.Sprintf("%q\n", strings.Repeat("x", 100000)),
+			query: "length",
+			want:  "100000\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := jqyaml.FilterJSONL(context.Background(), &buf, strings.NewReader(tt.input), tt.query, tt.opts...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterJSONLInvalidLine(t *testing.T) {
+	input := "1\n\n{\"a\":\n2 3\n"
+
+	err := jqyaml.FilterJSONL(context.Background(), &bytes.Buffer{}, strings.NewReader(input), ".")
+	var decodeErr *jqyaml.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected DecodeError, got %T: %v", err, err)
+	}
+	if decodeErr.Document != 2 || decodeErr.Position.Line != 3 {
+		t.Errorf("got document %d at %v, want document 2 at line 3", decodeErr.Document, decodeErr.Position)
+	}
+
+	var buf bytes.Buffer
+	err = jqyaml.FilterJSONL(context.Background(), &buf, strings.NewReader(input), ".",
+		jqyaml.WithDefaultExecuteOptions(jqyaml.WithCollectErrors(0)))
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Fatalf("expected 2 collected errors, got %v", err)
+	}
+	if got := buf.String(); got != "1\n" {
+		t.Errorf("got %q, want the valid line", got)
+	}
+}
+
+func benchmarkLog(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		level := "info"
+		if i%10 == 0 {
+			level = "error"
+		}
+		fmt.Fprintf(&sb, "{\"ts\":%d,\"level\":%q,\"msg\":\"request handled\",\"latency_ms\":%d,\"path\":\"/api/v1/items\"}\n", 1700000000+i, level, i%250)
+	}
+	return sb.String()
+}
+
+const benchmarkLogQuery = `select(.level == "error") | {ts, latency_ms}`
+
+func BenchmarkFilterJSONL(b *testing.B) {
+	input := benchmarkLog(1000)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := jqyaml.FilterJSONL(context.Background(), io.Discard, strings.NewReader(input), benchmarkLogQuery); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFilterJSONLExecuteReader filters the same log with ExecuteReader
+func BenchmarkFilterJSONLExecuteReader(b *testing.B) {
+	input := benchmarkLog(1000)
+	p, err := jqyaml.New(jqyaml.WithQuery(benchmarkLogQuery))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := p.ExecuteReader(context.Background(), strings.NewReader(input), jqyaml.FormatJSON,
+			jqyaml.WithWriter(io.Discard, jqyaml.FormatJSON), jqyaml.WithCompactJSONOutput())
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
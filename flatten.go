@@ -0,0 +1,168 @@
+package jqyaml
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// DefaultFlattenSeparator is the separator of the flatten_keys and
+// unflatten_keys jq functions when none is given
+const DefaultFlattenSeparator = "."
+
+// Flatten converts the nested objects and arrays of v, as produced by
+// json.Unmarshal or a query, to a single object whose keys join the path of
+// each leaf with sep, e.g. {"a": {"b": [1]}} to {"a.b.0": 1}, as in properties
+// files and metrics labels. Empty objects and arrays are kept as leaves.
+func Flatten(v interface{}, sep string) (map[string]interface{}, error) {
+	if sep == "" {
+		return nil, errors.New("flatten separator must not be empty")
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return nil, fmt.Errorf("cannot flatten %T, only objects and arrays", v)
+	}
+	flat := make(map[string]interface{})
+	flattenInto(flat, "", v, sep)
+	return flat, nil
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, v interface{}, sep string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + sep + key
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			flat[prefix] = v
+		}
+		for k, e := range v {
+			flattenInto(flat, join(k), e, sep)
+		}
+	case []interface{}:
+		if len(v) == 0 && prefix != "" {
+			flat[prefix] = v
+		}
+		for i, e := range v {
+			flattenInto(flat, join(strconv.Itoa(i)), e, sep)
+		}
+	default:
+		flat[prefix] = v
+	}
+}
+
+// Unflatten reverses Flatten, splitting each key of flat at sep into a path.
+// Objects whose keys are exactly 0 to n-1 become arrays. Keys whose paths
+// conflict, such as "a" and "a.b", are an error.
+func Unflatten(flat map[string]interface{}, sep string) (interface{}, error) {
+	if sep == "" {
+		return nil, errors.New("flatten separator must not be empty")
+	}
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	// Sorting puts "a" before "a.b", so conflicts are reported deterministically
+	sort.Strings(keys)
+
+	root := make(map[string]interface{})
+	for _, key := range keys {
+		path := strings.Split(key, sep)
+		obj := root
+		for i, segment := range path[:len(path)-1] {
+			child, ok := obj[segment]
+			if !ok {
+				next := make(map[string]interface{})
+				obj[segment] = next
+				obj = next
+				continue
+			}
+			next, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %q conflicts with %q", key, strings.Join(path[:i+1], sep))
+			}
+			obj = next
+		}
+		last := path[len(path)-1]
+		if _, ok := obj[last]; ok {
+			return nil, fmt.Errorf("key %q conflicts with another key", key)
+		}
+		obj[last] = flat[key]
+	}
+	return arraysFromIndexes(root), nil
+}
+
+// arraysFromIndexes converts the objects built by Unflatten whose keys are
+// the indexes of an array back to arrays
+func arraysFromIndexes(v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for k, e := range obj {
+		obj[k] = arraysFromIndexes(e)
+	}
+	if len(obj) == 0 {
+		return obj
+	}
+	arr := make([]interface{}, len(obj))
+	for k, e := range obj {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(arr) || strconv.Itoa(i) != k {
+			return obj
+		}
+		arr[i] = e
+	}
+	return arr
+}
+
+// flattenFunctions returns the flatten_keys and unflatten_keys jq functions
+// enabled by WithFlattenFunctions
+func flattenFunctions() []gojq.CompilerOption {
+	separator := func(args []interface{}) (string, error) {
+		if len(args) == 0 {
+			return DefaultFlattenSeparator, nil
+		}
+		sep, ok := args[0].(string)
+		if !ok {
+			return "", fmt.Errorf("flatten separator must be a string: %v", args[0])
+		}
+		return sep, nil
+	}
+	return []gojq.CompilerOption{
+		gojq.WithFunction("flatten_keys", 0, 1, func(v interface{}, args []interface{}) interface{} {
+			sep, err := separator(args)
+			if err != nil {
+				return err
+			}
+			flat, err := Flatten(v, sep)
+			if err != nil {
+				return err
+			}
+			return flat
+		}),
+		gojq.WithFunction("unflatten_keys", 0, 1, func(v interface{}, args []interface{}) interface{} {
+			sep, err := separator(args)
+			if err != nil {
+				return err
+			}
+			flat, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("cannot unflatten %T, only objects", v)
+			}
+			nested, err := Unflatten(flat, sep)
+			if err != nil {
+				return err
+			}
+			return nested
+		}),
+	}
+}
@@ -0,0 +1,101 @@
+package jqyaml_test
+
+import (
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFlatten(t *testing.T) {
+	nested := map[string]interface{}{
+		"server": map[string]interface{}{"host": "localhost", "ports": []interface{}{80, 443}},
+		"empty":  map[string]interface{}{},
+		"none":   []interface{}{},
+		"debug":  true,
+	}
+	flat := map[string]interface{}{
+		"server.host":    "localhost",
+		"server.ports.0": 80,
+		"server.ports.1": 443,
+		"empty":          map[string]interface{}{},
+		"none":           []interface{}{},
+		"debug":          true,
+	}
+
+	got, err := jqyaml.Flatten(nested, ".")
+	if err != nil {
+		t.Fatalf("Flatten: %v", err)
+	}
+	if diff := cmp.Diff(flat, got); diff != "" {
+		t.Errorf("Flatten mismatch (-want +got):\n%s", diff)
+	}
+
+	back, err := jqyaml.Unflatten(got, ".")
+	if err != nil {
+		t.Fatalf("Unflatten: %v", err)
+	}
+	if diff := cmp.Diff(nested, back); diff != "" {
+		t.Errorf("Unflatten mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFlattenErrors(t *testing.T) {
+	if _, err := jqyaml.Flatten("scalar", "."); err == nil {
+		t.Error("expected an error flattening a scalar")
+	}
+	if _, err := jqyaml.Flatten(map[string]interface{}{}, ""); err == nil {
+		t.Error("expected an error for an empty separator")
+	}
+	if _, err := jqyaml.Unflatten(map[string]interface{}{"a": 1, "a.b": 2}, "."); err == nil {
+		t.Error("expected an error for conflicting keys")
+	}
+}
+
+func TestUnflattenSparseIndexes(t *testing.T) {
+	got, err := jqyaml.Unflatten(map[string]interface{}{"a.0": 1, "a.2": 2, "b.01": 3}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"a": map[string]interface{}{"0": 1, "2": 2},
+		"b": map[string]interface{}{"01": 3},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithFlattenFunctions(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []interface{}
+	}{
+		{query: "flatten_keys", want: []interface{}{map[string]interface{}{"labels.app": "web", "labels.tier": "1"}}},
+		{query: `flatten_keys("_")`, want: []interface{}{map[string]interface{}{"labels_app": "web", "labels_tier": "1"}}},
+		{query: "flatten_keys | unflatten_keys", want: []interface{}{map[string]interface{}{"labels": map[string]interface{}{"app": "web", "tier": "1"}}}},
+	}
+	input := map[string]interface{}{"labels": map[string]interface{}{"app": "web", "tier": "1"}}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query), jqyaml.WithFlattenFunctions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(t, p, input)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("unflatten_keys"), jqyaml.WithFlattenFunctions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Execute(context.Background(), []interface{}{1}, jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err == nil {
+		t.Error("expected an error unflattening an array")
+	}
+}
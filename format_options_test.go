@@ -3,10 +3,12 @@ package jqyaml_test
 import (
 	"bytes"
 	"context"
+	"strconv"
 	"strings"
 	"testing"
 
 	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -192,6 +194,71 @@ func TestRawOutput(t *testing.T) {
 	}
 }
 
+func TestOutputSeparator(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		format   jqyaml.Format
+		opts     []jqyaml.ExecuteOption
+		expected string
+	}{
+		{
+			name:     "record separator with raw strings",
+			query:    `"a", "b"`,
+			format:   jqyaml.FormatJSON,
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithRawJSONOutput(), jqyaml.WithOutputSeparator("\x1e")},
+			expected: "a\x1eb\x1e",
+		},
+		{
+			name:     "NUL separator with non-strings",
+			query:    `1, {"a": 2}, "c"`,
+			format:   jqyaml.FormatJSON,
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithRawJSONOutput(), jqyaml.WithOutputSeparator("\x00")},
+			expected: "1\x00{\"a\":2}\x00c\x00",
+		},
+		{
+			name:     "empty separator joins output",
+			query:    `"a", 1, "b"`,
+			format:   jqyaml.FormatJSON,
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithRawJSONOutput(), jqyaml.WithOutputSeparator("")},
+			expected: "a1b",
+		},
+		{
+			name:     "compact JSON",
+			query:    `{"a": 1}, [2]`,
+			format:   jqyaml.FormatJSON,
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithCompactJSONOutput(), jqyaml.WithOutputSeparator("\x1e")},
+			expected: "{\"a\":1}\x1e[2]\x1e",
+		},
+		{
+			name:     "ignored for YAML",
+			query:    `"a"`,
+			format:   jqyaml.FormatYAML,
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithOutputSeparator("\x1e")},
+			expected: "a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatalf("failed to create pipeline: %v", err)
+			}
+
+			var buf bytes.Buffer
+			opts := append([]jqyaml.ExecuteOption{jqyaml.WithWriter(&buf, tt.format)}, tt.opts...)
+			if err := p.Execute(context.Background(), nil, opts...); err != nil {
+				t.Fatalf("execution failed: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestCombinedCompactAndRawOutput(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -372,3 +439,111 @@ func TestFormatOptionsDocumentation(t *testing.T) {
 		}
 	})
 }
+
+func TestWithIndent(t *testing.T) {
+	input := map[string]interface{}{
+		"meta": map[string]interface{}{"id": 1},
+	}
+
+	tests := []struct {
+		name     string
+		format   jqyaml.Format
+		opts     []jqyaml.ExecuteOption
+		expected string
+	}{
+		{
+			name:   "json",
+			format: jqyaml.FormatJSON,
+			opts:   []jqyaml.ExecuteOption{jqyaml.WithIndent(4)},
+			expected: `{
+    "meta": {
+        "id": 1
+    }
+}
+`,
+		},
+		{
+			name:   "yaml",
+			format: jqyaml.FormatYAML,
+			opts:   []jqyaml.ExecuteOption{jqyaml.WithIndent(4)},
+			expected: `meta:
+    id: 1
+`,
+		},
+		{
+			name:     "zero means compact json",
+			format:   jqyaml.FormatJSON,
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithIndent(0)},
+			expected: `{"meta":{"id":1}}` + "\n",
+		},
+		{
+			name:     "compact after indent wins",
+			format:   jqyaml.FormatJSON,
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithIndent(4), jqyaml.WithCompactJSONOutput()},
+			expected: `{"meta":{"id":1}}` + "\n",
+		},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := append([]jqyaml.ExecuteOption{jqyaml.WithWriter(&buf, tt.format)}, tt.opts...)
+			if err := p.Execute(context.Background(), input, opts...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSONOutputEncodeOptions(t *testing.T) {
+	fixed := yaml.CustomMarshaler[float64](func(f float64) ([]byte, error) {
+		return []byte(strconv.FormatFloat(f, 'f', 2, 64)), nil
+	})
+	input := map[string]interface{}{"price": 1.5, "name": "a"}
+
+	tests := []struct {
+		name     string
+		opts     []jqyaml.ExecuteOption
+		expected string
+	}{
+		{
+			name:     "compact",
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithCompactJSONOutput()},
+			expected: `{"name":"a","price":1.50}` + "\n",
+		},
+		{
+			name:     "pretty",
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithPrettyJSONOutput()},
+			expected: "{\n  \"name\": \"a\",\n  \"price\": 1.50\n}\n",
+		},
+		{
+			name:     "raw",
+			opts:     []jqyaml.ExecuteOption{jqyaml.WithRawJSONOutput(), jqyaml.WithOutputSeparator(";")},
+			expected: `{"name":"a","price":1.50};`,
+		},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("."), jqyaml.WithDefaultEncodeOptions(fixed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := append([]jqyaml.ExecuteOption{jqyaml.WithWriter(&buf, jqyaml.FormatJSON)}, tt.opts...)
+			if err := p.Execute(context.Background(), input, opts...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Package htmlreport writes query results as HTML, registering the "html"
+// output format of jqyaml when imported:
+//
+//	import _ "github.com/apstndb/go-jq-yamlformat/htmlreport"
+//
+//	err := p.Execute(ctx, input, jqyaml.WithWriter(f, htmlreport.Format))
+//
+// In table mode each object result is a row of a table, and array results
+// contribute a row per element. In pre mode each result is pretty-printed as
+// JSON in a <pre> block. The output is a complete HTML document, or a
+// fragment to embed in another page or an email.
+package htmlreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// Format is the name the html format is registered under
+const Format jqyaml.Format = "html"
+
+// Mode selects how results are laid out
+type Mode string
+
+const (
+	// Table writes results as the rows of a table
+	Table Mode = "table"
+	// Pre writes each result as indented JSON in a <pre> block
+	Pre Mode = "pre"
+)
+
+// DefaultStyle is a small stylesheet for EncoderOptions.Style
+const DefaultStyle = `body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+td.number { text-align: right; }
+pre { background: #f8f8f8; border: 1px solid #ddd; padding: 8px; }`
+
+func init() {
+	jqyaml.RegisterOutputProvider(Format, Provider{})
+}
+
+// Provider implements jqyaml.OutputProvider. Registering a Provider with its
+// own Options under another name writes styled reports, fragments, or the
+// pre mode.
+type Provider struct {
+	Options EncoderOptions
+}
+
+// NewEncoder implements jqyaml.OutputProvider
+func (p Provider) NewEncoder(w io.Writer) (jqyaml.Encoder, error) {
+	return NewEncoder(w, p.Options)
+}
+
+// EncoderOptions configure an Encoder
+type EncoderOptions struct {
+	// Mode is Table if empty
+	Mode Mode
+	// Title is the title of the document and, if not empty, a heading
+	// before the results
+	Title string
+	// Style is embedded as a <style> element, such as DefaultStyle; none if
+	// empty
+	Style string
+	// Fragment writes only the results (and the style and heading) without
+	// the <html>, <head> and <body> elements
+	Fragment bool
+	// Columns are the header of the table, in order. If empty, the header
+	// lists the fields of all rows sorted by name. Fields that are not
+	// columns are left out.
+	Columns []string
+}
+
+// Encoder writes results as HTML. The document is finished by Close, which
+// must be called after the last result; in table mode nothing is written
+// before Close, as the header depends on all rows.
+type Encoder struct {
+	w       io.Writer
+	opts    EncoderOptions
+	rows    []map[string]interface{}
+	started bool
+	err     error
+}
+
+// NewEncoder returns an Encoder writing to w
+func NewEncoder(w io.Writer, opts EncoderOptions) (*Encoder, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = Table
+	case Table, Pre:
+	default:
+		return nil, fmt.Errorf("htmlreport: unsupported mode %q", opts.Mode)
+	}
+	return &Encoder{w: w, opts: opts}, nil
+}
+
+// Encode adds v, which must be an object or an array of objects in table
+// mode
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.opts.Mode == Pre {
+		e.err = e.writePre(v)
+		return e.err
+	}
+	rows, ok := v.([]interface{})
+	if !ok {
+		rows = []interface{}{v}
+	}
+	for _, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			e.err = fmt.Errorf("htmlreport: table rows must be objects, got %T", r)
+			return e.err
+		}
+		e.rows = append(e.rows, row)
+	}
+	return nil
+}
+
+func (e *Encoder) writePre(v interface{}) error {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("htmlreport: %w", err)
+	}
+	var buf bytes.Buffer
+	if !e.started {
+		e.start(&buf)
+	}
+	buf.WriteString("<pre>")
+	buf.WriteString(html.EscapeString(strings.TrimSuffix(b.String(), "\n")))
+	buf.WriteString("</pre>\n")
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// start writes the beginning of the document to buf
+func (e *Encoder) start(buf *bytes.Buffer) {
+	e.started = true
+	if !e.opts.Fragment {
+		buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+		if e.opts.Title != "" {
+			fmt.Fprintf(buf, "<title>%s</title>\n", html.EscapeString(e.opts.Title))
+		}
+	}
+	if e.opts.Style != "" {
+		// Style is trusted CSS; only a closing tag could end the element early
+		fmt.Fprintf(buf, "<style>\n%s\n</style>\n", strings.ReplaceAll(e.opts.Style, "</", `<\/`))
+	}
+	if !e.opts.Fragment {
+		buf.WriteString("</head>\n<body>\n")
+	}
+	if e.opts.Title != "" {
+		fmt.Fprintf(buf, "<h1>%s</h1>\n", html.EscapeString(e.opts.Title))
+	}
+}
+
+// Close finishes the document; it does not close the writer
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	var buf bytes.Buffer
+	if !e.started {
+		e.start(&buf)
+	}
+	if e.opts.Mode == Table {
+		e.writeTable(&buf)
+	}
+	if !e.opts.Fragment {
+		buf.WriteString("</body>\n</html>\n")
+	}
+	if _, err := e.w.Write(buf.Bytes()); err != nil {
+		e.err = err
+		return err
+	}
+	e.err = errors.New("htmlreport: encoder closed")
+	return nil
+}
+
+// columns returns the header of the table
+func (e *Encoder) columns() []string {
+	if len(e.opts.Columns) > 0 {
+		return e.opts.Columns
+	}
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range e.rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func (e *Encoder) writeTable(buf *bytes.Buffer) {
+	columns := e.columns()
+	buf.WriteString("<table>\n<thead>\n<tr>")
+	for _, c := range columns {
+		fmt.Fprintf(buf, "<th>%s</th>", html.EscapeString(c))
+	}
+	buf.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range e.rows {
+		buf.WriteString("<tr>")
+		for _, c := range columns {
+			switch v := row[c].(type) {
+			case int, int64, float64, *big.Int, json.Number:
+				fmt.Fprintf(buf, `<td class="number">%s</td>`, cellText(v))
+			default:
+				fmt.Fprintf(buf, "<td>%s</td>", html.EscapeString(cellText(v)))
+			}
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</tbody>\n</table>\n")
+}
+
+// cellText returns the text of a cell value; null cells are empty
+func cellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int, int64, *big.Int, json.Number, bool:
+		return fmt.Sprint(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
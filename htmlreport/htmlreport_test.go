@@ -0,0 +1,77 @@
+package htmlreport_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/apstndb/go-jq-yamlformat/htmlreport"
+)
+
+func TestTable(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".items"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"name": "<b>", "count": 1},
+		map[string]interface{}{"name": "c", "tags": []interface{}{"x"}},
+	}}
+	var buf bytes.Buffer
+	if err := p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, htmlreport.Format)); err != nil {
+		t.Fatal(err)
+	}
+	want := "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n</head>\n<body>\n" +
+		"<table>\n<thead>\n<tr><th>count</th><th>name</th><th>tags</th></tr>\n</thead>\n<tbody>\n" +
+		"<tr><td class=\"number\">1</td><td>&lt;b&gt;</td><td></td></tr>\n" +
+		"<tr><td></td><td>c</td><td>[&#34;x&#34;]</td></tr>\n" +
+		"</tbody>\n</table>\n</body>\n</html>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPre(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := htmlreport.NewEncoder(&buf, htmlreport.EncoderOptions{
+		Mode:     htmlreport.Pre,
+		Title:    "Report & summary",
+		Style:    htmlreport.DefaultStyle,
+		Fragment: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []interface{}{map[string]interface{}{"a": "<x>"}, 2} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if !strings.HasPrefix(got, "<style>\n") || strings.Contains(got, "<html>") {
+		t.Errorf("want a styled fragment, got:\n%s", got)
+	}
+	want := "<h1>Report &amp; summary</h1>\n<pre>{\n  &#34;a&#34;: &#34;&lt;x&gt;&#34;\n}</pre>\n<pre>2</pre>\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("got:\n%s\nwant suffix:\n%s", got, want)
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	if _, err := htmlreport.NewEncoder(io.Discard, htmlreport.EncoderOptions{Mode: "list"}); err == nil {
+		t.Error("expected an error for an unsupported mode")
+	}
+	enc, err := htmlreport.NewEncoder(io.Discard, htmlreport.EncoderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode("text"); err == nil {
+		t.Error("expected an error for a row that is not an object")
+	}
+}
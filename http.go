@@ -0,0 +1,180 @@
+package jqyaml
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultHTTPTimeout is the timeout of httpget requests when the client has none
+	DefaultHTTPTimeout = 10 * time.Second
+	// MaxHTTPResponseBytes is the largest response body httpget accepts
+	MaxHTTPResponseBytes = 10 << 20
+)
+
+// httpFunction holds the configuration of httpget
+type httpFunction struct {
+	client    *http.Client
+	timeout   time.Duration // Per-request timeout applied through the context; 0 if the client has one
+	allowlist []string
+}
+
+func newHTTPFunction(client *http.Client, allowlist []string) *httpFunction {
+	f := &httpFunction{allowlist: make([]string, len(allowlist))}
+	for i, host := range allowlist {
+		f.allowlist[i] = strings.ToLower(host)
+	}
+
+	// Copy the client so that redirect checks don't affect the caller's client
+	c := &http.Client{}
+	if client != nil {
+		*c = *client
+	}
+	checkRedirect := c.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := f.checkURL(req.URL); err != nil {
+			return err
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	f.client = c
+	if c.Timeout == 0 {
+		f.timeout = DefaultHTTPTimeout
+	}
+	return f
+}
+
+// checkURL reports an error unless u may be fetched
+func (f *httpFunction) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("httpget: unsupported URL scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range f.allowlist {
+		if host == allowed {
+			return nil
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("httpget: host %q is not allowed", host)
+}
+
+// httpGet implements httpget using the context of the execution
+func (ex *execution) httpGet(_ interface{}, args []interface{}) interface{} {
+	rawURL, ok := args[0].(string)
+	if !ok {
+		return fmt.Errorf("httpget: URL must be a string, got %T", args[0])
+	}
+	v, err := ex.pipeline.http.get(ex.ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	return v
+}
+
+func (f *httpFunction) get(ctx context.Context, rawURL string) (interface{}, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("httpget: %w", err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("httpget: %w", err)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("httpget: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("httpget: %s returned %s", rawURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxHTTPResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("httpget: %w", err)
+	}
+	if len(body) > MaxHTTPResponseBytes {
+		return nil, fmt.Errorf("httpget: response from %s exceeds %d bytes", rawURL, MaxHTTPResponseBytes)
+	}
+
+	if !isJSONContentType(resp.Header.Get("Content-Type")) {
+		return string(body), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("httpget: invalid JSON from %s: %w", rawURL, err)
+	}
+	return normalizeNumbers(v), nil
+}
+
+// normalizeNumbers converts the json.Number, int64 and uint64 values in v to
+// the number types of gojq, which does not normalize the values returned by
+// custom functions
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil && i >= math.MinInt && i <= math.MaxInt {
+			return int(i)
+		}
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case int64:
+		if v >= math.MinInt && v <= math.MaxInt {
+			return int(v)
+		}
+		return big.NewInt(v)
+	case uint64:
+		if v <= math.MaxInt {
+			return int(v)
+		}
+		return new(big.Int).SetUint64(v)
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeNumbers(e)
+		}
+	}
+	return v
+}
+
+// isJSONContentType reports whether contentType is application/json or a +json type
+func isJSONContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
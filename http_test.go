@@ -0,0 +1,107 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithHTTPFunction(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"id": 1, "name": "alice"}`))
+	})
+	mux.HandleFunc("/motd", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	// The same server is reachable as localhost, which is not allowed
+	other := "http://localhost:" + u.Port()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other+"/motd", http.StatusFound)
+	})
+
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(`httpget($base + .path)`),
+		jqyaml.WithHTTPFunction(server.Client(), []string{u.Hostname()}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		base    string
+		path    string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "json", base: server.URL, path: "/users/1", want: map[string]interface{}{"id": 1, "name": "alice"}},
+		{name: "text", base: server.URL, path: "/motd", want: "hello"},
+		{name: "status error", base: server.URL, path: "/missing", wantErr: "404"},
+		{name: "host not allowed", base: other, path: "/motd", wantErr: `host "localhost" is not allowed`},
+		{name: "redirect not allowed", base: server.URL, path: "/redirect", wantErr: `host "localhost" is not allowed`},
+		{name: "scheme not allowed", base: "file://", path: "/etc/passwd", wantErr: "unsupported URL scheme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []interface{}
+			err := p.Execute(context.Background(), map[string]interface{}{"path": tt.path},
+				jqyaml.WithVariables(map[string]interface{}{"base": tt.base}),
+				jqyaml.WithCallback(func(v interface{}) error {
+					got = append(got, v)
+					return nil
+				}),
+			)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(errorChain(err), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", errorChain(err))
+			}
+			if diff := cmp.Diff([]interface{}{tt.want}, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPFunctionOptIn(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`httpget("http://example.com")`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Execute(context.Background(), nil, jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err == nil {
+		t.Error("expected httpget to be undefined by default")
+	}
+
+	if _, err := jqyaml.New(jqyaml.WithHTTPFunction(nil, nil)); err == nil {
+		t.Error("expected error for empty allowlist")
+	}
+}
+
+// errorChain joins the messages of err and the errors it wraps
+func errorChain(err error) string {
+	var msgs []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, ": ")
+}
@@ -0,0 +1,247 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// entryPoint runs p on input through one of the Pipeline methods taking an
+// input value and returns what the query produced
+type entryPoint struct {
+	name string
+	run  func(p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, error)
+	// paths is set for ExecutePaths, which produces paths rather than values
+	paths bool
+}
+
+func collecting(opts []jqyaml.ExecuteOption) (*[]interface{}, []jqyaml.ExecuteOption) {
+	var got []interface{}
+	return &got, append(opts, jqyaml.WithCallback(func(v interface{}) error {
+		got = append(got, v)
+		return nil
+	}))
+}
+
+var entryPoints = []entryPoint{
+	{name: "Execute", run: func(p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, error) {
+		got, opts := collecting(opts)
+		err := p.Execute(context.Background(), input, opts...)
+		return *got, err
+	}},
+	{name: "ExecuteR", run: func(p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, error) {
+		got, opts := collecting(opts)
+		return *got, p.ExecuteR(context.Background(), input, opts...).Err
+	}},
+	{name: "ExecuteAsync", run: func(p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, error) {
+		got, opts := collecting(opts)
+		h, err := p.ExecuteAsync(context.Background(), input, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return *got, h.Wait().Err
+	}},
+	{name: "ExecutePage", run: func(p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, error) {
+		page, err := p.ExecutePage(context.Background(), input, 0, 100, opts...)
+		return page.Items, err
+	}},
+	{name: "EvaluateNumber", run: func(p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, error) {
+		n, err := p.EvaluateNumber(context.Background(), input, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{int(n)}, nil
+	}},
+	{name: "ExecutePaths", paths: true, run: func(p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) ([]interface{}, error) {
+		paths, err := p.ExecutePaths(context.Background(), input, opts...)
+		var got []interface{}
+		for _, path := range paths {
+			got = append(got, path)
+		}
+		return got, err
+	}},
+}
+
+// TestInputOptionsEntryPoints runs each input option through every entry point
+func TestInputOptionsEntryPoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		input interface{}
+		// opts returns the execute options; readers are consumed by each run
+		opts      func() []jqyaml.ExecuteOption
+		want      []interface{}
+		wantPaths []interface{}
+		wantErr   string
+		// wantPathsErr is the error of ExecutePaths for queries that are not path expressions
+		wantPathsErr string
+	}{
+		{
+			name:  "reader input",
+			query: ".items[1]",
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithReaderInput(strings.NewReader(`{"items": [1, 2]}`), jqyaml.FormatJSON)}
+			},
+			want:      []interface{}{2},
+			wantPaths: []interface{}{[]interface{}{"items", 1}},
+		},
+		{
+			name:  "reader input with an input value",
+			query: ".",
+			input: 1,
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithReaderInput(strings.NewReader("1"), jqyaml.FormatJSON)}
+			},
+			wantErr: "input must be nil when WithReaderInput is given",
+		},
+		{
+			name:  "null input",
+			query: "[inputs] | length",
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithNullInput()}
+			},
+			want:         []interface{}{0},
+			wantPathsErr: "invalid path against",
+		},
+		{
+			name:  "null input with reader input",
+			query: "[inputs] | length",
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithNullInput(), jqyaml.WithReaderInput(strings.NewReader("1 2 3"), jqyaml.FormatJSON)}
+			},
+			want:         []interface{}{3},
+			wantPathsErr: "invalid path against",
+		},
+		{
+			name:  "null input with an input value",
+			query: ".",
+			input: 1,
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithNullInput()}
+			},
+			wantErr: "input must be nil when WithNullInput is given",
+		},
+		{
+			name:  "stream input",
+			query: "select(length == 2) | .[1]",
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithStreamInput(), jqyaml.WithReaderInput(strings.NewReader(`{"a": 5}`), jqyaml.FormatJSON)}
+			},
+			want:      []interface{}{5},
+			wantPaths: []interface{}{[]interface{}{1}},
+		},
+		{
+			name:  "stream input without a reader",
+			query: ".",
+			input: map[string]interface{}{"a": 5},
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithStreamInput()}
+			},
+			wantErr: "WithStreamInput requires ExecuteReader or WithReaderInput",
+		},
+		{
+			name:  "stream input of yaml",
+			query: ".",
+			opts: func() []jqyaml.ExecuteOption {
+				return []jqyaml.ExecuteOption{jqyaml.WithStreamInput(), jqyaml.WithReaderInput(strings.NewReader("a: 5"), jqyaml.FormatYAML)}
+			},
+			wantErr: "WithStreamInput requires JSON or JSON Lines input",
+		},
+	}
+	for _, tt := range tests {
+		for _, ep := range entryPoints {
+			t.Run(fmt.Sprintf("%s/%s", tt.name, ep.name), func(t *testing.T) {
+				p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ep.run(p, tt.input, tt.opts()...)
+				wantErr := tt.wantErr
+				if ep.paths && tt.wantPathsErr != "" {
+					wantErr = tt.wantPathsErr
+				}
+				if wantErr != "" {
+					if err == nil || !strings.Contains(errorText(err), wantErr) {
+						t.Errorf("got %v, want an error containing %q", err, wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := tt.want
+				if ep.paths {
+					want = tt.wantPaths
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+// errorText returns the message of err and of the error a QueryError hides
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	var queryErr *jqyaml.QueryError
+	if errors.As(err, &queryErr) && queryErr.Err != nil {
+		return err.Error() + ": " + queryErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// TestDefaultOutputOptionsEntryPoints checks that output options given to the
+// pipeline are replaced by entry points collecting the results themselves
+func TestDefaultOutputOptionsEntryPoints(t *testing.T) {
+	var batches, decoded int
+	p, err := jqyaml.New(jqyaml.WithQuery(".a"), jqyaml.WithDefaultExecuteOptions(
+		jqyaml.WithBatchCallback(10, func([]interface{}) error {
+			batches++
+			return nil
+		}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := jqyaml.New(jqyaml.WithQuery(".a"), jqyaml.WithDefaultExecuteOptions(
+		jqyaml.WithDecodeInto(func() interface{} { return new(int) }, func(interface{}) error {
+			decoded++
+			return nil
+		}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{"a": 1}
+	for _, ep := range entryPoints {
+		t.Run(ep.name, func(t *testing.T) {
+			for _, p := range []jqyaml.Pipeline{p, d} {
+				acks := 0
+				got, err := ep.run(p, input, jqyaml.WithAckCallback(func(int) error {
+					acks++
+					return nil
+				}))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(got) != 1 {
+					t.Errorf("got %v, want one result", got)
+				}
+				if acks != 1 {
+					t.Errorf("got %d acknowledgements, want 1", acks)
+				}
+			}
+			if batches != 0 || decoded != 0 {
+				t.Errorf("pipeline outputs were used: %d batches, %d decoded", batches, decoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,664 @@
+package jqyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WithJMESPathQuery sets the query to a JMESPath expression such as
+// `locations[?state == 'WA'].name | sort(@)`, translated to jq. Like JMESPath,
+// the query produces exactly one result, null where nothing matches. The
+// expressions and built-in functions of the JMESPath specification are
+// supported; invalid function arguments fail as jq errors.
+func WithJMESPathQuery(expr string) Option {
+	return func(p *pipeline) error {
+		query, err := translateJMESPath(expr)
+		if err != nil {
+			return &QueryError{Query: expr, Message: "failed to parse JMESPath", Err: err}
+		}
+		p.query = query
+		return nil
+	}
+}
+
+const jmesPathDefs = sliceDef + `def _jmes_truthy: . != false and . != null and . != [] and . != {} and . != "";
+`
+
+// translateJMESPath translates a JMESPath expression to a jq query
+func translateJMESPath(expr string) (string, error) {
+	tokens, err := lexJMESPath(expr)
+	if err != nil {
+		return "", err
+	}
+	p := &jmesParser{tokens: tokens}
+	node, err := p.expression(0)
+	if err != nil {
+		return "", err
+	}
+	if tok := p.peek(); tok.kind != jmesEOF {
+		return "", fmt.Errorf("at offset %d: unexpected %q", tok.pos, tok.text)
+	}
+	query, err := node.jq()
+	if err != nil {
+		return "", err
+	}
+	return jmesPathDefs + query, nil
+}
+
+type jmesTokenKind int
+
+const (
+	jmesEOF jmesTokenKind = iota
+	jmesIdentifier
+	jmesQuotedIdentifier
+	jmesNumber
+	jmesLiteral    // `json` or 'raw string', text is the JSON encoding
+	jmesPunct      // Operators and delimiters, text is the operator
+	jmesFilter     // [?
+	jmesFlatten    // []
+	jmesExpression // &
+)
+
+type jmesToken struct {
+	kind jmesTokenKind
+	text string
+	pos  int
+}
+
+// bindingPower is the precedence of tok as an infix operator, from the JMESPath reference parser
+func (tok jmesToken) bindingPower() int {
+	switch tok.kind {
+	case jmesFilter:
+		return 21
+	case jmesFlatten:
+		return 9
+	case jmesPunct:
+		switch tok.text {
+		case "|":
+			return 1
+		case "||":
+			return 2
+		case "&&":
+			return 3
+		case "==", "!=", "<", "<=", ">", ">=":
+			return 5
+		case "*":
+			return 20
+		case ".":
+			return 40
+		case "!":
+			return 45
+		case "{":
+			return 50
+		case "[":
+			return 55
+		case "(":
+			return 60
+		}
+	}
+	return 0
+}
+
+func lexJMESPath(src string) ([]jmesToken, error) {
+	var tokens []jmesToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+			continue
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			for i < len(src) && (src[i] == '_' || 'a' <= src[i] && src[i] <= 'z' || 'A' <= src[i] && src[i] <= 'Z' || '0' <= src[i] && src[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, jmesToken{kind: jmesIdentifier, text: src[start:i], pos: start})
+			continue
+		case c == '-' || '0' <= c && c <= '9':
+			i++
+			for i < len(src) && '0' <= src[i] && src[i] <= '9' {
+				i++
+			}
+			if src[start:i] == "-" {
+				return nil, fmt.Errorf("at offset %d: expected a number after -", start)
+			}
+			tokens = append(tokens, jmesToken{kind: jmesNumber, text: src[start:i], pos: start})
+			continue
+		case c == '"':
+			end, err := scanQuoted(src, i, '"')
+			if err != nil {
+				return nil, err
+			}
+			var name string
+			if err := json.Unmarshal([]byte(src[i:end]), &name); err != nil {
+				return nil, fmt.Errorf("at offset %d: invalid quoted identifier: %w", start, err)
+			}
+			tokens = append(tokens, jmesToken{kind: jmesQuotedIdentifier, text: name, pos: start})
+			i = end
+			continue
+		case c == '\'':
+			end, err := scanQuoted(src, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			raw := strings.ReplaceAll(src[i+1:end-1], `\'`, `'`)
+			tokens = append(tokens, jmesToken{kind: jmesLiteral, text: jqString(raw), pos: start})
+			i = end
+			continue
+		case c == '`':
+			end, err := scanQuoted(src, i, '`')
+			if err != nil {
+				return nil, err
+			}
+			data := strings.ReplaceAll(src[i+1:end-1], "\\`", "`")
+			var v interface{}
+			if err := json.Unmarshal([]byte(data), &v); err != nil {
+				return nil, fmt.Errorf("at offset %d: invalid JSON literal: %w", start, err)
+			}
+			literal, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, jmesToken{kind: jmesLiteral, text: string(literal), pos: start})
+			i = end
+			continue
+		}
+
+		var tok jmesToken
+		switch rest := src[i:]; {
+		case strings.HasPrefix(rest, "[?"):
+			tok = jmesToken{kind: jmesFilter, text: "[?"}
+		case strings.HasPrefix(rest, "[]"):
+			tok = jmesToken{kind: jmesFlatten, text: "[]"}
+		case strings.HasPrefix(rest, "||"), strings.HasPrefix(rest, "&&"),
+			strings.HasPrefix(rest, "=="), strings.HasPrefix(rest, "!="),
+			strings.HasPrefix(rest, "<="), strings.HasPrefix(rest, ">="):
+			tok = jmesToken{kind: jmesPunct, text: rest[:2]}
+		case c == '&':
+			tok = jmesToken{kind: jmesExpression, text: "&"}
+		case strings.IndexByte(".*[],:{}()|!<>@", c) >= 0:
+			tok = jmesToken{kind: jmesPunct, text: rest[:1]}
+		default:
+			return nil, fmt.Errorf("at offset %d: unexpected %q", i, c)
+		}
+		tok.pos = start
+		tokens = append(tokens, tok)
+		i += len(tok.text)
+	}
+	return append(tokens, jmesToken{kind: jmesEOF, pos: len(src)}), nil
+}
+
+// scanQuoted returns the offset after the quote closing the string starting at src[start]
+func scanQuoted(src string, start int, quote byte) (int, error) {
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("at offset %d: unterminated %c", start, quote)
+}
+
+// jmesNode is a node of a parsed JMESPath expression
+type jmesNode struct {
+	kind     string // See jq for the kinds
+	value    string
+	children []*jmesNode
+}
+
+var jmesIdentity = &jmesNode{kind: "current"}
+
+type jmesParser struct {
+	tokens []jmesToken
+	pos    int
+}
+
+func (p *jmesParser) peek() jmesToken {
+	return p.tokens[p.pos]
+}
+
+func (p *jmesParser) next() jmesToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != jmesEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *jmesParser) isPunct(text string) bool {
+	tok := p.peek()
+	return tok.kind == jmesPunct && tok.text == text
+}
+
+func (p *jmesParser) expect(text string) error {
+	if !p.isPunct(text) {
+		tok := p.peek()
+		return fmt.Errorf("at offset %d: expected %q, found %q", tok.pos, text, tok.text)
+	}
+	p.next()
+	return nil
+}
+
+// expression parses an expression whose operators bind tighter than bp
+func (p *jmesParser) expression(bp int) (*jmesNode, error) {
+	left, err := p.nud(p.next())
+	if err != nil {
+		return nil, err
+	}
+	for bp < p.peek().bindingPower() {
+		left, err = p.led(p.next(), left)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *jmesParser) nud(tok jmesToken) (*jmesNode, error) {
+	switch tok.kind {
+	case jmesLiteral:
+		return &jmesNode{kind: "literal", value: tok.text}, nil
+	case jmesIdentifier:
+		return &jmesNode{kind: "field", value: tok.text}, nil
+	case jmesQuotedIdentifier:
+		if p.isPunct("(") {
+			return nil, fmt.Errorf("at offset %d: quoted identifiers cannot name functions", tok.pos)
+		}
+		return &jmesNode{kind: "field", value: tok.text}, nil
+	case jmesFilter:
+		return p.filter(jmesIdentity)
+	case jmesFlatten:
+		rhs, err := p.projectionRHS(tok.bindingPower())
+		if err != nil {
+			return nil, err
+		}
+		return &jmesNode{kind: "projection", children: []*jmesNode{{kind: "flatten", children: []*jmesNode{jmesIdentity}}, rhs}}, nil
+	case jmesExpression:
+		e, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		return &jmesNode{kind: "expref", children: []*jmesNode{e}}, nil
+	case jmesPunct:
+		switch tok.text {
+		case "@":
+			return jmesIdentity, nil
+		case "*":
+			rhs, err := p.projectionRHS(tok.bindingPower())
+			if err != nil {
+				return nil, err
+			}
+			return &jmesNode{kind: "valueProjection", children: []*jmesNode{jmesIdentity, rhs}}, nil
+		case "!":
+			e, err := p.expression(tok.bindingPower())
+			if err != nil {
+				return nil, err
+			}
+			return &jmesNode{kind: "not", children: []*jmesNode{e}}, nil
+		case "(":
+			e, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		case "{":
+			return p.multiSelectHash()
+		case "[":
+			if t := p.peek(); t.kind == jmesNumber || t.kind == jmesPunct && t.text == ":" {
+				return p.indexOrSlice(jmesIdentity)
+			}
+			if p.isPunct("*") && p.tokens[p.pos+1].kind == jmesPunct && p.tokens[p.pos+1].text == "]" {
+				p.pos += 2
+				rhs, err := p.projectionRHS(jmesToken{kind: jmesPunct, text: "*"}.bindingPower())
+				if err != nil {
+					return nil, err
+				}
+				return &jmesNode{kind: "projection", children: []*jmesNode{jmesIdentity, rhs}}, nil
+			}
+			return p.multiSelectList()
+		}
+	}
+	return nil, fmt.Errorf("at offset %d: unexpected %q", tok.pos, tok.text)
+}
+
+func (p *jmesParser) led(tok jmesToken, left *jmesNode) (*jmesNode, error) {
+	switch tok.kind {
+	case jmesFilter:
+		return p.filter(left)
+	case jmesFlatten:
+		rhs, err := p.projectionRHS(tok.bindingPower())
+		if err != nil {
+			return nil, err
+		}
+		return &jmesNode{kind: "projection", children: []*jmesNode{{kind: "flatten", children: []*jmesNode{left}}, rhs}}, nil
+	}
+	switch tok.text {
+	case ".":
+		rhs, err := p.dotRHS(tok.bindingPower())
+		if err != nil {
+			return nil, err
+		}
+		return &jmesNode{kind: "subexpression", children: []*jmesNode{left, rhs}}, nil
+	case "|", "||", "&&", "==", "!=", "<", "<=", ">", ">=":
+		right, err := p.expression(tok.bindingPower())
+		if err != nil {
+			return nil, err
+		}
+		kind := map[string]string{"|": "pipe", "||": "or", "&&": "and"}[tok.text]
+		if kind == "" {
+			return &jmesNode{kind: "comparator", value: tok.text, children: []*jmesNode{left, right}}, nil
+		}
+		return &jmesNode{kind: kind, children: []*jmesNode{left, right}}, nil
+	case "(":
+		if left.kind != "field" {
+			return nil, fmt.Errorf("at offset %d: only names can be called", tok.pos)
+		}
+		var args []*jmesNode
+		for !p.isPunct(")") {
+			arg, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.isPunct(")") {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+		p.next()
+		return &jmesNode{kind: "function", value: left.value, children: args}, nil
+	case "[":
+		if t := p.peek(); t.kind == jmesNumber || t.kind == jmesPunct && t.text == ":" {
+			return p.indexOrSlice(left)
+		}
+		if err := p.expect("*"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		rhs, err := p.projectionRHS(jmesToken{kind: jmesPunct, text: "*"}.bindingPower())
+		if err != nil {
+			return nil, err
+		}
+		return &jmesNode{kind: "projection", children: []*jmesNode{left, rhs}}, nil
+	}
+	return nil, fmt.Errorf("at offset %d: unexpected %q", tok.pos, tok.text)
+}
+
+// indexOrSlice parses [n] or [start:stop:step] applied to left, after [
+func (p *jmesParser) indexOrSlice(left *jmesNode) (*jmesNode, error) {
+	bounds := []string{"null", "null", "null"}
+	n := 0
+	for {
+		if tok := p.peek(); tok.kind == jmesNumber {
+			bounds[n] = p.next().text
+		}
+		if p.isPunct("]") {
+			p.next()
+			break
+		}
+		if n == 2 {
+			tok := p.peek()
+			return nil, fmt.Errorf("at offset %d: expected \"]\", found %q", tok.pos, tok.text)
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	if n == 0 {
+		return &jmesNode{kind: "subexpression", children: []*jmesNode{left, {kind: "index", value: bounds[0]}}}, nil
+	}
+	if bounds[2] == "0" {
+		return nil, fmt.Errorf("slice step cannot be 0")
+	}
+	if bounds[2] == "null" {
+		bounds[2] = "1"
+	}
+	slice := &jmesNode{kind: "slice", value: strings.Join(bounds, "; ")}
+	rhs, err := p.projectionRHS(jmesToken{kind: jmesPunct, text: "*"}.bindingPower())
+	if err != nil {
+		return nil, err
+	}
+	return &jmesNode{kind: "projection", children: []*jmesNode{{kind: "subexpression", children: []*jmesNode{left, slice}}, rhs}}, nil
+}
+
+// filter parses the condition and the projected expression after [?
+func (p *jmesParser) filter(left *jmesNode) (*jmesNode, error) {
+	cond, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	rhs := jmesIdentity
+	if p.peek().kind != jmesFlatten {
+		rhs, err = p.projectionRHS(jmesToken{kind: jmesFilter}.bindingPower())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &jmesNode{kind: "filterProjection", children: []*jmesNode{left, rhs, cond}}, nil
+}
+
+// projectionRHS parses the expression applied to each element of a projection
+func (p *jmesParser) projectionRHS(bp int) (*jmesNode, error) {
+	tok := p.peek()
+	switch {
+	case tok.bindingPower() < 10:
+		return jmesIdentity, nil
+	case tok.kind == jmesFilter || tok.kind == jmesPunct && tok.text == "[":
+		return p.expression(bp)
+	case tok.kind == jmesPunct && tok.text == ".":
+		p.next()
+		return p.dotRHS(bp)
+	}
+	return nil, fmt.Errorf("at offset %d: unexpected %q after a projection", tok.pos, tok.text)
+}
+
+// dotRHS parses the expression after a dot
+func (p *jmesParser) dotRHS(bp int) (*jmesNode, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == jmesIdentifier || tok.kind == jmesQuotedIdentifier || tok.kind == jmesPunct && tok.text == "*":
+		return p.expression(bp)
+	case tok.kind == jmesPunct && tok.text == "[":
+		p.next()
+		return p.multiSelectList()
+	case tok.kind == jmesPunct && tok.text == "{":
+		p.next()
+		return p.multiSelectHash()
+	}
+	return nil, fmt.Errorf("at offset %d: unexpected %q after .", tok.pos, tok.text)
+}
+
+func (p *jmesParser) multiSelectList() (*jmesNode, error) {
+	var elems []*jmesNode
+	for {
+		e, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, e)
+		if p.isPunct("]") {
+			p.next()
+			return &jmesNode{kind: "multiSelectList", children: elems}, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *jmesParser) multiSelectHash() (*jmesNode, error) {
+	var pairs []*jmesNode
+	for {
+		key := p.next()
+		if key.kind != jmesIdentifier && key.kind != jmesQuotedIdentifier {
+			return nil, fmt.Errorf("at offset %d: expected a key, found %q", key.pos, key.text)
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, &jmesNode{kind: "pair", value: key.text, children: []*jmesNode{value}})
+		if p.isPunct("}") {
+			p.next()
+			return &jmesNode{kind: "multiSelectHash", children: pairs}, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// jq translates the node to a jq filter producing exactly one value
+func (n *jmesNode) jq() (string, error) {
+	args := make([]string, len(n.children))
+	if n.kind != "function" {
+		for i, c := range n.children {
+			if c.kind == "expref" {
+				return "", fmt.Errorf("expression references (&) are only allowed as function arguments")
+			}
+			s, err := c.jq()
+			if err != nil {
+				return "", err
+			}
+			args[i] = s
+		}
+	}
+	switch n.kind {
+	case "current":
+		return ".", nil
+	case "literal":
+		return n.value, nil
+	case "field":
+		return `(if type == "object" then .[` + jqString(n.value) + `] else null end)`, nil
+	case "index":
+		return `(if type == "array" then .[` + n.value + `] else null end)`, nil
+	case "slice":
+		return `(if type == "array" then [_slice(` + n.value + `)] else null end)`, nil
+	case "subexpression", "pipe":
+		return "(" + args[0] + " | " + args[1] + ")", nil
+	case "projection":
+		return "(" + args[0] + ` | if type == "array" then [.[] | ` + args[1] + ` | select(. != null)] else null end)`, nil
+	case "valueProjection":
+		return "(" + args[0] + ` | if type == "object" then [.[] | ` + args[1] + ` | select(. != null)] else null end)`, nil
+	case "filterProjection":
+		return "(" + args[0] + ` | if type == "array" then [.[] | select(` + args[2] + ` | _jmes_truthy) | ` + args[1] + ` | select(. != null)] else null end)`, nil
+	case "flatten":
+		return "(" + args[0] + ` | if type == "array" then [.[] | if type == "array" then .[] else . end] else null end)`, nil
+	case "or":
+		return "(" + args[0] + ` as $l | if $l | _jmes_truthy then $l else ` + args[1] + " end)", nil
+	case "and":
+		return "(" + args[0] + ` as $l | if $l | _jmes_truthy then ` + args[1] + " else $l end)", nil
+	case "not":
+		return "(" + args[0] + " | _jmes_truthy | not)", nil
+	case "comparator":
+		if n.value == "==" || n.value == "!=" {
+			return "(" + args[0] + " " + n.value + " " + args[1] + ")", nil
+		}
+		// JMESPath orders numbers only
+		return "(" + args[0] + " as $l | " + args[1] + ` as $r | if ($l | type) == "number" and ($r | type) == "number" then $l ` + n.value + " $r else null end)", nil
+	case "multiSelectList":
+		return "(if . == null then null else [" + strings.Join(args, ", ") + "] end)", nil
+	case "multiSelectHash":
+		return "(if . == null then null else {" + strings.Join(args, ", ") + "} end)", nil
+	case "pair":
+		return jqString(n.value) + ": " + args[0], nil
+	case "function":
+		return n.function()
+	}
+	return "", fmt.Errorf("unsupported JMESPath expression %s", n.kind)
+}
+
+// jmesFunctions maps the supported JMESPath functions to jq. $0, $1, ... are
+// the arguments; &0 is the expression of an expression reference argument.
+var jmesFunctions = map[string]struct {
+	arity int // -1 for one or more arguments
+	jq    string
+}{
+	"abs":         {1, `$0 | if . < 0 then -. else . end`},
+	"avg":         {1, `$0 | if length == 0 then null else add / length end`},
+	"ceil":        {1, `$0 | ceil`},
+	"contains":    {2, `if ($0 | type) == "array" then any($0[]; . == $1) else $0 | index($1) != null end`},
+	"ends_with":   {2, `$0 | endswith($1)`},
+	"floor":       {1, `$0 | floor`},
+	"join":        {2, `$1 | join($0)`},
+	"keys":        {1, `$0 | keys`},
+	"length":      {1, `$0 | length`},
+	"map":         {2, `$1 | map(&0)`},
+	"max":         {1, `$0 | max`},
+	"max_by":      {2, `$0 | max_by(&1)`},
+	"merge":       {-1, `$all | add`},
+	"min":         {1, `$0 | min`},
+	"min_by":      {2, `$0 | min_by(&1)`},
+	"not_null":    {-1, `[$all[] | select(. != null)] | .[0]`},
+	"reverse":     {1, `$0 | if type == "string" then explode | reverse | implode else reverse end`},
+	"sort":        {1, `$0 | sort`},
+	"sort_by":     {2, `$0 | sort_by(&1)`},
+	"starts_with": {2, `$0 | startswith($1)`},
+	"sum":         {1, `$0 | add // 0`},
+	"to_array":    {1, `$0 | if type == "array" then . else [.] end`},
+	"to_number":   {1, `$0 | if type == "number" then . elif type == "string" then (tonumber? // null) else null end`},
+	"to_string":   {1, `$0 | if type == "string" then . else tojson end`},
+	"type":        {1, `$0 | type`},
+	"values":      {1, `$0 | [.[]]`},
+}
+
+// function translates a function call, binding its arguments to variables
+// evaluated against the current node
+func (n *jmesNode) function() (string, error) {
+	fn, ok := jmesFunctions[n.value]
+	if !ok {
+		return "", fmt.Errorf("unknown function %s()", n.value)
+	}
+	if fn.arity >= 0 && len(n.children) != fn.arity || fn.arity < 0 && len(n.children) == 0 {
+		return "", fmt.Errorf("invalid number of arguments to %s(): %d", n.value, len(n.children))
+	}
+	body := fn.jq
+	var bindings, all []string
+	refs := map[string]string{}
+	for i, c := range n.children {
+		ref := "&" + strconv.Itoa(i)
+		isRef := c.kind == "expref"
+		if isRef != strings.Contains(body, ref) {
+			if isRef {
+				return "", fmt.Errorf("argument %d of %s() must not be an expression reference", i+1, n.value)
+			}
+			return "", fmt.Errorf("argument %d of %s() must be an expression reference (&)", i+1, n.value)
+		}
+		if isRef {
+			e, err := c.children[0].jq()
+			if err != nil {
+				return "", err
+			}
+			refs[ref] = e
+			continue
+		}
+		arg, err := c.jq()
+		if err != nil {
+			return "", err
+		}
+		name := "$jmes_arg" + strconv.Itoa(i)
+		bindings = append(bindings, arg+" as "+name+" | ")
+		all = append(all, name)
+		body = strings.ReplaceAll(body, "$"+strconv.Itoa(i), name)
+	}
+	body = strings.ReplaceAll(body, "$all", "["+strings.Join(all, ", ")+"]")
+	// Expressions are inserted last, so that their text is not rewritten
+	for ref, e := range refs {
+		body = strings.ReplaceAll(body, ref, e)
+	}
+	return "(" + strings.Join(bindings, "") + body + ")", nil
+}
@@ -2,11 +2,14 @@
 package jqyaml
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"time"
 
 	yamlformat "github.com/apstndb/go-yamlformat"
@@ -14,10 +17,31 @@ import (
 	"github.com/itchyny/gojq"
 )
 
-// Pipeline represents a data processing pipeline with jq query support
+// Pipeline represents a data processing pipeline with jq query support.
+// A Pipeline is safe for concurrent use by multiple goroutines: its
+// configuration is fixed by New, compiled queries are shared, and each
+// execution keeps its own state. Outputs given to WithDefaultWriter and
+// WithDefaultEncoder are shared by concurrent executions, and results served
+// by WithResultCache are shared values that must not be modified.
 type Pipeline interface {
 	// Execute runs the pipeline with options
 	Execute(ctx context.Context, input interface{}, opts ...ExecuteOption) error
+	// ExecuteReader decodes JSON or YAML documents from r and runs the pipeline on each of them
+	ExecuteReader(ctx context.Context, r io.Reader, format Format, opts ...ExecuteOption) error
+	// ExecuteR runs the pipeline like Execute and reports the outcome as an ExecuteResult
+	ExecuteR(ctx context.Context, input interface{}, opts ...ExecuteOption) *ExecuteResult
+	// ExecuteAsync starts the pipeline in a new goroutine and returns a Handle to control it
+	ExecuteAsync(ctx context.Context, input interface{}, opts ...ExecuteOption) (*Handle, error)
+	// ExecutePage runs the pipeline and returns one page of its results
+	ExecutePage(ctx context.Context, input interface{}, page, pageSize int, opts ...ExecuteOption) (PageResult, error)
+	// ExecutePaths returns the jq path() of each result of the query
+	ExecutePaths(ctx context.Context, input interface{}, opts ...ExecuteOption) ([][]interface{}, error)
+	// EvaluateBool runs the query as a predicate that must produce exactly one boolean
+	EvaluateBool(ctx context.Context, input interface{}, opts ...ExecuteOption) (bool, error)
+	// EvaluateString returns the single string result of the query
+	EvaluateString(ctx context.Context, input interface{}, opts ...ExecuteOption) (string, error)
+	// EvaluateNumber returns the single number result of the query
+	EvaluateNumber(ctx context.Context, input interface{}, opts ...ExecuteOption) (float64, error)
 }
 
 // Encoder interface for output encoding
@@ -38,44 +62,118 @@ type Format = yamlformat.Format
 const (
 	FormatYAML = yamlformat.FormatYAML
 	FormatJSON = yamlformat.FormatJSON
+	// FormatJSONL is JSON Lines (NDJSON): one JSON value per line, so that an
+	// invalid input line only fails its own document. As an output format it
+	// is compact JSON.
+	FormatJSONL Format = "jsonl"
 )
 
+// DefaultTimeout is the execution timeout used unless the pipeline or
+// Execute call specifies another one
+const DefaultTimeout = 30 * time.Second
+
 // pipeline implements the Pipeline interface
 type pipeline struct {
 	query                string
-	compiled             *gojq.Code
+	parsed               *gojq.Query // Query parsed by New
+	compiled             *codeCache  // Compilations of the query by variable names
 	defaultEncodeOptions []yaml.EncodeOption
+	defaultDecodeOptions []yaml.DecodeOption
 	compilerOptions      []gojq.CompilerOption
 	inputMarshaler       InputMarshaler
+	defaultWriter        io.Writer
+	defaultFormat        Format
+	defaultEncoder       Encoder
+	timeout              time.Duration
+	metadata             bool        // Bind the execution metadata variables
+	envAccess            bool        // Bind $ENV and env to the process environment
+	quota                *quotaState // Usage of each principal under WithQuota
+	externalJQ           *externalJQ // Command running the query instead of gojq
+	externalJQHooks      ExternalJQHooks
+	clock                func() time.Time // Source of $__now
+	lookups              []lookupTable    // Tables registered by WithLookup, converted in New
+	http                 *httpFunction    // httpget configuration set by WithHTTPFunction
+	regexLimits          *regexLimits     // Limits of the regular expression builtins
+	iterRooted           bool             // Whether the query has the shape `.[] | ...`
+	resultCache          *resultCache     // Cache set by WithResultCache
+	queryCache           *QueryCache      // Compiled query cache set by WithQueryCache
+	executeOptions       []ExecuteOption  // Applied before the options of each call
+	valueResolver        ValueResolver    // Resolver set by WithValueResolver
+	binaryPolicy         BinaryPolicy     // Conversion of []byte and io.Reader values set by WithBinaryInput
+	variableNames        map[string]bool  // Variables declared by WithVariableNames; nil if undeclared
 }
 
 // executeConfig holds execution-specific configuration
 type executeConfig struct {
-	encoder             Encoder
-	writer              io.Writer
-	format              Format
-	callback            func(interface{}) error // For streaming mode
-	variables           map[string]interface{}
-	timeout             time.Duration
-	encodeOptions       []yaml.EncodeOption
-	compactOutputSet    bool // Whether compactOutput was explicitly set
-	compactOutput       bool // For JSON output only
-	rawOutput           bool // For JSON output only
+	encoder          Encoder
+	writer           io.Writer
+	format           Format
+	callback         func(interface{}) error // For streaming mode
+	variables        map[string]interface{}
+	secrets          map[string]string     // Variables whose values are redacted from errors
+	compilerOptions  []gojq.CompilerOption // Functions registered for this execution
+	timeout          time.Duration
+	maxCPU           time.Duration // Evaluation budget; 0 means unlimited
+	yieldEvery       int           // Yield and check the context every n iterator steps
+	inputChunks      int           // Chunk size for slice inputs of `.[]`-shaped queries
+	outputBuffer     int           // Capacity of the channel to the output goroutine; 0 means synchronous output
+	encodeOptions    []yaml.EncodeOption
+	decodeOptions    []yaml.DecodeOption
+	inputEncoding    InputEncoding
+	strictInput      bool        // Reject duplicate keys in ExecuteReader input
+	inputLineNumber  bool        // Define input_line_number
+	collectErrors    bool        // Continue after record errors
+	maxErrors        int         // Maximum number of collected errors; 0 means unlimited
+	compactOutputSet bool        // Whether compactOutput was explicitly set
+	compactOutput    bool        // For JSON output only
+	rawOutput        bool        // For JSON output only
+	separator        *string     // Written after each JSON value instead of a newline when set
+	indent           int         // Indentation width for pretty JSON and YAML output (0 means default)
+	ignoreBrokenPipe bool        // Treat EPIPE on the writer as normal termination
+	stages           []stageSpec // Result stages in the order they were added
+	err              error       // First error reported by an option
+	onEmit           func()      // Called after each result reaches the output
+	newline          NewlineStyle
+	bom              bool
+	rawYAML          bool
+	multilineStyle   MultilineStyle
+	ordered          bool
+	decodeTarget     *decodeTarget
+	yamlStyle        yamlStyle
+	outputs          []string // Kinds of the output options in the order applied
+	lastOutputWins   bool
+	replacedOutputs  []string // Output kinds replaced under WithLastOutputWins
+	batch            *batchOutput
+	flushInterval    time.Duration
+	ack              func(index int) error
+	channel          *channelOutput
+	reader           io.Reader // Input of Execute set by WithReaderInput
+	readerFormat     Format
+	nullInput        bool // Run once on null, as jq -n does
+	canonicalJSON    bool // Write JSON output in the RFC 8785 canonical form
+	digest           *digestOutput
+	streamInput      bool // Decompose ExecuteReader input into jq --stream events
+	recordCipher     EncryptFunc
+	positionalArgs   []interface{}          // $ARGS.positional
+	typedArgs        map[string]interface{} // Variables bound without conversion
 }
 
 // New creates a new Pipeline with the given options
 func New(opts ...Option) (Pipeline, error) {
-	p := &pipeline{}
-	
+	p := &pipeline{
+		timeout:  DefaultTimeout,
+		compiled: newCodeCache(maxCompiledQueries),
+	}
+
 	for _, opt := range opts {
 		if err := opt(p); err != nil {
 			return nil, err
 		}
 	}
-	
+
 	// Validate the query if provided
 	if p.query != "" {
-		_, err := gojq.Parse(p.query)
+		parsed, err := gojq.Parse(p.query)
 		if err != nil {
 			return nil, &QueryError{
 				Query:   p.query,
@@ -83,74 +181,270 @@ func New(opts ...Option) (Pipeline, error) {
 				Err:     err,
 			}
 		}
-		
+		p.parsed = parsed
+		p.iterRooted = isIterRooted(parsed)
+
 		// Don't compile yet - we'll compile at execution time with proper variables
 	}
-	
+
+	if (p.externalJQHooks.BeforeStart != nil || p.externalJQHooks.AfterExit != nil) && p.externalJQ == nil {
+		return nil, fmt.Errorf("WithExternalJQHooks requires WithExternalJQ")
+	}
+
+	// Convert lookup tables once all options, including the marshaler, are known
+	if err := p.registerLookups(); err != nil {
+		return nil, err
+	}
+
+	// Compile ahead of time when the variables are declared
+	if p.variableNames != nil {
+		p.declareImplicitVariables()
+		if err := p.compileDeclared(); err != nil {
+			return nil, err
+		}
+	}
+
 	return p, nil
 }
 
-// Execute runs the pipeline on the input data
-func (p *pipeline) Execute(ctx context.Context, input interface{}, opts ...ExecuteOption) error {
-	// Configure execution
+// validate reports option errors and output choices that cannot run
+func (c *executeConfig) validate() error {
+	if c.err != nil {
+		return c.err
+	}
+	// Ensure either encoder or callback is set
+	if c.writer == nil && c.encoder == nil && c.callback == nil {
+		return fmt.Errorf("no output method specified: use WithWriter, WithEncoder, or WithCallback")
+	}
+	if (c.writer != nil || c.encoder != nil) && c.callback != nil {
+		return fmt.Errorf("cannot specify both encoder and callback")
+	}
+	if c.canonicalJSON && (c.writer == nil || c.encoder != nil || c.format != FormatJSON) {
+		return fmt.Errorf("WithCanonicalJSON requires WithWriter with JSON output")
+	}
+	if c.recordCipher != nil && (c.writer == nil || c.encoder != nil) {
+		return fmt.Errorf("WithRecordCipher requires WithWriter")
+	}
+	if c.digest != nil && (c.writer == nil || c.encoder != nil) {
+		return fmt.Errorf("WithDigest requires WithWriter")
+	}
+	if c.canonicalJSON && c.rawOutput {
+		return fmt.Errorf("WithCanonicalJSON cannot be used with raw output")
+	}
+	return nil
+}
+
+// newExecuteConfig creates an executeConfig from the pipeline defaults and applies opts in order
+func (p *pipeline) newExecuteConfig(opts ...ExecuteOption) *executeConfig {
 	cfg := &executeConfig{
-		timeout: 30 * time.Second, // default
+		timeout: p.timeout,
+	}
+	for _, opt := range p.executeOptions {
+		opt(cfg)
 	}
-	
-	// Apply options
 	for _, opt := range opts {
 		opt(cfg)
 	}
-	
+	cfg.resolveOutputs()
+
+	// Fall back to the pipeline's default writer or encoder when no output is given
+	if cfg.writer == nil && cfg.encoder == nil && cfg.callback == nil {
+		cfg.writer = p.defaultWriter
+		cfg.format = p.defaultFormat
+		cfg.encoder = p.defaultEncoder
+	}
+	if cfg.format == FormatJSONL {
+		// JSON Lines output is compact JSON
+		cfg.format = FormatJSON
+		cfg.compactOutputSet, cfg.compactOutput = true, true
+	}
+	return cfg
+}
+
+// Execute runs the pipeline on the input data
+func (p *pipeline) Execute(ctx context.Context, input interface{}, opts ...ExecuteOption) error {
+	// Configure execution
+	cfg := p.newExecuteConfig(opts...)
+	defer closeChannel(cfg.channel)
+	body, err := p.inputBody(cfg, input)
+	if err != nil {
+		return err
+	}
+	return p.run(ctx, cfg, body)
+}
+
+// inputBody returns the function that feeds the input of an execution to it
+// as the input options of cfg describe: the documents of WithReaderInput, null
+// under WithNullInput, the chunks of WithInputChunks, or else input itself.
+// Every entry point taking an input value runs it, so that they all honor the
+// same options.
+func (p *pipeline) inputBody(cfg *executeConfig, input interface{}) (func(*execution) error, error) {
+	if cfg.reader != nil {
+		if input != nil {
+			return nil, errors.New("input must be nil when WithReaderInput is given")
+		}
+		return p.readerBody(cfg, cfg.reader, cfg.readerFormat)
+	}
+	if cfg.streamInput {
+		return nil, errors.New("WithStreamInput requires ExecuteReader or WithReaderInput")
+	}
+	if cfg.nullInput {
+		if input != nil {
+			return nil, errors.New("input must be nil when WithNullInput is given")
+		}
+		return func(ex *execution) error {
+			if usesInputs(ex.pipeline.query) {
+				// There are no further inputs
+				ex.compilerOptions = append(ex.compilerOptions, gojq.WithInputIter(gojq.NewIter()))
+			}
+			return ex.processNull()
+		}, nil
+	}
+	return func(ex *execution) error {
+		if cfg.inputChunks > 0 {
+			if ok, err := ex.processChunks(input, cfg.inputChunks); ok {
+				return err
+			}
+		}
+		return ex.processRecord(input)
+	}, nil
+}
+
+// run prepares the output, variables, and result stages described by cfg,
+// lets body feed inputs to the execution, and flushes the stages afterwards
+func (p *pipeline) run(ctx context.Context, cfg *executeConfig, body func(ex *execution) error) error {
+	defer closeChannel(cfg.channel)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	var quota *quotaCharge
+	if p.quota != nil {
+		var err error
+		if quota, err = p.beginQuota(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Handle WithWriter case - create appropriate encoder
+	var tracker *writeTracker
+	var records *recordWriter // Encrypts each result's output under WithRecordCipher
+	var closer io.Closer      // Encoder of a registered output format to close at the end
 	if cfg.writer != nil && cfg.encoder == nil {
-		if cfg.format == FormatJSON && (cfg.compactOutputSet || cfg.rawOutput) {
-			// Use custom JSON encoder only when compact/raw options are explicitly set
-			cfg.encoder = newJSONEncoder(cfg.writer, cfg.compactOutput, cfg.rawOutput)
+		// Track writes so writer failures can be reported as WriteError
+		tracker = &writeTracker{w: cfg.writer}
+		if cfg.digest != nil {
+			tracker.hash = cfg.digest.hash.New()
+			// The digest covers whatever was written, however the execution ends
+			defer func() { *cfg.digest.out = tracker.hash.Sum(nil) }()
+		}
+		var out io.Writer = tracker
+		if cfg.recordCipher != nil {
+			records = &recordWriter{w: out, encrypt: cfg.recordCipher}
+			out = records
+		}
+		if cfg.newline == NewlineCRLF {
+			out = &crlfWriter{w: out}
+		}
+		if cfg.bom {
+			out = &bomWriter{w: out}
+		}
+		if provider, ok := lookupOutputProvider(cfg.format); ok {
+			encoder, err := provider.NewEncoder(out)
+			if err != nil {
+				return err
+			}
+			cfg.encoder = encoder
+			if c, ok := encoder.(io.Closer); ok {
+				closer = c
+			}
+		} else if cfg.canonicalJSON {
+			cfg.encoder = &canonicalEncoder{writer: out, separator: cfg.separator}
+		} else if cfg.format == FormatJSON && (cfg.compactOutputSet || cfg.rawOutput || cfg.separator != nil) {
+			// Use custom JSON encoder only when compact/raw/separator options are explicitly set
+			encoder := newJSONEncoder(out, cfg.compactOutput, cfg.rawOutput)
+			encoder.indent = cfg.indent
+			encoder.separator = cfg.separator
+			encoder.ordered = cfg.ordered
+			cfg.encoder = encoder
 		} else {
 			// Use standard encoder wrapper for default behavior
-			cfg.encoder = &encoderWrapper{
-				writer: cfg.writer,
+			wrapper := &encoderWrapper{
+				writer: out,
 				format: cfg.format,
 			}
+			if cfg.format == FormatYAML {
+				wrapper.overrides = append(cfg.yamlStyle.encodeOptions(), cfg.multilineStyle.encodeOptions()...)
+				wrapper.emptyMapNull = cfg.yamlStyle.emptyMapNull
+			}
+			if cfg.ordered {
+				wrapper.overrides = append(wrapper.overrides, numberLiteral)
+			}
+			cfg.encoder = wrapper
+			if cfg.format == FormatYAML && cfg.rawYAML {
+				cfg.encoder = &rawYAMLEncoder{encoderWrapper: wrapper, separator: cfg.separator}
+			}
 		}
 	}
-	
-	// Ensure either encoder or callback is set
-	if cfg.encoder == nil && cfg.callback == nil {
-		return fmt.Errorf("no output method specified: use WithWriter, WithEncoder, or WithCallback")
-	}
-	if cfg.encoder != nil && cfg.callback != nil {
-		return fmt.Errorf("cannot specify both encoder and callback")
-	}
-	
+
 	// Apply timeout if specified
 	if cfg.timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
 		defer cancel()
 	}
-	
-	// Combine encode options (default + execution-specific)
-	allEncodeOpts := append(p.defaultEncodeOptions, cfg.encodeOptions...)
-	
+
+	// Apply the evaluation budget if specified
+	var budget *cpuBudget
+	if cfg.maxCPU > 0 {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		budget = newCPUBudget(ctx, cfg.maxCPU, cancel)
+	}
+
+	// Combine encode options (default + execution-specific) in a new slice, so
+	// that concurrent executions do not append to the pipeline's
+	allEncodeOpts := append(append([]yaml.EncodeOption{}, p.defaultEncodeOptions...), cfg.encodeOptions...)
+
 	// Determine which input marshaler to use
-	marshaler := p.inputMarshaler
-	if marshaler == nil {
-		// Use default marshaler with current encode options
-		marshaler = &defaultInputMarshaler{encodeOptions: allEncodeOpts}
+	marshaler := p.marshaler(allEncodeOpts)
+
+	variables := cfg.variables
+	if p.metadata {
+		variables = p.withMetadataVariables(variables)
 	}
-	
-	// Convert input to jq-compatible format using the input marshaler
-	jsonData, err := marshaler.Marshal(input)
+	if p.envAccess {
+		variables = withEnvVariable(variables)
+	}
+
+	// Convert variables to jq-compatible format using the same marshaler
+	convertedVars, err := p.convertVariables(variables, marshaler)
 	if err != nil {
-		return &ConversionError{
-			Value: input,
-			Type:  "jq-compatible",
-			Err:   err,
+		return err
+	}
+	convertedVars = withTypedArgs(convertedVars, cfg.typedArgs)
+	if convertedVars, err = p.withArgsVariable(convertedVars, cfg, marshaler); err != nil {
+		return err
+	}
+	if p.variableNames != nil {
+		if convertedVars, err = p.bindDeclared(convertedVars); err != nil {
+			return err
 		}
 	}
-	
+
+	if cfg.decodeTarget != nil {
+		cfg.decodeTarget.opts = append(append([]yaml.DecodeOption{}, p.defaultDecodeOptions...), cfg.decodeOptions...)
+	}
+
+	if cfg.channel != nil {
+		cfg.channel.ctx = ctx
+	}
+
+	if cfg.batch != nil {
+		cfg.batch.interval = cfg.flushInterval
+		cfg.batch.ack = cfg.ack
+	}
+
 	// Determine callback
 	callback := cfg.callback
 	if callback == nil && cfg.encoder != nil {
@@ -162,62 +456,317 @@ func (p *pipeline) Execute(ctx context.Context, input interface{}, opts ...Execu
 		}
 		// Use encoder.Encode as callback
 		callback = cfg.encoder.Encode
+		if records != nil {
+			encode := callback
+			callback = func(v interface{}) error {
+				if err := encode(v); err != nil {
+					return err
+				}
+				return records.flush(ctx)
+			}
+		}
+		if tracker != nil {
+			callback = tracker.wrap(callback)
+		}
+	}
+
+	ex := &execution{
+		pipeline:      p,
+		ctx:           ctx,
+		cfg:           cfg,
+		marshaler:     marshaler,
+		variables:     convertedVars,
+		encodeOptions: allEncodeOpts,
+		budget:        budget,
+	}
+	ex.compilerOptions = append(ex.compilerOptions, cfg.compilerOptions...)
+	if p.http != nil {
+		ex.compilerOptions = append(ex.compilerOptions, gojq.WithFunction("httpget", 1, 1, ex.httpGet))
+	}
+	if cfg.inputLineNumber {
+		ex.compilerOptions = append(ex.compilerOptions, gojq.WithFunction("input_line_number", 0, 0, ex.inputLineNumber))
+	}
+
+	// Charge the bytes written since the last call to the quota
+	var chargedBytes int64
+	chargeOutput := func() error {
+		if quota == nil || tracker == nil {
+			return nil
+		}
+		n := tracker.n - chargedBytes
+		chargedBytes = tracker.n
+		return quota.charge("output bytes", n)
 	}
-	
+
+	// Count the results that reach the output
+	sink := func(v interface{}) error {
+		// Stop on cancellation, also between results the query does not evaluate
+		if ctx.Err() != nil {
+			return ex.canceled()
+		}
+		if quota != nil {
+			if err := quota.charge("results", 1); err != nil {
+				return err
+			}
+		}
+		if err := callback(v); err != nil {
+			if ctxErr := ex.contextError(err); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+		if err := chargeOutput(); err != nil {
+			return err
+		}
+		ex.emitted++
+		if cfg.onEmit != nil {
+			cfg.onEmit()
+		}
+		if cfg.ack != nil && cfg.batch == nil {
+			// Batches are acknowledged once the batch callback returns
+			if tracker != nil {
+				if err := tracker.flush(); err != nil {
+					return err
+				}
+			}
+			return cfg.ack(ex.emitted - 1)
+		}
+		return nil
+	}
+
+	// Hand results to a separate output goroutine if buffering is enabled
+	output := sink
+	var buffered *bufferedOutput
+	if cfg.outputBuffer > 0 {
+		buffered = newBufferedOutput(ex, cfg.outputBuffer, sink)
+		output = buffered.send
+	}
+
+	// Insert result stages between the query and the output
+	emit, flush, err := ex.buildStages(cfg.stages, output)
+	if err != nil {
+		return err
+	}
+	ex.emit = emit
+	if buffered != nil {
+		buffered.start()
+	}
+
 	// Process with streaming (works for both callback and encoder modes)
-	return p.streamingProcess(ctx, jsonData, cfg.variables, marshaler, callback, cfg.timeout)
+	err = body(ex)
+	if err == errTooManyErrors {
+		err = nil
+	}
+	if err == nil {
+		err = flush()
+	}
+	if buffered != nil {
+		// Wait for the output goroutine so that no output happens after returning
+		if outErr := buffered.close(); err == nil {
+			err = outErr
+		}
+	}
+	if cfg.batch != nil {
+		if err == nil {
+			err = cfg.batch.flush()
+		} else {
+			cfg.batch.stop()
+		}
+	}
+	if closer != nil {
+		closeErr := closer.Close()
+		if records != nil && closeErr == nil {
+			// Encoders of registered formats may write when closed
+			closeErr = records.flush(ctx)
+		}
+		if tracker.err != nil {
+			closeErr = &WriteError{BytesWritten: tracker.n, Err: tracker.err}
+		}
+		if err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = chargeOutput()
+	}
+	if len(ex.errs) > 0 {
+		err = errors.Join(append(ex.errs, err)...)
+	}
+	if cfg.ignoreBrokenPipe && isBrokenPipe(err) {
+		return nil
+	}
+	return err
 }
 
-// streamingProcess processes data through jq with streaming callback
-func (p *pipeline) streamingProcess(ctx context.Context, data interface{}, variables map[string]interface{}, marshaler InputMarshaler, callback func(interface{}) error, timeout time.Duration) error {
-	// If no query, stream data as-is
-	if p.query == "" {
-		return callback(data)
+// execution holds the per-call state of Execute
+type execution struct {
+	pipeline      *pipeline
+	ctx           context.Context
+	cfg           *executeConfig
+	marshaler     InputMarshaler
+	variables     map[string]interface{} // Converted variables
+	encodeOptions []yaml.EncodeOption    // Default and execution-specific encode options
+	emit          func(interface{}) error
+	// Compiler options added to the pipeline's for this execution
+	compilerOptions []gojq.CompilerOption
+	// Decoder of ExecuteReader input; nil for Execute
+	input documentDecoder
+	// Record errors collected by WithCollectErrors
+	errs []error
+	// Number of results passed to the output
+	emitted int
+	// Evaluation budget set by WithMaxCPU; nil if unlimited
+	budget *cpuBudget
+	// Number of iterator steps taken, for WithYieldEvery
+	steps int
+	// Number of inputs served from and missing in the result cache
+	cacheHits, cacheMisses int
+	// Number of documents read from input, including those read by inputs
+	documents int
+	// Whether input has reached the end of the stream
+	inputDone bool
+	// Last error returned by input, reported instead of the query error it causes
+	inputErr error
+	// Compiled query, reused for each input
+	code *gojq.Code
+}
+
+// errTooManyErrors stops processing once WithCollectErrors has collected its maximum
+var errTooManyErrors = errors.New("too many errors")
+
+// isRecordError reports whether err is confined to a single input record,
+// so that processing may continue with the next one
+func isRecordError(err error) bool {
+	var queryErr *QueryError
+	var conversionErr *ConversionError
+	var sizeErr *ResultSizeError
+	var decodeErr *DecodeError
+	var utf8Err *InvalidUTF8Error
+	return errors.As(err, &queryErr) || errors.As(err, &conversionErr) || errors.As(err, &sizeErr) ||
+		errors.As(err, &decodeErr) || errors.As(err, &utf8Err)
+}
+
+// recordFailed handles the failure of a single input record. Without
+// WithCollectErrors it returns err; otherwise it collects err and returns nil
+// until the maximum number of errors is reached.
+func (ex *execution) recordFailed(err error) error {
+	if !ex.cfg.collectErrors {
+		return err
 	}
-	
-	// Convert variables to jq-compatible format using the same marshaler
-	convertedVars, err := p.convertVariables(variables, marshaler)
-	if err != nil {
+	ex.errs = append(ex.errs, err)
+	if ex.cfg.maxErrors > 0 && len(ex.errs) >= ex.cfg.maxErrors {
+		return errTooManyErrors
+	}
+	return nil
+}
+
+// processRecord processes a single input record, collecting its error if
+// WithCollectErrors is set
+func (ex *execution) processRecord(input interface{}) error {
+	if err := ex.process(input); err != nil {
+		if isRecordError(err) {
+			return ex.recordFailed(err)
+		}
 		return err
 	}
-	
+	return nil
+}
+
+// processNull runs the query once on null under WithNullInput; there is no
+// input to convert
+func (ex *execution) processNull() error {
+	var err error
+	if ex.cacheable() {
+		err = ex.cachedProcess(nil)
+	} else {
+		err = ex.streamingProcess(nil)
+	}
+	if err != nil && isRecordError(err) {
+		return ex.recordFailed(err)
+	}
+	return err
+}
+
+// process converts a single input and runs the query on it
+func (ex *execution) process(input interface{}) error {
+	// Convert input to jq-compatible format using the input marshaler
+	jsonData, err := ex.marshaler.Marshal(input)
+	if err == nil && ex.pipeline.inputMarshaler != nil {
+		// The default marshaler only produces values gojq accepts
+		err = validateJQValue(jsonData)
+	}
+	if err != nil {
+		if ctxErr := ex.contextError(err); ctxErr != nil {
+			return ctxErr
+		}
+		return &ConversionError{
+			Value: input,
+			Type:  "jq-compatible",
+			Err:   err,
+		}
+	}
+	if ex.ctx.Err() != nil {
+		// The context ended during the conversion
+		return ex.canceled()
+	}
+	if ex.cacheable() {
+		return ex.cachedProcess(jsonData)
+	}
+	return ex.streamingProcess(jsonData)
+}
+
+// streamingProcess processes data through jq, passing each result to ex.emit
+func (ex *execution) streamingProcess(data interface{}) error {
+	p := ex.pipeline
+	// If no query, stream data as-is
+	if p.query == "" {
+		return ex.emit(data)
+	}
+
 	// Run query
-	iter := p.runQueryWithVariables(ctx, data, convertedVars)
-	
+	iter := ex.runQuery(data)
+
 	// Stream results
 	for {
-		v, ok := iter.Next()
+		v, ok := ex.next(iter)
 		if !ok {
 			break
 		}
 		if err, ok := v.(error); ok {
-			if err == context.DeadlineExceeded {
-				return &TimeoutError{Duration: timeout}
+			if ctxErr := ex.contextError(err); ctxErr != nil {
+				return ctxErr
+			}
+			if inputErr := ex.inputErr; inputErr != nil && strings.Contains(err.Error(), inputErr.Error()) {
+				// gojq hides the type of the errors of input and inputs
+				ex.inputErr = nil
+				return inputErr
 			}
 			return &QueryError{
 				Query:   p.query,
-				Message: "execution error",  
-				Err:     err,
+				Message: "execution error",
+				Err:     ex.redact(err),
 			}
 		}
-		if err := callback(v); err != nil {
+		if err := ex.emit(v); err != nil {
 			return err
 		}
 	}
-	
+
 	return nil
 }
 
-
 // convertVariables converts variables to jq-compatible format
 func (p *pipeline) convertVariables(variables map[string]interface{}, marshaler InputMarshaler) (map[string]interface{}, error) {
 	if len(variables) == 0 {
 		return nil, nil
 	}
-	
+
 	convertedVars := make(map[string]interface{})
 	for k, v := range variables {
 		converted, err := marshaler.Marshal(v)
+		if err == nil && p.inputMarshaler != nil {
+			err = validateJQValue(converted)
+		}
 		if err != nil {
 			return nil, &ConversionError{
 				Value: v,
@@ -230,35 +779,21 @@ func (p *pipeline) convertVariables(variables map[string]interface{}, marshaler
 	return convertedVars, nil
 }
 
-// runQueryWithVariables runs the compiled query with variables
-func (p *pipeline) runQueryWithVariables(ctx context.Context, data interface{}, variables map[string]interface{}) gojq.Iter {
-	// Parse the query (already validated in New)
-	parsed, _ := gojq.Parse(p.query)
-	
-	// Prepare variables for gojq
-	var varNames []string
-	var varValues []interface{}
-	if len(variables) > 0 {
-		// Collect variable names with $ prefix (as gojq expects)
-		for k := range variables {
-			varNames = append(varNames, "$" + k)
-		}
-		sort.Strings(varNames)
-		// Collect values in the same order
-		for _, varName := range varNames {
-			key := varName[1:] // Remove $ to get the key
-			varValues = append(varValues, variables[key])
-		}
-	}
-	
-	// Compile with variables and user-provided compiler options
-	var code *gojq.Code
+// runQuery runs the query with the variables of the execution
+func (ex *execution) runQuery(data interface{}) gojq.Iter {
+	p := ex.pipeline
+	if p.externalJQ != nil {
+		return ex.runExternal(data)
+	}
+
+	// The variables are the same for every input of the execution
+	varNames, varValues := variableNamesAndValues(ex.variables)
+	code := ex.code
 	var err error
-	opts := append([]gojq.CompilerOption{}, p.compilerOptions...)
-	if len(varNames) > 0 {
-		opts = append(opts, gojq.WithVariables(varNames))
+	if code == nil {
+		code, err = p.compileQuery(varNames, ex.compilerOptions)
+		ex.code = code
 	}
-	code, err = gojq.Compile(parsed, opts...)
 	if err != nil {
 		// Return an iterator that yields the error
 		return &errorIter{err: &QueryError{
@@ -267,13 +802,61 @@ func (p *pipeline) runQueryWithVariables(ctx context.Context, data interface{},
 			Err:     err,
 		}}
 	}
-	
-	return code.RunWithContext(ctx, data, varValues...)
+
+	return code.RunWithContext(ex.evalContext(), data, varValues...)
+}
+
+// variableNamesAndValues returns the sorted variable names with the $ prefix
+// (as gojq expects) and their values in the same order
+func variableNamesAndValues(variables map[string]interface{}) ([]string, []interface{}) {
+	if len(variables) == 0 {
+		return nil, nil
+	}
+	varNames := make([]string, 0, len(variables))
+	for k := range variables {
+		varNames = append(varNames, "$"+k)
+	}
+	sort.Strings(varNames)
+	varValues := make([]interface{}, 0, len(varNames))
+	for _, varName := range varNames {
+		key := varName[1:] // Remove $ to get the key
+		varValues = append(varValues, variables[key])
+	}
+	return varNames, varValues
+}
+
+// compile compiles a parsed query with the given variable names, user-provided compiler options
+// and any extra options
+func (p *pipeline) compile(parsed *gojq.Query, varNames []string, extra ...gojq.CompilerOption) (*gojq.Code, error) {
+	var key string
+	if p.queryCache != nil && len(p.compilerOptions) == 0 && len(extra) == 0 {
+		key = p.queryCacheKey(parsed, varNames)
+		if code, ok := p.queryCache.codes.get(key); ok {
+			return code, nil
+		}
+	}
+	opts := append([]gojq.CompilerOption{}, p.compilerOptions...)
+	opts = append(opts, extra...)
+	if p.envAccess {
+		parsed = withEnvDefs(parsed)
+	}
+	if p.regexLimits != nil {
+		parsed = withRegexGuard(parsed)
+		opts = append(opts, p.regexLimits.compilerOption())
+	}
+	if len(varNames) > 0 {
+		opts = append(opts, gojq.WithVariables(varNames))
+	}
+	code, err := gojq.Compile(parsed, opts...)
+	if err == nil && key != "" {
+		p.queryCache.codes.add(key, code)
+	}
+	return code, err
 }
 
 // errorIter is an iterator that yields a single error
 type errorIter struct {
-	err error
+	err  error
 	done bool
 }
 
@@ -285,7 +868,6 @@ func (e *errorIter) Next() (interface{}, bool) {
 	return e.err, true
 }
 
-
 // convertToJQCompatible converts any Go value to gojq-compatible types
 func convertToJQCompatible(v interface{}, opts ...yaml.EncodeOption) (interface{}, error) {
 	// Use yamlformat for marshaling to respect CustomMarshaler options
@@ -293,34 +875,52 @@ func convertToJQCompatible(v interface{}, opts ...yaml.EncodeOption) (interface{
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Unmarshal to generic interface
 	var result interface{}
 	if err := yamlformat.Unmarshal(data, &result); err != nil {
 		return nil, err
 	}
-	
+
 	return result, nil
 }
 
 // defaultInputMarshaler implements InputMarshaler using the existing convertToJQCompatible logic
 type defaultInputMarshaler struct {
 	encodeOptions []yaml.EncodeOption
+	resolver      ValueResolver // Set by WithValueResolver
+	binary        BinaryPolicy  // Set by WithBinaryInput
 }
 
 func (d *defaultInputMarshaler) Marshal(v interface{}) (interface{}, error) {
+	if d.resolver != nil || d.binary != 0 {
+		converted, err := d.resolve(v, 0)
+		if _, skip := converted.(skippedValue); skip {
+			converted = nil
+		}
+		return converted, err
+	}
 	return convertToJQCompatible(v, d.encodeOptions...)
 }
 
 // encoderWrapper wraps yamlformat encoders to support option setting
 type encoderWrapper struct {
-	writer  io.Writer
-	format  Format
-	options []yaml.EncodeOption
+	writer       io.Writer
+	format       Format
+	options      []yaml.EncodeOption
+	overrides    []yaml.EncodeOption // Applied after options, e.g. by WithYAMLMultilineStyle
+	emptyMapNull bool                // Encode empty objects as null
 }
 
 func (e *encoderWrapper) Encode(v interface{}) error {
-	encoder := e.format.NewEncoder(e.writer, e.options...)
+	opts := e.options
+	if len(e.overrides) > 0 {
+		opts = append(append([]yaml.EncodeOption{}, e.options...), e.overrides...)
+	}
+	if e.emptyMapNull {
+		v, _ = nullEmptyMaps(v)
+	}
+	encoder := e.format.NewEncoder(e.writer, opts...)
 	return encoder.Encode(v)
 }
 
@@ -330,10 +930,14 @@ func (e *encoderWrapper) SetOptions(opts ...yaml.EncodeOption) {
 
 // jsonEncoder implements custom JSON encoding with compact and raw output support
 type jsonEncoder struct {
-	writer        io.Writer
-	compact       bool
-	raw           bool
-	needNewline   bool
+	writer      io.Writer
+	compact     bool
+	raw         bool
+	indent      int     // Spaces per level for pretty output (0 means 2)
+	separator   *string // Written after each value instead of a newline when set
+	ordered     bool    // Whether values may contain yaml.MapSlice
+	options     []yaml.EncodeOption
+	needNewline bool
 }
 
 func newJSONEncoder(w io.Writer, compact, raw bool) *jsonEncoder {
@@ -345,6 +949,14 @@ func newJSONEncoder(w io.Writer, compact, raw bool) *jsonEncoder {
 	}
 }
 
+// separatorString returns the string written after each value
+func (e *jsonEncoder) separatorString() string {
+	if e.separator == nil {
+		return "\n"
+	}
+	return *e.separator
+}
+
 func (e *jsonEncoder) Encode(v interface{}) error {
 	// Add newline before next item if needed (for raw output)
 	if e.needNewline {
@@ -359,8 +971,8 @@ func (e *jsonEncoder) Encode(v interface{}) error {
 			if _, err := io.WriteString(e.writer, s); err != nil {
 				return err
 			}
-			// Add newline after the string
-			if _, err := e.writer.Write([]byte("\n")); err != nil {
+			// Add the separator after the string
+			if _, err := io.WriteString(e.writer, e.separatorString()); err != nil {
 				return err
 			}
 			e.needNewline = false
@@ -368,16 +980,74 @@ func (e *jsonEncoder) Encode(v interface{}) error {
 		}
 	}
 
-	// Use standard JSON encoder
-	encoder := json.NewEncoder(e.writer)
+	// Use standard JSON encoder, buffering the value when its trailing
+	// newline must be replaced with another separator
+	var buf bytes.Buffer
+	var w io.Writer = e.writer
+	if e.separator != nil {
+		w = &buf
+	}
+	encoder := json.NewEncoder(w)
 	// By default, json.Encoder produces compact output
 	// Only set indent for non-compact (pretty) output
 	// Note: raw output should always be compact for non-strings
 	if !e.compact && !e.raw {
-		encoder.SetIndent("", "  ")
+		indent := e.indent
+		if indent == 0 {
+			indent = 2
+		}
+		encoder.SetIndent("", strings.Repeat(" ", indent))
+	}
+
+	var err error
+	if len(e.options) > 0 {
+		err = e.encodeWithOptions(w, v)
+	} else {
+		if e.ordered {
+			v = orderedJSON(v)
+		}
+		err = encoder.Encode(v)
 	}
-	
-	err := encoder.Encode(v)
 	e.needNewline = false // json.Encoder already adds newline
+	if err != nil || e.separator == nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1)
+	buf.WriteString(*e.separator)
+	_, err = e.writer.Write(buf.Bytes())
 	return err
-}
\ No newline at end of file
+}
+
+// SetOptions sets the encode options, such as custom marshalers, applied to non-raw values
+func (e *jsonEncoder) SetOptions(opts ...yaml.EncodeOption) {
+	e.options = append(e.options, opts...)
+}
+
+// encodeWithOptions encodes v with go-yaml's JSON mode so that the encode
+// options apply, then reformats the result like json.Encoder would
+func (e *jsonEncoder) encodeWithOptions(w io.Writer, v interface{}) error {
+	opts := e.options
+	if e.ordered {
+		opts = append(append([]yaml.EncodeOption{}, opts...), numberLiteral)
+	}
+	data, err := FormatJSON.Marshal(v, opts...)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if e.compact || e.raw {
+		err = json.Compact(&buf, data)
+	} else {
+		indent := e.indent
+		if indent == 0 {
+			indent = 2
+		}
+		err = json.Indent(&buf, bytes.TrimSpace(data), "", strings.Repeat(" ", indent))
+	}
+	if err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return err
+}
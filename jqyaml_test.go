@@ -280,6 +280,57 @@ func TestTimeout(t *testing.T) {
 	}
 }
 
+func TestDefaultTimeout(t *testing.T) {
+	t.Run("pipeline default applies", func(t *testing.T) {
+		p, err := jqyaml.New(
+			jqyaml.WithQuery("while(true; .+1)"), // Infinite loop
+			jqyaml.WithDefaultTimeout(50*time.Millisecond),
+		)
+		if err != nil {
+			t.Fatalf("failed to create pipeline: %v", err)
+		}
+
+		var buf bytes.Buffer
+		err = p.Execute(context.Background(), 0,
+			jqyaml.WithWriter(&buf, yamlformat.FormatJSON),
+		)
+		var timeoutErr *jqyaml.TimeoutError
+		if !errors.As(err, &timeoutErr) {
+			t.Fatalf("expected TimeoutError, got %T: %v", err, err)
+		}
+		if timeoutErr.Duration != 50*time.Millisecond {
+			t.Errorf("Duration = %s, want 50ms", timeoutErr.Duration)
+		}
+	})
+
+	t.Run("execute option overrides no timeout", func(t *testing.T) {
+		p, err := jqyaml.New(
+			jqyaml.WithQuery("while(true; .+1)"), // Infinite loop
+			jqyaml.WithNoTimeout(),
+		)
+		if err != nil {
+			t.Fatalf("failed to create pipeline: %v", err)
+		}
+
+		var buf bytes.Buffer
+		err = p.Execute(context.Background(), 0,
+			jqyaml.WithWriter(&buf, yamlformat.FormatJSON),
+			jqyaml.WithTimeout(50*time.Millisecond),
+		)
+		var timeoutErr *jqyaml.TimeoutError
+		if !errors.As(err, &timeoutErr) {
+			t.Fatalf("expected TimeoutError, got %T: %v", err, err)
+		}
+	})
+
+	t.Run("negative timeout", func(t *testing.T) {
+		_, err := jqyaml.New(jqyaml.WithDefaultTimeout(-time.Second))
+		if err == nil {
+			t.Fatal("expected error for negative default timeout")
+		}
+	})
+}
+
 func TestCustomMarshaler(t *testing.T) {
 	t.Skip("Custom marshaler for input data conversion is not yet supported")
 	// TODO: This test is currently failing because the custom marshaler
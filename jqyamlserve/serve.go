@@ -0,0 +1,284 @@
+// Package jqyamlserve runs jqyaml pipelines as a long-lived service, so shell
+// scripts and programs in other languages can reuse warm query caches instead
+// of starting a process per query. Clients connect over a UNIX socket or TCP
+// and exchange newline-delimited JSON: each Request line is answered by a
+// stream of Response lines ending with one whose Done field is true.
+package jqyamlserve
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = errors.New("jqyamlserve: server closed")
+
+// MaxRequestBytes bounds the size of a request line
+const MaxRequestBytes = 64 << 20
+
+// errRequestTooLarge is answered to a request line exceeding MaxRequestBytes
+// before the connection is closed
+var errRequestTooLarge = fmt.Errorf("request exceeds %d bytes", MaxRequestBytes)
+
+// Request is a query to run on an input
+type Request struct {
+	Query     string                 `json:"query"`
+	Input     json.RawMessage        `json:"input,omitempty"` // JSON input; absent means null
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	// Format "json" or "yaml" streams the formatted output as Output chunks;
+	// empty streams each result as a JSON Result
+	Format    string `json:"format,omitempty"`
+	Compact   bool   `json:"compact,omitempty"` // Compact JSON output
+	Raw       bool   `json:"raw,omitempty"`     // Strings without quotes, like jq -r
+	TimeoutMs int    `json:"timeout_ms,omitempty"`
+}
+
+// Response is one line of the answer to a Request
+type Response struct {
+	Result json.RawMessage `json:"result,omitempty"` // A result, without Format
+	Output string          `json:"output,omitempty"` // A chunk of formatted output, with Format
+	Done   bool            `json:"done,omitempty"`   // Set on the last response of a request
+	Error  string          `json:"error,omitempty"`  // Why the request failed, on the last response
+}
+
+// Server answers requests on the connections of its listeners
+type Server struct {
+	opts  []jqyaml.Option
+	cache *jqyaml.QueryCache
+
+	ctx    context.Context // Canceled by Close, ending the requests in progress
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// NewServer creates a Server building the pipeline of each request with the
+// pipeline options opts and a query cache shared by all requests
+func NewServer(opts ...jqyaml.Option) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		opts:      opts,
+		cache:     jqyaml.NewQueryCache(jqyaml.DefaultQueryCacheSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on network ("unix" or "tcp") and address and serves
+// the connections it accepts
+func (s *Server) ListenAndServe(network, address string) error {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each in its own goroutine until
+// Close is called, when it returns ErrServerClosed. Requests on a connection
+// are answered in order.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.untrack(conn)
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Close stops the listeners, cancels the requests in progress, closes the
+// connections and waits for their goroutines to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// ServeConn answers the requests read from conn until the client closes it
+// or the server is closed, then closes conn. A request line exceeding
+// MaxRequestBytes is answered with a final error response before closing.
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	enc := json.NewEncoder(w)
+	send := func(resp Response) error {
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		// Flush each response, so that clients see results as they are produced
+		return w.Flush()
+	}
+
+	for {
+		line, err := readLine(r)
+		if len(bytes.TrimSpace(line)) > 0 {
+			var req Request
+			var reqErr error
+			if jsonErr := json.Unmarshal(line, &req); jsonErr != nil {
+				reqErr = fmt.Errorf("invalid request: %w", jsonErr)
+			} else {
+				reqErr = s.handle(s.ctx, &req, send)
+			}
+			done := Response{Done: true}
+			if reqErr != nil {
+				done.Error = reqErr.Error()
+			}
+			if err := send(done); err != nil {
+				return
+			}
+		}
+		if errors.Is(err, errRequestTooLarge) {
+			// Tell the client why the connection ends
+			_ = send(Response{Done: true, Error: err.Error()})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readLine reads a request line, up to MaxRequestBytes
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > MaxRequestBytes {
+			return nil, errRequestTooLarge
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+}
+
+// handle runs req, sending its results; the returned error ends the request
+func (s *Server) handle(ctx context.Context, req *Request, send func(Response) error) error {
+	p, err := jqyaml.New(append(s.opts[:len(s.opts):len(s.opts)],
+		jqyaml.WithQuery(req.Query),
+		jqyaml.WithQueryCache(s.cache),
+	)...)
+	if err != nil {
+		return err
+	}
+
+	var opts []jqyaml.ExecuteOption
+	if req.Variables != nil {
+		opts = append(opts, jqyaml.WithVariables(req.Variables))
+	}
+	if req.TimeoutMs > 0 {
+		opts = append(opts, jqyaml.WithTimeout(time.Duration(req.TimeoutMs)*time.Millisecond))
+	}
+	if req.Raw {
+		opts = append(opts, jqyaml.WithRawJSONOutput())
+	}
+	if req.Compact {
+		opts = append(opts, jqyaml.WithCompactJSONOutput())
+	}
+	switch req.Format {
+	case "":
+		opts = append(opts, jqyaml.WithCallback(func(v interface{}) error {
+			result, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			return send(Response{Result: result})
+		}))
+	case "json", "yaml":
+		opts = append(opts, jqyaml.WithWriter(outputWriter(send), jqyaml.Format(req.Format)))
+	default:
+		return fmt.Errorf("unsupported format: %q", req.Format)
+	}
+
+	input := req.Input
+	if len(input) == 0 {
+		input = json.RawMessage("null")
+	}
+	return p.ExecuteReader(ctx, bytes.NewReader(input), jqyaml.FormatJSON, opts...)
+}
+
+// outputWriter sends each write as an Output response
+type outputWriter func(Response) error
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	if err := w(Response{Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,144 @@
+package jqyamlserve_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apstndb/go-jq-yamlformat/jqyamlserve"
+	"github.com/google/go-cmp/cmp"
+)
+
+// startServer serves on a UNIX socket and returns a connected client
+func startServer(t *testing.T) (*jqyamlserve.Server, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "jq.sock"))
+	if err != nil {
+		t.Skipf("UNIX sockets unavailable: %v", err)
+	}
+	s := jqyamlserve.NewServer()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-served; !errors.Is(err, jqyamlserve.ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, conn
+}
+
+// roundTrip sends req and reads the responses up to the one marked done
+func roundTrip(t *testing.T, conn net.Conn, r *bufio.Reader, req string) []jqyamlserve.Response {
+	t.Helper()
+	if _, err := conn.Write([]byte(req + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	var responses []jqyamlserve.Response
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("reading response: %v", err)
+		}
+		var resp jqyamlserve.Response
+		if err := json.Unmarshal(line, &resp); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, resp)
+		if resp.Done {
+			return responses
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	_, conn := startServer(t)
+	r := bufio.NewReader(conn)
+
+	t.Run("results", func(t *testing.T) {
+		got := roundTrip(t, conn, r, `{"query": ".items[] | select(. > $min)", "input": {"items": [1, 5, 9]}, "variables": {"min": 2}}`)
+		want := []jqyamlserve.Response{
+			{Result: json.RawMessage("5")},
+			{Result: json.RawMessage("9")},
+			{Done: true},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("responses mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("formatted output", func(t *testing.T) {
+		got := roundTrip(t, conn, r, `{"query": ".", "input": {"name": "a"}, "format": "yaml"}`)
+		var out strings.Builder
+		for _, resp := range got[:len(got)-1] {
+			out.WriteString(resp.Output)
+		}
+		if out.String() != "name: a\n" {
+			t.Errorf("got output %q", out.String())
+		}
+	})
+
+	t.Run("errors keep the connection usable", func(t *testing.T) {
+		for _, req := range []string{`{"query": ".["}`, `not json`, `{"query": ".", "format": "xml"}`} {
+			got := roundTrip(t, conn, r, req)
+			if len(got) != 1 || got[0].Error == "" {
+				t.Errorf("%s: got %+v, want a single error response", req, got)
+			}
+		}
+		got := roundTrip(t, conn, r, `{"query": "1 + 1"}`)
+		if len(got) != 2 || string(got[0].Result) != "2" {
+			t.Errorf("got %+v after errors", got)
+		}
+	})
+}
+
+func TestServerClose(t *testing.T) {
+	s, conn := startServer(t)
+	r := bufio.NewReader(conn)
+	roundTrip(t, conn, r, `{"query": "."}`)
+
+	s.Close()
+	if _, err := r.ReadByte(); err == nil {
+		t.Error("expected the connection to be closed")
+	}
+}
+
+func TestServerRequestTooLarge(t *testing.T) {
+	s := jqyamlserve.NewServer()
+	defer s.Close()
+	client, server := net.Pipe()
+	defer client.Close()
+	go s.ServeConn(server)
+
+	go func() {
+		chunk := []byte(strings.Repeat(" ", 1<<20))
+		for n := 0; n <= jqyamlserve.MaxRequestBytes; n += len(chunk) {
+			if _, err := client.Write(chunk); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The connection ends with a final response telling why
+	line, err := bufio.NewReader(client).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	var resp jqyamlserve.Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Done || !strings.Contains(resp.Error, "request exceeds") {
+		t.Errorf("got %+v, want a final error response", resp)
+	}
+}
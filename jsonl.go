@@ -0,0 +1,20 @@
+package jqyaml
+
+import "io"
+
+// JSONLOptions configures NewJSONLEncoder
+type JSONLOptions struct {
+	Pretty bool // Indent each value instead of writing one value per line
+	Raw    bool // Write strings without JSON quotes, like jq -r; other values stay compact
+	Indent int  // Spaces per level for Pretty output (0 means 2)
+}
+
+// NewJSONLEncoder creates an Encoder writing one JSON value per line with
+// the same jq-compatible encoding as WithWriter(w, FormatJSON) combined with
+// WithCompactJSONOutput, WithPrettyJSONOutput and WithRawJSONOutput, so
+// applications can encode values they compute themselves consistently.
+func NewJSONLEncoder(w io.Writer, opts JSONLOptions) Encoder {
+	encoder := newJSONEncoder(w, !opts.Pretty, opts.Raw)
+	encoder.indent = opts.Indent
+	return encoder
+}
@@ -0,0 +1,120 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSONLInput(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, _ := new(big.Int).SetString("12345678901234567890", 10)
+
+	t.Run("one value per line", func(t *testing.T) {
+		got := collectReader(t, p, "{\"id\": 1}\n\n{\"id\": 12345678901234567890}\r\n{\"id\": \"x\"}", jqyaml.FormatJSONL)
+		want := []interface{}{1, large, "x"}
+		if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b *big.Int) bool { return a.Cmp(b) == 0 })); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid lines are separate documents", func(t *testing.T) {
+		var got []interface{}
+		err := p.ExecuteReader(context.Background(), strings.NewReader("{\"id\": 1}\n{\"id\": \n{\"id\": 3}\n"), jqyaml.FormatJSONL,
+			jqyaml.WithCollectErrors(0),
+			jqyaml.WithCallback(func(v interface{}) error {
+				got = append(got, v)
+				return nil
+			}))
+		var decodeErr *jqyaml.DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Document != 2 || decodeErr.Position.Line != 2 {
+			t.Errorf("got %v, want a DecodeError for line 2", err)
+		}
+		if diff := cmp.Diff([]interface{}{1, 3}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := jqyaml.Transform(context.Background(), &buf, jqyaml.FormatJSONL, strings.NewReader("a: [1, 2]\n"), jqyaml.FormatYAML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != "{\"a\":[1,2]}\n" {
+			t.Errorf("got %q", got)
+		}
+	})
+}
+
+func TestInputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		input  string
+		format jqyaml.Format
+		want   []interface{}
+	}{
+		{
+			name:   "input pairs documents",
+			query:  "[., input]",
+			input:  "1 2 3 4",
+			format: jqyaml.FormatJSON,
+			want:   []interface{}{[]interface{}{1, 2}, []interface{}{3, 4}},
+		},
+		{
+			name:   "inputs takes the rest",
+			query:  "[., inputs.n]",
+			input:  "n: 1\n---\nn: 2\n---\nn: 3\n",
+			format: jqyaml.FormatYAML,
+			want:   []interface{}{[]interface{}{map[string]interface{}{"n": 1}, 2, 3}},
+		},
+		{
+			name:   "jsonl",
+			query:  "reduce inputs as $x (.; . + $x)",
+			input:  "1\n2\n3\n",
+			format: jqyaml.FormatJSONL,
+			want:   []interface{}{6},
+		},
+		{
+			name:   "fields named input",
+			query:  ".input",
+			input:  `{"input": 1} {"input": 2}`,
+			format: jqyaml.FormatJSON,
+			want:   []interface{}{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collectReader(t, p, tt.input, tt.format)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("decode errors", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("[inputs]"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.ExecuteReader(context.Background(), strings.NewReader("1\n{\n"), jqyaml.FormatJSONL, jqyaml.WithCallback(func(interface{}) error { return nil }))
+		var decodeErr *jqyaml.DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Document != 2 {
+			t.Errorf("got %v, want a DecodeError for document 2", err)
+		}
+	})
+}
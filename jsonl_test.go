@@ -177,3 +177,42 @@ func isValidJSON(s string) bool {
 	d := json.NewDecoder(strings.NewReader(s))
 	return d.Decode(&v) == nil
 }
+
+func TestNewJSONLEncoder(t *testing.T) {
+	values := []interface{}{"text", map[string]interface{}{"a": []interface{}{1, 2}}, nil}
+	tests := []struct {
+		name     string
+		opts     JSONLOptions
+		execOpts []ExecuteOption
+	}{
+		{name: "compact", execOpts: []ExecuteOption{WithCompactJSONOutput()}},
+		{name: "pretty", opts: JSONLOptions{Pretty: true, Indent: 4}, execOpts: []ExecuteOption{WithIndent(4)}},
+		{name: "raw", opts: JSONLOptions{Raw: true}, execOpts: []ExecuteOption{WithCompactJSONOutput(), WithRawJSONOutput()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bytes.Buffer
+			encoder := NewJSONLEncoder(&got, tt.opts)
+			for _, v := range values {
+				if err := encoder.Encode(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The output matches the pipeline's own JSON output
+			p, err := New(WithQuery(".[]"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want bytes.Buffer
+			opts := append([]ExecuteOption{WithWriter(&want, FormatJSON)}, tt.execOpts...)
+			if err := p.Execute(context.Background(), values, opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("expected %q, got %q", want.String(), got.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package jqyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WithJSONPathQuery sets the query to a JSONPath expression such as
+// `$.store.book[?(@.price < 10)].title`, translated to jq. Each node the
+// expression selects becomes a result, so the usual outputs apply. Supported
+// are names, wildcards, indexes, slices with steps, unions, descendant
+// segments (..) and filters with comparisons, &&, || and !.
+func WithJSONPathQuery(expr string) Option {
+	return func(p *pipeline) error {
+		query, err := translateJSONPath(expr)
+		if err != nil {
+			return &QueryError{Query: expr, Message: "failed to parse JSONPath", Err: err}
+		}
+		p.query = query
+		return nil
+	}
+}
+
+// sliceDef defines _slice($start; $end; $step), which yields the elements of
+// an array selected by a Python-style slice, nothing for other values
+const sliceDef = `def _slice($s; $e; $st):
+  if type != "array" or $st == 0 then empty
+  else . as $a | length as $n
+    | if $st > 0 then
+        [($s // 0 | if . < 0 then [$n + ., 0] | max else [., $n] | min end),
+         ($e // $n | if . < 0 then [$n + ., 0] | max else [., $n] | min end)]
+      else
+        [($s // ($n - 1) | if . < 0 then [$n + ., -1] | max else [., $n - 1] | min end),
+         ($e // (-$n - 1) | if . < 0 then [$n + ., -1] | max else [., $n - 1] | min end)]
+      end
+    | $a[range(.[0]; .[1]; $st)]
+  end;
+`
+
+// jsonPathDefs select nothing where JSONPath finds no node, instead of null
+const jsonPathDefs = sliceDef + `def _jp_key($k): if type == "object" and has($k) then .[$k] else empty end;
+def _jp_index($i): if type == "array" then (if $i < 0 then length + $i else $i end) as $j
+  | if $j >= 0 and $j < length then .[$j] else empty end else empty end;
+def _jp_wild: if type == "object" or type == "array" then .[] else empty end;
+`
+
+// translateJSONPath translates a JSONPath expression to a jq query
+func translateJSONPath(expr string) (string, error) {
+	p := &jsonPathParser{src: expr}
+	p.skipSpace()
+	if !p.consume("$") {
+		return "", p.errorf("expression must start with $")
+	}
+	path, err := p.segments()
+	if err != nil {
+		return "", err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return "", p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return jsonPathDefs + ". as $jsonpath_root | " + path, nil
+}
+
+type jsonPathParser struct {
+	src string
+	pos int
+}
+
+func (p *jsonPathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *jsonPathParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if p.peek(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// segments parses the segments following $ or @, returning "." for none
+func (p *jsonPathParser) segments() (string, error) {
+	var parts []string
+	for {
+		var selector string
+		var err error
+		descendant := false
+		switch {
+		case p.consume(".."):
+			descendant = true
+			if p.peek("[") {
+				p.pos++
+				selector, err = p.bracket()
+			} else {
+				selector, err = p.dotSelector()
+			}
+		case p.consume("."):
+			selector, err = p.dotSelector()
+		case p.consume("["):
+			selector, err = p.bracket()
+		default:
+			if len(parts) == 0 {
+				return ".", nil
+			}
+			return strings.Join(parts, " | "), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if descendant {
+			selector = "(.. | " + selector + ")"
+		}
+		parts = append(parts, selector)
+	}
+}
+
+// dotSelector parses the name or wildcard after a dot
+func (p *jsonPathParser) dotSelector() (string, error) {
+	if p.consume("*") {
+		return "_jp_wild", nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c == '-' || c >= 0x80 || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a name or * after .")
+	}
+	return "_jp_key(" + jqString(p.src[start:p.pos]) + ")", nil
+}
+
+// bracket parses the selectors of a bracketed segment after [
+func (p *jsonPathParser) bracket() (string, error) {
+	var selectors []string
+	for {
+		p.skipSpace()
+		selector, err := p.bracketSelector()
+		if err != nil {
+			return "", err
+		}
+		selectors = append(selectors, selector)
+		p.skipSpace()
+		if p.consume("]") {
+			break
+		}
+		if !p.consume(",") {
+			return "", p.errorf("expected , or ]")
+		}
+	}
+	if len(selectors) == 1 {
+		return selectors[0], nil
+	}
+	return "(" + strings.Join(selectors, ", ") + ")", nil
+}
+
+func (p *jsonPathParser) bracketSelector() (string, error) {
+	switch {
+	case p.consume("*"):
+		return "_jp_wild", nil
+	case p.consume("?"):
+		p.skipSpace()
+		// The parentheses of the original syntax are optional in RFC 9535
+		cond, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		return "(_jp_wild | select(" + cond + "))", nil
+	case p.peek("'") || p.peek(`"`):
+		name, err := p.stringLiteral()
+		if err != nil {
+			return "", err
+		}
+		return "_jp_key(" + jqString(name) + ")", nil
+	}
+
+	// An index or a slice
+	var bounds [3]string
+	n := 0
+	for {
+		p.skipSpace()
+		start := p.pos
+		p.consume("-")
+		for p.pos < len(p.src) && '0' <= p.src[p.pos] && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if num := p.src[start:p.pos]; num != "" {
+			if _, err := strconv.Atoi(num); err != nil {
+				return "", p.errorf("invalid index %q", num)
+			}
+			bounds[n] = num
+		}
+		p.skipSpace()
+		if n == 2 || !p.consume(":") {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		if bounds[0] == "" {
+			return "", p.errorf("expected a selector")
+		}
+		return "_jp_index(" + bounds[0] + ")", nil
+	}
+	for i, b := range bounds {
+		if b == "" {
+			bounds[i] = "null"
+		}
+	}
+	if bounds[2] == "null" {
+		bounds[2] = "1"
+	}
+	return "_slice(" + strings.Join(bounds[:], "; ") + ")", nil
+}
+
+// stringLiteral parses a single- or double-quoted string
+func (p *jsonPathParser) stringLiteral() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.pos == len(p.src) {
+				return "", p.errorf("unterminated string")
+			}
+			e := p.src[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsonPathParser) or() (string, error) {
+	left, err := p.and()
+	if err != nil {
+		return "", err
+	}
+	for p.skipSpace(); p.consume("||"); p.skipSpace() {
+		right, err := p.and()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " or " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *jsonPathParser) and() (string, error) {
+	left, err := p.unary()
+	if err != nil {
+		return "", err
+	}
+	for p.skipSpace(); p.consume("&&"); p.skipSpace() {
+		right, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " and " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *jsonPathParser) unary() (string, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		operand, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		return "(" + operand + " | not)", nil
+	}
+	if p.consume("(") {
+		cond, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return "", p.errorf("expected )")
+		}
+		return cond, nil
+	}
+	left, nodes, err := p.operand()
+	if err != nil {
+		return "", err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		p.skipSpace()
+		right, _, err := p.operand()
+		if err != nil {
+			return "", err
+		}
+		if op == "==" || op == "!=" {
+			return "(" + left + " " + op + " " + right + ")", nil
+		}
+		// Unlike jq, JSONPath orders only numbers and strings, each among themselves
+		return "(" + left + " as $l | " + right + " as $r | ($l | type) == ($r | type) and " +
+			"(($l | type) == \"number\" or ($l | type) == \"string\") and $l " + op + " $r)", nil
+	}
+	if nodes == "" {
+		return "", p.errorf("expected a comparison")
+	}
+	// A path alone tests for the existence of a node
+	return "(" + nodes + " | length > 0)", nil
+}
+
+// operand parses a path or a literal of a filter. Paths evaluate to their
+// first node, or null if there is none; nodes is the array of all their nodes.
+func (p *jsonPathParser) operand() (value, nodes string, err error) {
+	var root string
+	switch {
+	case p.consume("@"):
+		root = "."
+	case p.consume("$"):
+		root = "$jsonpath_root"
+	case p.peek("'") || p.peek(`"`):
+		s, err := p.stringLiteral()
+		if err != nil {
+			return "", "", err
+		}
+		return jqString(s), "", nil
+	default:
+		for _, lit := range []string{"true", "false", "null"} {
+			if p.consume(lit) {
+				return lit, "", nil
+			}
+		}
+		start := p.pos
+		for p.pos < len(p.src) && strings.IndexByte("+-.0123456789eE", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		num := p.src[start:p.pos]
+		if _, err := strconv.ParseFloat(num, 64); err != nil {
+			p.pos = start
+			return "", "", p.errorf("expected a path or a literal")
+		}
+		return num, "", nil
+	}
+	path, err := p.segments()
+	if err != nil {
+		return "", "", err
+	}
+	nodes = "[" + root + " | " + path + "]"
+	return "(" + nodes + " | .[0])", nodes, nil
+}
+
+// jqString quotes s as a jq string literal
+func jqString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,80 @@
+package jqyaml
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/itchyny/gojq"
+)
+
+// lookupTable is a table registered by WithLookup
+type lookupTable struct {
+	name  string
+	table map[string]interface{}
+}
+
+// registerLookups converts the lookup tables and registers their functions
+func (p *pipeline) registerLookups() error {
+	if len(p.lookups) == 0 {
+		return nil
+	}
+	marshaler := p.marshaler(p.defaultEncodeOptions)
+	for _, l := range p.lookups {
+		converted, err := marshaler.Marshal(l.table)
+		if err != nil {
+			return &ConversionError{
+				Value: l.table,
+				Type:  fmt.Sprintf("lookup table %s", l.name),
+				Err:   err,
+			}
+		}
+		table, _ := converted.(map[string]interface{})
+		p.compilerOptions = append(p.compilerOptions, gojq.WithFunction(l.name, 1, 1, lookupFunction(table)))
+	}
+	return nil
+}
+
+// lookupFunction returns the implementation of a lookup function over table
+func lookupFunction(table map[string]interface{}) func(interface{}, []interface{}) interface{} {
+	return func(_ interface{}, args []interface{}) interface{} {
+		key, ok := args[0].(string)
+		if !ok {
+			b, err := gojq.Marshal(args[0])
+			if err != nil {
+				return err
+			}
+			key = string(b)
+		}
+		return table[key]
+	}
+}
+
+// readLookupCSV reads a lookup table from CSV with a header row
+func readLookupCSV(r io.Reader) (map[string]interface{}, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(map[string]interface{})
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		table[record[0]] = row
+	}
+}
@@ -0,0 +1,59 @@
+package jqyaml_test
+
+import (
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithLookup(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(`.[] | {id, owner: user(.id).name}`),
+		jqyaml.WithLookup("user", map[string]interface{}{
+			"42":  user{Name: "alice"},
+			"u-7": user{Name: "bob"},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, p, []map[string]interface{}{{"id": 42}, {"id": "u-7"}, {"id": 1}})
+	want := []interface{}{
+		map[string]interface{}{"id": 42, "owner": "alice"},
+		map[string]interface{}{"id": "u-7", "owner": "bob"},
+		map[string]interface{}{"id": 1, "owner": nil},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithLookupCSV(t *testing.T) {
+	csv := "code,name,region\nJP,Japan,APAC\nDE,Germany,EMEA\n"
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(`.[] | country(.) | "\(.name) (\(.region))"`),
+		jqyaml.WithLookupCSV("country", strings.NewReader(csv)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, p, []string{"DE", "JP"})
+	if diff := cmp.Diff([]interface{}{"Germany (EMEA)", "Japan (APAC)"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for name, input := range map[string]string{
+		"empty":            "",
+		"ragged row":       "a,b\n1\n",
+		"unterminated row": "a,b\n\"1,2\n",
+	} {
+		if _, err := jqyaml.New(jqyaml.WithLookupCSV("t", strings.NewReader(input))); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package jqyaml
+
+import (
+	"context"
+	"fmt"
+)
+
+// MergeStrategy selects how MergeDocuments combines documents
+type MergeStrategy int
+
+const (
+	// MergeDeep merges objects recursively, like jq's `*`; other values of
+	// later documents replace earlier ones
+	MergeDeep MergeStrategy = iota + 1
+	// MergeOverride replaces top-level keys, like jq's `+` on objects
+	MergeOverride
+	// MergeAppendArrays merges objects recursively like MergeDeep and
+	// concatenates arrays found at the same path
+	MergeAppendArrays
+)
+
+func (s MergeStrategy) String() string {
+	switch s {
+	case MergeDeep:
+		return "deep"
+	case MergeOverride:
+		return "override"
+	case MergeAppendArrays:
+		return "append-arrays"
+	default:
+		return fmt.Sprintf("MergeStrategy(%d)", int(s))
+	}
+}
+
+// query returns the jq program folding an array of documents into one
+func (s MergeStrategy) query() (string, error) {
+	switch s {
+	case MergeDeep:
+		return `reduce .[] as $doc ({}; . * $doc)`, nil
+	case MergeOverride:
+		return `reduce .[] as $doc ({}; . + $doc)`, nil
+	case MergeAppendArrays:
+		return `def merge($a; $b):
+  if ($a | type) == "object" and ($b | type) == "object" then
+    reduce ($b | keys[]) as $k ($a; .[$k] = if has($k) then merge(.[$k]; $b[$k]) else $b[$k] end)
+  elif ($a | type) == "array" and ($b | type) == "array" then $a + $b
+  else $b
+  end;
+reduce .[] as $doc ({}; merge(.; $doc))`, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy: %v", s)
+	}
+}
+
+// MergeDocuments merges docs in order, later documents taking precedence, and
+// writes the merged document to the output given by opts, e.g. WithWriter, as
+// configuration layering tools do. Documents are converted with the default
+// input marshaler and must be objects, or a QueryError is returned.
+func MergeDocuments(ctx context.Context, docs []interface{}, strategy MergeStrategy, opts ...ExecuteOption) error {
+	query, err := strategy.query()
+	if err != nil {
+		return err
+	}
+	// jq's operators would report mismatched types with less context
+	query = `if any(.[]; type != "object") then error("documents to merge must be objects") else ` + query + ` end`
+	p, err := New(WithQuery(query))
+	if err != nil {
+		return err
+	}
+	return p.Execute(ctx, docs, opts...)
+}
@@ -0,0 +1,88 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestMergeDocuments(t *testing.T) {
+	base := map[string]interface{}{
+		"name":   "app",
+		"server": map[string]interface{}{"host": "localhost", "port": 8080},
+		"tags":   []interface{}{"base"},
+	}
+	override := map[string]interface{}{
+		"server": map[string]interface{}{"port": 9090},
+		"tags":   []interface{}{"prod"},
+	}
+
+	tests := []struct {
+		strategy jqyaml.MergeStrategy
+		want     string
+	}{
+		{
+			strategy: jqyaml.MergeDeep,
+			want:     "name: app\nserver:\n  host: localhost\n  port: 9090\ntags:\n- prod\n",
+		},
+		{
+			strategy: jqyaml.MergeOverride,
+			want:     "name: app\nserver:\n  port: 9090\ntags:\n- prod\n",
+		},
+		{
+			strategy: jqyaml.MergeAppendArrays,
+			want:     "name: app\nserver:\n  host: localhost\n  port: 9090\ntags:\n- base\n- prod\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := jqyaml.MergeDocuments(context.Background(), []interface{}{base, override}, tt.strategy,
+				jqyaml.WithWriter(&buf, jqyaml.FormatYAML))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeDocumentsStructs(t *testing.T) {
+	type config struct {
+		Name    string `json:"name"`
+		Replica int    `json:"replica,omitempty"`
+	}
+	var got map[string]interface{}
+	err := jqyaml.MergeDocuments(context.Background(),
+		[]interface{}{config{Name: "app", Replica: 1}, config{Name: "app-prod"}}, jqyaml.MergeDeep,
+		jqyaml.WithCallback(func(v interface{}) error {
+			got = v.(map[string]interface{})
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["name"] != "app-prod" || got["replica"] != 1 {
+		t.Errorf("got %v, want name app-prod with replica 1", got)
+	}
+}
+
+func TestMergeDocumentsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	err := jqyaml.MergeDocuments(context.Background(), []interface{}{map[string]interface{}{}, []interface{}{1}},
+		jqyaml.MergeDeep, jqyaml.WithWriter(&buf, jqyaml.FormatJSON))
+	var queryErr *jqyaml.QueryError
+	if !errors.As(err, &queryErr) {
+		t.Errorf("expected QueryError for a non-object document, got %T: %v", err, err)
+	}
+
+	err = jqyaml.MergeDocuments(context.Background(), nil, jqyaml.MergeStrategy(0), jqyaml.WithWriter(&buf, jqyaml.FormatJSON))
+	if err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
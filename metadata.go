@@ -0,0 +1,32 @@
+package jqyaml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// withMetadataVariables returns vars with the execution metadata variables added
+func (p *pipeline) withMetadataVariables(vars map[string]interface{}) map[string]interface{} {
+	hostname, _ := os.Hostname()
+	sum := sha256.Sum256([]byte(p.query))
+
+	merged := map[string]interface{}{
+		"__now":      p.now().UTC().Format(time.RFC3339),
+		"__pipeline": hex.EncodeToString(sum[:]),
+		"__hostname": hostname,
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return merged
+}
+
+// now returns the current time of the pipeline's clock
+func (p *pipeline) now() time.Time {
+	if p.clock == nil {
+		return time.Now()
+	}
+	return p.clock()
+}
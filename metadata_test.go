@@ -0,0 +1,60 @@
+package jqyaml_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithExecutionMetadata(t *testing.T) {
+	query := `{generated: $__now, pipeline: $__pipeline, host: $__hostname, value: .}`
+	clock := func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("JST", 9*60*60)) }
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(query),
+		jqyaml.WithExecutionMetadata(),
+		jqyaml.WithClock(clock),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostname, _ := os.Hostname()
+	sum := sha256.Sum256([]byte(query))
+	got := collect(t, p, 1)
+	want := []interface{}{map[string]interface{}{
+		"generated": "2024-05-01T03:00:00Z",
+		"pipeline":  hex.EncodeToString(sum[:]),
+		"host":      hostname,
+		"value":     1,
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// Explicit variables take precedence
+	got = collect(t, p, 1, jqyaml.WithVariables(map[string]interface{}{"__hostname": "override"}))
+	if host := got[0].(map[string]interface{})["host"]; host != "override" {
+		t.Errorf("expected overridden hostname, got %v", host)
+	}
+}
+
+func TestExecutionMetadataOptIn(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("$__now"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Execute(context.Background(), nil, jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err == nil {
+		t.Error("expected undefined variable error without WithExecutionMetadata")
+	}
+
+	if _, err := jqyaml.New(jqyaml.WithClock(nil)); err == nil {
+		t.Error("expected error for nil clock")
+	}
+}
@@ -0,0 +1,101 @@
+package jqyaml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// MultilineStyle selects how WithYAMLMultilineStyle renders strings
+// containing newlines in YAML output. Strings that a block scalar cannot
+// represent faithfully, such as those with indented lines or several
+// trailing newlines, are double-quoted. go-yaml cannot emit multi-line
+// plain scalars, so there is no plain style.
+type MultilineStyle int
+
+const (
+	// MultilineLiteral uses literal block scalars (|), keeping each line as-is
+	MultilineLiteral MultilineStyle = iota + 1
+	// MultilineFolded uses folded block scalars (>)
+	MultilineFolded
+	// MultilineDoubleQuoted uses double-quoted scalars with "\n" escapes
+	MultilineDoubleQuoted
+)
+
+func (s MultilineStyle) String() string {
+	switch s {
+	case MultilineLiteral:
+		return "literal"
+	case MultilineFolded:
+		return "folded"
+	case MultilineDoubleQuoted:
+		return "double-quoted"
+	default:
+		return fmt.Sprintf("MultilineStyle(%d)", int(s))
+	}
+}
+
+// encodeOptions returns the encode options implementing the style
+func (s MultilineStyle) encodeOptions() []yaml.EncodeOption {
+	switch s {
+	case MultilineLiteral, MultilineFolded, MultilineDoubleQuoted:
+		return []yaml.EncodeOption{yaml.UseLiteralStyleIfMultiline(true), yaml.CustomMarshaler[string](s.marshal)}
+	default:
+		return nil
+	}
+}
+
+// marshal renders a string in the style
+func (s MultilineStyle) marshal(str string) ([]byte, error) {
+	if !strings.Contains(str, "\n") {
+		return yaml.Marshal(str)
+	}
+	body := strings.TrimRight(str, "\n")
+	lines := strings.Split(body, "\n")
+	trailing := len(str) - len(body)
+	if s == MultilineDoubleQuoted || !blockSafe(lines, trailing) {
+		return []byte(strconv.Quote(str)), nil
+	}
+	if s == MultilineLiteral {
+		return yaml.MarshalWithOptions(str, yaml.UseLiteralStyleIfMultiline(true))
+	}
+
+	var b bytes.Buffer
+	if trailing == 0 {
+		b.WriteString(">-\n")
+	} else {
+		b.WriteString(">\n")
+	}
+	for i, line := range lines {
+		if line != "" {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+		// A single line break between lines folds into a space, so each
+		// line break after a line with content is written as an empty line
+		if line != "" && i < len(lines)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// blockSafe reports whether go-yaml writes a string with the given lines
+// and number of trailing newlines as a block scalar that decodes to the same
+// string. Lines starting with whitespace need an indentation indicator or
+// are not folded, "\r" is not preserved, and go-yaml drops the extra
+// newlines of a keep (+) chomping indicator.
+func blockSafe(lines []string, trailing int) bool {
+	if trailing > 1 || lines[0] == "" {
+		return false
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.Contains(line, "\r") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithYAMLMultilineStyle(t *testing.T) {
+	input := map[string]interface{}{"text": "line1\nline2\n", "name": "plain"}
+
+	tests := []struct {
+		style jqyaml.MultilineStyle
+		want  string
+	}{
+		{jqyaml.MultilineLiteral, "name: plain\ntext: |\n  line1\n  line2\n"},
+		{jqyaml.MultilineFolded, "name: plain\ntext: >\n  line1\n\n  line2\n"},
+		{jqyaml.MultilineDoubleQuoted, "name: plain\ntext: \"line1\\nline2\\n\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style.String(), func(t *testing.T) {
+			p, err := jqyaml.New(
+				jqyaml.WithQuery("."),
+				// The style overrides the pass-through option
+				jqyaml.WithDefaultEncodeOptions(yaml.UseLiteralStyleIfMultiline(tt.style != jqyaml.MultilineLiteral)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			err = p.Execute(context.Background(), input,
+				jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+				jqyaml.WithYAMLMultilineStyle(tt.style))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestMultilineStyleRoundTrip checks that the output of each style decodes to the original strings
+func TestMultilineStyleRoundTrip(t *testing.T) {
+	values := []interface{}{
+		"a\nb",
+		"a\nb\n",
+		"a\n\nb\n\n\n",
+		"\nleading",
+		"  indented\nlines",
+		"crlf\r\nline",
+		"single line",
+		"true",
+		map[string]interface{}{"nested": map[string]interface{}{"deep": []interface{}{"x\ny", "z"}}},
+	}
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, style := range []jqyaml.MultilineStyle{jqyaml.MultilineLiteral, jqyaml.MultilineFolded, jqyaml.MultilineDoubleQuoted} {
+		for _, v := range values {
+			var buf bytes.Buffer
+			err := p.Execute(context.Background(), v,
+				jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+				jqyaml.WithYAMLMultilineStyle(style))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got interface{}
+			if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Errorf("%s %q: invalid YAML %q: %v", style, v, buf.String(), err)
+				continue
+			}
+			if diff := cmp.Diff(v, got); diff != "" {
+				t.Errorf("%s %q: round trip mismatch in %q (-want +got):\n%s", style, v, buf.String(), diff)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// nilMarshaler fails on nil, showing that null input is not converted
+type nilMarshaler struct{}
+
+func (nilMarshaler) Marshal(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, errors.New("unexpected conversion")
+	}
+	return v, nil
+}
+
+func TestNullInput(t *testing.T) {
+	t.Run("variables", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("[., $x]"), jqyaml.WithInputMarshaler(nilMarshaler{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, nil, jqyaml.WithNullInput(), jqyaml.WithVariables(map[string]interface{}{"x": 1}))
+		if diff := cmp.Diff([]interface{}{[]interface{}{nil, 1}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("no inputs without a reader", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("[inputs]"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, nil, jqyaml.WithNullInput())
+		if diff := cmp.Diff([]interface{}{[]interface{}{}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("inputs from a reader", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("reduce inputs.n as $n (0; . + $n)"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collectReader(t, p, "n: 1\n---\nn: 2\n---\nn: 3\n", jqyaml.FormatYAML, jqyaml.WithNullInput())
+		if diff := cmp.Diff([]interface{}{6}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+
+		var buf bytes.Buffer
+		err = p.Execute(context.Background(), nil,
+			jqyaml.WithReaderInput(strings.NewReader(`{"n": 4} {"n": 5}`), jqyaml.FormatJSON),
+			jqyaml.WithNullInput(),
+			jqyaml.WithWriter(&buf, jqyaml.FormatJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != "9\n" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("non-nil input", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("."))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Execute(context.Background(), 1, jqyaml.WithNullInput(), jqyaml.WithCallback(func(interface{}) error { return nil }))
+		if err == nil {
+			t.Error("expected an error for a non-nil input")
+		}
+	})
+}
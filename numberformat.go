@@ -0,0 +1,165 @@
+package jqyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// NumberFormatter converts a number found in a result (int, float64, or
+// *big.Int) to the value written to the output, typically a string
+type NumberFormatter func(n interface{}) (interface{}, error)
+
+// NumberFormat renders numbers with a fixed number of decimals and
+// locale-specific separators, e.g. NumberFormat{Decimals: 2, ThousandsSeparator: ","}
+// renders 1234.5 as "1,234.50"
+type NumberFormat struct {
+	// Decimals is the number of digits after the decimal separator.
+	// A negative value keeps the shortest representation of the number.
+	Decimals int
+	// DecimalSeparator separates the fractional part (default ".")
+	DecimalSeparator string
+	// ThousandsSeparator separates groups of three integer digits (default none)
+	ThousandsSeparator string
+}
+
+// Format renders n as a string. It implements NumberFormatter.
+func (f NumberFormat) Format(n interface{}) (interface{}, error) {
+	var s string
+	switch n := n.(type) {
+	case int:
+		s = f.padDecimals(strconv.Itoa(n))
+	case float64:
+		s = strconv.FormatFloat(n, 'f', f.Decimals, 64)
+	case *big.Int:
+		s = f.padDecimals(n.String())
+	default:
+		return nil, fmt.Errorf("not a number: %T", n)
+	}
+	return f.separate(s), nil
+}
+
+// padDecimals pads an integer's digits with the configured decimals
+func (f NumberFormat) padDecimals(digits string) string {
+	if f.Decimals > 0 {
+		return digits + "." + strings.Repeat("0", f.Decimals)
+	}
+	return digits
+}
+
+// separate applies the configured separators to a number formatted with "."
+func (f NumberFormat) separate(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, fracPart, hasFrac := strings.Cut(s, ".")
+
+	if f.ThousandsSeparator != "" && len(intPart) > 3 {
+		var b strings.Builder
+		first := len(intPart) % 3
+		if first > 0 {
+			b.WriteString(intPart[:first])
+		}
+		for i := first; i < len(intPart); i += 3 {
+			if b.Len() > 0 {
+				b.WriteString(f.ThousandsSeparator)
+			}
+			b.WriteString(intPart[i : i+3])
+		}
+		intPart = b.String()
+	}
+
+	if !hasFrac {
+		return sign + intPart
+	}
+	decimalSeparator := f.DecimalSeparator
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	return sign + intPart + decimalSeparator + fracPart
+}
+
+// numberFormatStage replaces every number in a result using a NumberFormatter
+type numberFormatStage struct {
+	format NumberFormatter
+}
+
+func newNumberFormatStage(format NumberFormatter) stageSpec {
+	return stageSpec{
+		name: "number_format",
+		build: func(*execution) (resultStage, error) {
+			if format == nil {
+				return nil, fmt.Errorf("number formatter cannot be nil")
+			}
+			return &numberFormatStage{format: format}, nil
+		},
+		ordered: true,
+	}
+}
+
+func (s *numberFormatStage) emit(v interface{}, next func(interface{}) error) error {
+	formatted, err := s.apply(v)
+	if err != nil {
+		return err
+	}
+	return next(formatted)
+}
+
+func (s *numberFormatStage) flush(func(interface{}) error) error {
+	return nil
+}
+
+// apply walks v and formats the numbers it contains
+func (s *numberFormatStage) apply(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int, float64, *big.Int:
+		return s.format(v)
+	case json.Number, int64, uint64:
+		// Numbers of ordered conversions are passed as gojq would see them
+		return s.format(normalizeNumbers(v))
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			formatted, err := s.apply(elem)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = formatted
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			formatted, err := s.apply(elem)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = formatted
+		}
+		return result, nil
+	case yaml.MapSlice:
+		// Ordered objects keep their key order
+		result := make(yaml.MapSlice, len(v))
+		for i, item := range v {
+			formatted, err := s.apply(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = formatted.(yaml.MapItem)
+		}
+		return result, nil
+	case yaml.MapItem:
+		formatted, err := s.apply(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return yaml.MapItem{Key: v.Key, Value: formatted}, nil
+	default:
+		return v, nil
+	}
+}
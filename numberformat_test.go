@@ -0,0 +1,105 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestNumberFormat(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-12345678901234567890", 10)
+
+	tests := []struct {
+		name   string
+		format jqyaml.NumberFormat
+		input  interface{}
+		want   string
+	}{
+		{name: "fixed decimals", format: jqyaml.NumberFormat{Decimals: 2}, input: 3.14159, want: "3.14"},
+		{name: "thousands", format: jqyaml.NumberFormat{ThousandsSeparator: ","}, input: 1234567, want: "1,234,567"},
+		{name: "short integer", format: jqyaml.NumberFormat{ThousandsSeparator: ","}, input: 123, want: "123"},
+		{name: "negative", format: jqyaml.NumberFormat{Decimals: 1, ThousandsSeparator: ","}, input: -1234.56, want: "-1,234.6"},
+		{name: "integer with decimals", format: jqyaml.NumberFormat{Decimals: 2}, input: 42, want: "42.00"},
+		{
+			name:   "european",
+			format: jqyaml.NumberFormat{Decimals: 2, DecimalSeparator: ",", ThousandsSeparator: "."},
+			input:  1234567.891,
+			want:   "1.234.567,89",
+		},
+		{name: "shortest", format: jqyaml.NumberFormat{Decimals: -1, ThousandsSeparator: " "}, input: 12345.625, want: "12 345.625"},
+		{name: "big int", format: jqyaml.NumberFormat{ThousandsSeparator: ","}, input: huge, want: "-12,345,678,901,234,567,890"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.format.Format(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%v) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithNumberFormatter(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[] | {name, total, counts}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := []map[string]interface{}{
+		{"name": "widgets", "total": 1234.5, "counts": []int{1000, 2}},
+	}
+
+	var buf bytes.Buffer
+	err = p.Execute(context.Background(), input,
+		jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+		jqyaml.WithNumberFormatter(jqyaml.NumberFormat{Decimals: 2, ThousandsSeparator: ","}.Format),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "counts:\n- 1,000.00\n- \"2.00\"\nname: widgets\ntotal: 1,234.50\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestWithNumberFormatterOrdered(t *testing.T) {
+	// Without a query, conversions keep the key order with a number formatter
+	p, err := jqyaml.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := jqyaml.WithNumberFormatter(jqyaml.NumberFormat{Decimals: -1, ThousandsSeparator: ","}.Format)
+
+	tests := []struct {
+		name   string
+		input  string
+		format jqyaml.Format
+	}{
+		{name: "json", input: `{"total": 1234.5, "counts": [1000, {"size": 12345678901234567890}]}`, format: jqyaml.FormatJSON},
+		{name: "yaml", input: "total: 1234.5\ncounts:\n- 1000\n- size: 12345678901234567890\n", format: jqyaml.FormatYAML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := p.ExecuteReader(context.Background(), strings.NewReader(tt.input), tt.format,
+				jqyaml.WithWriter(&buf, jqyaml.FormatYAML), format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := "total: 1,234.5\ncounts:\n- 1,000\n- size: 12,345,678,901,234,567,890\n"
+			if got := buf.String(); got != want {
+				t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+			}
+		})
+	}
+}
@@ -1,8 +1,13 @@
 package jqyaml
 
 import (
+	"context"
+	"crypto"
 	"fmt"
 	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
 	"time"
 
 	"github.com/goccy/go-yaml"
@@ -29,6 +34,15 @@ func WithDefaultEncodeOptions(opts ...yaml.EncodeOption) Option {
 	}
 }
 
+// WithDefaultDecodeOptions sets default decoding options
+// These options apply when ExecuteReader decodes JSON or YAML input
+func WithDefaultDecodeOptions(opts ...yaml.DecodeOption) Option {
+	return func(p *pipeline) error {
+		p.defaultDecodeOptions = append(p.defaultDecodeOptions, opts...)
+		return nil
+	}
+}
+
 // WithCompilerOptions sets gojq compiler options
 func WithCompilerOptions(opts ...gojq.CompilerOption) Option {
 	return func(p *pipeline) error {
@@ -49,6 +63,301 @@ func WithInputMarshaler(marshaler InputMarshaler) Option {
 	}
 }
 
+// WithDefaultWriter sets the output writer and format used when Execute is
+// called without WithWriter, WithEncoder, or WithCallback. It replaces a
+// default encoder.
+func WithDefaultWriter(w io.Writer, format Format) Option {
+	return func(p *pipeline) error {
+		if w == nil {
+			return fmt.Errorf("default writer cannot be nil")
+		}
+		p.defaultWriter = w
+		p.defaultFormat = format
+		p.defaultEncoder = nil
+		return nil
+	}
+}
+
+// WithDefaultEncoder sets the encoder used when Execute is called without
+// WithWriter, WithEncoder, or WithCallback. It replaces a default writer.
+func WithDefaultEncoder(enc Encoder) Option {
+	return func(p *pipeline) error {
+		if enc == nil {
+			return fmt.Errorf("default encoder cannot be nil")
+		}
+		p.defaultEncoder = enc
+		p.defaultWriter = nil
+		return nil
+	}
+}
+
+// WithDefaultTimeout sets the execution timeout used when Execute is called
+// without WithTimeout. Zero means no timeout. Defaults to DefaultTimeout.
+func WithDefaultTimeout(timeout time.Duration) Option {
+	return func(p *pipeline) error {
+		if timeout < 0 {
+			return fmt.Errorf("default timeout cannot be negative: %s", timeout)
+		}
+		p.timeout = timeout
+		return nil
+	}
+}
+
+// WithNoTimeout disables the default execution timeout
+func WithNoTimeout() Option {
+	return WithDefaultTimeout(0)
+}
+
+// WithExecutionMetadata binds variables describing each execution:
+// $__now (the start time in RFC 3339, UTC), $__pipeline (the SHA-256 of the query
+// in hex) and $__hostname. Variables set by execute options take precedence.
+func WithExecutionMetadata() Option {
+	return func(p *pipeline) error {
+		p.metadata = true
+		return nil
+	}
+}
+
+// WithClock sets the clock used for $__now, e.g. to get reproducible output in tests
+func WithClock(clock func() time.Time) Option {
+	return func(p *pipeline) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		p.clock = clock
+		return nil
+	}
+}
+
+// WithLookup defines a jq function name(key) that returns the value of key in table,
+// or null if it is missing, for enrichment joins such as ID to name.
+// Keys that are not strings are looked up by their JSON text, so id 42 finds "42".
+// The table is converted with the pipeline's input marshaler once, in New.
+func WithLookup(name string, table map[string]interface{}) Option {
+	return func(p *pipeline) error {
+		if name == "" {
+			return fmt.Errorf("lookup function name must not be empty")
+		}
+		p.lookups = append(p.lookups, lookupTable{name: name, table: table})
+		return nil
+	}
+}
+
+// WithLookupCSV defines a lookup function like WithLookup from CSV data.
+// The first row is the header; each following row maps the value of its
+// first column to an object of all its columns keyed by the header.
+func WithLookupCSV(name string, r io.Reader) Option {
+	return func(p *pipeline) error {
+		table, err := readLookupCSV(r)
+		if err != nil {
+			return fmt.Errorf("failed to read lookup table %s: %w", name, err)
+		}
+		return WithLookup(name, table)(p)
+	}
+}
+
+// WithFlattenFunctions defines the flatten_keys and unflatten_keys functions,
+// the jq counterparts of Flatten and Unflatten. Both take an optional
+// separator, DefaultFlattenSeparator by default:
+// `flatten_keys("_")`, `unflatten_keys`.
+func WithFlattenFunctions() Option {
+	return func(p *pipeline) error {
+		p.compilerOptions = append(p.compilerOptions, flattenFunctions()...)
+		return nil
+	}
+}
+
+// WithHTTPFunction defines the httpget(url) function, which fetches url with a GET
+// request and returns the response body, decoded if it is JSON. Only http and
+// https URLs whose host is in allowlist may be fetched, including redirect
+// targets; an entry "*.example.com" allows the subdomains of example.com.
+// If client is nil or has no timeout, each request times out after
+// DefaultHTTPTimeout. Responses larger than MaxHTTPResponseBytes are rejected.
+// It is not supported on wasip1.
+func WithHTTPFunction(client *http.Client, allowlist []string) Option {
+	return func(p *pipeline) error {
+		if len(allowlist) == 0 {
+			return fmt.Errorf("httpget allowlist must not be empty")
+		}
+		if runtime.GOOS == "wasip1" {
+			return fmt.Errorf("httpget is not supported on %s, which has no network access", runtime.GOOS)
+		}
+		p.http = newHTTPFunction(client, allowlist)
+		return nil
+	}
+}
+
+// WithEnvAccess makes $ENV and env return the environment of the process,
+// as in the jq command, e.g. $ENV.HOME. The environment is read when each
+// execution starts and converted like the variables; a variable named ENV
+// set by execute options takes precedence. Without this option both are an
+// empty object, so filters cannot read secrets from the environment.
+func WithEnvAccess() Option {
+	return func(p *pipeline) error {
+		p.envAccess = true
+		return nil
+	}
+}
+
+// WithQuota enforces q on the executions of the pipeline, charging each to
+// the principal returned by q.Principal, for multi-tenant filter services.
+// Executions over a limit fail with a QuotaExceededError; usage is counted
+// in fixed windows of q.Window measured with the pipeline's clock.
+func WithQuota(q Quota) Option {
+	return func(p *pipeline) error {
+		if q.Window <= 0 {
+			return fmt.Errorf("quota window must be positive: %s", q.Window)
+		}
+		if q.MaxExecutions < 0 || q.MaxResults < 0 || q.MaxOutputBytes < 0 {
+			return fmt.Errorf("quota limits must not be negative")
+		}
+		p.quota = newQuotaState(q)
+		return nil
+	}
+}
+
+// externalJQ is the command of WithExternalJQ
+type externalJQ struct {
+	path string
+	args []string
+}
+
+// WithExternalJQ runs the query with the jq (or gojq) command at path
+// instead of in process, while input conversion, variables, result stages
+// and output formatting stay in the pipeline. The variables and then each
+// input are passed as JSON on stdin, so that their values, secrets included,
+// do not appear in the command line of the process. This verifies parity
+// with jq, or isolates untrusted filters in a separate process: args come
+// before the jq arguments, so path can be a wrapper applying rlimits, e.g.
+// WithExternalJQ("prlimit", "--as=1073741824", "--cpu=10", "jq").
+// The command gets an empty environment unless WithEnvAccess is given.
+// Go functions registered with the pipeline cannot be called by the
+// command, and queries of result stages still run in process. It is not
+// supported on js and wasip1.
+func WithExternalJQ(path string, args ...string) Option {
+	return func(p *pipeline) error {
+		if runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+			return fmt.Errorf("external jq is not supported on %s, which cannot start processes", runtime.GOOS)
+		}
+		if path == "" {
+			return fmt.Errorf("external jq path must not be empty")
+		}
+		p.externalJQ = &externalJQ{path: path, args: args}
+		return nil
+	}
+}
+
+// ExternalJQHooks let operators harden the process of WithExternalJQ, e.g.
+// with namespaces, credentials or a cgroup set in cmd.SysProcAttr, or a
+// wrapper applying seccomp or landlock set as cmd.Path and cmd.Args
+type ExternalJQHooks struct {
+	// BeforeStart is called with each command before it starts; an error
+	// fails the execution without starting it
+	BeforeStart func(ctx context.Context, cmd *exec.Cmd) error
+	// AfterExit is called once the command has exited or failed to start,
+	// if BeforeStart succeeded, e.g. to release a cgroup or to record usage
+	// from cmd.ProcessState, which is nil if the command did not start. An
+	// error fails the execution.
+	AfterExit func(ctx context.Context, cmd *exec.Cmd) error
+}
+
+// WithExternalJQHooks sets hooks run around each process of WithExternalJQ,
+// which is required
+func WithExternalJQHooks(hooks ExternalJQHooks) Option {
+	return func(p *pipeline) error {
+		p.externalJQHooks = hooks
+		return nil
+	}
+}
+
+// WithRegexLimits bounds the regular expression builtins (test, match, capture,
+// scan, split/2, splits, sub and gsub) for untrusted filters. Go's regexp
+// package guarantees matching in time linear in the size of the pattern and the
+// input, without backtracking, so bounding both bounds the CPU time of each
+// match. Calls exceeding maxPatternBytes or maxInputBytes fail with a
+// RegexLimitError; zero means no limit.
+func WithRegexLimits(maxPatternBytes, maxInputBytes int) Option {
+	return func(p *pipeline) error {
+		if maxPatternBytes < 0 || maxInputBytes < 0 {
+			return fmt.Errorf("regex limits must not be negative: pattern %d, input %d", maxPatternBytes, maxInputBytes)
+		}
+		p.regexLimits = &regexLimits{maxPattern: maxPatternBytes, maxInput: maxInputBytes}
+		return nil
+	}
+}
+
+// WithResultCache caches the results of the query keyed by a hash of the query,
+// the converted input and the variables, so that identical executions skip
+// evaluation. Entries expire after ttl (measured with the pipeline's clock);
+// zero means they don't expire. Executions using custom functions, compiler
+// options, lookups, httpget or execution metadata are not cached. Cached
+// results are shared between executions and must not be modified.
+func WithResultCache(cache ResultCache, ttl time.Duration) Option {
+	return func(p *pipeline) error {
+		if cache == nil {
+			return fmt.Errorf("result cache must not be nil")
+		}
+		p.resultCache = &resultCache{cache: cache, ttl: ttl}
+		return nil
+	}
+}
+
+// WithQueryCache shares compiled queries through cache, so that pipelines
+// constructed from the same filter compile it once. Use DefaultQueryCache for
+// a process-wide cache. Compilations with compiler options, custom functions,
+// lookups or httpget are not cached.
+func WithQueryCache(cache *QueryCache) Option {
+	return func(p *pipeline) error {
+		if cache == nil {
+			return fmt.Errorf("query cache must not be nil")
+		}
+		p.queryCache = cache
+		return nil
+	}
+}
+
+// WithDefaultExecuteOptions applies opts to every execution of the pipeline
+// before the options passed to the call, which take precedence
+func WithDefaultExecuteOptions(opts ...ExecuteOption) Option {
+	return func(p *pipeline) error {
+		p.executeOptions = append(p.executeOptions, opts...)
+		return nil
+	}
+}
+
+// WithValueResolver lets the default input conversion replace values it
+// cannot handle, such as func-valued fields of lazy loaders or ORM relations,
+// with the data returned by resolver. The resolver is consulted only for
+// values that fail to convert; their containers are then converted element
+// by element, with struct fields named by their json tags. It has no effect
+// with WithInputMarshaler.
+func WithValueResolver(resolver ValueResolver) Option {
+	return func(p *pipeline) error {
+		if resolver == nil {
+			return fmt.Errorf("value resolver must not be nil")
+		}
+		p.valueResolver = resolver
+		return nil
+	}
+}
+
+// WithBinaryInput converts []byte and io.Reader values in the input and
+// variables according to policy, e.g. so that structs holding response bodies
+// can be queried. Readers are read fully during conversion. By default
+// []byte values become arrays of numbers and readers are converted like
+// other structs. It has no effect with WithInputMarshaler.
+func WithBinaryInput(policy BinaryPolicy) Option {
+	return func(p *pipeline) error {
+		switch policy {
+		case BinaryString, BinaryBase64, BinarySkip:
+		default:
+			return fmt.Errorf("invalid binary policy: %s", policy)
+		}
+		p.binaryPolicy = policy
+		return nil
+	}
+}
+
 // ExecuteOption configures the execution
 type ExecuteOption func(*executeConfig)
 
@@ -56,6 +365,7 @@ type ExecuteOption func(*executeConfig)
 func WithEncoder(encoder Encoder) ExecuteOption {
 	return func(c *executeConfig) {
 		c.encoder = encoder
+		c.setOutput(outputEncoder)
 	}
 }
 
@@ -65,23 +375,285 @@ func WithWriter(w io.Writer, format Format) ExecuteOption {
 		// Store the writer and format for later processing in Execute
 		c.writer = w
 		c.format = format
+		c.setOutput(outputWriter)
+	}
+}
+
+// WithReaderInput makes Execute decode its input from r in format, as
+// ExecuteReader does, instead of taking a Go value; the input argument of
+// Execute must then be nil. This keeps input decoding in the pipeline's
+// options alongside WithWriter for callers that always call Execute.
+func WithReaderInput(r io.Reader, format Format) ExecuteOption {
+	return func(c *executeConfig) {
+		if r == nil {
+			if c.err == nil {
+				c.err = fmt.Errorf("reader input cannot be nil")
+			}
+			return
+		}
+		c.reader = r
+		c.readerFormat = format
 	}
 }
 
-// WithVariables sets jq variables (accepts any Go object, including structs with json tags)
+// WithNullInput runs the pipeline once with null as its input, like jq -n,
+// so that results come from variables or the input and inputs functions.
+// Execute must then be given a nil input, which is not converted; with
+// ExecuteReader or WithReaderInput the documents of the stream are only
+// read by input and inputs.
+func WithNullInput() ExecuteOption {
+	return func(c *executeConfig) {
+		c.nullInput = true
+	}
+}
+
+// WithRecordCipher encrypts the bytes the output format writes for each
+// result with encrypt before they reach the WithWriter writer, e.g. for
+// exporting sensitive records to shared storage with envelope encryption.
+// Combined with WithDigest, the digest is that of the encrypted output.
+// Formats that write the whole file when closed, such as some registered
+// output formats, are encrypted as a single record.
+func WithRecordCipher(encrypt EncryptFunc) ExecuteOption {
+	return func(c *executeConfig) {
+		if encrypt == nil {
+			if c.err == nil {
+				c.err = fmt.Errorf("record cipher cannot be nil")
+			}
+			return
+		}
+		c.recordCipher = encrypt
+	}
+}
+
+// digestOutput receives the digest of the output requested by WithDigest
+type digestOutput struct {
+	hash crypto.Hash
+	out  *[]byte
+}
+
+// WithDigest hashes the bytes written to the WithWriter writer and stores
+// the digest in *out when Execute returns, so that exported files can be
+// checked without reading them again. The digest covers the output as
+// written, including newline conversion and byte order marks, and is stored
+// for partial output when Execute fails. The hash must be linked into the
+// binary, as by importing crypto/sha256.
+func WithDigest(hash crypto.Hash, out *[]byte) ExecuteOption {
+	return func(c *executeConfig) {
+		if c.err != nil {
+			return
+		}
+		switch {
+		case out == nil:
+			c.err = fmt.Errorf("digest output cannot be nil")
+		case !hash.Available():
+			c.err = fmt.Errorf("digest hash %v is not available", hash)
+		default:
+			c.digest = &digestOutput{hash: hash, out: out}
+		}
+	}
+}
+
+// WithStreamInput decomposes JSON and JSON Lines input of ExecuteReader or
+// WithReaderInput into the events of jq --stream, so that huge documents
+// are processed without holding them in memory: [path, leaf] for each
+// scalar and empty array or object, and [path] after the last element of
+// each other array or object, where path is that of the last element.
+// Each event is a document the pipeline runs on, or that input and inputs
+// return; fromstream reassembles values from events, e.g.
+// fromstream(1 | truncate_stream(inputs)) with WithNullInput.
+func WithStreamInput() ExecuteOption {
+	return func(c *executeConfig) {
+		c.streamInput = true
+	}
+}
+
+// WithVariables sets jq variables (accepts any Go object, including structs with json tags).
+// Values are converted exactly like the input, by the input marshaler or else
+// with the encode options, so custom marshalers apply to nested values too.
 func WithVariables(vars map[string]interface{}) ExecuteOption {
 	return func(c *executeConfig) {
 		c.variables = vars
 	}
 }
 
-// WithTimeout sets execution timeout
+// setTypedArg binds the jq value v to the variable name
+func (c *executeConfig) setTypedArg(name string, v interface{}) {
+	if c.typedArgs == nil {
+		c.typedArgs = make(map[string]interface{})
+	}
+	c.typedArgs[name] = v
+}
+
+// WithArgString binds the string value to $name, like --arg of the jq
+// command. Typed arguments are not converted by the input marshaler and take
+// precedence over variables of the same name.
+func WithArgString(name, value string) ExecuteOption {
+	return func(c *executeConfig) {
+		c.setTypedArg(name, value)
+	}
+}
+
+// WithArgJSON binds the JSON text to $name, like --argjson of the jq
+// command. Integers are kept exactly rather than becoming floats.
+func WithArgJSON(name, text string) ExecuteOption {
+	return func(c *executeConfig) {
+		v, err := decodeJSONLine([]byte(text))
+		if err != nil {
+			if c.err == nil {
+				c.err = fmt.Errorf("invalid JSON for argument %s: %w", name, err)
+			}
+			return
+		}
+		c.setTypedArg(name, normalizeNumbers(v))
+	}
+}
+
+// WithArgRaw binds data as a string to $name, like --rawfile of the jq
+// command
+func WithArgRaw(name string, data []byte) ExecuteOption {
+	return func(c *executeConfig) {
+		c.setTypedArg(name, string(data))
+	}
+}
+
+// WithPositionalArgs sets $ARGS.positional, as the --args and --jsonargs
+// options of the jq command do. $ARGS.named holds the variables set by
+// WithVariables and the other variable options, so scripts written for the
+// jq command run unchanged. The arguments are converted like the variables.
+func WithPositionalArgs(args ...interface{}) ExecuteOption {
+	return func(c *executeConfig) {
+		c.positionalArgs = append(c.positionalArgs, args...)
+	}
+}
+
+// WithVariablesFromStruct binds each exported field of the struct v (or pointer to struct)
+// as a jq variable, named by its json tag or else its field name, e.g. $min_value.
+// Fields tagged "-" are skipped, omitempty is ignored so that every variable stays
+// defined, and fields of embedded structs are promoted as in encoding/json.
+// The variables are added to those set by previous options.
+func WithVariablesFromStruct(v interface{}) ExecuteOption {
+	return func(c *executeConfig) {
+		vars, err := structVariables(v)
+		if err != nil {
+			if c.err == nil {
+				c.err = err
+			}
+			return
+		}
+		merged := make(map[string]interface{}, len(c.variables)+len(vars))
+		for k, v := range c.variables {
+			merged[k] = v
+		}
+		for k, v := range vars {
+			merged[k] = v
+		}
+		c.variables = merged
+	}
+}
+
+// WithSecretVariables binds string variables like WithVariables, but their values
+// are replaced with "[REDACTED]" in the messages of errors returned by the
+// execution, so that tokens used in filters do not leak into logs.
+// Query results are not redacted.
+func WithSecretVariables(vars map[string]string) ExecuteOption {
+	return func(c *executeConfig) {
+		variables := make(map[string]interface{}, len(c.variables)+len(vars))
+		for k, v := range c.variables {
+			variables[k] = v
+		}
+		if c.secrets == nil {
+			c.secrets = make(map[string]string, len(vars))
+		}
+		for k, v := range vars {
+			variables[k] = v
+			c.secrets[k] = v
+		}
+		c.variables = variables
+	}
+}
+
+// WithExecFunction registers a custom jq function for this execution only, like
+// gojq.WithFunction. Since the query is compiled for each execution, f may close
+// over request-scoped state, and concurrent executions of a shared Pipeline
+// each see their own function.
+func WithExecFunction(name string, minarity, maxarity int, f func(interface{}, []interface{}) interface{}) ExecuteOption {
+	return func(c *executeConfig) {
+		c.compilerOptions = append(c.compilerOptions, gojq.WithFunction(name, minarity, maxarity, f))
+	}
+}
+
+// WithExecIterFunction registers a custom jq function yielding multiple values for
+// this execution only, like gojq.WithIterFunction
+func WithExecIterFunction(name string, minarity, maxarity int, f func(interface{}, []interface{}) gojq.Iter) ExecuteOption {
+	return func(c *executeConfig) {
+		c.compilerOptions = append(c.compilerOptions, gojq.WithIterFunction(name, minarity, maxarity, f))
+	}
+}
+
+// WithTimeout sets execution timeout, overriding the pipeline default
+// Zero means no timeout
 func WithTimeout(timeout time.Duration) ExecuteOption {
 	return func(c *executeConfig) {
 		c.timeout = timeout
 	}
 }
 
+// WithMaxCPU limits the evaluation of the queries in this execution,
+// including the queries of result stages, to d of CPU time. The budget is
+// counted in gojq instructions, 20 million per second, rather than measured,
+// so the same query on the same input fails or succeeds however loaded the
+// host is. Unlike WithTimeout, writing output or reading input does not
+// count, so a slow writer doesn't exhaust the budget while a runaway filter
+// still fails with CPULimitError. Zero means no limit.
+func WithMaxCPU(d time.Duration) ExecuteOption {
+	return func(c *executeConfig) {
+		c.maxCPU = d
+	}
+}
+
+// WithYieldEvery calls runtime.Gosched and checks the context every n steps
+// of the query iterators, so that hot loops emitting many results stay
+// responsive to cancellation and don't starve other goroutines. Zero or a
+// negative n disables it.
+func WithYieldEvery(n int) ExecuteOption {
+	return func(c *executeConfig) {
+		c.yieldEvery = n
+		if n < 0 {
+			c.yieldEvery = 0
+		}
+	}
+}
+
+// WithPipelineBuffer runs the output (encoder, writer or callback) in a separate
+// goroutine, connected to query evaluation by a channel buffering up to n
+// results. This improves throughput when the writer is slow and the query is
+// CPU-bound. Output still happens in order and has finished when Execute
+// returns; after an output error, evaluation stops at the next result.
+// Zero or a negative n keeps output synchronous.
+func WithPipelineBuffer(n int) ExecuteOption {
+	return func(c *executeConfig) {
+		c.outputBuffer = n
+		if n < 0 {
+			c.outputBuffer = 0
+		}
+	}
+}
+
+// WithInputChunks bounds the memory used to convert a large slice input when the
+// query has the shape `.[] | ...`: the slice is converted and queried in
+// chunks of size elements instead of being deep-converted up front. The
+// results are the same as without chunking; other inputs and queries are
+// processed as usual. ExecutePaths converts the whole slice, since paths index
+// into it. Zero or a negative size disables chunking.
+func WithInputChunks(size int) ExecuteOption {
+	return func(c *executeConfig) {
+		c.inputChunks = size
+		if size < 0 {
+			c.inputChunks = 0
+		}
+	}
+}
+
 // WithEncodeOptions sets encoding options for both jq conversion and output formatting
 func WithEncodeOptions(opts ...yaml.EncodeOption) ExecuteOption {
 	return func(c *executeConfig) {
@@ -89,10 +661,158 @@ func WithEncodeOptions(opts ...yaml.EncodeOption) ExecuteOption {
 	}
 }
 
+// WithDecodeOptions sets decoding options for JSON or YAML input read by ExecuteReader
+func WithDecodeOptions(opts ...yaml.DecodeOption) ExecuteOption {
+	return func(c *executeConfig) {
+		c.decodeOptions = append(c.decodeOptions, opts...)
+	}
+}
+
+// WithStrictInput makes ExecuteReader reject objects with duplicate keys
+// instead of keeping the last value. The DecodeError reports the position of
+// the duplicate key. Aliases to undefined YAML anchors are always rejected.
+func WithStrictInput() ExecuteOption {
+	return func(c *executeConfig) {
+		c.strictInput = true
+	}
+}
+
+// WithInputEncoding sets the character encoding of ExecuteReader input
+// instead of detecting it. Input is transcoded to UTF-8 before decoding.
+func WithInputEncoding(encoding InputEncoding) ExecuteOption {
+	return func(c *executeConfig) {
+		c.inputEncoding = encoding
+	}
+}
+
+// WithInputLineNumber defines the input_line_number function, which returns the
+// line on which the current document of ExecuteReader input starts.
+// It returns null in Execute, where there is no input stream.
+func WithInputLineNumber() ExecuteOption {
+	return func(c *executeConfig) {
+		c.inputLineNumber = true
+	}
+}
+
+// WithCollectErrors continues with the next input record when one fails
+// instead of returning the first error. Query, conversion and result size
+// errors and decode errors of single documents are collected, and the
+// returned error joins them with errors.Join. Processing stops once max errors
+// have been collected; zero or a negative max collects all errors. Errors
+// that affect the whole execution, such as timeouts and write errors, still
+// stop processing immediately.
+func WithCollectErrors(max int) ExecuteOption {
+	return func(c *executeConfig) {
+		c.collectErrors = true
+		c.maxErrors = max
+		if max < 0 {
+			c.maxErrors = 0
+		}
+	}
+}
+
 // WithCallback sets a callback for streaming mode
 func WithCallback(callback func(interface{}) error) ExecuteOption {
 	return func(c *executeConfig) {
-		c.callback = callback
+		c.setCallback(callback)
+	}
+}
+
+// WithBatchCallback passes the results to fn in slices of size results, e.g.
+// for bulk APIs; the last batch may be smaller. It replaces the output like
+// WithCallback, and fn may retain the slices.
+func WithBatchCallback(size int, fn func([]interface{}) error) ExecuteOption {
+	return func(c *executeConfig) {
+		if size <= 0 || fn == nil {
+			if c.err == nil {
+				c.err = fmt.Errorf("batch callback requires a positive size and a function: size=%d", size)
+			}
+			return
+		}
+		batch := &batchOutput{size: size, fn: fn}
+		c.setCallback(batch.add)
+		c.batch = batch
+	}
+}
+
+// WithFlushInterval passes a partially filled WithBatchCallback batch on once
+// its first result has waited for d, for near-real-time consumers. The batch
+// callback may then be called from another goroutine, but never concurrently.
+// An error from a timed flush is returned with the next result or at the end.
+func WithFlushInterval(d time.Duration) ExecuteOption {
+	return func(c *executeConfig) {
+		if d < 0 {
+			if c.err == nil {
+				c.err = fmt.Errorf("flush interval cannot be negative: %s", d)
+			}
+			return
+		}
+		c.flushInterval = d
+	}
+}
+
+// WithChannelOutput sends each result to ch, blocking while the receiver is
+// not ready, and closes ch when the execution ends, successfully or not, so
+// goroutines can range over it. A canceled or timed-out context unblocks a
+// pending send and ends the execution.
+func WithChannelOutput(ch chan<- interface{}) ExecuteOption {
+	return func(c *executeConfig) {
+		if ch == nil {
+			if c.err == nil {
+				c.err = fmt.Errorf("output channel must not be nil")
+			}
+			return
+		}
+		output := &channelOutput{ch: ch}
+		c.setCallback(output.send)
+		c.channel = output
+	}
+}
+
+// WithAckCallback calls ack with the 0-based index of each result once the
+// output has taken it: after the encoder or callback returned and, for writers
+// implementing Flush() error such as *bufio.Writer, after flushing; with
+// WithBatchCallback, after the batch callback returned. Results are
+// acknowledged in order, so a source can commit the offset of the last
+// acknowledged result for at-least-once delivery. An error from ack stops
+// the execution.
+func WithAckCallback(ack func(index int) error) ExecuteOption {
+	return func(c *executeConfig) {
+		if ack == nil {
+			if c.err == nil {
+				c.err = fmt.Errorf("ack callback must not be nil")
+			}
+			return
+		}
+		c.ack = ack
+	}
+}
+
+// WithLastOutputWins lets the output option applied last among WithWriter,
+// WithEncoder, WithCallback and WithDecodeInto replace the earlier ones
+// instead of conflicting with them, so that layered option sets can override
+// the output. ExecuteConfigString reports the replaced outputs.
+func WithLastOutputWins() ExecuteOption {
+	return func(c *executeConfig) {
+		c.lastOutputWins = true
+	}
+}
+
+// WithDecodeInto decodes each result into a destination returned by factory,
+// typically a pointer to a new struct, and passes it to handle. Decoding
+// respects the decode options of the pipeline and the execution. It replaces
+// the output like WithCallback.
+func WithDecodeInto(factory func() interface{}, handle func(interface{}) error) ExecuteOption {
+	return func(c *executeConfig) {
+		if factory == nil || handle == nil {
+			if c.err == nil {
+				c.err = fmt.Errorf("decode factory and handler must not be nil")
+			}
+			return
+		}
+		target := &decodeTarget{factory: factory, handle: handle}
+		c.setCallback(target.decode)
+		c.decodeTarget = target
 	}
 }
 
@@ -105,6 +825,17 @@ func WithCompactJSONOutput() ExecuteOption {
 	}
 }
 
+// WithCanonicalJSON writes each result in the JSON Canonicalization Scheme
+// of RFC 8785: compact, with object keys sorted, numbers in their shortest
+// double form and strings minimally escaped, so that equal results have
+// identical bytes to hash or sign. It requires WithWriter with FormatJSON or
+// FormatJSONL and cannot be combined with raw output.
+func WithCanonicalJSON() ExecuteOption {
+	return func(c *executeConfig) {
+		c.canonicalJSON = true
+	}
+}
+
 // WithPrettyJSONOutput enables pretty JSON output with indentation
 // This option only applies to JSON output format and is ignored for YAML
 func WithPrettyJSONOutput() ExecuteOption {
@@ -122,3 +853,209 @@ func WithRawJSONOutput() ExecuteOption {
 		c.rawOutput = true
 	}
 }
+
+// WithOutputSeparator writes sep after each JSON value instead of a newline,
+// e.g. "\x1e" for record-separated or "\x00" for NUL-separated consumers,
+// or "" to concatenate raw strings like jq's --join-output
+// This option applies to JSON output and to the strings written by
+// WithRawYAMLOutput
+func WithOutputSeparator(sep string) ExecuteOption {
+	return func(c *executeConfig) {
+		c.separator = &sep
+	}
+}
+
+// WithNewlineStyle sets the line endings of WithWriter output for every format,
+// e.g. NewlineCRLF for files destined for Windows tooling
+func WithNewlineStyle(style NewlineStyle) ExecuteOption {
+	return func(c *executeConfig) {
+		c.newline = style
+	}
+}
+
+// WithBOM writes a UTF-8 byte order mark before WithWriter output, for
+// consumers such as Excel that need it to detect the encoding
+func WithBOM() ExecuteOption {
+	return func(c *executeConfig) {
+		c.bom = true
+	}
+}
+
+// WithYAMLMultilineStyle sets how strings containing newlines are rendered
+// in YAML output written by WithWriter, overriding go-yaml's
+// UseLiteralStyleIfMultiline from the encode options
+func WithYAMLMultilineStyle(style MultilineStyle) ExecuteOption {
+	return func(c *executeConfig) {
+		c.multilineStyle = style
+	}
+}
+
+// WithYAMLIndentSequence indents sequences nested in mappings in YAML output
+func WithYAMLIndentSequence() ExecuteOption {
+	return func(c *executeConfig) {
+		c.yamlStyle.indentSequence = true
+	}
+}
+
+// WithYAMLSingleQuote prefers single quotes over double quotes for strings
+// that need quoting in YAML output
+func WithYAMLSingleQuote() ExecuteOption {
+	return func(c *executeConfig) {
+		c.yamlStyle.singleQuote = true
+	}
+}
+
+// WithYAMLEmptyMapNull writes empty objects as null instead of {} in YAML output
+func WithYAMLEmptyMapNull() ExecuteOption {
+	return func(c *executeConfig) {
+		c.yamlStyle.emptyMapNull = true
+	}
+}
+
+// WithRawYAMLOutput writes string results to YAML output as-is followed by a
+// newline, like gojq --yaml-output --raw-output, instead of as YAML scalars
+// (plain, quoted or block). Other results are written as YAML documents.
+// Combine with WithOutputSeparator("") for gojq's --join-output.
+func WithRawYAMLOutput() ExecuteOption {
+	return func(c *executeConfig) {
+		c.rawYAML = true
+	}
+}
+
+// WithIgnoreBrokenPipe treats EPIPE on the output writer as normal termination
+// Execute stops producing results and returns nil, matching jq's behavior when
+// its output is piped into a command like head that exits early
+func WithIgnoreBrokenPipe() ExecuteOption {
+	return func(c *executeConfig) {
+		c.ignoreBrokenPipe = true
+	}
+}
+
+// WithDedup drops results whose key was already emitted during the execution.
+// The key is computed by keyQuery, a jq expression evaluated against each
+// result; when keyQuery is empty the whole result is compared by deep equality.
+func WithDedup(keyQuery string) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newDedupStage(keyQuery))
+	}
+}
+
+// WithSortBy buffers all results and emits them sorted by the key computed by
+// keyQuery, using jq's ordering (like sort_by). When keyQuery is empty the
+// results themselves are compared. Results with equal keys keep their order.
+// All results are held in memory until the query finishes.
+func WithSortBy(keyQuery string, desc bool) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newSortStage(keyQuery, desc))
+	}
+}
+
+// WithGroupBy buffers all results and emits one array per distinct key
+// computed by keyQuery, so each group becomes a separate output document.
+// Groups are ordered by key and results keep their order within a group,
+// matching jq's group_by. All results are held in memory until the query finishes.
+func WithGroupBy(keyQuery string) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newGroupStage(keyQuery))
+	}
+}
+
+// WithSummary evaluates query over the array of all results once the main
+// query finishes, and writes its outputs to w in the given format. Results
+// still reach the regular output unchanged. When w is nil the summary is
+// appended to the regular output instead, after the last result.
+// All results are held in memory until the query finishes.
+func WithSummary(query string, w io.Writer, format Format) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newSummaryStage(query, w, format))
+	}
+}
+
+// WithResultFilter drops the results for which keep reports false, so that
+// rules implemented in Go, such as per-record access checks, apply before the
+// output. An error from keep stops the execution. Like other result stages,
+// it applies in the order the options are given.
+func WithResultFilter(keep func(v interface{}) (bool, error)) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newFilterStage(keep))
+	}
+}
+
+// WithEnricher replaces each result with the value enrich returns for it,
+// e.g. a record augmented from a database or cache. Up to concurrency
+// results are enriched at a time, so enrich must be safe for concurrent use
+// when concurrency exceeds 1; the results keep their order. The enriched
+// values are converted like the input.
+func WithEnricher(enrich Enricher, concurrency int) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newEnrichStage(enrich, concurrency))
+	}
+}
+
+// WithSignedResults replaces each result with a DSSE envelope, an object
+// with the keys "payloadType", "payload" (the result as canonical JSON, see
+// WithCanonicalJSON, in base64) and "signatures" holding the signature of
+// signer, so that deployment systems can verify where artifacts came from.
+// payloadType is DefaultPayloadType if empty. Results are signed as they
+// reach this stage, so it usually comes after the other result stages.
+func WithSignedResults(signer Signer, payloadType string) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newSignStage(signer, payloadType))
+	}
+}
+
+// WithSample emits only the first head and the last tail results. When
+// results are skipped in between, a marker object {"skipped": n} is emitted
+// in their place. Only the last tail results are buffered.
+func WithSample(head, tail int) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newSampleStage(head, tail))
+	}
+}
+
+// WithMaxResultBytes limits the size of each result, measured as compact JSON.
+// Depending on policy, an oversized result either stops the execution with a
+// ResultSizeError or is replaced by a stub object with the keys "truncated",
+// "type", "bytes", and "preview".
+func WithMaxResultBytes(n int, policy TruncatePolicy) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newSizeLimitStage(n, policy))
+	}
+}
+
+// WithNumberFormatter replaces every number in the results with the value
+// returned by format before the results are written, so reports can use
+// locale-specific number rendering without formatting numbers inside the
+// jq query. See NumberFormat for a ready-made formatter. It keeps the key
+// order of ExecuteReader conversions without a query.
+func WithNumberFormatter(format NumberFormatter) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newNumberFormatStage(format))
+	}
+}
+
+// WithInvalidUTF8 applies policy to strings and object keys in the results
+// that are not valid UTF-8, so output does not depend on how the encoder
+// handles them
+func WithInvalidUTF8(policy InvalidUTF8Policy) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stages = append(c.stages, newInvalidUTF8Stage(policy))
+	}
+}
+
+// WithIndent sets the indentation width for both output formats: yaml.Indent
+// for YAML and pretty-printing with n spaces for JSON. Like jq's --indent,
+// zero selects compact JSON output.
+func WithIndent(n int) ExecuteOption {
+	return func(c *executeConfig) {
+		if n < 0 {
+			n = 0
+		}
+		c.indent = n
+		c.compactOutputSet = true
+		c.compactOutput = n == 0
+		if n > 0 {
+			c.encodeOptions = append(c.encodeOptions, yaml.Indent(n))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package jqyaml
+
+// Output kinds recorded by the output options
+const (
+	outputWriter   = "writer"
+	outputEncoder  = "encoder"
+	outputCallback = "callback"
+)
+
+// setOutput records that an output option of kind was applied
+func (c *executeConfig) setOutput(kind string) {
+	c.outputs = append(c.outputs, kind)
+}
+
+// setCallback makes callback the output, replacing the batch, decoding and
+// channel outputs of earlier callback options
+func (c *executeConfig) setCallback(callback func(interface{}) error) {
+	c.callback = callback
+	c.decodeTarget, c.batch, c.channel = nil, nil, nil
+	c.setOutput(outputCallback)
+}
+
+// resolveOutputs keeps only the output set last when WithLastOutputWins is
+// given, recording the kinds it replaced
+func (c *executeConfig) resolveOutputs() {
+	if !c.lastOutputWins || len(c.outputs) == 0 {
+		return
+	}
+	last := c.outputs[len(c.outputs)-1]
+	for _, kind := range c.outputs[:len(c.outputs)-1] {
+		if kind != last && !containsString(c.replacedOutputs, kind) {
+			c.replacedOutputs = append(c.replacedOutputs, kind)
+		}
+	}
+	if last != outputWriter {
+		c.writer = nil
+	}
+	if last != outputEncoder {
+		c.encoder = nil
+	}
+	if last != outputCallback {
+		c.callback = nil
+		c.decodeTarget = nil
+		c.batch = nil
+		c.channel = nil
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
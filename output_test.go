@@ -0,0 +1,79 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestWithLastOutputWins(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+
+	t.Run("conflict without the option", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := p.Execute(context.Background(), 1,
+			jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+			jqyaml.WithCallback(func(interface{}) error { return nil }),
+		)
+		if err == nil {
+			t.Fatal("expected conflict error")
+		}
+	})
+
+	t.Run("callback replaces writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		var got []interface{}
+		err := p.Execute(context.Background(), 1,
+			jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+			jqyaml.WithCallback(func(v interface{}) error {
+				got = append(got, v)
+				return nil
+			}),
+			jqyaml.WithLastOutputWins(),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || buf.Len() != 0 {
+			t.Errorf("got %v and output %q, want one callback result and no output", got, buf.String())
+		}
+	})
+
+	t.Run("writer replaces encoder", func(t *testing.T) {
+		var buf bytes.Buffer
+		encoded := 0
+		err := p.Execute(context.Background(), 1,
+			jqyaml.WithLastOutputWins(),
+			jqyaml.WithEncoder(jqyaml.EncoderFunc(func(interface{}) error {
+				encoded++
+				return nil
+			})),
+			jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if encoded != 0 || buf.String() != "1\n" {
+			t.Errorf("encoded %d values and output %q, want writer output only", encoded, buf.String())
+		}
+	})
+}
+
+func TestLastOutputWinsDescribe(t *testing.T) {
+	var buf bytes.Buffer
+	got := jqyaml.ExecuteConfigString(
+		jqyaml.WithLastOutputWins(),
+		jqyaml.WithCallback(func(interface{}) error { return nil }),
+		jqyaml.WithEncoder(jqyaml.EncoderFunc(func(interface{}) error { return nil })),
+		jqyaml.WithWriter(&buf, jqyaml.FormatYAML),
+	)
+	if !strings.HasPrefix(got, "output: writer\nreplaced outputs: callback, encoder\n") {
+		t.Errorf("unexpected configuration:\n%s", got)
+	}
+}
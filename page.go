@@ -0,0 +1,111 @@
+package jqyaml
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// PageResult is a window of the results of a query returned by ExecutePage
+type PageResult struct {
+	Items    []interface{} // Results of the requested page
+	Page     int           // 0-based index of the page
+	PageSize int           // Maximum number of results per page
+	HasMore  bool          // Whether results follow this page
+	// NextToken identifies the next page for ParsePageToken; empty on the last page
+	NextToken string
+}
+
+// errPageFull stops the evaluation once the page and one following result are collected
+var errPageFull = errors.New("page full")
+
+// ExecutePage runs the pipeline and returns page (0-based) of its results,
+// pageSize results at a time. Evaluation stops as soon as the page is complete
+// and it is known whether more results follow, so early pages of large
+// results are cheap. Output options are ignored; result stages still apply.
+// With WithCollectErrors, the page is returned together with the errors
+// collected while filling it.
+//
+// Each call evaluates the query from the start and skips the results of the
+// earlier pages, so fetching a page costs O(n) in its position, and the
+// NextToken, which only records the page and page size, is not stable if the
+// input changes between calls.
+func (p *pipeline) ExecutePage(ctx context.Context, input interface{}, page, pageSize int, opts ...ExecuteOption) (PageResult, error) {
+	result := PageResult{Page: page, PageSize: pageSize}
+	if page < 0 {
+		return result, fmt.Errorf("page must not be negative: %d", page)
+	}
+	if pageSize <= 0 {
+		return result, fmt.Errorf("page size must be positive: %d", pageSize)
+	}
+
+	cfg := p.newExecuteConfig(opts...)
+	defer closeChannel(cfg.channel)
+	cfg.writer, cfg.encoder, cfg.decodeTarget, cfg.batch, cfg.channel = nil, nil, nil, nil, nil
+	skip := page * pageSize
+	cfg.callback = func(v interface{}) error {
+		switch {
+		case skip > 0:
+			skip--
+		case len(result.Items) < pageSize:
+			result.Items = append(result.Items, v)
+		default:
+			result.HasMore = true
+			return errPageFull
+		}
+		return nil
+	}
+
+	body, err := p.inputBody(cfg, input)
+	if err != nil {
+		return result, err
+	}
+	err = p.run(ctx, cfg, body)
+	switch {
+	case err == errPageFull:
+		err = nil
+	case errors.Is(err, errPageFull):
+		// WithCollectErrors joins the errors it collected with the sentinel
+		err = withoutPageFull(err)
+	case err != nil:
+		return result, err
+	}
+	if result.HasMore {
+		result.NextToken = pageToken(page+1, pageSize)
+	}
+	return result, err
+}
+
+// withoutPageFull returns the errors joined with errPageFull, or nil if err
+// only wraps it
+func withoutPageFull(err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		if e != errPageFull {
+			errs = append(errs, e)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pageToken encodes a page position as an opaque continuation token
+func pageToken(page, pageSize int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", page, pageSize)))
+}
+
+// ParsePageToken returns the page and page size identified by a PageResult.NextToken
+func ParsePageToken(token string) (page, pageSize int, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		_, err = fmt.Sscanf(string(b), "%d:%d", &page, &pageSize)
+	}
+	if err != nil || page < 0 || pageSize <= 0 {
+		return 0, 0, fmt.Errorf("invalid page token: %q", token)
+	}
+	return page, pageSize, nil
+}
@@ -0,0 +1,104 @@
+package jqyaml_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExecutePage(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[] | produce"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		page     int
+		want     []interface{}
+		hasMore  bool
+		produced int
+	}{
+		{page: 0, want: []interface{}{1, 2}, hasMore: true, produced: 3},
+		{page: 1, want: []interface{}{3, 4}, hasMore: true, produced: 5},
+		{page: 2, want: []interface{}{5}, hasMore: false, produced: 5},
+		{page: 3, want: nil, hasMore: false, produced: 5},
+	}
+	for _, tt := range tests {
+		produced := 0
+		produce := jqyaml.WithExecFunction("produce", 0, 0, func(v interface{}, _ []interface{}) interface{} {
+			produced++
+			return v
+		})
+		result, err := p.ExecutePage(context.Background(), input, tt.page, 2, produce)
+		if err != nil {
+			t.Fatalf("page %d: %v", tt.page, err)
+		}
+		if diff := cmp.Diff(tt.want, result.Items); diff != "" {
+			t.Errorf("page %d: mismatch (-want +got):\n%s", tt.page, diff)
+		}
+		if result.HasMore != tt.hasMore {
+			t.Errorf("page %d: expected HasMore %v", tt.page, tt.hasMore)
+		}
+		// Evaluation stops once the page and the following result are known
+		if produced != tt.produced {
+			t.Errorf("page %d: expected %d evaluated results, got %d", tt.page, tt.produced, produced)
+		}
+
+		if !tt.hasMore {
+			if result.NextToken != "" {
+				t.Errorf("page %d: expected no next token, got %q", tt.page, result.NextToken)
+			}
+			continue
+		}
+		page, pageSize, err := jqyaml.ParsePageToken(result.NextToken)
+		if err != nil {
+			t.Fatalf("page %d: %v", tt.page, err)
+		}
+		if page != tt.page+1 || pageSize != 2 {
+			t.Errorf("page %d: token decoded to page %d size %d", tt.page, page, pageSize)
+		}
+	}
+}
+
+func TestExecutePageErrors(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`.[] | if . == 3 then error("boom") else . end`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{1, 2, 3}
+
+	// Errors after the page is complete are not evaluated
+	if _, err := p.ExecutePage(context.Background(), input, 0, 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := p.ExecutePage(context.Background(), input, 1, 2); err == nil {
+		t.Error("expected query error")
+	}
+	if _, err := p.ExecutePage(context.Background(), input, 0, 0); err == nil {
+		t.Error("expected error for zero page size")
+	}
+	if _, _, err := jqyaml.ParsePageToken("not a token"); err == nil {
+		t.Error("expected error for invalid token")
+	}
+
+	// Errors collected before the page is complete are returned with it
+	p, err = jqyaml.New(jqyaml.WithQuery(`if . == 2 then error("boom") else . end`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.ExecutePage(context.Background(), nil, 0, 2, jqyaml.WithCollectErrors(0),
+		jqyaml.WithReaderInput(strings.NewReader("1 2 3 4"), jqyaml.FormatJSON))
+	if err == nil || !strings.Contains(errorText(err), "boom") {
+		t.Errorf("got %v, want the collected error", err)
+	}
+	if diff := cmp.Diff([]interface{}{1, 3}, result.Items); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if !result.HasMore || result.NextToken == "" {
+		t.Errorf("expected a next page, got %+v", result)
+	}
+}
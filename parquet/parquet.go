@@ -0,0 +1,575 @@
+// Package parquet writes query results as Parquet files, registering the
+// "parquet" output format of jqyaml when imported:
+//
+//	import _ "github.com/apstndb/go-jq-yamlformat/parquet"
+//
+//	err := p.Execute(ctx, input, jqyaml.WithWriter(f, parquet.Format))
+//
+// Each object result is a row, and array results contribute a row per
+// element. Columns are flat and optional: nested objects, arrays and columns
+// mixing types are stored as JSON strings. The columns are inferred from the
+// rows of the first row group unless EncoderOptions lists them.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// Format is the name the parquet format is registered under
+const Format jqyaml.Format = "parquet"
+
+// DefaultRowGroupRows is the number of rows per row group when
+// EncoderOptions does not set one
+const DefaultRowGroupRows = 10000
+
+func init() {
+	jqyaml.RegisterOutputProvider(Format, Provider{})
+}
+
+// Provider implements jqyaml.OutputProvider. Registering a Provider with its
+// own Options under another name writes results with fixed columns or codec.
+type Provider struct {
+	Options EncoderOptions
+}
+
+// NewEncoder implements jqyaml.OutputProvider
+func (p Provider) NewEncoder(w io.Writer) (jqyaml.Encoder, error) {
+	return NewEncoder(w, p.Options)
+}
+
+// ColumnType is the type of the values of a column
+type ColumnType int
+
+const (
+	Boolean ColumnType = iota + 1 // BOOLEAN
+	Int64                         // INT64
+	Double                        // DOUBLE
+	String                        // BYTE_ARRAY annotated as UTF8
+	JSON                          // Any value, as BYTE_ARRAY annotated as JSON
+)
+
+func (t ColumnType) String() string {
+	switch t {
+	case Boolean:
+		return "boolean"
+	case Int64:
+		return "int64"
+	case Double:
+		return "double"
+	case String:
+		return "string"
+	case JSON:
+		return "json"
+	default:
+		return fmt.Sprintf("ColumnType(%d)", int(t))
+	}
+}
+
+// Column describes a column; rows without the field, or with null, store null
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// EncoderOptions configure an Encoder
+type EncoderOptions struct {
+	// Columns are the columns to write, in order. If empty, they are the
+	// fields of the rows of the first row group, sorted by name. Rows must
+	// not have fields that are not columns.
+	Columns []Column
+	// Codec is "uncompressed" (the default) or "gzip"
+	Codec string
+	// RowGroupRows is the number of rows per row group, DefaultRowGroupRows if zero
+	RowGroupRows int
+}
+
+// Encoder writes rows to a Parquet file. Close must be called after the last
+// row to write the last row group and the file footer.
+type Encoder struct {
+	w         io.Writer
+	opts      EncoderOptions
+	codec     int32
+	columns   []Column
+	rows      []map[string]interface{} // Rows of the current row group
+	offset    int64                    // Bytes written so far
+	rowGroups []rowGroup
+	numRows   int64
+	err       error
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	rows    int64
+	size    int64
+}
+
+type columnChunk struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// Parquet enumerations
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedUTF8 = 0
+	convertedJSON = 19
+
+	codecUncompressed = 0
+	codecGzip         = 2
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionOptional = 1
+	pageData           = 0
+)
+
+var magic = []byte("PAR1")
+
+// NewEncoder returns an Encoder writing to w
+func NewEncoder(w io.Writer, opts EncoderOptions) (*Encoder, error) {
+	e := &Encoder{w: w, opts: opts}
+	switch opts.Codec {
+	case "", "uncompressed":
+		e.codec = codecUncompressed
+	case "gzip":
+		e.codec = codecGzip
+	default:
+		return nil, fmt.Errorf("parquet: unsupported codec %q", opts.Codec)
+	}
+	if e.opts.RowGroupRows <= 0 {
+		e.opts.RowGroupRows = DefaultRowGroupRows
+	}
+	seen := make(map[string]bool)
+	for _, c := range opts.Columns {
+		if c.Name == "" || seen[c.Name] || c.Type < Boolean || c.Type > JSON {
+			return nil, fmt.Errorf("parquet: invalid column %q of type %v", c.Name, c.Type)
+		}
+		seen[c.Name] = true
+	}
+	e.columns = opts.Columns
+	return e, nil
+}
+
+// Encode writes v, an object or an array of objects, as rows
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	rows, ok := v.([]interface{})
+	if !ok {
+		rows = []interface{}{v}
+	}
+	for _, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			e.err = fmt.Errorf("parquet: rows must be objects, got %s", typeName(r))
+			return e.err
+		}
+		e.rows = append(e.rows, row)
+		if len(e.rows) >= e.opts.RowGroupRows {
+			if e.err = e.writeRowGroup(); e.err != nil {
+				return e.err
+			}
+		}
+	}
+	return nil
+}
+
+// Close writes the rows not written yet and the footer; it does not close the writer
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.rows) > 0 || e.offset == 0 {
+		e.err = e.writeRowGroup()
+	}
+	if e.err == nil {
+		e.err = e.writeFooter()
+	}
+	if e.err != nil {
+		return e.err
+	}
+	e.err = errors.New("parquet: encoder closed")
+	return nil
+}
+
+func (e *Encoder) write(b []byte) error {
+	n, err := e.w.Write(b)
+	e.offset += int64(n)
+	return err
+}
+
+// inferColumns derives the columns from the rows of the first row group
+func (e *Encoder) inferColumns() {
+	values := make(map[string][]interface{})
+	for _, row := range e.rows {
+		for k, v := range row {
+			values[k] = append(values[k], v)
+		}
+	}
+	for name, vs := range values {
+		e.columns = append(e.columns, Column{Name: name, Type: inferType(vs)})
+	}
+	sort.Slice(e.columns, func(i, j int) bool { return e.columns[i].Name < e.columns[j].Name })
+}
+
+func inferType(values []interface{}) ColumnType {
+	var typ ColumnType
+	for _, v := range values {
+		var t ColumnType
+		switch v := v.(type) {
+		case nil:
+			continue
+		case bool:
+			t = Boolean
+		case int, int64:
+			t = Int64
+		case *big.Int:
+			t = Int64
+			if !v.IsInt64() {
+				t = Double
+			}
+		case float64:
+			t = Double
+		case json.Number:
+			t = Double
+			if _, err := v.Int64(); err == nil {
+				t = Int64
+			}
+		case string:
+			t = String
+		default:
+			return JSON
+		}
+		switch {
+		case typ == 0 || typ == t:
+			typ = t
+		case typ == Int64 && t == Double || typ == Double && t == Int64:
+			typ = Double
+		default:
+			return JSON
+		}
+	}
+	if typ == 0 {
+		// Columns of nulls only
+		return String
+	}
+	return typ
+}
+
+// writeRowGroup writes the buffered rows as a row group, preceded by the
+// file header for the first one
+func (e *Encoder) writeRowGroup() error {
+	if e.offset == 0 {
+		if e.columns == nil {
+			e.inferColumns()
+		}
+		if err := e.write(magic); err != nil {
+			return err
+		}
+	}
+	if len(e.rows) == 0 {
+		return nil
+	}
+	known := make(map[string]bool, len(e.columns))
+	for _, c := range e.columns {
+		known[c.Name] = true
+	}
+	for _, row := range e.rows {
+		for k := range row {
+			if !known[k] {
+				return fmt.Errorf("parquet: field %q is not a column", k)
+			}
+		}
+	}
+
+	rg := rowGroup{rows: int64(len(e.rows))}
+	for _, c := range e.columns {
+		chunk, err := e.writeColumn(c)
+		if err != nil {
+			return err
+		}
+		rg.columns = append(rg.columns, chunk)
+		rg.size += chunk.uncompressedSize
+	}
+	e.rowGroups = append(e.rowGroups, rg)
+	e.numRows += rg.rows
+	e.rows = e.rows[:0]
+	return nil
+}
+
+// writeColumn writes the values of column c as a column chunk of one data page
+func (e *Encoder) writeColumn(c Column) (columnChunk, error) {
+	levels := make([]byte, 0, len(e.rows))
+	var values []byte
+	var bits []bool
+	for _, row := range e.rows {
+		v := row[c.Name]
+		if v == nil {
+			levels = append(levels, 0)
+			continue
+		}
+		levels = append(levels, 1)
+		var err error
+		if c.Type == Boolean {
+			b, ok := v.(bool)
+			if !ok {
+				return columnChunk{}, mismatch(c, v)
+			}
+			bits = append(bits, b)
+			continue
+		}
+		if values, err = appendPlain(values, c, v); err != nil {
+			return columnChunk{}, err
+		}
+	}
+	if c.Type == Boolean {
+		values = packBits(bits)
+	}
+
+	page := appendLevels(nil, levels)
+	page = append(page, values...)
+	data := page
+	if e.codec == codecGzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(page)
+		if err := zw.Close(); err != nil {
+			return columnChunk{}, err
+		}
+		data = buf.Bytes()
+	}
+
+	header := newThriftWriter()
+	header.i32(1, pageData)
+	header.i32(2, int32(len(page)))
+	header.i32(3, int32(len(data)))
+	header.structField(5)
+	header.i32(1, int32(len(e.rows)))
+	header.i32(2, encodingPlain)
+	header.i32(3, encodingRLE)
+	header.i32(4, encodingRLE)
+	header.end()
+	header.end()
+
+	chunk := columnChunk{
+		offset:           e.offset,
+		numValues:        int64(len(e.rows)),
+		uncompressedSize: int64(len(header.buf) + len(page)),
+		compressedSize:   int64(len(header.buf) + len(data)),
+	}
+	if err := e.write(header.buf); err != nil {
+		return columnChunk{}, err
+	}
+	return chunk, e.write(data)
+}
+
+func mismatch(c Column, v interface{}) error {
+	return fmt.Errorf("parquet: cannot write %s to %v column %q", typeName(v), c.Type, c.Name)
+}
+
+// appendPlain appends v in the PLAIN encoding of column c
+func appendPlain(b []byte, c Column, v interface{}) ([]byte, error) {
+	switch c.Type {
+	case Int64:
+		n, ok := integer(v)
+		if !ok {
+			return nil, mismatch(c, v)
+		}
+		return binary.LittleEndian.AppendUint64(b, uint64(n)), nil
+	case Double:
+		f, ok := float(v)
+		if !ok {
+			return nil, mismatch(c, v)
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			return nil, mismatch(c, v)
+		}
+		return appendByteArray(b, []byte(s)), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("parquet: column %q: %w", c.Name, err)
+		}
+		return appendByteArray(b, data), nil
+	}
+}
+
+func appendByteArray(b, data []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// appendLevels appends definition levels of bit width 1 as length-prefixed
+// runs of the RLE/bit-packing hybrid encoding
+func appendLevels(b []byte, levels []byte) []byte {
+	var runs []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		runs = binary.AppendUvarint(runs, uint64(j-i)<<1)
+		runs = append(runs, levels[i])
+		i = j
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(runs)))
+	return append(b, runs...)
+}
+
+// packBits packs booleans in the PLAIN encoding, least significant bit first
+func packBits(bits []bool) []byte {
+	b := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			b[i/8] |= 1 << (i % 8)
+		}
+	}
+	return b
+}
+
+func (e *Encoder) writeFooter() error {
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(e.columns)+1)
+	meta.begin()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(e.columns)))
+	meta.end()
+	for _, c := range e.columns {
+		meta.begin()
+		meta.i32(1, physicalType(c.Type))
+		meta.i32(3, repetitionOptional)
+		meta.binary(4, []byte(c.Name))
+		switch c.Type {
+		case String:
+			meta.i32(6, convertedUTF8)
+		case JSON:
+			meta.i32(6, convertedJSON)
+		}
+		meta.end()
+	}
+	meta.i64(3, e.numRows)
+	meta.list(4, thriftStruct, len(e.rowGroups))
+	for _, rg := range e.rowGroups {
+		meta.begin()
+		meta.list(1, thriftStruct, len(rg.columns))
+		for i, chunk := range rg.columns {
+			c := e.columns[i]
+			meta.begin()
+			meta.i64(2, chunk.offset)
+			meta.structField(3)
+			meta.i32(1, physicalType(c.Type))
+			meta.i32List(2, []int32{encodingPlain, encodingRLE})
+			meta.stringList(3, []string{c.Name})
+			meta.i32(4, e.codec)
+			meta.i64(5, chunk.numValues)
+			meta.i64(6, chunk.uncompressedSize)
+			meta.i64(7, chunk.compressedSize)
+			meta.i64(9, chunk.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, rg.size)
+		meta.i64(3, rg.rows)
+		meta.end()
+	}
+	meta.binary(6, []byte("go-jq-yamlformat"))
+	meta.end()
+
+	b := append(meta.buf, binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf)))...)
+	return e.write(append(b, magic...))
+}
+
+func physicalType(t ColumnType) int32 {
+	switch t {
+	case Boolean:
+		return typeBoolean
+	case Int64:
+		return typeInt64
+	case Double:
+		return typeDouble
+	default:
+		return typeByteArray
+	}
+}
+
+// integer returns v as an int64 if it is an integral number in range
+func integer(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), true
+		}
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64(), true
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func float(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int64, float64, *big.Int, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
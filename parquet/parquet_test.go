@@ -0,0 +1,268 @@
+package parquet_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/apstndb/go-jq-yamlformat/parquet"
+	"github.com/google/go-cmp/cmp"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps keyed by field id
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) int() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		h := r.b[r.pos]
+		r.pos++
+		if h == 0 {
+			return fields
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.int())
+		}
+		last = id
+		fields[id] = r.value(h & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 4, 5, 6:
+		return r.int()
+	case 8:
+		n := int(r.uvarint())
+		b := r.b[r.pos : r.pos+n]
+		r.pos += n
+		return string(b)
+	case 9:
+		h := r.b[r.pos]
+		r.pos++
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(h & 0x0f)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	panic("unsupported thrift type")
+}
+
+type fileInfo struct {
+	meta    map[int16]interface{}
+	columns []string
+	rows    []map[string]interface{}
+}
+
+// readFile decodes the footer and the column chunks of a Parquet file
+func readFile(t *testing.T, data []byte) fileInfo {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing magic bytes")
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&thriftReader{b: data[len(data)-8-n : len(data)-8]}).readStruct()
+	info := fileInfo{meta: meta}
+	schema := meta[2].([]interface{})
+	for _, el := range schema[1:] {
+		info.columns = append(info.columns, el.(map[int16]interface{})[4].(string))
+	}
+	for _, rg := range meta[4].([]interface{}) {
+		rg := rg.(map[int16]interface{})
+		numRows := int(rg[3].(int64))
+		rows := make([]map[string]interface{}, numRows)
+		for i := range rows {
+			rows[i] = make(map[string]interface{})
+		}
+		for i, cc := range rg[1].([]interface{}) {
+			md := cc.(map[int16]interface{})[3].(map[int16]interface{})
+			typ := md[1].(int64)
+			r := &thriftReader{b: data, pos: int(md[9].(int64))}
+			header := r.readStruct()
+			page := data[r.pos : r.pos+int(header[3].(int64))]
+			if md[4].(int64) == 2 {
+				zr, err := gzip.NewReader(bytes.NewReader(page))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(page) != int(header[2].(int64)) {
+				t.Fatalf("page of %d bytes, header says %d", len(page), header[2])
+			}
+			levels := decodeLevels(page, numRows)
+			values := page[4+binary.LittleEndian.Uint32(page):]
+			bit := 0
+			for row, level := range levels {
+				if level == 0 {
+					rows[row][info.columns[i]] = nil
+					continue
+				}
+				var v interface{}
+				switch typ {
+				case 0:
+					v = values[bit/8]&(1<<(bit%8)) != 0
+					bit++
+				case 2:
+					v = int64(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+				case 5:
+					v = math.Float64frombits(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+				case 6:
+					n := binary.LittleEndian.Uint32(values)
+					v = string(values[4 : 4+n])
+					values = values[4+n:]
+				}
+				rows[row][info.columns[i]] = v
+			}
+		}
+		info.rows = append(info.rows, rows...)
+	}
+	return info
+}
+
+// decodeLevels decodes the RLE runs of definition levels written by the encoder
+func decodeLevels(page []byte, n int) []byte {
+	r := &thriftReader{b: page[4 : 4+binary.LittleEndian.Uint32(page)]}
+	var levels []byte
+	for len(levels) < n {
+		count := int(r.uvarint() >> 1)
+		v := r.b[r.pos]
+		r.pos++
+		for ; count > 0; count-- {
+			levels = append(levels, v)
+		}
+	}
+	return levels
+}
+
+func TestEncoder(t *testing.T) {
+	for _, codec := range []string{"uncompressed", "gzip"} {
+		t.Run(codec, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(".items"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			input := map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"name": "a", "count": 1, "ok": true, "score": 0.5, "tags": []interface{}{"x"}},
+				map[string]interface{}{"name": "b", "count": 2, "ok": false, "score": 2},
+				map[string]interface{}{"name": nil, "count": 3, "ok": true},
+			}}
+			var buf bytes.Buffer
+			jqyaml.RegisterOutputProvider(jqyaml.Format("parquet-"+codec), parquet.Provider{Options: parquet.EncoderOptions{Codec: codec, RowGroupRows: 2}})
+			if err := p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, jqyaml.Format("parquet-"+codec))); err != nil {
+				t.Fatal(err)
+			}
+
+			info := readFile(t, buf.Bytes())
+			if diff := cmp.Diff([]string{"count", "name", "ok", "score", "tags"}, info.columns); diff != "" {
+				t.Errorf("columns mismatch (-want +got):\n%s", diff)
+			}
+			want := []map[string]interface{}{
+				{"name": "a", "count": int64(1), "ok": true, "score": 0.5, "tags": `["x"]`},
+				{"name": "b", "count": int64(2), "ok": false, "score": 2.0, "tags": nil},
+				{"name": nil, "count": int64(3), "ok": true, "score": nil, "tags": nil},
+			}
+			if diff := cmp.Diff(want, info.rows); diff != "" {
+				t.Errorf("rows mismatch (-want +got):\n%s", diff)
+			}
+			if rowGroups := len(info.meta[4].([]interface{})); rowGroups != 2 {
+				t.Errorf("got %d row groups, want 2", rowGroups)
+			}
+			if numRows := info.meta[3].(int64); numRows != 3 {
+				t.Errorf("got %d rows, want 3", numRows)
+			}
+		})
+	}
+}
+
+func TestEncoderColumns(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := parquet.NewEncoder(&buf, parquet.EncoderOptions{Columns: []parquet.Column{
+		{Name: "id", Type: parquet.Int64},
+		{Name: "payload", Type: parquet.JSON},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(map[string]interface{}{"id": 7, "payload": map[string]interface{}{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(map[string]interface{}{"id": "8"}); err != nil {
+		t.Fatal(err)
+	}
+	// Rows are checked against the columns when their row group is written
+	if err := enc.Close(); err == nil || !strings.Contains(err.Error(), `cannot write string to int64 column "id"`) {
+		t.Errorf("got %v", err)
+	}
+
+	buf.Reset()
+	enc, err = parquet.NewEncoder(&buf, parquet.EncoderOptions{Columns: []parquet.Column{{Name: "id", Type: parquet.Int64}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info := readFile(t, buf.Bytes()); len(info.rows) != 0 || len(info.columns) != 1 {
+		t.Errorf("got %d rows and %d columns for an empty file", len(info.rows), len(info.columns))
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	if _, err := parquet.NewEncoder(io.Discard, parquet.EncoderOptions{Codec: "snappy"}); err == nil {
+		t.Error("expected an error for an unsupported codec")
+	}
+	enc, err := parquet.NewEncoder(io.Discard, parquet.EncoderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode("text"); err == nil {
+		t.Error("expected an error for a row that is not an object")
+	}
+
+	enc, err = parquet.NewEncoder(io.Discard, parquet.EncoderOptions{RowGroupRows: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(map[string]interface{}{"b": 1}); err == nil || !strings.Contains(err.Error(), `field "b" is not a column`) {
+		t.Errorf("got %v", err)
+	}
+}
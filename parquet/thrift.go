@@ -0,0 +1,102 @@
+package parquet
+
+import "encoding/binary"
+
+// Types of the Thrift compact protocol
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift structures of the file metadata and page
+// headers with the compact protocol
+type thriftWriter struct {
+	buf  []byte
+	last []int16 // Last field id of each open struct
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		w.buf = append(w.buf, byte(d)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.buf = binary.AppendUvarint(w.buf, zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func zigzag(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.buf = binary.AppendUvarint(w.buf, zigzag(int64(v)))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.buf = binary.AppendUvarint(w.buf, zigzag(v))
+}
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.fieldHeader(id, thriftBinary)
+	w.appendBinary(b)
+}
+
+func (w *thriftWriter) appendBinary(b []byte) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// list writes the header of a list field of n elements of type elem
+func (w *thriftWriter) list(id int16, elem byte, n int) {
+	w.fieldHeader(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|elem)
+		return
+	}
+	w.buf = append(w.buf, 0xf0|elem)
+	w.buf = binary.AppendUvarint(w.buf, uint64(n))
+}
+
+// i32List writes a list field of i32 values
+func (w *thriftWriter) i32List(id int16, values []int32) {
+	w.list(id, thriftI32, len(values))
+	for _, v := range values {
+		w.buf = binary.AppendUvarint(w.buf, zigzag(int64(v)))
+	}
+}
+
+// stringList writes a list field of strings
+func (w *thriftWriter) stringList(id int16, values []string) {
+	w.list(id, thriftBinary, len(values))
+	for _, v := range values {
+		w.appendBinary([]byte(v))
+	}
+}
+
+// structField begins a struct field, ended by end
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.begin()
+}
+
+// begin begins a struct that is a list element or the top-level value
+func (w *thriftWriter) begin() {
+	w.last = append(w.last, 0)
+}
+
+// end ends the innermost struct
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, 0)
+	w.last = w.last[:len(w.last)-1]
+}
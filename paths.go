@@ -0,0 +1,89 @@
+package jqyaml
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// ExecutePaths runs path(query) instead of the query and returns the path of
+// each result as an array of keys and indices, like jq's path(), so user
+// interfaces can highlight where in the input document the results come
+// from. The query must be a path expression such as `.items[] | select(.active)`.
+// Output options and result stages are ignored.
+func (p *pipeline) ExecutePaths(ctx context.Context, input interface{}, opts ...ExecuteOption) ([][]interface{}, error) {
+	if p.query == "" {
+		return nil, fmt.Errorf("no query specified")
+	}
+	// The newlines keep a trailing comment in the query from hiding the parenthesis
+	paths := *p
+	paths.query = "path(\n" + p.query + "\n)"
+	paths.iterRooted = false
+	parsed, err := gojq.Parse(paths.query)
+	if err != nil {
+		return nil, &QueryError{Query: paths.query, Message: "failed to parse query", Err: err}
+	}
+	paths.parsed = parsed
+	paths.compiled = newCodeCache(maxCompiledQueries)
+
+	cfg := p.newExecuteConfig(opts...)
+	defer closeChannel(cfg.channel)
+	cfg.writer, cfg.encoder, cfg.decodeTarget, cfg.batch, cfg.stages, cfg.channel = nil, nil, nil, nil, nil, nil
+	var result [][]interface{}
+	cfg.callback = func(v interface{}) error {
+		result = append(result, v.([]interface{}))
+		return nil
+	}
+	body, err := paths.inputBody(cfg, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := paths.run(ctx, cfg, body); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// getPathCode and setPathCode run jq's getpath and setpath
+var (
+	getPathCode = mustCompilePathFunc("getpath($path)", "$path")
+	setPathCode = mustCompilePathFunc("setpath($path; $value)", "$path", "$value")
+)
+
+func mustCompilePathFunc(query string, varNames ...string) *gojq.Code {
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		panic(err)
+	}
+	code, err := gojq.Compile(parsed, gojq.WithVariables(varNames))
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
+
+// GetPath returns the value at path in v like jq's getpath, e.g. for the
+// paths returned by ExecutePaths: missing keys and indices give null, and
+// indexing a value of the wrong type is an error. v is a jq value, such as
+// one returned by ToJQValue or passed to a callback.
+func GetPath(v interface{}, path []interface{}) (interface{}, error) {
+	return runPathFunc(getPathCode, v, path)
+}
+
+// SetPath returns a copy of v with the value at path replaced by value, like
+// jq's setpath: missing objects and arrays along the path are created, and
+// arrays are padded with nulls. v is not modified.
+func SetPath(v interface{}, path []interface{}, value interface{}) (interface{}, error) {
+	return runPathFunc(setPathCode, v, path, jqNumbers(value))
+}
+
+func runPathFunc(code *gojq.Code, v interface{}, path []interface{}, values ...interface{}) (interface{}, error) {
+	// gojq normalizes numbers in place, so it is given copies of the values
+	iter := code.Run(jqNumbers(v), append([]interface{}{jqNumbers(path)}, values...)...)
+	result, _ := iter.Next()
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return result, nil
+}
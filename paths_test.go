@@ -0,0 +1,148 @@
+package jqyaml_test
+
+import (
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExecutePaths(t *testing.T) {
+	input := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "active": true},
+			map[string]interface{}{"name": "b", "active": false},
+			map[string]interface{}{"name": "c", "active": true},
+		},
+	}
+
+	tests := []struct {
+		query string
+		opts  []jqyaml.ExecuteOption
+		want  [][]interface{}
+	}{
+		{
+			query: ".items[] | select(.active) | .name",
+			want:  [][]interface{}{{"items", 0, "name"}, {"items", 2, "name"}},
+		},
+		{
+			query: "def active: select(.active); .items[] | active # trailing comment",
+			want:  [][]interface{}{{"items", 0}, {"items", 2}},
+		},
+		{
+			query: ".items[$i]",
+			opts:  []jqyaml.ExecuteOption{jqyaml.WithVariables(map[string]interface{}{"i": 1})},
+			want:  [][]interface{}{{"items", 1}},
+		},
+	}
+	for _, tt := range tests {
+		p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.ExecutePaths(context.Background(), input, tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", tt.query, diff)
+		}
+	}
+}
+
+func TestExecutePathsNotPathExpression(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".items | length"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ExecutePaths(context.Background(), map[string]interface{}{"items": []interface{}{}}); err == nil {
+		t.Error("expected error for a query that is not a path expression")
+	}
+}
+
+func TestGetPathSetPath(t *testing.T) {
+	input := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "count": int64(1)},
+			map[string]interface{}{"name": "b"},
+		},
+	}
+
+	t.Run("paths of ExecutePaths", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(".items[] | select(.count) | .name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths, err := p.ExecutePaths(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := jqyaml.GetPath(input, paths[0])
+		if err != nil || v != "a" {
+			t.Fatalf("got %v, %v", v, err)
+		}
+		updated, err := jqyaml.SetPath(input, paths[0], "A")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "A", "count": 1},
+				map[string]interface{}{"name": "b"},
+			},
+		}
+		if diff := cmp.Diff(want, updated); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+		// The input is left as it was
+		if item := input["items"].([]interface{})[0].(map[string]interface{}); item["name"] != "a" || item["count"] != int64(1) {
+			t.Errorf("input was modified: %v", item)
+		}
+	})
+
+	t.Run("getpath", func(t *testing.T) {
+		input := map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "a", "count": 1},
+				map[string]interface{}{"name": "b"},
+			},
+		}
+		tests := []struct {
+			path []interface{}
+			want interface{}
+		}{
+			{nil, input},
+			{[]interface{}{"items", -1, "name"}, "b"},
+			{[]interface{}{"items", 5, "name"}, nil},
+			{[]interface{}{"missing", "deeper", 0}, nil},
+			{[]interface{}{"items", map[string]interface{}{"start": 1, "end": nil}}, []interface{}{map[string]interface{}{"name": "b"}}},
+		}
+		for _, tt := range tests {
+			got, err := jqyaml.GetPath(input, tt.path)
+			if err != nil {
+				t.Errorf("GetPath(%v): %v", tt.path, err)
+				continue
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("GetPath(%v) mismatch (-want +got):\n%s", tt.path, diff)
+			}
+		}
+		if _, err := jqyaml.GetPath(input, []interface{}{"items", "name"}); err == nil {
+			t.Error("expected an error for a string index of an array")
+		}
+	})
+
+	t.Run("setpath", func(t *testing.T) {
+		got, err := jqyaml.SetPath(nil, []interface{}{"a", 2}, uint64(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string]interface{}{"a": []interface{}{nil, nil, 1}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+		if _, err := jqyaml.SetPath([]interface{}{}, []interface{}{-1}, 1); err == nil {
+			t.Error("expected an error for a negative index out of range")
+		}
+	})
+}
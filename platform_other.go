@@ -0,0 +1,7 @@
+//go:build !js && !wasip1
+
+package jqyaml
+
+// defaultYieldEvery is the WithYieldEvery interval used when none is given;
+// preemption lets timers fire without yielding on these platforms
+const defaultYieldEvery = 0
@@ -0,0 +1,10 @@
+//go:build js || wasip1
+
+package jqyaml
+
+// defaultYieldEvery makes executions yield between iterator steps by default.
+// js/wasm and wasip1 run goroutines on one thread without preemption, so the
+// timers behind timeouts and cancellation only fire while the evaluating
+// goroutine yields. Filters that spin inside a single step, such as
+// last(range(1e12)), still cannot be interrupted there.
+const defaultYieldEvery = 1
@@ -0,0 +1,143 @@
+package jqyaml
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// InputProvider decodes an input format this package does not implement,
+// such as Parquet or Avro, so that modules providing it can be registered
+// with RegisterInputProvider instead of this package depending on them
+type InputProvider interface {
+	// NewDecoder returns a decoder of the documents in r
+	NewDecoder(r io.Reader) (Decoder, error)
+}
+
+// Decoder reads the successive documents of an input stream
+type Decoder interface {
+	// Decode returns the next document, of a type the input marshaler
+	// accepts, or io.EOF at the end of the stream
+	Decode() (interface{}, error)
+}
+
+// OutputProvider encodes results in an output format this package does not
+// implement, registered with RegisterOutputProvider
+type OutputProvider interface {
+	// NewEncoder returns an encoder writing to w. Encoders implementing
+	// io.Closer are closed after the last result, e.g. to write a footer.
+	NewEncoder(w io.Writer) (Encoder, error)
+}
+
+// builtinFormats are the formats this package implements for both input and
+// output; FormatJSONL is not a yamlformat format, so IsValid reports false for it
+var builtinFormats = []Format{FormatJSON, FormatJSONL, FormatYAML}
+
+func isBuiltinFormat(format Format) bool {
+	for _, f := range builtinFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+var providers struct {
+	sync.RWMutex
+	inputs  map[Format]InputProvider
+	outputs map[Format]OutputProvider
+}
+
+// RegisterInputProvider makes an input format available to ExecuteReader
+// and Transform under the name format. It is meant to be called from the
+// init function of the providing package, and panics if provider is nil or
+// format is already registered or built in.
+func RegisterInputProvider(format Format, provider InputProvider) {
+	providers.Lock()
+	defer providers.Unlock()
+	if provider == nil {
+		panic("jqyaml: RegisterInputProvider provider is nil")
+	}
+	if _, dup := providers.inputs[format]; dup || isBuiltinFormat(format) {
+		panic(fmt.Sprintf("jqyaml: input format %q registered twice", format))
+	}
+	if providers.inputs == nil {
+		providers.inputs = make(map[Format]InputProvider)
+	}
+	providers.inputs[format] = provider
+}
+
+// RegisterOutputProvider makes an output format available to WithWriter and
+// WithDefaultWriter under the name format. Like RegisterInputProvider, it
+// panics if provider is nil or format is already registered or built in.
+func RegisterOutputProvider(format Format, provider OutputProvider) {
+	providers.Lock()
+	defer providers.Unlock()
+	if provider == nil {
+		panic("jqyaml: RegisterOutputProvider provider is nil")
+	}
+	if _, dup := providers.outputs[format]; dup || isBuiltinFormat(format) {
+		panic(fmt.Sprintf("jqyaml: output format %q registered twice", format))
+	}
+	if providers.outputs == nil {
+		providers.outputs = make(map[Format]OutputProvider)
+	}
+	providers.outputs[format] = provider
+}
+
+// InputFormats returns the sorted names of the input formats, built in and registered
+func InputFormats() []Format {
+	providers.RLock()
+	defer providers.RUnlock()
+	formats := append([]Format{}, builtinFormats...)
+	for format := range providers.inputs {
+		formats = append(formats, format)
+	}
+	sortFormats(formats)
+	return formats
+}
+
+// OutputFormats returns the sorted names of the output formats, built in and registered
+func OutputFormats() []Format {
+	providers.RLock()
+	defer providers.RUnlock()
+	formats := append([]Format{}, builtinFormats...)
+	for format := range providers.outputs {
+		formats = append(formats, format)
+	}
+	sortFormats(formats)
+	return formats
+}
+
+func sortFormats(formats []Format) {
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+}
+
+func lookupInputProvider(format Format) (InputProvider, bool) {
+	providers.RLock()
+	defer providers.RUnlock()
+	provider, ok := providers.inputs[format]
+	return provider, ok
+}
+
+func lookupOutputProvider(format Format) (OutputProvider, bool) {
+	providers.RLock()
+	defer providers.RUnlock()
+	provider, ok := providers.outputs[format]
+	return provider, ok
+}
+
+// providerDecoder adapts the Decoder of a registered format; the positions
+// of its documents are unknown
+type providerDecoder struct {
+	dec Decoder
+}
+
+func (d *providerDecoder) decode() (interface{}, error) {
+	return d.dec.Decode()
+}
+
+func (d *providerDecoder) position() Position {
+	return Position{}
+}
@@ -0,0 +1,157 @@
+package jqyaml_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// kvProvider reads and writes "key=value" lines, one record per line
+type kvProvider struct{}
+
+func (kvProvider) NewDecoder(r io.Reader) (jqyaml.Decoder, error) {
+	return &kvDecoder{s: bufio.NewScanner(r)}, nil
+}
+
+type kvRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type kvDecoder struct {
+	s *bufio.Scanner
+}
+
+func (d *kvDecoder) Decode() (interface{}, error) {
+	if !d.s.Scan() {
+		if err := d.s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	key, value, ok := strings.Cut(d.s.Text(), "=")
+	if !ok {
+		return nil, fmt.Errorf("missing = in %q", d.s.Text())
+	}
+	// Structs go through the input marshaler like Execute input
+	return kvRecord{Key: key, Value: value}, nil
+}
+
+func (kvProvider) NewEncoder(w io.Writer) (jqyaml.Encoder, error) {
+	return &kvEncoder{w: w}, nil
+}
+
+type kvEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *kvEncoder) Encode(v interface{}) error {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("kv records must be objects, got %T", v)
+	}
+	e.count++
+	_, err := fmt.Fprintf(e.w, "%v=%v\n", obj["key"], obj["value"])
+	return err
+}
+
+func (e *kvEncoder) Close() error {
+	_, err := fmt.Fprintf(e.w, "# %d records\n", e.count)
+	return err
+}
+
+func init() {
+	jqyaml.RegisterInputProvider("kv", kvProvider{})
+	jqyaml.RegisterOutputProvider("kv", kvProvider{})
+}
+
+func TestFormatProviders(t *testing.T) {
+	t.Run("input", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := jqyaml.Transform(context.Background(), &buf, jqyaml.FormatJSON,
+			strings.NewReader("a=1\nb=2\n"), "kv", jqyaml.WithQuery(".key"),
+			jqyaml.WithDefaultExecuteOptions(jqyaml.WithCompactJSONOutput()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != "\"a\"\n\"b\"\n" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("conversion without a query", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := jqyaml.Transform(context.Background(), &buf, jqyaml.FormatYAML, strings.NewReader("a=1\n"), "kv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != "key: a\nvalue: \"1\"\n" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("output is closed", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(".[] | {key: .name, value: .id}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		input := []interface{}{map[string]interface{}{"name": "a", "id": 1}, map[string]interface{}{"name": "b", "id": 2}}
+		if err := p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, "kv")); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("a=1\nb=2\n# 2 records\n", buf.String()); diff != "" {
+			t.Errorf("output mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("decode errors", func(t *testing.T) {
+		p, err := jqyaml.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.ExecuteReader(context.Background(), strings.NewReader("a=1\nb\n"), "kv", jqyaml.WithCallback(func(interface{}) error { return nil }))
+		var decodeErr *jqyaml.DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Document != 2 || decodeErr.Format != "kv" {
+			t.Errorf("got %v, want a DecodeError for document 2", err)
+		}
+	})
+
+	t.Run("listed formats", func(t *testing.T) {
+		want := []jqyaml.Format{jqyaml.FormatJSON, jqyaml.FormatJSONL, "kv", jqyaml.FormatYAML}
+		if diff := cmp.Diff(want, jqyaml.InputFormats()); diff != "" {
+			t.Errorf("input formats mismatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(want, jqyaml.OutputFormats()); diff != "" {
+			t.Errorf("output formats mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestRegisterProviderPanics(t *testing.T) {
+	for name, register := range map[string]func(){
+		"duplicate": func() { jqyaml.RegisterInputProvider("kv", kvProvider{}) },
+		"built in":  func() { jqyaml.RegisterOutputProvider(jqyaml.FormatJSON, kvProvider{}) },
+		"jsonl":     func() { jqyaml.RegisterInputProvider(jqyaml.FormatJSONL, kvProvider{}) },
+		"jsonl out": func() { jqyaml.RegisterOutputProvider(jqyaml.FormatJSONL, kvProvider{}) },
+		"nil":       func() { jqyaml.RegisterInputProvider("nil", nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			register()
+		})
+	}
+}
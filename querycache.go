@@ -0,0 +1,66 @@
+package jqyaml
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// DefaultQueryCacheSize is the number of compiled queries held by DefaultQueryCache
+const DefaultQueryCacheSize = 256
+
+// DefaultQueryCache is a process-wide QueryCache that pipelines can share with
+// WithQueryCache(DefaultQueryCache)
+var DefaultQueryCache = NewQueryCache(DefaultQueryCacheSize)
+
+// QueryCache holds compiled queries keyed by their text, variable names and
+// regular expression limits, so pipelines built from the same filter share one
+// compilation. It is safe for concurrent use.
+type QueryCache struct {
+	codes *codeCache
+}
+
+// NewQueryCache creates a QueryCache holding up to size compiled queries
+func NewQueryCache(size int) *QueryCache {
+	return &QueryCache{codes: newCodeCache(size)}
+}
+
+// Len returns the number of compiled queries in the cache
+func (c *QueryCache) Len() int {
+	return c.codes.len()
+}
+
+// queryCacheKey identifies a compilation. Compiler options are functions that
+// cannot be compared, so compilations using them are not cached.
+func (p *pipeline) queryCacheKey(parsed *gojq.Query, varNames []string) string {
+	var limits string
+	if p.regexLimits != nil {
+		limits = fmt.Sprintf("%d,%d", p.regexLimits.maxPattern, p.regexLimits.maxInput)
+	}
+	return strings.Join([]string{parsed.String(), strings.Join(varNames, ","), limits}, "\x00")
+}
+
+// maxCompiledQueries bounds the compilations that a pipeline keeps of its
+// query, one per set of variable names, since executions choosing their
+// variables freely would otherwise grow them without limit
+const maxCompiledQueries = 16
+
+// compileQuery compiles the pipeline's query with the given variable names and
+// execution compiler options. Compilations without execution options are kept
+// in the pipeline, since the functions they register differ per execution.
+func (p *pipeline) compileQuery(varNames []string, extra []gojq.CompilerOption) (*gojq.Code, error) {
+	if len(extra) > 0 {
+		return p.compile(p.parsed, varNames, extra...)
+	}
+	key := strings.Join(varNames, ",")
+	if code, ok := p.compiled.get(key); ok {
+		return code, nil
+	}
+	code, err := p.compile(p.parsed, varNames)
+	if err != nil {
+		return nil, err
+	}
+	p.compiled.add(key, code)
+	return code, nil
+}
@@ -0,0 +1,74 @@
+package jqyaml_test
+
+import (
+	"sync"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithQueryCache(t *testing.T) {
+	cache := jqyaml.NewQueryCache(8)
+	newPipeline := func(query string) jqyaml.Pipeline {
+		t.Helper()
+		p, err := jqyaml.New(jqyaml.WithQuery(query), jqyaml.WithQueryCache(cache))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// Many short-lived pipelines from the same filter share one compilation
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := collect(t, newPipeline(".a + 1"), map[string]interface{}{"a": 1})
+			if diff := cmp.Diff([]interface{}{2}, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		}()
+	}
+	wg.Wait()
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 compiled query, got %d", cache.Len())
+	}
+
+	// Variable names are part of the key
+	got := collect(t, newPipeline(".a + 1"), map[string]interface{}{"a": 1},
+		jqyaml.WithVariables(map[string]interface{}{"n": 2}))
+	if diff := cmp.Diff([]interface{}{2}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 compiled queries, got %d", cache.Len())
+	}
+}
+
+func TestQueryCacheSkipsCompilerOptions(t *testing.T) {
+	cache := jqyaml.NewQueryCache(8)
+	p, err := jqyaml.New(jqyaml.WithQuery("double"), jqyaml.WithQueryCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, factor := range []int{2, 3} {
+		factor := factor
+		got := collect(t, p, 5, jqyaml.WithExecFunction("double", 0, 0, func(v interface{}, _ []interface{}) interface{} {
+			return v.(int) * factor
+		}))
+		if diff := cmp.Diff([]interface{}{5 * factor}, got); diff != "" {
+			t.Errorf("factor %d: mismatch (-want +got):\n%s", factor, diff)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected no compiled queries, got %d", cache.Len())
+	}
+}
+
+func TestWithQueryCacheNil(t *testing.T) {
+	if _, err := jqyaml.New(jqyaml.WithQueryCache(nil)); err == nil {
+		t.Error("expected error for nil cache")
+	}
+}
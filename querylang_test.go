@@ -0,0 +1,124 @@
+package jqyaml_test
+
+import (
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+var storeDocument = map[string]interface{}{
+	"store": map[string]interface{}{
+		"book": []interface{}{
+			map[string]interface{}{"title": "Sayings", "price": 8.95, "category": "reference"},
+			map[string]interface{}{"title": "Sword", "price": 12.99, "category": "fiction"},
+			map[string]interface{}{"title": "Moby Dick", "price": 8.99, "category": "fiction", "isbn": "0-553"},
+			map[string]interface{}{"title": "Rings", "price": 22.99, "category": "fiction", "isbn": "0-395"},
+		},
+		"bicycle": map[string]interface{}{"color": "red", "price": 19.95},
+	},
+}
+
+func TestWithJSONPathQuery(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{
+		{expr: "$", want: []interface{}{storeDocument}},
+		{expr: "$.store.book[*].title", want: []interface{}{"Sayings", "Sword", "Moby Dick", "Rings"}},
+		{expr: "$['store']['bicycle'].color", want: []interface{}{"red"}},
+		{expr: "$.store.book[-1].title", want: []interface{}{"Rings"}},
+		{expr: "$.store.book[0,2].title", want: []interface{}{"Sayings", "Moby Dick"}},
+		{expr: "$.store.book[1:3].title", want: []interface{}{"Sword", "Moby Dick"}},
+		{expr: "$.store.book[::-2].title", want: []interface{}{"Rings", "Sword"}},
+		{expr: "$.store.book[9].title", want: nil},
+		{expr: "$.store.missing", want: nil},
+		{expr: "$..isbn", want: []interface{}{"0-553", "0-395"}},
+		{expr: "$.store.book[?(@.price < 10)].title", want: []interface{}{"Sayings", "Moby Dick"}},
+		{expr: "$.store.book[?@.isbn && @.price > 20].title", want: []interface{}{"Rings"}},
+		{expr: "$.store.book[?!@.isbn].title", want: []interface{}{"Sayings", "Sword"}},
+		{expr: "$.store.book[?(@.category == 'reference' || @.price > $.store.bicycle.price)].title", want: []interface{}{"Sayings", "Rings"}},
+		{expr: "$.store.book[?(@.isbn < 10)].title", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithJSONPathQuery(tt.expr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(t, p, storeDocument)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithJMESPathQuery(t *testing.T) {
+	input := map[string]interface{}{
+		"locations": []interface{}{
+			map[string]interface{}{"name": "Seattle", "state": "WA", "pop": 737},
+			map[string]interface{}{"name": "New York", "state": "NY", "pop": 8336},
+			map[string]interface{}{"name": "Bellevue", "state": "WA", "pop": 151},
+			map[string]interface{}{"name": "Olympia", "state": "WA"},
+		},
+		"nested":  []interface{}{[]interface{}{1, 2}, []interface{}{3}, 4},
+		"servers": map[string]interface{}{"a": map[string]interface{}{"up": true}, "b": map[string]interface{}{"up": false}},
+		"empty":   "",
+	}
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{expr: "locations[0].name", want: "Seattle"},
+		{expr: "missing.field", want: nil},
+		{expr: "locations[?state == 'WA'].name | sort(@)", want: []interface{}{"Bellevue", "Olympia", "Seattle"}},
+		{expr: "locations[*].pop", want: []interface{}{737, 8336, 151}},
+		{expr: "locations[-1:].name", want: []interface{}{"Olympia"}},
+		{expr: "locations[?pop > `500`].name", want: []interface{}{"Seattle", "New York"}},
+		{expr: "nested[]", want: []interface{}{1, 2, 3, 4}},
+		{expr: "servers.*.up", want: []interface{}{true, false}},
+		{expr: "locations[0].{city: name, n: pop}", want: map[string]interface{}{"city": "Seattle", "n": 737}},
+		{expr: "locations[1].[name, state]", want: []interface{}{"New York", "NY"}},
+		{expr: "empty || 'default'", want: "default"},
+		{expr: "!empty", want: true},
+		{expr: "length(locations)", want: 4},
+		{expr: "max_by(locations[?pop], &pop).name", want: "New York"},
+		{expr: "sort_by(locations, &name)[0].name", want: "Bellevue"},
+		{expr: "map(&name, locations)", want: []interface{}{"Seattle", "New York", "Bellevue", "Olympia"}},
+		{expr: "join(', ', locations[:2].state)", want: "WA, NY"},
+		{expr: "contains(locations[*].state, 'NY')", want: true},
+		{expr: "not_null(missing, locations[0].state)", want: "WA"},
+		{expr: `"locations"[0].state`, want: "WA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithJMESPathQuery(tt.expr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(t, p, input)
+			if diff := cmp.Diff([]interface{}{tt.want}, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQueryLanguageParseErrors(t *testing.T) {
+	for _, opt := range []jqyaml.Option{
+		jqyaml.WithJSONPathQuery("store.book"),
+		jqyaml.WithJSONPathQuery("$.store["),
+		jqyaml.WithJMESPathQuery("locations[?"),
+		jqyaml.WithJMESPathQuery("unknown_function(@)"),
+		jqyaml.WithJMESPathQuery("sort_by(locations, name)"),
+		jqyaml.WithJMESPathQuery("a[::0]"),
+	} {
+		_, err := jqyaml.New(opt)
+		var queryErr *jqyaml.QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("expected QueryError, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package jqyaml
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Quota limits what each principal, such as a tenant of a filter service,
+// may use per time window. Zero limits are unlimited.
+type Quota struct {
+	// Window is the length of the fixed windows the limits apply to
+	Window time.Duration
+	// MaxExecutions limits the calls of Execute and ExecuteReader
+	MaxExecutions int
+	// MaxResults limits the results that reach the output
+	MaxResults int
+	// MaxOutputBytes limits the bytes written to WithWriter writers. The
+	// result crossing the limit is still written.
+	MaxOutputBytes int64
+	// Principal returns the principal an execution is charged to, e.g. a
+	// tenant ID stored in ctx by middleware. If nil, all executions are
+	// charged to the same principal "".
+	Principal func(ctx context.Context) string
+}
+
+// QuotaExceededError is returned when an execution exceeds the Quota of its
+// principal
+type QuotaExceededError struct {
+	Principal string
+	Resource  string // "executions", "results" or "output bytes"
+	Limit     int64
+	ResetAt   time.Time // End of the current window
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota of %d %s exceeded for principal %q until %s", e.Limit, e.Resource, e.Principal, e.ResetAt.Format(time.RFC3339))
+}
+
+// quotaState holds the usage of each principal in its current window
+type quotaState struct {
+	quota Quota
+	mu    sync.Mutex
+	usage map[string]*quotaUsage
+}
+
+type quotaUsage struct {
+	start      time.Time
+	executions int64
+	results    int64
+	bytes      int64
+}
+
+// maxIdleQuotaUsage is the number of principals above which usage of
+// finished windows is dropped
+const maxIdleQuotaUsage = 1024
+
+func newQuotaState(q Quota) *quotaState {
+	return &quotaState{quota: q, usage: make(map[string]*quotaUsage)}
+}
+
+// quotaCharge charges the resources of one execution to its principal
+type quotaCharge struct {
+	state     *quotaState
+	principal string
+	now       func() time.Time
+}
+
+// beginQuota charges an execution to the principal of ctx
+func (p *pipeline) beginQuota(ctx context.Context) (*quotaCharge, error) {
+	s := p.quota
+	principal := ""
+	if s.quota.Principal != nil {
+		principal = s.quota.Principal(ctx)
+	}
+	c := &quotaCharge{state: s, principal: principal, now: p.now}
+	return c, c.charge("executions", 1)
+}
+
+// charge adds n units of resource to the usage of the principal, failing if
+// the limit of the resource is exceeded
+func (c *quotaCharge) charge(resource string, n int64) error {
+	s := c.state
+	now := c.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.usage[c.principal]
+	if !ok || now.Sub(u.start) >= s.quota.Window {
+		if !ok && len(s.usage) >= maxIdleQuotaUsage {
+			s.dropFinished(now)
+		}
+		u = &quotaUsage{start: now}
+		s.usage[c.principal] = u
+	}
+	var used *int64
+	var limit int64
+	switch resource {
+	case "executions":
+		used, limit = &u.executions, int64(s.quota.MaxExecutions)
+	case "results":
+		used, limit = &u.results, int64(s.quota.MaxResults)
+	default:
+		used, limit = &u.bytes, s.quota.MaxOutputBytes
+	}
+	if limit > 0 && *used+n > limit {
+		// Output bytes are counted after they are written
+		if resource == "output bytes" {
+			*used += n
+		}
+		return &QuotaExceededError{Principal: c.principal, Resource: resource, Limit: limit, ResetAt: u.start.Add(s.quota.Window)}
+	}
+	*used += n
+	return nil
+}
+
+// dropFinished removes the usage of windows that ended before now
+func (s *quotaState) dropFinished(now time.Time) {
+	for principal, u := range s.usage {
+		if now.Sub(u.start) >= s.quota.Window {
+			delete(s.usage, principal)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+type tenantKey struct{}
+
+func TestQuota(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	principal := func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	}
+	callback := jqyaml.WithCallback(func(interface{}) error { return nil })
+	input := []interface{}{1, 2, 3}
+
+	t.Run("executions", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(".[]"), jqyaml.WithClock(clock),
+			jqyaml.WithQuota(jqyaml.Quota{Window: time.Minute, MaxExecutions: 2, Principal: principal}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := context.WithValue(context.Background(), tenantKey{}, "a")
+		b := context.WithValue(context.Background(), tenantKey{}, "b")
+		for i := 0; i < 2; i++ {
+			if err := p.Execute(a, input, callback); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = p.Execute(a, input, callback)
+		var quotaErr *jqyaml.QuotaExceededError
+		if !errors.As(err, &quotaErr) || quotaErr.Principal != "a" || quotaErr.Resource != "executions" || !quotaErr.ResetAt.Equal(now.Add(time.Minute)) {
+			t.Fatalf("got %v, want a QuotaExceededError for the executions of a", err)
+		}
+		// Other principals have their own usage
+		if err := p.Execute(b, input, callback); err != nil {
+			t.Fatal(err)
+		}
+		// The next window starts over
+		now = now.Add(time.Minute)
+		if err := p.Execute(a, input, callback); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("results", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(".[]"), jqyaml.WithClock(clock),
+			jqyaml.WithQuota(jqyaml.Quota{Window: time.Minute, MaxResults: 4}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Execute(context.Background(), input, callback); err != nil {
+			t.Fatal(err)
+		}
+		var got []interface{}
+		err = p.Execute(context.Background(), input, jqyaml.WithCallback(func(v interface{}) error {
+			got = append(got, v)
+			return nil
+		}))
+		var quotaErr *jqyaml.QuotaExceededError
+		if !errors.As(err, &quotaErr) || quotaErr.Resource != "results" || quotaErr.Limit != 4 {
+			t.Fatalf("got %v, want a QuotaExceededError for results", err)
+		}
+		if len(got) != 1 {
+			t.Errorf("got %d results within the quota, want 1", len(got))
+		}
+	})
+
+	t.Run("output bytes", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(".[]"), jqyaml.WithClock(clock),
+			jqyaml.WithQuota(jqyaml.Quota{Window: time.Minute, MaxOutputBytes: 3}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		err = p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, jqyaml.FormatJSON))
+		var quotaErr *jqyaml.QuotaExceededError
+		if !errors.As(err, &quotaErr) || quotaErr.Resource != "output bytes" {
+			t.Fatalf("got %v, want a QuotaExceededError for output bytes", err)
+		}
+		// The result crossing the limit is written
+		if got := buf.String(); got != "1\n2\n" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := jqyaml.New(jqyaml.WithQuota(jqyaml.Quota{MaxExecutions: 1})); err == nil {
+			t.Error("expected an error without a window")
+		}
+		if _, err := jqyaml.New(jqyaml.WithQuota(jqyaml.Quota{Window: time.Second, MaxResults: -1})); err == nil {
+			t.Error("expected an error for a negative limit")
+		}
+	})
+}
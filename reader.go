@@ -0,0 +1,395 @@
+package jqyaml
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/itchyny/gojq"
+)
+
+// ExecuteReader decodes a stream of JSON or YAML documents from r and runs
+// the pipeline on each document in turn, like the jq command does for its
+// inputs. JSON input may contain any number of whitespace-separated values;
+// YAML input may contain multiple documents separated by "---"; FormatJSONL
+// input contains one value per line. As in jq, the input and inputs functions
+// take the next documents, which the pipeline then does not run on. Under
+// WithNullInput the pipeline runs once on null and reads the documents only
+// through input and inputs. Under WithStreamInput the documents are the
+// events of jq --stream.
+// Result stages and the timeout span the whole stream.
+func (p *pipeline) ExecuteReader(ctx context.Context, r io.Reader, format Format, opts ...ExecuteOption) error {
+	cfg := p.newExecuteConfig(opts...)
+	defer closeChannel(cfg.channel)
+	if cfg.reader != nil {
+		return errors.New("WithReaderInput cannot be used with ExecuteReader")
+	}
+	return p.executeReader(ctx, cfg, r, format)
+}
+
+// executeReader runs the pipeline on the documents of r as configured by cfg
+func (p *pipeline) executeReader(ctx context.Context, cfg *executeConfig, r io.Reader, format Format) error {
+	body, err := p.readerBody(cfg, r, format)
+	if err != nil {
+		return err
+	}
+	return p.run(ctx, cfg, body)
+}
+
+// readerBody returns the function that feeds the documents of r to an
+// execution as configured by cfg
+func (p *pipeline) readerBody(cfg *executeConfig, r io.Reader, format Format) (func(*execution) error, error) {
+	var decodeOpts []yaml.DecodeOption
+	if !cfg.strictInput {
+		// Duplicate keys are accepted and the last value wins, as in jq
+		decodeOpts = append(decodeOpts, yaml.AllowDuplicateMapKey())
+	}
+	decodeOpts = append(decodeOpts, p.defaultDecodeOptions...)
+	decodeOpts = append(decodeOpts, cfg.decodeOptions...)
+	// Other formats decode to other types, which need the input marshaler
+	cfg.ordered = p.converts(cfg) && format.IsValid() && !cfg.streamInput
+	if cfg.ordered {
+		decodeOpts = append(decodeOpts, yaml.UseOrderedMap())
+	}
+
+	var dec documentDecoder
+	if cfg.streamInput {
+		if format != FormatJSON && format != FormatJSONL {
+			return nil, fmt.Errorf("WithStreamInput requires JSON or JSON Lines input, got %q", format)
+		}
+		dec = newJSONStreamDecoder(newInputReader(r, cfg.inputEncoding))
+	} else {
+		var err error
+		dec, err = newDocumentDecoder(newInputReader(r, cfg.inputEncoding), format, decodeOpts, cfg.ordered, cfg.strictInput)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(ex *execution) error {
+		if dec, ok := dec.(*jsonlDecoder); ok {
+			defer dec.release()
+		}
+		process := ex.process
+		switch {
+		case cfg.ordered:
+			// Documents are converted to the output format without the input marshaler
+			process = ex.emit
+		case format == FormatJSONL || cfg.streamInput:
+			// Lines and stream events are decoded to jq values
+			process = ex.processJQValue
+		}
+		if usesInputs(ex.pipeline.query) {
+			iter := &inputIter{ex: ex, dec: dec, format: format, jqValues: format == FormatJSONL || cfg.streamInput}
+			ex.compilerOptions = append(ex.compilerOptions, gojq.WithInputIter(iter))
+		}
+		if cfg.nullInput {
+			ex.input = dec
+			return ex.processNull()
+		}
+		return ex.processDocuments(dec, format, process)
+	}, nil
+}
+
+// processDocuments passes each document of dec to process, recording invalid
+// documents and inputs under WithCollectErrors
+func (ex *execution) processDocuments(dec documentDecoder, format Format, process func(interface{}) error) error {
+	ex.input = dec
+	for !ex.inputDone {
+		doc, err := dec.decode()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		ex.documents++
+		i := ex.documents
+		if err != nil {
+			decodeErr := &DecodeError{Format: format, Document: i, Position: dec.position(), Err: err}
+			var docErr *documentError
+			if errors.As(err, &docErr) {
+				// The stream is intact; only this document is invalid
+				decodeErr.Err = docErr.err
+				if err := ex.recordFailed(decodeErr); err != nil {
+					return err
+				}
+				continue
+			}
+			return decodeErr
+		}
+		if err := process(doc); err != nil {
+			if !isRecordError(err) {
+				return err
+			}
+			if err := ex.recordFailed(&InputError{Document: i, Position: dec.position(), Err: err}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// inputsPattern matches calls of the input and inputs functions, but not
+// fields and variables of those names
+var inputsPattern = regexp.MustCompile(`(^|[^.$\w])inputs?\b`)
+
+// usesInputs reports whether query may call input or inputs. Defining them
+// for queries that don't keeps the compiled query cacheable.
+func usesInputs(query string) bool {
+	return inputsPattern.MatchString(query)
+}
+
+// inputIter yields the next documents of the stream to the input and inputs
+// functions, converted like the documents the pipeline runs on
+type inputIter struct {
+	ex       *execution
+	dec      documentDecoder
+	format   Format
+	jqValues bool // The documents are already jq values
+}
+
+func (it *inputIter) Next() (interface{}, bool) {
+	ex := it.ex
+	if ex.inputDone {
+		return nil, false
+	}
+	doc, err := it.dec.decode()
+	if errors.Is(err, io.EOF) {
+		ex.inputDone = true
+		return nil, false
+	}
+	ex.documents++
+	if err != nil {
+		var docErr *documentError
+		if errors.As(err, &docErr) {
+			err = docErr.err
+		}
+		ex.inputErr = &DecodeError{Format: it.format, Document: ex.documents, Position: it.dec.position(), Err: err}
+		return ex.inputErr, true
+	}
+	if it.jqValues && ex.pipeline.inputMarshaler == nil {
+		return doc, true
+	}
+	v, err := ex.marshaler.Marshal(doc)
+	if err == nil && ex.pipeline.inputMarshaler != nil {
+		err = validateJQValue(v)
+	}
+	if err != nil {
+		ex.inputErr = &InputError{Document: ex.documents, Position: it.dec.position(), Err: err}
+		return ex.inputErr, true
+	}
+	return v, true
+}
+
+// Position is a location in an input stream
+type Position struct {
+	Line   int // 1-based line number
+	Column int // 1-based column number, counted in bytes
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// documentDecoder reads successive documents from an input stream
+type documentDecoder interface {
+	// decode returns the next document, or io.EOF at the end of the stream
+	decode() (interface{}, error)
+	// position returns where the last decoded document starts, or where
+	// decoding failed. The zero Position means the location is unknown.
+	position() Position
+}
+
+// newDocumentDecoder returns a decoder of format documents from r. Ordered
+// decoders represent mappings as yaml.MapSlice and JSON numbers as json.Number;
+// strict makes ordered JSON decoding reject duplicate keys.
+func newDocumentDecoder(r io.Reader, format Format, opts []yaml.DecodeOption, ordered, strict bool) (documentDecoder, error) {
+	switch format {
+	case FormatJSON:
+		lines := &lineCounter{r: r}
+		return &jsonDocumentDecoder{dec: json.NewDecoder(lines), lines: lines, opts: opts, ordered: ordered, strict: strict}, nil
+	case FormatYAML:
+		return &yamlDocumentDecoder{r: r, opts: opts, ordered: ordered}, nil
+	case FormatJSONL:
+		return newJSONLDecoder(r), nil
+	default:
+		provider, ok := lookupInputProvider(format)
+		if !ok {
+			return nil, fmt.Errorf("unsupported input format: %q", format)
+		}
+		dec, err := provider.NewDecoder(r)
+		if err != nil {
+			return nil, err
+		}
+		return &providerDecoder{dec: dec}, nil
+	}
+}
+
+// jsonDocumentDecoder splits a stream of JSON values and decodes each with go-yaml,
+// so that decode options apply to JSON input in the same way as to YAML input
+type jsonDocumentDecoder struct {
+	dec     *json.Decoder
+	lines   *lineCounter
+	opts    []yaml.DecodeOption
+	pos     Position
+	ordered bool
+	strict  bool
+}
+
+func (d *jsonDocumentDecoder) decode() (interface{}, error) {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		d.pos = Position{}
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.Offset > 0 {
+			// The error occurred after reading the offending byte
+			d.pos = d.lines.position(syntaxErr.Offset - 1)
+		}
+		return nil, err
+	}
+	d.pos = d.lines.position(d.dec.InputOffset() - int64(len(raw)))
+	if d.ordered {
+		v, err := decodeOrderedJSON(raw, d.strict)
+		if err != nil {
+			return nil, &documentError{err: err}
+		}
+		return v, nil
+	}
+	var v interface{}
+	if err := yaml.UnmarshalWithOptions(raw, &v, d.opts...); err != nil {
+		return nil, &documentError{err: err}
+	}
+	return v, nil
+}
+
+func (d *jsonDocumentDecoder) position() Position {
+	return d.pos
+}
+
+// documentError is a decoding failure of a single document that leaves the
+// rest of the stream readable
+type documentError struct {
+	err error
+}
+
+func (e *documentError) Error() string {
+	return e.err.Error()
+}
+
+// lineCounter records the newlines read through it, so that offsets into the
+// data already read can be converted to positions
+type lineCounter struct {
+	r         io.Reader
+	read      int64   // Number of bytes read so far
+	newlines  []int64 // Offsets of newlines after lineStart
+	line      int     // Number of lines before lineStart
+	lineStart int64   // Offset of the line containing the last converted offset
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.newlines = append(c.newlines, c.read+int64(i))
+		}
+	}
+	c.read += int64(n)
+	return n, err
+}
+
+// position converts offset to a position; offsets must not decrease between calls
+func (c *lineCounter) position(offset int64) Position {
+	i := 0
+	for i < len(c.newlines) && c.newlines[i] < offset {
+		c.line++
+		c.lineStart = c.newlines[i] + 1
+		i++
+	}
+	c.newlines = c.newlines[i:]
+	return Position{Line: c.line + 1, Column: int(offset-c.lineStart) + 1}
+}
+
+// yamlDocumentDecoder decodes the documents of a YAML stream
+type yamlDocumentDecoder struct {
+	r         io.Reader
+	opts      []yaml.DecodeOption
+	data      []byte
+	dec       *yaml.Decoder
+	decoded   int        // Number of documents decoded so far
+	positions []Position // Start of each document, computed on first use
+	ordered   bool
+}
+
+func (d *yamlDocumentDecoder) decode() (interface{}, error) {
+	if d.dec == nil {
+		// go-yaml reads the whole stream before decoding the first document anyway;
+		// keeping the data allows locating documents later
+		data, err := io.ReadAll(d.r)
+		if err != nil {
+			return nil, err
+		}
+		d.data = data
+		d.dec = yaml.NewDecoder(bytes.NewReader(data), d.opts...)
+	}
+	var v interface{}
+	if err := d.dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	d.decoded++
+	if d.ordered {
+		v = normalizeOrderedYAML(v)
+	}
+	return v, nil
+}
+
+func (d *yamlDocumentDecoder) position() Position {
+	if d.positions == nil {
+		d.positions = yamlDocumentPositions(d.data)
+	}
+	if d.decoded == 0 || d.decoded > len(d.positions) {
+		return Position{}
+	}
+	return d.positions[d.decoded-1]
+}
+
+// yamlDocumentPositions returns the start of each non-empty document in data,
+// matching the documents returned by yaml.Decoder
+func yamlDocumentPositions(data []byte) []Position {
+	positions := []Position{}
+	file, err := parser.ParseBytes(data, 0, parser.AllowDuplicateMapKey())
+	if err != nil {
+		return positions
+	}
+	for _, doc := range file.Docs {
+		if doc.Body == nil {
+			continue
+		}
+		tk := doc.Body.GetToken()
+		// The token of a block mapping is its first ':'; locate its first key instead
+		if m, ok := doc.Body.(*ast.MappingNode); ok && len(m.Values) > 0 {
+			tk = m.Values[0].Key.GetToken()
+		}
+		if tk == nil {
+			positions = append(positions, Position{})
+			continue
+		}
+		positions = append(positions, Position{Line: tk.Position.Line, Column: tk.Position.Column})
+	}
+	return positions
+}
+
+// inputLineNumber implements the input_line_number function enabled by WithInputLineNumber
+func (ex *execution) inputLineNumber(interface{}, []interface{}) interface{} {
+	if ex.input == nil {
+		return nil
+	}
+	if pos := ex.input.position(); pos.Line > 0 {
+		return pos.Line
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+)
+
+// collectReader executes p on r and returns all results passed to the callback
+func collectReader(t *testing.T, p jqyaml.Pipeline, input string, format jqyaml.Format, opts ...jqyaml.ExecuteOption) []interface{} {
+	t.Helper()
+	var results []interface{}
+	opts = append(opts, jqyaml.WithCallback(func(v interface{}) error {
+		results = append(results, v)
+		return nil
+	}))
+	if err := p.ExecuteReader(context.Background(), strings.NewReader(input), format, opts...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return results
+}
+
+func TestExecuteReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		input  string
+		format jqyaml.Format
+		want   []interface{}
+	}{
+		{
+			name:   "json value stream",
+			query:  ".id",
+			input:  `{"id": 1} {"id": 2}` + "\n" + `{"id": 3}`,
+			format: jqyaml.FormatJSON,
+			want:   []interface{}{1, 2, 3},
+		},
+		{
+			name:   "yaml documents",
+			query:  ".name",
+			input:  "name: a\n---\nname: b\n",
+			format: jqyaml.FormatYAML,
+			want:   []interface{}{"a", "b"},
+		},
+		{
+			name:   "numbers are jq-compatible",
+			query:  ".[] | . + 1",
+			input:  "[1, -2, 1.5]",
+			format: jqyaml.FormatYAML,
+			want:   []interface{}{2, -1, 2.5},
+		},
+		{
+			name:   "duplicate keys keep the last value",
+			query:  ".a",
+			input:  `{"a": 1, "a": 2}`,
+			format: jqyaml.FormatJSON,
+			want:   []interface{}{2},
+		},
+		{
+			name:   "json with byte order mark",
+			query:  ".id",
+			input:  "\xef\xbb\xbf" + `{"id": 1}`,
+			format: jqyaml.FormatJSON,
+			want:   []interface{}{1},
+		},
+		{
+			name:   "yaml with byte order mark",
+			query:  ".name",
+			input:  "\xef\xbb\xbfname: a\n",
+			format: jqyaml.FormatYAML,
+			want:   []interface{}{"a"},
+		},
+		{
+			name:   "empty input",
+			query:  ".",
+			input:  "",
+			format: jqyaml.FormatJSON,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collectReader(t, p, tt.input, tt.format)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExecuteReaderStagesSpanDocuments(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectReader(t, p, `{"id": 2} {"id": 1} {"id": 2}`, jqyaml.FormatJSON,
+		jqyaml.WithDedup(""),
+		jqyaml.WithSortBy("", false),
+	)
+	if diff := cmp.Diff([]interface{}{1, 2}, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	anchors := "base: &base\n  region: us\n"
+
+	t.Run("execute option", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(".region"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collectReader(t, p, "<<: *base\nname: app\n", jqyaml.FormatYAML,
+			jqyaml.WithDecodeOptions(yaml.ReferenceReaders(strings.NewReader(anchors))),
+		)
+		if diff := cmp.Diff([]interface{}{"us"}, got); diff != "" {
+			t.Errorf("results mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("pipeline default", func(t *testing.T) {
+		p, err := jqyaml.New(
+			jqyaml.WithQuery(".region"),
+			jqyaml.WithDefaultDecodeOptions(yaml.ReferenceReaders(strings.NewReader(anchors))),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collectReader(t, p, "<<: *base\nname: app\n", jqyaml.FormatYAML)
+		if diff := cmp.Diff([]interface{}{"us"}, got); diff != "" {
+			t.Errorf("results mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestExecuteReaderDecodeError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []interface{}
+	err = p.ExecuteReader(context.Background(), strings.NewReader(`{"ok": true} {"broken": `), jqyaml.FormatJSON,
+		jqyaml.WithCallback(func(v interface{}) error {
+			results = append(results, v)
+			return nil
+		}),
+	)
+	var decodeErr *jqyaml.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected DecodeError, got %T: %v", err, err)
+	}
+	if decodeErr.Document != 2 {
+		t.Errorf("Document = %d, want 2", decodeErr.Document)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 result before the error, got %d", len(results))
+	}
+}
+
+func TestStrictInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		format  jqyaml.Format
+		wantPos string
+	}{
+		{name: "json duplicate key", input: `{"a": 1, "a": 2}`, format: jqyaml.FormatJSON, wantPos: "[1:10]"},
+		{name: "yaml duplicate key", input: "a: 1\nb:\n  c: 1\n  c: 2\n", format: jqyaml.FormatYAML, wantPos: "[4:3]"},
+		{name: "yaml undefined alias", input: "a: *missing\n", format: jqyaml.FormatYAML, wantPos: "[1:5]"},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.ExecuteReader(context.Background(), strings.NewReader(tt.input), tt.format,
+				jqyaml.WithStrictInput(),
+				jqyaml.WithCallback(func(interface{}) error { return nil }),
+			)
+			var decodeErr *jqyaml.DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("expected DecodeError, got %T: %v", err, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantPos) {
+				t.Errorf("error should contain position %s, got: %v", tt.wantPos, err)
+			}
+		})
+	}
+}
+
+func TestInputPosition(t *testing.T) {
+	t.Run("query error", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(".a + 1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := "{\"a\": 1}\n\n  {\"a\": \"x\"}\n"
+		err = p.ExecuteReader(context.Background(), strings.NewReader(input), jqyaml.FormatJSON,
+			jqyaml.WithCallback(func(interface{}) error { return nil }),
+		)
+		var inputErr *jqyaml.InputError
+		if !errors.As(err, &inputErr) {
+			t.Fatalf("expected InputError, got %T: %v", err, err)
+		}
+		want := jqyaml.Position{Line: 3, Column: 3}
+		if inputErr.Document != 2 || inputErr.Position != want {
+			t.Errorf("got document %d at %v, want document 2 at %v", inputErr.Document, inputErr.Position, want)
+		}
+		var queryErr *jqyaml.QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("expected wrapped QueryError, got %v", err)
+		}
+	})
+
+	t.Run("json syntax error", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("."))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.ExecuteReader(context.Background(), strings.NewReader("{\"a\": 1}\n{\"a\": x}\n"), jqyaml.FormatJSON,
+			jqyaml.WithCallback(func(interface{}) error { return nil }),
+		)
+		var decodeErr *jqyaml.DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("expected DecodeError, got %T: %v", err, err)
+		}
+		if want := (jqyaml.Position{Line: 2, Column: 7}); decodeErr.Position != want {
+			t.Errorf("got position %v, want %v", decodeErr.Position, want)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		input  string
+		format jqyaml.Format
+		want   []interface{}
+	}{
+		{name: "json", input: "1\n[\n 2\n]\n\n{\"a\": 3}", format: jqyaml.FormatJSON, want: []interface{}{1, 2, 6}},
+		{name: "yaml", input: "a: 1\n---\n# comment\nb: 2\n---\n- 3\n", format: jqyaml.FormatYAML, want: []interface{}{1, 4, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery("input_line_number"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collectReader(t, p, tt.input, tt.format, jqyaml.WithInputLineNumber())
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithReaderInput(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, p, nil, jqyaml.WithReaderInput(strings.NewReader("name: a\n---\nname: b\n"), jqyaml.FormatYAML))
+	if diff := cmp.Diff([]interface{}{"a", "b"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	ctx := context.Background()
+	r := strings.NewReader(`{"name": "a"}`)
+	if err := p.Execute(ctx, map[string]interface{}{}, jqyaml.WithReaderInput(r, jqyaml.FormatJSON)); err == nil {
+		t.Error("expected an error for input given with WithReaderInput")
+	}
+	if err := p.ExecuteReader(ctx, r, jqyaml.FormatJSON, jqyaml.WithReaderInput(r, jqyaml.FormatJSON)); err == nil {
+		t.Error("expected an error for WithReaderInput with ExecuteReader")
+	}
+	if err := p.Execute(ctx, nil, jqyaml.WithReaderInput(nil, jqyaml.FormatJSON)); err == nil {
+		t.Error("expected an error for a nil reader")
+	}
+}
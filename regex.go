@@ -0,0 +1,77 @@
+package jqyaml
+
+import (
+	"github.com/itchyny/gojq"
+)
+
+// regexLimits bounds the inputs of the regular expression builtins
+type regexLimits struct {
+	maxPattern int
+	maxInput   int
+}
+
+// regexGuardFunc is the name of the function checking the limits
+const regexGuardFunc = "_jqyaml_regex_guard"
+
+// regexGuardDefs shadow the public regular expression builtins with definitions
+// that check the limits before calling the builtin. Each builtin is aliased
+// first, since a definition cannot otherwise refer to the builtin it shadows.
+// Builtins calling each other internally (e.g. capture calling match) are
+// unaffected, so every public entry point is guarded.
+var regexGuardDefs = mustParseFuncDefs(`
+def _jqyaml_test($re; $flags): test($re; $flags);
+def _jqyaml_match($re; $flags): match($re; $flags);
+def _jqyaml_capture($re; $flags): capture($re; $flags);
+def _jqyaml_scan($re; $flags): scan($re; $flags);
+def _jqyaml_split($re; $flags): split($re; $flags);
+def _jqyaml_splits($re; $flags): splits($re; $flags);
+def _jqyaml_sub($re; str; $flags): sub($re; str; $flags);
+def _jqyaml_gsub($re; str; $flags): gsub($re; str; $flags);
+def test($re): _jqyaml_regex_guard($re) | _jqyaml_test($re; null);
+def test($re; $flags): _jqyaml_regex_guard($re) | _jqyaml_test($re; $flags);
+def match($re): _jqyaml_regex_guard($re) | _jqyaml_match($re; null);
+def match($re; $flags): _jqyaml_regex_guard($re) | _jqyaml_match($re; $flags);
+def capture($re): _jqyaml_regex_guard($re) | _jqyaml_capture($re; null);
+def capture($re; $flags): _jqyaml_regex_guard($re) | _jqyaml_capture($re; $flags);
+def scan($re): _jqyaml_regex_guard($re) | _jqyaml_scan($re; null);
+def scan($re; $flags): _jqyaml_regex_guard($re) | _jqyaml_scan($re; $flags);
+def split($re; $flags): _jqyaml_regex_guard($re) | _jqyaml_split($re; $flags);
+def splits($re): _jqyaml_regex_guard($re) | _jqyaml_splits($re; null);
+def splits($re; $flags): _jqyaml_regex_guard($re) | _jqyaml_splits($re; $flags);
+def sub($re; str): _jqyaml_regex_guard($re) | _jqyaml_sub($re; str; null);
+def sub($re; str; $flags): _jqyaml_regex_guard($re) | _jqyaml_sub($re; str; $flags);
+def gsub($re; str): _jqyaml_regex_guard($re) | _jqyaml_gsub($re; str; null);
+def gsub($re; str; $flags): _jqyaml_regex_guard($re) | _jqyaml_gsub($re; str; $flags);
+.`)
+
+func mustParseFuncDefs(src string) []*gojq.FuncDef {
+	q, err := gojq.Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return q.FuncDefs
+}
+
+// withRegexGuard returns a copy of parsed with the guarded definitions prepended,
+// so that definitions in the query itself still take precedence
+func withRegexGuard(parsed *gojq.Query) *gojq.Query {
+	q := *parsed
+	q.FuncDefs = append(append([]*gojq.FuncDef{}, regexGuardDefs...), parsed.FuncDefs...)
+	return &q
+}
+
+// guard implements _jqyaml_regex_guard, returning its input if it is within the limits
+func (l *regexLimits) guard(v interface{}, args []interface{}) interface{} {
+	if re, ok := args[0].(string); ok && l.maxPattern > 0 && len(re) > l.maxPattern {
+		return &RegexLimitError{Kind: "pattern", Size: len(re), Limit: l.maxPattern}
+	}
+	if s, ok := v.(string); ok && l.maxInput > 0 && len(s) > l.maxInput {
+		return &RegexLimitError{Kind: "input", Size: len(s), Limit: l.maxInput}
+	}
+	return v
+}
+
+// compilerOption registers the guard function
+func (l *regexLimits) compilerOption() gojq.CompilerOption {
+	return gojq.WithFunction(regexGuardFunc, 1, 1, l.guard)
+}
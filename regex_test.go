@@ -0,0 +1,71 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithRegexLimits(t *testing.T) {
+	long := strings.Repeat("a", 20)
+
+	tests := []struct {
+		name     string
+		query    string
+		input    interface{}
+		want     []interface{}
+		wantKind string
+	}{
+		{name: "test within limits", query: `test("a+")`, input: "aaa", want: []interface{}{true}},
+		{name: "sub within limits", query: `gsub("a"; "b")`, input: "aba", want: []interface{}{"bbb"}},
+		{name: "capture within limits", query: `capture("(?<x>b)").x`, input: "abc", want: []interface{}{"b"}},
+		{name: "split/1 is not a regex", query: `split(",")`, input: long + "," + long, want: []interface{}{[]interface{}{long, long}}},
+		{name: "pattern too long", query: `test("` + long + `")`, input: "a", wantKind: "pattern"},
+		{name: "input too long", query: `[match("a"; "g")] | length`, input: long, wantKind: "input"},
+		{name: "input too long via capture", query: `capture("(?<x>a)")`, input: long, wantKind: "input"},
+		{name: "input too long via split/2", query: `split("a"; null)`, input: long, wantKind: "input"},
+		{name: "input too long via gsub", query: `gsub("a"; "b")`, input: long, wantKind: "input"},
+		{name: "user definitions take precedence", query: `def test($re): "mine"; test("` + long + `")`, input: "a", want: []interface{}{"mine"}},
+	}
+
+	p := func(query string) jqyaml.Pipeline {
+		p, err := jqyaml.New(jqyaml.WithQuery(query), jqyaml.WithRegexLimits(10, 10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []interface{}
+			err := p(tt.query).Execute(context.Background(), tt.input, jqyaml.WithCallback(func(v interface{}) error {
+				got = append(got, v)
+				return nil
+			}))
+			if tt.wantKind != "" {
+				var limitErr *jqyaml.RegexLimitError
+				if !errors.As(err, &limitErr) {
+					t.Fatalf("expected RegexLimitError, got %T: %v", err, err)
+				}
+				if limitErr.Kind != tt.wantKind || limitErr.Size != 20 || limitErr.Limit != 10 {
+					t.Errorf("unexpected limit error: %+v", limitErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := jqyaml.New(jqyaml.WithRegexLimits(-1, 0)); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
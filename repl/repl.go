@@ -0,0 +1,129 @@
+// Package repl supports building interactive, ijq-style explorers on top of
+// jqyaml: a Session converts its input once and re-evaluates a query against
+// it as the user edits the query, returning a bounded preview of the results.
+package repl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// DefaultPreviewLimit is the number of results returned by Evaluate unless
+// changed with SetPreviewLimit
+const DefaultPreviewLimit = 100
+
+// Session evaluates queries against a fixed input. It is safe for concurrent
+// use; starting an evaluation cancels the one in progress, so results for
+// an outdated query are never awaited.
+type Session struct {
+	opts      []jqyaml.Option
+	converter jqyaml.Pipeline // Identity query converting values with opts
+	input     interface{}     // Input converted to jq-compatible values
+	cache     *jqyaml.QueryCache
+
+	mu     sync.Mutex
+	limit  int
+	cancel context.CancelFunc // Cancels the evaluation in progress
+}
+
+// Preview is the outcome of evaluating a query in a Session
+type Preview struct {
+	Query     string
+	Results   []interface{} // Up to the preview limit results
+	Truncated bool          // Whether results beyond the limit were dropped
+}
+
+// NewSession converts input with the pipeline options opts (such as
+// WithInputMarshaler or WithDefaultEncodeOptions) and returns a Session
+// evaluating queries against the converted value
+func NewSession(ctx context.Context, input interface{}, opts ...jqyaml.Option) (*Session, error) {
+	converter, err := jqyaml.New(append(opts[:len(opts):len(opts)], jqyaml.WithQuery("."))...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		opts:      opts,
+		converter: converter,
+		cache:     jqyaml.NewQueryCache(jqyaml.DefaultQueryCacheSize),
+		limit:     DefaultPreviewLimit,
+	}
+	if s.input, err = s.convert(ctx, input); err != nil {
+		return nil, fmt.Errorf("failed to convert input: %w", err)
+	}
+	return s, nil
+}
+
+// convert returns v converted to jq-compatible values
+func (s *Session) convert(ctx context.Context, v interface{}) (interface{}, error) {
+	var converted interface{}
+	err := s.converter.Execute(ctx, v, jqyaml.WithCallback(func(v interface{}) error {
+		converted = v
+		return nil
+	}))
+	return converted, err
+}
+
+// Input returns the converted input queries are evaluated against
+func (s *Session) Input() interface{} {
+	return s.input
+}
+
+// SetPreviewLimit sets the maximum number of results returned by Evaluate
+func (s *Session) SetPreviewLimit(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = n
+}
+
+// Evaluate runs query against the session's input and returns a preview of
+// its first results. Evaluation stops once the preview is full. A query that
+// does not parse yet, as is common while typing, returns a QueryError.
+func (s *Session) Evaluate(ctx context.Context, query string, opts ...jqyaml.ExecuteOption) (*Preview, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.cancel = cancel
+	limit := s.limit
+	s.mu.Unlock()
+
+	p, err := jqyaml.New(append(s.opts[:len(s.opts):len(s.opts)],
+		jqyaml.WithQuery(query),
+		jqyaml.WithInputMarshaler(sessionMarshaler{s}),
+		jqyaml.WithQueryCache(s.cache),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	page, err := p.ExecutePage(ctx, sessionInput{s.input}, 0, limit, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{Query: query, Results: page.Items, Truncated: page.HasMore}, nil
+}
+
+// sessionInput marks the already converted input of a Session
+type sessionInput struct {
+	v interface{}
+}
+
+// sessionMarshaler passes the session's input through unchanged and
+// converts other values, such as variables, as the session's options do
+type sessionMarshaler struct {
+	s *Session
+}
+
+func (m sessionMarshaler) Marshal(v interface{}) (interface{}, error) {
+	if in, ok := v.(sessionInput); ok {
+		return in.v, nil
+	}
+	return m.s.convert(context.Background(), v)
+}
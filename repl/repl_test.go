@@ -0,0 +1,93 @@
+package repl_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/apstndb/go-jq-yamlformat/repl"
+	"github.com/google/go-cmp/cmp"
+)
+
+type user struct {
+	Name  string `json:"name"`
+	Admin bool   `json:"admin"`
+}
+
+// countingMarshaler counts conversions of user values
+type countingMarshaler struct {
+	calls *int
+}
+
+func (m countingMarshaler) Marshal(v interface{}) (interface{}, error) {
+	if users, ok := v.([]user); ok {
+		*m.calls++
+		result := make([]interface{}, len(users))
+		for i, u := range users {
+			result[i] = map[string]interface{}{"name": u.Name, "admin": u.Admin}
+		}
+		return result, nil
+	}
+	return v, nil
+}
+
+func TestSession(t *testing.T) {
+	calls := 0
+	input := []user{{"alice", true}, {"bob", false}, {"carol", true}}
+	s, err := repl.NewSession(context.Background(), input, jqyaml.WithInputMarshaler(countingMarshaler{&calls}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetPreviewLimit(2)
+
+	tests := []struct {
+		query     string
+		want      []interface{}
+		truncated bool
+	}{
+		{query: ".[].name", want: []interface{}{"alice", "bob"}, truncated: true},
+		{query: ".[] | select(.admin).name", want: []interface{}{"alice", "carol"}},
+		{query: "length", want: []interface{}{3}},
+	}
+	for _, tt := range tests {
+		preview, err := s.Evaluate(context.Background(), tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if diff := cmp.Diff(tt.want, preview.Results); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", tt.query, diff)
+		}
+		if preview.Truncated != tt.truncated {
+			t.Errorf("%s: expected Truncated %v", tt.query, tt.truncated)
+		}
+	}
+	// The input is converted once for all evaluations
+	if calls != 1 {
+		t.Errorf("expected 1 conversion, got %d", calls)
+	}
+}
+
+func TestSessionIncompleteQuery(t *testing.T) {
+	s, err := repl.NewSession(context.Background(), map[string]interface{}{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Evaluate(context.Background(), ".a |")
+	var queryErr *jqyaml.QueryError
+	if !errors.As(err, &queryErr) {
+		t.Errorf("expected QueryError, got %T: %v", err, err)
+	}
+
+	// Variables are converted like the input
+	preview, err := s.Evaluate(context.Background(), ".a + $n.x",
+		jqyaml.WithVariables(map[string]interface{}{"n": struct {
+			X int `json:"x"`
+		}{2}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]interface{}{3}, preview.Results); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
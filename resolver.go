@@ -0,0 +1,166 @@
+package jqyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValueResolver replaces a value that the default conversion cannot handle,
+// such as a func-valued lazy loader, with concrete data. It reports false to
+// leave the value unresolved.
+type ValueResolver func(v interface{}) (interface{}, bool)
+
+// maxResolveDepth bounds the nesting of values converted by resolve, so that
+// resolvers returning values that need resolving again cannot loop forever
+const maxResolveDepth = 100
+
+// resolve converts v like convertToJQCompatible, consulting the resolver for
+// the values that cannot be converted and converting the containers holding
+// them, or holding binary values subject to the binary policy, element by
+// element. Struct fields follow their json tags.
+func (d *defaultInputMarshaler) resolve(v interface{}, depth int) (interface{}, error) {
+	if depth > maxResolveDepth {
+		return nil, fmt.Errorf("value resolution exceeds depth %d", maxResolveDepth)
+	}
+	rv := reflect.ValueOf(v)
+	var err error
+	if d.binary != 0 {
+		if converted, ok, err := d.binary.convert(v); ok {
+			return converted, err
+		}
+	}
+	if d.binary == 0 || !containsBinary(rv, 0) {
+		var converted interface{}
+		converted, err = convertToJQCompatible(v, d.encodeOptions...)
+		if err == nil {
+			return converted, nil
+		}
+		if d.resolver != nil {
+			if resolved, ok := d.resolver(v); ok {
+				return d.resolve(resolved, depth+1)
+			}
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return d.resolve(rv.Elem().Interface(), depth+1)
+	case reflect.Struct:
+		obj := map[string]interface{}{}
+		if err := d.resolveFields(obj, rv, depth); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case reflect.Map:
+		obj := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			value, err := d.resolve(iter.Value().Interface(), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if _, skip := value.(skippedValue); skip {
+				continue
+			}
+			obj[fmt.Sprint(iter.Key().Interface())] = value
+		}
+		return obj, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		arr := make([]interface{}, rv.Len())
+		for i := range arr {
+			value, err := d.resolve(rv.Index(i).Interface(), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if _, skip := value.(skippedValue); skip {
+				value = nil
+			}
+			arr[i] = value
+		}
+		return arr, nil
+	default:
+		if err == nil {
+			err = fmt.Errorf("unsupported type %T", v)
+		}
+		return nil, err
+	}
+}
+
+// resolveFields adds the exported fields of the struct rv to obj like
+// encoding/json does. Embedded structs are expanded after the direct fields,
+// so that fields of the outer struct take precedence.
+func (d *defaultInputMarshaler) resolveFields(obj map[string]interface{}, rv reflect.Value, depth int) error {
+	var embedded []reflect.Value
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := rv.Field(i)
+
+		if field.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				embedded = append(embedded, fv)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := obj[name]; ok {
+			continue
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+		value, err := d.resolve(fv.Interface(), depth+1)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if _, skip := value.(skippedValue); skip {
+			continue
+		}
+		obj[name] = value
+	}
+	for _, fv := range embedded {
+		if err := d.resolveFields(obj, fv, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmptyValue reports whether omitempty omits v, as in encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return v.IsZero()
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package jqyaml_test
+
+import (
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+type lazyLoader func() interface{}
+
+type author struct {
+	Name string `json:"name"`
+}
+
+type article struct {
+	Title  string     `json:"title"`
+	Author lazyLoader `json:"author"`
+	Draft  bool       `json:"draft,omitempty"`
+	Tags   []string   `json:"tags"`
+	hidden string
+}
+
+func resolveLazy(v interface{}) (interface{}, bool) {
+	if l, ok := v.(lazyLoader); ok {
+		return l(), true
+	}
+	return nil, false
+}
+
+func TestWithValueResolver(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."), jqyaml.WithValueResolver(resolveLazy))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	input := map[string]interface{}{
+		"articles": []article{{
+			Title:  "a",
+			Author: func() interface{} { return author{Name: "alice"} },
+			Tags:   []string{"go"},
+			hidden: "x",
+		}},
+		"count": 1,
+	}
+	got := collect(t, p, input)
+	want := []interface{}{map[string]interface{}{
+		"articles": []interface{}{map[string]interface{}{
+			"title":  "a",
+			"author": map[string]interface{}{"name": "alice"},
+			"tags":   []interface{}{"go"},
+		}},
+		"count": 1,
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithValueResolverVariables(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("$loader"), jqyaml.WithValueResolver(resolveLazy))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	loader := lazyLoader(func() interface{} { return []int{1, 2} })
+	got := collect(t, p, nil, jqyaml.WithVariables(map[string]interface{}{"loader": loader}))
+	if diff := cmp.Diff([]interface{}{[]interface{}{1, 2}}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithValueResolverUnresolved(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."), jqyaml.WithValueResolver(resolveLazy))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	err = p.Execute(context.Background(), map[string]interface{}{"ch": make(chan int)},
+		jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err == nil {
+		t.Fatal("expected error for unresolved channel")
+	}
+}
+
+func TestWithValueResolverValidation(t *testing.T) {
+	if _, err := jqyaml.New(jqyaml.WithValueResolver(nil)); err == nil {
+		t.Error("expected error for nil resolver")
+	}
+}
@@ -0,0 +1,107 @@
+package jqyaml
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ExitStatus classifies the outcome of an execution, using the exit codes of the jq command
+type ExitStatus int
+
+const (
+	// ExitOK means the execution succeeded
+	ExitOK ExitStatus = 0
+	// ExitUsage means the execution could not run or write its output,
+	// e.g. because of conflicting options or a failing writer
+	ExitUsage ExitStatus = 2
+	// ExitCompile means a query failed to parse or compile
+	ExitCompile ExitStatus = 3
+	// ExitRuntime means the input could not be processed, including query
+	// runtime errors, conversion and decode errors, and timeouts
+	ExitRuntime ExitStatus = 5
+)
+
+// ExecuteResult reports the outcome of ExecuteR
+type ExecuteResult struct {
+	Emitted    int           // Number of results passed to the output
+	Skipped    int           // Number of input records skipped after failing (see WithCollectErrors)
+	Err        error         // The error Execute would have returned
+	FirstError error         // First of the errors joined in Err, or Err itself
+	LastError  error         // Last of the errors joined in Err, or Err itself
+	Duration   time.Duration // Wall-clock time of the execution
+	Status     ExitStatus    // Classification of LastError
+	// Number of inputs served from and missing in the WithResultCache cache
+	CacheHits, CacheMisses int
+}
+
+// ExecuteR runs the pipeline like Execute and reports the outcome as an ExecuteResult
+func (p *pipeline) ExecuteR(ctx context.Context, input interface{}, opts ...ExecuteOption) *ExecuteResult {
+	cfg := p.newExecuteConfig(opts...)
+	defer closeChannel(cfg.channel)
+	body, err := p.inputBody(cfg, input)
+	if err != nil {
+		return newExecuteResult(err, nil, 0)
+	}
+	return p.executeResult(ctx, cfg, body)
+}
+
+// executeResult runs the pipeline with cfg, feeding it the input with body,
+// and reports the outcome
+func (p *pipeline) executeResult(ctx context.Context, cfg *executeConfig, body func(*execution) error) *ExecuteResult {
+	start := time.Now()
+
+	var ex *execution
+	err := p.run(ctx, cfg, func(e *execution) error {
+		ex = e
+		return body(e)
+	})
+	return newExecuteResult(err, ex, time.Since(start))
+}
+
+// newExecuteResult reports the outcome of ex, which is nil if the execution
+// did not start
+func newExecuteResult(err error, ex *execution, duration time.Duration) *ExecuteResult {
+	result := &ExecuteResult{
+		Err:      err,
+		Duration: duration,
+	}
+	if ex != nil {
+		result.Emitted = ex.emitted
+		result.Skipped = len(ex.errs)
+		result.CacheHits = ex.cacheHits
+		result.CacheMisses = ex.cacheMisses
+	}
+	if err != nil {
+		result.FirstError, result.LastError = err, err
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs := joined.Unwrap()
+			result.FirstError, result.LastError = errs[0], errs[len(errs)-1]
+		}
+	}
+	result.Status = exitStatus(result.LastError)
+	return result
+}
+
+// exitStatus classifies err
+func exitStatus(err error) ExitStatus {
+	var queryErr *QueryError
+	var conversionErr *ConversionError
+	var decodeErr *DecodeError
+	var sizeErr *ResultSizeError
+	var timeoutErr *TimeoutError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &queryErr):
+		if strings.HasPrefix(queryErr.Message, "failed to parse") || strings.HasPrefix(queryErr.Message, "failed to compile") {
+			return ExitCompile
+		}
+		return ExitRuntime
+	case errors.As(err, &conversionErr), errors.As(err, &decodeErr), errors.As(err, &sizeErr), errors.As(err, &timeoutErr):
+		return ExitRuntime
+	default:
+		return ExitUsage
+	}
+}
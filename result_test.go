@@ -0,0 +1,92 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestExecuteR(t *testing.T) {
+	discard := jqyaml.WithCallback(func(interface{}) error { return nil })
+
+	tests := []struct {
+		name        string
+		query       string
+		input       interface{}
+		opts        []jqyaml.ExecuteOption
+		wantEmitted int
+		wantSkipped int
+		wantStatus  jqyaml.ExitStatus
+	}{
+		{
+			name:        "success",
+			query:       ".[]",
+			input:       []interface{}{1, 2, 3},
+			opts:        []jqyaml.ExecuteOption{discard},
+			wantEmitted: 3,
+			wantStatus:  jqyaml.ExitOK,
+		},
+		{
+			name:        "stages reduce emitted results",
+			query:       ".[]",
+			input:       []interface{}{1, 1, 2},
+			opts:        []jqyaml.ExecuteOption{discard, jqyaml.WithDedup("")},
+			wantEmitted: 2,
+			wantStatus:  jqyaml.ExitOK,
+		},
+		{
+			name:        "runtime error after results",
+			query:       ".[] | 1 / .",
+			input:       []interface{}{1, 0},
+			opts:        []jqyaml.ExecuteOption{discard},
+			wantEmitted: 1,
+			wantStatus:  jqyaml.ExitRuntime,
+		},
+		{
+			name:        "collected error",
+			query:       ".a + 1",
+			input:       map[string]interface{}{"a": "x"},
+			opts:        []jqyaml.ExecuteOption{discard, jqyaml.WithCollectErrors(0)},
+			wantSkipped: 1,
+			wantStatus:  jqyaml.ExitRuntime,
+		},
+		{
+			name:       "aux query compile error",
+			query:      ".",
+			input:      1,
+			opts:       []jqyaml.ExecuteOption{discard, jqyaml.WithSortBy(".[", false)},
+			wantStatus: jqyaml.ExitCompile,
+		},
+		{
+			name:       "no output",
+			query:      ".",
+			input:      1,
+			wantStatus: jqyaml.ExitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := p.ExecuteR(context.Background(), tt.input, tt.opts...)
+			if result.Emitted != tt.wantEmitted || result.Skipped != tt.wantSkipped || result.Status != tt.wantStatus {
+				t.Errorf("got emitted=%d skipped=%d status=%d, want emitted=%d skipped=%d status=%d (err: %v)",
+					result.Emitted, result.Skipped, result.Status, tt.wantEmitted, tt.wantSkipped, tt.wantStatus, result.Err)
+			}
+			if (tt.wantStatus == jqyaml.ExitOK) != (result.Err == nil) {
+				t.Errorf("unexpected error: %v", result.Err)
+			}
+			if result.Err != nil && (result.FirstError == nil || !errors.Is(result.Err, result.LastError)) {
+				t.Errorf("first/last errors not taken from Err: %v, %v", result.FirstError, result.LastError)
+			}
+			if result.Duration < 0 {
+				t.Errorf("expected non-negative duration, got %v", result.Duration)
+			}
+		})
+	}
+}
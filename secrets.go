@@ -0,0 +1,48 @@
+package jqyaml
+
+import (
+	"sort"
+	"strings"
+)
+
+// redactedPlaceholder replaces secret values in error messages
+const redactedPlaceholder = "[REDACTED]"
+
+// redact hides the values of secret variables in the message of err
+func (ex *execution) redact(err error) error {
+	if err == nil || len(ex.cfg.secrets) == 0 {
+		return err
+	}
+	return &redactedError{err: err, replacer: ex.secretReplacer()}
+}
+
+// secretReplacer returns a replacer of the secret values, preferring longer
+// values where they overlap
+func (ex *execution) secretReplacer() *strings.Replacer {
+	values := make([]string, 0, len(ex.cfg.secrets))
+	for _, v := range ex.cfg.secrets {
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, redactedPlaceholder)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// redactedError is an error whose message has secret values removed
+type redactedError struct {
+	err      error
+	replacer *strings.Replacer
+}
+
+func (e *redactedError) Error() string {
+	return e.replacer.Replace(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,55 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithSecretVariables(t *testing.T) {
+	const token = "tok-12345"
+	p, err := jqyaml.New(jqyaml.WithQuery(`if .token == $token then .user else error("invalid token \(.token), expected \($token)") end`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, p, map[string]interface{}{"token": token, "user": "alice"},
+		jqyaml.WithSecretVariables(map[string]string{"token": token}),
+	)
+	if diff := cmp.Diff([]interface{}{"alice"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for name, opts := range map[string][]jqyaml.ExecuteOption{
+		"main query": nil,
+		"aux query":  {jqyaml.WithSortBy(`error("key \($token)")`, false)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			query := `if .token == $token then error("invalid token \(.token), expected \($token)") else . end`
+			if opts != nil {
+				query = "."
+			}
+			p, err := jqyaml.New(jqyaml.WithQuery(query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts = append(opts,
+				jqyaml.WithSecretVariables(map[string]string{"token": token}),
+				jqyaml.WithCallback(func(interface{}) error { return nil }),
+			)
+			err = p.Execute(context.Background(), map[string]interface{}{"token": token}, opts...)
+			var queryErr *jqyaml.QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("expected QueryError, got %T: %v", err, err)
+			}
+			msg := queryErr.Err.Error()
+			if strings.Contains(msg, token) || !strings.Contains(msg, "[REDACTED]") {
+				t.Errorf("secret not redacted: %s", msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,593 @@
+package jqyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/goccy/go-yaml"
+	"github.com/itchyny/gojq"
+)
+
+// resultStage transforms the stream of results between the query and the output
+type resultStage interface {
+	// emit receives one result and passes zero or more values to next
+	emit(v interface{}, next func(interface{}) error) error
+	// flush is called after the last result and may emit buffered values
+	flush(next func(interface{}) error) error
+}
+
+// stageSpec describes a result stage configured by an ExecuteOption.
+// The stage itself is built per execution because it may depend on variables.
+type stageSpec struct {
+	name  string
+	build func(ex *execution) (resultStage, error)
+	// ordered is set for stages accepting the values of a conversion without
+	// a query: yaml.MapSlice objects and numbers as decoded
+	ordered bool
+}
+
+// buildStages chains the configured stages in front of sink and returns the
+// entry point for results and a function to flush buffered results at the end
+func (ex *execution) buildStages(specs []stageSpec, sink func(interface{}) error) (func(interface{}) error, func() error, error) {
+	stages := make([]resultStage, len(specs))
+	for i, spec := range specs {
+		stage, err := spec.build(ex)
+		if err != nil {
+			return nil, nil, err
+		}
+		stages[i] = stage
+	}
+
+	// nexts[i] is the function stage i forwards its output to
+	nexts := make([]func(interface{}) error, len(stages)+1)
+	nexts[len(stages)] = sink
+	for i := len(stages) - 1; i >= 0; i-- {
+		stage, next := stages[i], nexts[i+1]
+		nexts[i] = func(v interface{}) error {
+			return stage.emit(v, next)
+		}
+	}
+
+	flush := func() error {
+		// Flush in order so that values released by a stage can still be
+		// buffered by the stages after it
+		for i, stage := range stages {
+			if err := stage.flush(nexts[i+1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nexts[0], flush, nil
+}
+
+// compileAux compiles a secondary query evaluated against individual results.
+// It sees the same variables and compiler options as the main query.
+func (ex *execution) compileAux(query, purpose string) (*auxQuery, error) {
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		return nil, &QueryError{
+			Query:   query,
+			Message: fmt.Sprintf("failed to parse %s query", purpose),
+			Err:     err,
+		}
+	}
+	varNames, varValues := variableNamesAndValues(ex.variables)
+	code, err := ex.pipeline.compile(parsed, varNames, ex.compilerOptions...)
+	if err != nil {
+		return nil, &QueryError{
+			Query:   query,
+			Message: fmt.Sprintf("failed to compile %s query", purpose),
+			Err:     err,
+		}
+	}
+	return &auxQuery{query: query, code: code, ex: ex, values: varValues}, nil
+}
+
+// auxQuery is a compiled secondary query bound to an execution
+type auxQuery struct {
+	query  string
+	code   *gojq.Code
+	ex     *execution
+	values []interface{}
+}
+
+// collect runs the query on v and returns all of its outputs
+func (q *auxQuery) collect(v interface{}) ([]interface{}, error) {
+	var results []interface{}
+	iter := q.code.RunWithContext(q.ex.evalContext(), v, q.values...)
+	for {
+		r, ok := q.ex.next(iter)
+		if !ok {
+			return results, nil
+		}
+		if err, ok := r.(error); ok {
+			if ctxErr := q.ex.contextError(err); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, &QueryError{
+				Query:   q.query,
+				Message: "execution error",
+				Err:     q.ex.redact(err),
+			}
+		}
+		results = append(results, r)
+	}
+}
+
+// key computes the comparison key of v. Like jq's unique_by and sort_by,
+// the key of a query is the array of all its outputs; without a query the
+// value itself is the key.
+func (q *auxQuery) key(v interface{}) (interface{}, error) {
+	if q == nil {
+		return v, nil
+	}
+	return q.collect(v)
+}
+
+// compileKeyQuery compiles keyQuery unless it is empty
+func (ex *execution) compileKeyQuery(keyQuery, purpose string) (*auxQuery, error) {
+	if keyQuery == "" {
+		return nil, nil
+	}
+	return ex.compileAux(keyQuery, purpose)
+}
+
+// canonicalKey encodes a jq value so that equal values produce equal strings.
+// Object keys are sorted, 1 and 1.0 encode identically, and NaN and the
+// infinities, which JSON cannot encode, are written as fixed tokens.
+func canonicalKey(v interface{}) (string, error) {
+	b, err := appendKey(nil, v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func appendKey(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case string:
+		return strconv.AppendQuote(b, v), nil
+	case int:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case float64:
+		return appendKeyFloat(b, v), nil
+	case *big.Int:
+		return v.Append(b, 10), nil
+	case json.Number:
+		if i, ok := new(big.Int).SetString(string(v), 10); ok {
+			return i.Append(b, 10), nil
+		}
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", v)
+		}
+		return appendKeyFloat(b, f), nil
+	case []interface{}:
+		b = append(b, '[')
+		for i, e := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = appendKey(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case map[string]interface{}:
+		return appendKeyObject(b, v)
+	case yaml.MapSlice:
+		// Ordered values are objects whose later duplicate keys win
+		m := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			m[fmt.Sprint(item.Key)] = item.Value
+		}
+		return appendKeyObject(b, m)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, data...), nil
+	}
+}
+
+func appendKeyObject(b []byte, m map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b = append(b, '{')
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, k)
+		b = append(b, ':')
+		var err error
+		if b, err = appendKey(b, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendKeyFloat writes integral floats like integers of any size, so that
+// they equal ints and big integers of the same value
+func appendKeyFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, "NaN"...)
+	case math.IsInf(f, 1):
+		return append(b, "Infinity"...)
+	case math.IsInf(f, -1):
+		return append(b, "-Infinity"...)
+	case f == math.Trunc(f):
+		i, _ := big.NewFloat(f).Int(nil)
+		return i.Append(b, 10)
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, 64)
+}
+
+// dedupStage drops results whose key was already emitted
+type dedupStage struct {
+	key  *auxQuery
+	seen map[string]struct{}
+}
+
+func newDedupStage(keyQuery string) stageSpec {
+	return stageSpec{
+		name: fmt.Sprintf("dedup(%s)", keyQuery),
+		build: func(ex *execution) (resultStage, error) {
+			key, err := ex.compileKeyQuery(keyQuery, "dedup key")
+			if err != nil {
+				return nil, err
+			}
+			return &dedupStage{key: key, seen: make(map[string]struct{})}, nil
+		},
+	}
+}
+
+func (s *dedupStage) emit(v interface{}, next func(interface{}) error) error {
+	key, err := s.key.key(v)
+	if err != nil {
+		return err
+	}
+	k, err := canonicalKey(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.seen[k]; ok {
+		return nil
+	}
+	s.seen[k] = struct{}{}
+	return next(v)
+}
+
+func (s *dedupStage) flush(func(interface{}) error) error {
+	return nil
+}
+
+// sortStage buffers all results and emits them ordered by key
+type sortStage struct {
+	key     *auxQuery
+	desc    bool
+	results []keyedResult
+}
+
+// keyedResult is a buffered result with its precomputed key
+type keyedResult struct {
+	key   interface{}
+	value interface{}
+}
+
+func newSortStage(keyQuery string, desc bool) stageSpec {
+	name := fmt.Sprintf("sort_by(%s)", keyQuery)
+	if desc {
+		name += " desc"
+	}
+	return stageSpec{
+		name: name,
+		build: func(ex *execution) (resultStage, error) {
+			key, err := ex.compileKeyQuery(keyQuery, "sort key")
+			if err != nil {
+				return nil, err
+			}
+			return &sortStage{key: key, desc: desc}, nil
+		},
+	}
+}
+
+func (s *sortStage) emit(v interface{}, _ func(interface{}) error) error {
+	key, err := s.key.key(v)
+	if err != nil {
+		return err
+	}
+	s.results = append(s.results, keyedResult{key: key, value: v})
+	return nil
+}
+
+func (s *sortStage) flush(next func(interface{}) error) error {
+	// Results with equal keys keep their original order in both directions
+	sort.SliceStable(s.results, func(i, j int) bool {
+		c := gojq.Compare(s.results[i].key, s.results[j].key)
+		if s.desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	for _, r := range s.results {
+		if err := next(r.value); err != nil {
+			return err
+		}
+	}
+	s.results = nil
+	return nil
+}
+
+// groupStage buffers all results and emits one array per distinct key
+type groupStage struct {
+	key    *auxQuery
+	index  map[string]int // Canonical key to position in groups
+	groups []resultGroup
+}
+
+// resultGroup holds the results sharing a key, in arrival order
+type resultGroup struct {
+	key    interface{}
+	values []interface{}
+}
+
+func newGroupStage(keyQuery string) stageSpec {
+	return stageSpec{
+		name: fmt.Sprintf("group_by(%s)", keyQuery),
+		build: func(ex *execution) (resultStage, error) {
+			key, err := ex.compileKeyQuery(keyQuery, "group key")
+			if err != nil {
+				return nil, err
+			}
+			return &groupStage{key: key, index: make(map[string]int)}, nil
+		},
+	}
+}
+
+func (s *groupStage) emit(v interface{}, _ func(interface{}) error) error {
+	key, err := s.key.key(v)
+	if err != nil {
+		return err
+	}
+	k, err := canonicalKey(key)
+	if err != nil {
+		return err
+	}
+	i, ok := s.index[k]
+	if !ok {
+		i = len(s.groups)
+		s.index[k] = i
+		s.groups = append(s.groups, resultGroup{key: key})
+	}
+	s.groups[i].values = append(s.groups[i].values, v)
+	return nil
+}
+
+func (s *groupStage) flush(next func(interface{}) error) error {
+	// Order groups by key like jq's group_by
+	sort.SliceStable(s.groups, func(i, j int) bool {
+		return gojq.Compare(s.groups[i].key, s.groups[j].key) < 0
+	})
+	for _, g := range s.groups {
+		if err := next(g.values); err != nil {
+			return err
+		}
+	}
+	s.groups = nil
+	return nil
+}
+
+// summaryStage passes results through and evaluates a query over all of them at the end
+type summaryStage struct {
+	query   *auxQuery
+	encoder Encoder // nil means the summary goes to the main output
+	results []interface{}
+}
+
+func newSummaryStage(query string, w io.Writer, format Format) stageSpec {
+	return stageSpec{
+		name: fmt.Sprintf("summary(%s)", query),
+		build: func(ex *execution) (resultStage, error) {
+			q, err := ex.compileAux(query, "summary")
+			if err != nil {
+				return nil, err
+			}
+			stage := &summaryStage{query: q, results: []interface{}{}}
+			if w != nil {
+				stage.encoder = &encoderWrapper{writer: w, format: format, options: ex.encodeOptions}
+			}
+			return stage, nil
+		},
+	}
+}
+
+func (s *summaryStage) emit(v interface{}, next func(interface{}) error) error {
+	s.results = append(s.results, v)
+	return next(v)
+}
+
+func (s *summaryStage) flush(next func(interface{}) error) error {
+	summaries, err := s.query.collect(s.results)
+	if err != nil {
+		return err
+	}
+	s.results = nil
+	output := next
+	if s.encoder != nil {
+		output = s.encoder.Encode
+	}
+	for _, v := range summaries {
+		if err := output(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterStage passes on the results accepted by a Go predicate
+type filterStage struct {
+	keep func(v interface{}) (bool, error)
+}
+
+func newFilterStage(keep func(v interface{}) (bool, error)) stageSpec {
+	return stageSpec{
+		name: "filter",
+		build: func(*execution) (resultStage, error) {
+			if keep == nil {
+				return nil, fmt.Errorf("result filter must not be nil")
+			}
+			return &filterStage{keep: keep}, nil
+		},
+	}
+}
+
+func (s *filterStage) emit(v interface{}, next func(interface{}) error) error {
+	ok, err := s.keep(v)
+	if err != nil || !ok {
+		return err
+	}
+	return next(v)
+}
+
+func (s *filterStage) flush(func(interface{}) error) error {
+	return nil
+}
+
+// sampleStage emits the first head results, buffers the last tail results,
+// and reports how many results were skipped in between
+type sampleStage struct {
+	head, tail int
+	seen       int
+	ring       []interface{} // Last tail results after the head, in ring order
+}
+
+func newSampleStage(head, tail int) stageSpec {
+	return stageSpec{
+		name: fmt.Sprintf("sample(%d, %d)", head, tail),
+		build: func(*execution) (resultStage, error) {
+			if head < 0 || tail < 0 {
+				return nil, fmt.Errorf("sample sizes cannot be negative: head=%d, tail=%d", head, tail)
+			}
+			return &sampleStage{head: head, tail: tail}, nil
+		},
+	}
+}
+
+func (s *sampleStage) emit(v interface{}, next func(interface{}) error) error {
+	s.seen++
+	if s.seen <= s.head {
+		return next(v)
+	}
+	if s.tail == 0 {
+		return nil
+	}
+	if len(s.ring) < s.tail {
+		s.ring = append(s.ring, v)
+	} else {
+		s.ring[(s.seen-s.head-1)%s.tail] = v
+	}
+	return nil
+}
+
+func (s *sampleStage) flush(next func(interface{}) error) error {
+	if skipped := s.seen - s.head - len(s.ring); skipped > 0 {
+		if err := next(map[string]interface{}{"skipped": skipped}); err != nil {
+			return err
+		}
+	}
+	// The oldest buffered result is at the next write position once the ring is full
+	start := 0
+	if len(s.ring) == s.tail && s.tail > 0 {
+		start = (s.seen - s.head) % s.tail
+	}
+	for i := range s.ring {
+		if err := next(s.ring[(start+i)%len(s.ring)]); err != nil {
+			return err
+		}
+	}
+	s.ring = nil
+	return nil
+}
+
+// TruncatePolicy selects what happens to a result exceeding WithMaxResultBytes
+type TruncatePolicy int
+
+const (
+	// TruncateError stops the execution with a ResultSizeError
+	TruncateError TruncatePolicy = iota
+	// TruncateStub replaces the result with a summary object describing it
+	TruncateStub
+)
+
+func (t TruncatePolicy) String() string {
+	switch t {
+	case TruncateError:
+		return "error"
+	case TruncateStub:
+		return "stub"
+	default:
+		return fmt.Sprintf("TruncatePolicy(%d)", int(t))
+	}
+}
+
+// sizeLimitStage enforces a maximum encoded size for each result
+type sizeLimitStage struct {
+	limit  int
+	policy TruncatePolicy
+}
+
+func newSizeLimitStage(limit int, policy TruncatePolicy) stageSpec {
+	return stageSpec{
+		name: fmt.Sprintf("max_result_bytes(%d, %s)", limit, policy),
+		build: func(*execution) (resultStage, error) {
+			if limit <= 0 {
+				return nil, fmt.Errorf("result size limit must be positive: %d", limit)
+			}
+			return &sizeLimitStage{limit: limit, policy: policy}, nil
+		},
+	}
+}
+
+func (s *sizeLimitStage) emit(v interface{}, next func(interface{}) error) error {
+	// Measure the compact JSON encoding, which does not depend on the output format
+	b, err := gojq.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(b) <= s.limit {
+		return next(v)
+	}
+	if s.policy == TruncateError {
+		return &ResultSizeError{Size: len(b), Limit: s.limit}
+	}
+	return next(map[string]interface{}{
+		"truncated": true,
+		"type":      gojq.TypeOf(v),
+		"bytes":     len(b),
+		"preview":   gojq.Preview(v),
+	})
+}
+
+func (s *sizeLimitStage) flush(func(interface{}) error) error {
+	return nil
+}
@@ -0,0 +1,429 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// collect executes p and returns all results passed to the callback
+func collect(t *testing.T, p jqyaml.Pipeline, input interface{}, opts ...jqyaml.ExecuteOption) []interface{} {
+	t.Helper()
+	var results []interface{}
+	opts = append(opts, jqyaml.WithCallback(func(v interface{}) error {
+		results = append(results, v)
+		return nil
+	}))
+	if err := p.Execute(context.Background(), input, opts...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return results
+}
+
+func TestDedup(t *testing.T) {
+	pages := []interface{}{
+		[]interface{}{
+			map[string]interface{}{"id": 1, "name": "Alice"},
+			map[string]interface{}{"id": 2, "name": "Bob"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": 2, "name": "Bob"},
+			map[string]interface{}{"id": 3, "name": "Bob"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		opts      []jqyaml.ExecuteOption
+		variables map[string]interface{}
+		want      []interface{}
+	}{
+		{
+			name: "deep equality",
+			opts: []jqyaml.ExecuteOption{jqyaml.WithDedup("")},
+			want: []interface{}{
+				map[string]interface{}{"id": 1, "name": "Alice"},
+				map[string]interface{}{"id": 2, "name": "Bob"},
+				map[string]interface{}{"id": 3, "name": "Bob"},
+			},
+		},
+		{
+			name: "key query",
+			opts: []jqyaml.ExecuteOption{jqyaml.WithDedup(".name")},
+			want: []interface{}{
+				map[string]interface{}{"id": 1, "name": "Alice"},
+				map[string]interface{}{"id": 2, "name": "Bob"},
+			},
+		},
+		{
+			name: "key query with variables",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithDedup(".id > $threshold"),
+				jqyaml.WithVariables(map[string]interface{}{"threshold": 1}),
+			},
+			want: []interface{}{
+				map[string]interface{}{"id": 1, "name": "Alice"},
+				map[string]interface{}{"id": 2, "name": "Bob"},
+			},
+		},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery(".[][]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(t, p, pages, tt.opts...)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDedupNumbers(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, p, []interface{}{1, 1.0, 2, 1}, jqyaml.WithDedup(""))
+	if diff := cmp.Diff([]interface{}{1, 2}, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+
+	// JSON cannot encode NaN and the infinities, but they are keys like any
+	// other number
+	got = collect(t, p, []interface{}{1, 2, 3, 4}, jqyaml.WithDedup(`if . < 3 then nan else infinite end`))
+	if diff := cmp.Diff([]interface{}{1, 3}, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+	got = collect(t, p, []interface{}{1, 2, 3}, jqyaml.WithDedup(`if . < 3 then 1e1000 else -1e1000 end`))
+	if diff := cmp.Diff([]interface{}{1, 3}, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStageQueryError(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Execute(context.Background(), []int{1, 2},
+		jqyaml.WithDedup(".id |"),
+		jqyaml.WithCallback(func(interface{}) error { return nil }),
+	)
+	var queryErr *jqyaml.QueryError
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("expected QueryError, got %T: %v", err, err)
+	}
+	if queryErr.Query != ".id |" {
+		t.Errorf("Query = %q, want %q", queryErr.Query, ".id |")
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	input := []interface{}{
+		map[string]interface{}{"id": 3, "group": "b"},
+		map[string]interface{}{"id": 1, "group": "a"},
+		map[string]interface{}{"id": 2, "group": "b"},
+		map[string]interface{}{"id": 4, "group": "a"},
+	}
+
+	tests := []struct {
+		name    string
+		opts    []jqyaml.ExecuteOption
+		wantIDs []interface{}
+	}{
+		{
+			name:    "ascending",
+			opts:    []jqyaml.ExecuteOption{jqyaml.WithSortBy(".id", false)},
+			wantIDs: []interface{}{1, 2, 3, 4},
+		},
+		{
+			name:    "descending",
+			opts:    []jqyaml.ExecuteOption{jqyaml.WithSortBy(".id", true)},
+			wantIDs: []interface{}{4, 3, 2, 1},
+		},
+		{
+			name:    "stable for equal keys",
+			opts:    []jqyaml.ExecuteOption{jqyaml.WithSortBy(".group", false)},
+			wantIDs: []interface{}{1, 4, 3, 2},
+		},
+		{
+			name:    "stable for equal keys descending",
+			opts:    []jqyaml.ExecuteOption{jqyaml.WithSortBy(".group", true)},
+			wantIDs: []interface{}{3, 2, 1, 4},
+		},
+		{
+			name: "after dedup",
+			opts: []jqyaml.ExecuteOption{
+				jqyaml.WithDedup(".group"),
+				jqyaml.WithSortBy(".id", false),
+			},
+			wantIDs: []interface{}{1, 3},
+		},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []interface{}
+			for _, r := range collect(t, p, input, tt.opts...) {
+				ids = append(ids, r.(map[string]interface{})["id"])
+			}
+			if diff := cmp.Diff(tt.wantIDs, ids); diff != "" {
+				t.Errorf("order mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortByValue(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// jq ordering: null < false < true < numbers < strings < arrays < objects
+	input := []interface{}{"b", 2, nil, true, []interface{}{1}, "a", 1.5, false}
+	got := collect(t, p, input, jqyaml.WithSortBy("", false))
+	want := []interface{}{nil, false, true, 1.5, 2, "a", "b", []interface{}{1}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("order mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	input := []interface{}{
+		map[string]interface{}{"id": 1, "team": "b"},
+		map[string]interface{}{"id": 2, "team": "a"},
+		map[string]interface{}{"id": 3, "team": "b"},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("results", func(t *testing.T) {
+		got := collect(t, p, input, jqyaml.WithGroupBy(".team"))
+		want := []interface{}{
+			[]interface{}{
+				map[string]interface{}{"id": 2, "team": "a"},
+			},
+			[]interface{}{
+				map[string]interface{}{"id": 1, "team": "b"},
+				map[string]interface{}{"id": 3, "team": "b"},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("results mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("one document per group", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := p.Execute(context.Background(), input,
+			jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+			jqyaml.WithCompactJSONOutput(),
+			jqyaml.WithGroupBy(".team"),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := `[{"id":2,"team":"a"}]` + "\n" + `[{"id":1,"team":"b"},{"id":3,"team":"b"}]` + "\n"
+		if got := buf.String(); got != want {
+			t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
+	t.Run("NaN key", func(t *testing.T) {
+		got := collect(t, p, input, jqyaml.WithGroupBy(`if .team == "b" then nan else 1 end`))
+		want := []interface{}{
+			[]interface{}{
+				map[string]interface{}{"id": 1, "team": "b"},
+				map[string]interface{}{"id": 3, "team": "b"},
+			},
+			[]interface{}{
+				map[string]interface{}{"id": 2, "team": "a"},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("results mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestSummary(t *testing.T) {
+	input := []interface{}{
+		map[string]interface{}{"id": 1, "size": 10},
+		map[string]interface{}{"id": 2, "size": 32},
+	}
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("designated writer", func(t *testing.T) {
+		var out, summary bytes.Buffer
+		err := p.Execute(context.Background(), input,
+			jqyaml.WithWriter(&out, jqyaml.FormatYAML),
+			jqyaml.WithSummary("{count: length, total: (map(.size) | add)}", &summary, jqyaml.FormatYAML),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "id: 1\nsize: 10\nid: 2\nsize: 32\n"; out.String() != want {
+			t.Errorf("output mismatch\ngot:  %q\nwant: %q", out.String(), want)
+		}
+		if want := "count: 2\ntotal: 42\n"; summary.String() != want {
+			t.Errorf("summary mismatch\ngot:  %q\nwant: %q", summary.String(), want)
+		}
+	})
+
+	t.Run("appended to the main output", func(t *testing.T) {
+		got := collect(t, p, input, jqyaml.WithSummary("length", nil, jqyaml.FormatYAML))
+		want := []interface{}{input[0], input[1], 2}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("results mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("empty stream", func(t *testing.T) {
+		got := collect(t, p, []interface{}{}, jqyaml.WithSummary("length", nil, jqyaml.FormatYAML))
+		if diff := cmp.Diff([]interface{}{0}, got); diff != "" {
+			t.Errorf("results mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestSample(t *testing.T) {
+	skipped := func(n int) interface{} {
+		return map[string]interface{}{"skipped": n}
+	}
+
+	tests := []struct {
+		name       string
+		n          int
+		head, tail int
+		want       []interface{}
+	}{
+		{name: "head and tail", n: 10, head: 2, tail: 3, want: []interface{}{0, 1, skipped(5), 7, 8, 9}},
+		{name: "head only", n: 10, head: 3, want: []interface{}{0, 1, 2, skipped(7)}},
+		{name: "tail only", n: 10, tail: 2, want: []interface{}{skipped(8), 8, 9}},
+		{name: "nothing skipped", n: 4, head: 2, tail: 2, want: []interface{}{0, 1, 2, 3}},
+		{name: "fewer than head", n: 1, head: 2, tail: 2, want: []interface{}{0}},
+		{name: "partially filled tail", n: 3, head: 2, tail: 5, want: []interface{}{0, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery("range(.)"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(t, p, tt.n, jqyaml.WithSample(tt.head, tt.tail))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResultFilter(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{
+		map[string]interface{}{"id": 1, "owner": "alice"},
+		map[string]interface{}{"id": 2, "owner": "bob"},
+		map[string]interface{}{"id": 3, "owner": "alice"},
+	}
+	allowed := func(v interface{}) (bool, error) {
+		return v.(map[string]interface{})["owner"] == "alice", nil
+	}
+
+	got := collect(t, p, input, jqyaml.WithResultFilter(allowed), jqyaml.WithSample(1, 0))
+	want := []interface{}{
+		map[string]interface{}{"id": 1, "owner": "alice"},
+		map[string]interface{}{"skipped": 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+
+	errDenied := errors.New("denied")
+	err = p.Execute(context.Background(), input,
+		jqyaml.WithResultFilter(func(interface{}) (bool, error) { return false, errDenied }),
+		jqyaml.WithCallback(func(interface{}) error { return nil }),
+	)
+	if !errors.Is(err, errDenied) {
+		t.Errorf("expected filter error, got %v", err)
+	}
+
+	err = p.Execute(context.Background(), input, jqyaml.WithResultFilter(nil),
+		jqyaml.WithCallback(func(interface{}) error { return nil }))
+	if err == nil {
+		t.Error("expected error for nil filter")
+	}
+}
+
+func TestMaxResultBytes(t *testing.T) {
+	input := []interface{}{
+		"short",
+		map[string]interface{}{"message": "a very long message that exceeds the limit"},
+	}
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("stub", func(t *testing.T) {
+		got := collect(t, p, input, jqyaml.WithMaxResultBytes(20, jqyaml.TruncateStub))
+		if len(got) != 2 {
+			t.Fatalf("expected 2 results, got %d: %v", len(got), got)
+		}
+		if got[0] != "short" {
+			t.Errorf("result[0] = %v, want short", got[0])
+		}
+		stub, ok := got[1].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected stub object, got %T", got[1])
+		}
+		if stub["truncated"] != true || stub["type"] != "object" || stub["bytes"] != 56 {
+			t.Errorf("unexpected stub: %v", stub)
+		}
+		if _, ok := stub["preview"].(string); !ok {
+			t.Errorf("expected string preview, got %T", stub["preview"])
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var results []interface{}
+		err := p.Execute(context.Background(), input,
+			jqyaml.WithMaxResultBytes(20, jqyaml.TruncateError),
+			jqyaml.WithCallback(func(v interface{}) error {
+				results = append(results, v)
+				return nil
+			}),
+		)
+		var sizeErr *jqyaml.ResultSizeError
+		if !errors.As(err, &sizeErr) {
+			t.Fatalf("expected ResultSizeError, got %T: %v", err, err)
+		}
+		if sizeErr.Size != 56 || sizeErr.Limit != 20 {
+			t.Errorf("unexpected error fields: %+v", sizeErr)
+		}
+		if len(results) != 1 {
+			t.Errorf("expected 1 result before the error, got %d", len(results))
+		}
+	})
+}
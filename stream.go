@@ -0,0 +1,139 @@
+package jqyaml
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// jsonStreamDecoder decomposes a stream of JSON values into the events of
+// jq --stream, holding only the path to the current value in memory: a
+// [path, leaf] pair for each scalar and empty array or object, and a
+// one-element [path] after the last child of each other array or object
+type jsonStreamDecoder struct {
+	dec    *json.Decoder
+	lines  *lineCounter
+	pos    Position
+	frames []streamFrame // Open arrays and objects, outermost first
+}
+
+// streamFrame is an open array or object
+type streamFrame struct {
+	object    bool
+	expectKey bool        // The next token of an object is a key or its end
+	key       string      // Key of the current member of an object
+	index     int         // Index of the next element of an array
+	last      interface{} // Key or index of the last completed child
+}
+
+func newJSONStreamDecoder(r io.Reader) *jsonStreamDecoder {
+	lines := &lineCounter{r: r}
+	dec := json.NewDecoder(lines)
+	dec.UseNumber()
+	return &jsonStreamDecoder{dec: dec, lines: lines}
+}
+
+func (d *jsonStreamDecoder) decode() (interface{}, error) {
+	for {
+		start := d.tokenStart()
+		tok, err := d.dec.Token()
+		if err != nil {
+			d.pos = Position{}
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) && syntaxErr.Offset > 0 {
+				d.pos = d.lines.position(syntaxErr.Offset - 1)
+			}
+			if errors.Is(err, io.EOF) && len(d.frames) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		d.pos = d.lines.position(start)
+		if n := len(d.frames); n > 0 && d.frames[n-1].expectKey {
+			if key, ok := tok.(string); ok {
+				d.frames[n-1].key = key
+				d.frames[n-1].expectKey = false
+				continue
+			}
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			object := tok == json.Delim('{')
+			if !d.dec.More() {
+				// An empty array or object is a leaf
+				if _, err := d.dec.Token(); err != nil {
+					return nil, err
+				}
+				var leaf interface{} = []interface{}{}
+				if object {
+					leaf = map[string]interface{}{}
+				}
+				return d.leaf(leaf), nil
+			}
+			d.frames = append(d.frames, streamFrame{object: object, expectKey: object})
+		case json.Delim('}'), json.Delim(']'):
+			last := d.frames[len(d.frames)-1].last
+			d.frames = d.frames[:len(d.frames)-1]
+			event := []interface{}{append(d.path(), last)}
+			d.complete()
+			return event, nil
+		default:
+			return d.leaf(tok), nil
+		}
+	}
+}
+
+// leaf returns the event of a leaf value at the current path
+func (d *jsonStreamDecoder) leaf(v interface{}) []interface{} {
+	event := []interface{}{d.path(), v}
+	d.complete()
+	return event
+}
+
+// path returns a new path to the current value
+func (d *jsonStreamDecoder) path() []interface{} {
+	path := make([]interface{}, len(d.frames), len(d.frames)+1)
+	for i, f := range d.frames {
+		if f.object {
+			path[i] = f.key
+		} else {
+			path[i] = f.index
+		}
+	}
+	return path
+}
+
+// complete moves past the current value of the innermost array or object
+func (d *jsonStreamDecoder) complete() {
+	if len(d.frames) == 0 {
+		return
+	}
+	f := &d.frames[len(d.frames)-1]
+	if f.object {
+		f.last = f.key
+		f.expectKey = true
+	} else {
+		f.last = f.index
+		f.index++
+	}
+}
+
+// tokenStart returns the offset of the next token, skipping the whitespace
+// and separators before it that are already buffered
+func (d *jsonStreamDecoder) tokenStart() int64 {
+	offset := d.dec.InputOffset()
+	if r, ok := d.dec.Buffered().(io.ByteReader); ok {
+		for {
+			c, err := r.ReadByte()
+			if err != nil || (c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != ',' && c != ':') {
+				break
+			}
+			offset++
+		}
+	}
+	return offset
+}
+
+func (d *jsonStreamDecoder) position() Position {
+	return d.pos
+}
@@ -0,0 +1,69 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStreamInput(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("events", func(t *testing.T) {
+		got := collectReader(t, p, `{"a": [1, {"b": null}], "c": [], "d": {}} 2 [3]`, jqyaml.FormatJSON, jqyaml.WithStreamInput())
+		// The events of jq -c --stream for the same input
+		want := []interface{}{
+			[]interface{}{[]interface{}{"a", 0}, 1},
+			[]interface{}{[]interface{}{"a", 1, "b"}, nil},
+			[]interface{}{[]interface{}{"a", 1, "b"}},
+			[]interface{}{[]interface{}{"a", 1}},
+			[]interface{}{[]interface{}{"c"}, []interface{}{}},
+			[]interface{}{[]interface{}{"d"}, map[string]interface{}{}},
+			[]interface{}{[]interface{}{"d"}},
+			[]interface{}{[]interface{}{}, 2},
+			[]interface{}{[]interface{}{0}, 3},
+			[]interface{}{[]interface{}{0}},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("fromstream", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("fromstream(1 | truncate_stream(inputs))"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collectReader(t, p, "[{\"id\": 1}, {\"id\": 2, \"tags\": [\"x\"]}]\n", jqyaml.FormatJSONL,
+			jqyaml.WithStreamInput(), jqyaml.WithNullInput())
+		want := []interface{}{
+			map[string]interface{}{"id": 1},
+			map[string]interface{}{"id": 2, "tags": []interface{}{"x"}},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		callback := jqyaml.WithCallback(func(interface{}) error { return nil })
+		err := p.ExecuteReader(context.Background(), strings.NewReader("[1,\n 2 3]"), jqyaml.FormatJSON, jqyaml.WithStreamInput(), callback)
+		var decodeErr *jqyaml.DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Document != 3 || decodeErr.Position.Line != 2 {
+			t.Errorf("got %v, want a DecodeError for the third event on line 2", err)
+		}
+		if err := p.ExecuteReader(context.Background(), strings.NewReader("a: 1"), jqyaml.FormatYAML, jqyaml.WithStreamInput(), callback); err == nil {
+			t.Error("expected an error for YAML input")
+		}
+		if err := p.Execute(context.Background(), nil, jqyaml.WithStreamInput(), callback); err == nil {
+			t.Error("expected an error without reader input")
+		}
+	})
+}
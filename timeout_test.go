@@ -0,0 +1,117 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// slowMarshaler converts values after a delay
+type slowMarshaler struct {
+	delay time.Duration
+}
+
+func (m slowMarshaler) Marshal(v interface{}) (interface{}, error) {
+	time.Sleep(m.delay)
+	return v, nil
+}
+
+func TestTimeoutWrapping(t *testing.T) {
+	const timeout = 10 * time.Millisecond
+	tests := []struct {
+		name string
+		run  func(ctx context.Context) error
+	}{
+		{
+			name: "conversion",
+			run: func(ctx context.Context) error {
+				p, err := jqyaml.New(jqyaml.WithInputMarshaler(slowMarshaler{delay: 3 * timeout}))
+				if err != nil {
+					return err
+				}
+				return p.Execute(ctx, 1, jqyaml.WithTimeout(timeout),
+					jqyaml.WithCallback(func(interface{}) error { return nil }))
+			},
+		},
+		{
+			name: "encoder",
+			run: func(ctx context.Context) error {
+				p, err := jqyaml.New()
+				if err != nil {
+					return err
+				}
+				slow := jqyaml.EncoderFunc(func(interface{}) error {
+					time.Sleep(3 * timeout)
+					return nil
+				})
+				return p.ExecuteReader(ctx, strings.NewReader("1 2"), jqyaml.FormatJSON,
+					jqyaml.WithTimeout(timeout), jqyaml.WithEncoder(slow))
+			},
+		},
+		{
+			name: "callback returning the context error",
+			run: func(ctx context.Context) error {
+				p, err := jqyaml.New(jqyaml.WithQuery("."))
+				if err != nil {
+					return err
+				}
+				ctx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				return p.Execute(ctx, 1, jqyaml.WithTimeout(timeout),
+					jqyaml.WithCallback(func(interface{}) error {
+						<-ctx.Done()
+						return fmt.Errorf("callback: %w", ctx.Err())
+					}))
+			},
+		},
+		{
+			name: "query",
+			run: func(ctx context.Context) error {
+				p, err := jqyaml.New(jqyaml.WithQuery("range(1e9)"))
+				if err != nil {
+					return err
+				}
+				return p.Execute(ctx, nil, jqyaml.WithTimeout(timeout),
+					jqyaml.WithCallback(func(interface{}) error { return nil }))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(context.Background())
+			var timeoutErr *jqyaml.TimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("expected TimeoutError, got %T: %v", err, err)
+			}
+			if timeoutErr.Duration != timeout {
+				t.Errorf("Duration = %s, want %s", timeoutErr.Duration, timeout)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("errors.Is(%v, context.DeadlineExceeded) = false", err)
+			}
+		})
+	}
+}
+
+func TestCallbackDeadlineWithoutTimeout(t *testing.T) {
+	// A deadline error of the callback's own context is not an execution timeout
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	err = p.Execute(context.Background(), 1, jqyaml.WithCallback(func(interface{}) error {
+		return fmt.Errorf("request: %w", context.DeadlineExceeded)
+	}))
+	var timeoutErr *jqyaml.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		t.Fatalf("unexpected TimeoutError: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+}
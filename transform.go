@@ -0,0 +1,18 @@
+package jqyaml
+
+import (
+	"context"
+	"io"
+)
+
+// Transform decodes the documents of src in srcFormat, runs the pipeline
+// configured by opts over them and writes the results to dst in dstFormat.
+// Execute options such as WithCompactJSONOutput are given through
+// WithDefaultExecuteOptions.
+func Transform(ctx context.Context, dst io.Writer, dstFormat Format, src io.Reader, srcFormat Format, opts ...Option) error {
+	p, err := New(opts...)
+	if err != nil {
+		return err
+	}
+	return p.ExecuteReader(ctx, src, srcFormat, WithWriter(dst, dstFormat))
+}
@@ -0,0 +1,83 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		srcFormat jqyaml.Format
+		dstFormat jqyaml.Format
+		opts      []jqyaml.Option
+		want      string
+	}{
+		{
+			name:      "yaml to json",
+			src:       "name: a\n---\nname: b\n",
+			srcFormat: jqyaml.FormatYAML,
+			dstFormat: jqyaml.FormatJSON,
+			opts:      []jqyaml.Option{jqyaml.WithQuery(".name")},
+			want:      "\"a\"\n\"b\"\n",
+		},
+		{
+			name:      "json to yaml",
+			src:       `{"items": [1, 2]}`,
+			srcFormat: jqyaml.FormatJSON,
+			dstFormat: jqyaml.FormatYAML,
+			opts:      []jqyaml.Option{jqyaml.WithQuery(".items | map(. * 10)")},
+			want:      "- 10\n- 20\n",
+		},
+		{
+			name:      "default execute options",
+			src:       `{"name": "a"} {"name": "b"}`,
+			srcFormat: jqyaml.FormatJSON,
+			dstFormat: jqyaml.FormatJSON,
+			opts: []jqyaml.Option{
+				jqyaml.WithQuery(".name"),
+				jqyaml.WithDefaultExecuteOptions(jqyaml.WithRawJSONOutput()),
+			},
+			want: "a\nb\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := jqyaml.Transform(context.Background(), &buf, tt.dstFormat, strings.NewReader(tt.src), tt.srcFormat, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformInvalidOption(t *testing.T) {
+	var buf bytes.Buffer
+	err := jqyaml.Transform(context.Background(), &buf, jqyaml.FormatJSON, strings.NewReader("{}"), jqyaml.FormatJSON, jqyaml.WithQuery(".["))
+	if err == nil {
+		t.Fatal("expected error for invalid query")
+	}
+}
+
+func TestDefaultExecuteOptionsPrecedence(t *testing.T) {
+	p, err := jqyaml.New(
+		jqyaml.WithQuery("$x"),
+		jqyaml.WithDefaultExecuteOptions(jqyaml.WithVariables(map[string]interface{}{"x": "default"})),
+	)
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	got := collect(t, p, nil, jqyaml.WithVariables(map[string]interface{}{"x": "call"}))
+	if len(got) != 1 || got[0] != "call" {
+		t.Errorf("got %v, want [call]", got)
+	}
+}
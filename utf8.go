@@ -0,0 +1,119 @@
+package jqyaml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Policy selects what WithInvalidUTF8 does with strings in results
+// that are not valid UTF-8, e.g. produced by @base64d from binary data
+type InvalidUTF8Policy int
+
+const (
+	// UTF8Replace replaces each invalid byte sequence with U+FFFD
+	UTF8Replace InvalidUTF8Policy = iota
+	// UTF8Error stops the execution with an InvalidUTF8Error
+	UTF8Error
+	// UTF8Base64 replaces the whole string with its standard base64 encoding
+	UTF8Base64
+)
+
+func (p InvalidUTF8Policy) String() string {
+	switch p {
+	case UTF8Replace:
+		return "replace"
+	case UTF8Error:
+		return "error"
+	case UTF8Base64:
+		return "base64"
+	default:
+		return fmt.Sprintf("InvalidUTF8Policy(%d)", int(p))
+	}
+}
+
+// invalidUTF8Stage applies an InvalidUTF8Policy to the strings and object keys of each result
+type invalidUTF8Stage struct {
+	policy InvalidUTF8Policy
+}
+
+func newInvalidUTF8Stage(policy InvalidUTF8Policy) stageSpec {
+	return stageSpec{
+		name: fmt.Sprintf("invalid_utf8(%s)", policy),
+		build: func(*execution) (resultStage, error) {
+			if policy < UTF8Replace || policy > UTF8Base64 {
+				return nil, fmt.Errorf("unknown invalid UTF-8 policy: %s", policy)
+			}
+			return &invalidUTF8Stage{policy: policy}, nil
+		},
+	}
+}
+
+func (s *invalidUTF8Stage) emit(v interface{}, next func(interface{}) error) error {
+	fixed, err := s.apply(v)
+	if err != nil {
+		return err
+	}
+	return next(fixed)
+}
+
+func (s *invalidUTF8Stage) flush(func(interface{}) error) error {
+	return nil
+}
+
+// apply walks v and fixes the strings it contains
+func (s *invalidUTF8Stage) apply(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return s.fix(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			fixed, err := s.apply(elem)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = fixed
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			key, err := s.fix(k)
+			if err != nil {
+				return nil, err
+			}
+			fixed, err := s.apply(elem)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = fixed
+		}
+		return result, nil
+	default:
+		return v, nil
+	}
+}
+
+func (s *invalidUTF8Stage) fix(str string) (string, error) {
+	if utf8.ValidString(str) {
+		return str, nil
+	}
+	switch s.policy {
+	case UTF8Error:
+		offset := 0
+		for offset < len(str) {
+			r, size := utf8.DecodeRuneInString(str[offset:])
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			offset += size
+		}
+		return "", &InvalidUTF8Error{Offset: offset}
+	case UTF8Base64:
+		return base64.StdEncoding.EncodeToString([]byte(str)), nil
+	default:
+		return strings.ToValidUTF8(str, "\uFFFD"), nil
+	}
+}
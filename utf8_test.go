@@ -0,0 +1,59 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithInvalidUTF8(t *testing.T) {
+	// "/w==" decodes to the single byte 0xff
+	query := `{("/w==" | @base64d): ["ok", ("Yf9i" | @base64d)]}`
+
+	tests := []struct {
+		policy jqyaml.InvalidUTF8Policy
+		want   []interface{}
+	}{
+		{jqyaml.UTF8Replace, []interface{}{map[string]interface{}{"\uFFFD": []interface{}{"ok", "a\uFFFDb"}}}},
+		{jqyaml.UTF8Base64, []interface{}{map[string]interface{}{"/w==": []interface{}{"ok", "Yf9i"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(t, p, nil, jqyaml.WithInvalidUTF8(tt.policy))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithInvalidUTF8Error(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`"ok", ("Yf9i" | @base64d)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []interface{}
+	err = p.Execute(context.Background(), nil,
+		jqyaml.WithInvalidUTF8(jqyaml.UTF8Error),
+		jqyaml.WithCallback(func(v interface{}) error {
+			got = append(got, v)
+			return nil
+		}))
+	var utf8Err *jqyaml.InvalidUTF8Error
+	if !errors.As(err, &utf8Err) {
+		t.Fatalf("expected InvalidUTF8Error, got %T: %v", err, err)
+	}
+	if utf8Err.Offset != 1 {
+		t.Errorf("expected offset 1, got %d", utf8Err.Offset)
+	}
+	if diff := cmp.Diff([]interface{}{"ok"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,75 @@
+package jqyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// validateJQValue reports the first value in v that gojq cannot handle.
+// gojq panics on such values when a query reaches them, so the values
+// returned by custom input marshalers are checked before execution.
+// Number types that gojq normalizes itself are accepted.
+func validateJQValue(v interface{}) error {
+	return validateJQValueAt(v, nil)
+}
+
+func validateJQValueAt(v interface{}, path []interface{}) error {
+	switch v := v.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, float32, float64, *big.Int, json.Number:
+		return nil
+	case []interface{}:
+		for i, e := range v {
+			if err := validateJQValueAt(e, append(path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for k, e := range v {
+			if err := validateJQValueAt(e, append(path, k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return &InvalidValueError{Path: append([]interface{}{}, path...), Value: v}
+	}
+}
+
+// formatPath renders a path of object keys and array indices in jq syntax, e.g. .items[0].name
+func formatPath(path []interface{}) string {
+	if len(path) == 0 {
+		return "."
+	}
+	var b strings.Builder
+	for _, p := range path {
+		switch p := p.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", p)
+		case string:
+			if isIdentifier(p) {
+				b.WriteString("." + p)
+			} else {
+				b.WriteString("[" + strconv.Quote(p) + "]")
+			}
+		}
+	}
+	return b.String()
+}
+
+// isIdentifier reports whether s can follow "." in a jq path
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && (i == 0 || !('0' <= c && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// identityMarshaler passes values to gojq unchanged
+type identityMarshaler struct{}
+
+func (identityMarshaler) Marshal(v interface{}) (interface{}, error) {
+	return v, nil
+}
+
+func TestInvalidInputValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		vars     map[string]interface{}
+		wantPath []interface{}
+		wantMsg  string
+	}{
+		{
+			name:     "func in input",
+			input:    map[string]interface{}{"items": []interface{}{1, func() {}}},
+			wantPath: []interface{}{"items", 1},
+			wantMsg:  "unsupported type func() at .items[1]",
+		},
+		{
+			name:     "typed slice in input",
+			input:    map[string]interface{}{"my key": []int{1}},
+			wantPath: []interface{}{"my key"},
+			wantMsg:  `unsupported type []int at ["my key"]`,
+		},
+		{
+			name:     "complex variable",
+			input:    nil,
+			vars:     map[string]interface{}{"c": complex(1, 2)},
+			wantPath: []interface{}{},
+			wantMsg:  "unsupported type complex128 at .",
+		},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("."), jqyaml.WithInputMarshaler(identityMarshaler{}))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Execute(context.Background(), tt.input,
+				jqyaml.WithVariables(tt.vars),
+				jqyaml.WithCallback(func(interface{}) error { return nil }),
+			)
+			var convErr *jqyaml.ConversionError
+			if !errors.As(err, &convErr) {
+				t.Fatalf("expected ConversionError, got %v", err)
+			}
+			var invalidErr *jqyaml.InvalidValueError
+			if !errors.As(err, &invalidErr) {
+				t.Fatalf("expected InvalidValueError, got %v", err)
+			}
+			if diff := cmp.Diff(tt.wantPath, invalidErr.Path); diff != "" {
+				t.Errorf("path mismatch (-want +got):\n%s", diff)
+			}
+			if got := invalidErr.Error(); got != tt.wantMsg {
+				t.Errorf("message = %q, want %q", got, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestValidInputValueNumbers(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery("[.[] + 1]"), jqyaml.WithInputMarshaler(identityMarshaler{}))
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	got := collect(t, p, []interface{}{int32(1), uint8(2), float32(0.5)})
+	if diff := cmp.Diff([]interface{}{[]interface{}{2, 3, 1.5}}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,153 @@
+package jqyaml
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// variableNamePattern matches the names jq accepts after $
+var variableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// structVariables expands the fields of a struct into jq variables
+func structVariables(v interface{}) (map[string]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("variables struct must not be nil")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("variables must be a struct, got %T", v)
+	}
+	vars := make(map[string]interface{})
+	if err := addStructVariables(vars, rv); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// addStructVariables adds the fields of rv to vars. Embedded structs are expanded
+// after the direct fields, so that fields of the outer struct take precedence.
+func addStructVariables(vars map[string]interface{}, rv reflect.Value) error {
+	var embedded []reflect.Value
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			fv := rv.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				embedded = append(embedded, fv)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if !variableNamePattern.MatchString(name) {
+			return fmt.Errorf("field %s: %q is not a valid jq variable name", field.Name, name)
+		}
+		if _, ok := vars[name]; !ok {
+			vars[name] = rv.Field(i).Interface()
+		}
+	}
+	for _, fv := range embedded {
+		if err := addStructVariables(vars, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithVariableNames declares the variables of the query, given with or without
+// the $ prefix, so that New compiles the query ahead of time and reports
+// undefined variables. Executions then only supply values: declared variables
+// they leave unset are null, and setting a variable that is not declared is an
+// error. $ARGS and the variables bound by WithExecutionMetadata and
+// WithEnvAccess are declared implicitly.
+func WithVariableNames(names ...string) Option {
+	return func(p *pipeline) error {
+		if p.variableNames == nil {
+			p.variableNames = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			name = strings.TrimPrefix(name, "$")
+			if !variableNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid variable name %q", name)
+			}
+			p.variableNames[name] = true
+		}
+		return nil
+	}
+}
+
+// declareImplicitVariables adds the variables bound by the pipeline itself to
+// the names declared by WithVariableNames
+func (p *pipeline) declareImplicitVariables() {
+	p.variableNames["ARGS"] = true
+	if p.envAccess {
+		p.variableNames["ENV"] = true
+	}
+	if p.metadata {
+		for name := range p.withMetadataVariables(nil) {
+			p.variableNames[name] = true
+		}
+	}
+}
+
+// compileDeclared compiles the query with the declared variables in New.
+// Functions registered per execution are not known yet, so queries calling
+// undefined functions are left to be compiled by each execution.
+func (p *pipeline) compileDeclared() error {
+	if p.parsed == nil || p.externalJQ != nil {
+		return nil
+	}
+	vars := make(map[string]interface{}, len(p.variableNames))
+	for name := range p.variableNames {
+		vars[name] = nil
+	}
+	varNames, _ := variableNamesAndValues(vars)
+	if _, err := p.compileQuery(varNames, nil); err != nil && !strings.HasPrefix(err.Error(), "function not defined: ") {
+		return &QueryError{
+			Query:   p.query,
+			Message: "failed to compile query",
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// bindDeclared checks the converted variables of an execution against the
+// declared names and binds the declared variables left unset to null
+func (p *pipeline) bindDeclared(converted map[string]interface{}) (map[string]interface{}, error) {
+	bound := make(map[string]interface{}, len(p.variableNames))
+	for name, v := range converted {
+		if !p.variableNames[name] {
+			return nil, fmt.Errorf("variable $%s is not declared by WithVariableNames", name)
+		}
+		bound[name] = v
+	}
+	for name := range p.variableNames {
+		if _, ok := bound[name]; !ok {
+			bound[name] = nil
+		}
+	}
+	return bound, nil
+}
@@ -0,0 +1,237 @@
+package jqyaml_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+)
+
+type pagination struct {
+	Limit int `json:"limit"`
+}
+
+type filterParams struct {
+	pagination
+	MinValue int      `json:"min_value"`
+	Tags     []string `json:"tags,omitempty"`
+	Owner    string
+	Internal string `json:"-"`
+	secret   string
+}
+
+func TestWithVariablesFromStruct(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`[.[] | select(.value >= $min_value and (.tag | IN($tags[])))] | .[:$limit] | map(.owner = $Owner)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []map[string]interface{}{
+		{"value": 1, "tag": "a"},
+		{"value": 5, "tag": "a"},
+		{"value": 6, "tag": "b"},
+		{"value": 7, "tag": "a"},
+		{"value": 8, "tag": "a"},
+	}
+	params := filterParams{
+		pagination: pagination{Limit: 2},
+		MinValue:   5,
+		Tags:       []string{"a"},
+		Owner:      "alice",
+		secret:     "unused",
+	}
+
+	got := collect(t, p, input, jqyaml.WithVariablesFromStruct(&params))
+	want := []interface{}{[]interface{}{
+		map[string]interface{}{"value": 5, "tag": "a", "owner": "alice"},
+		map[string]interface{}{"value": 7, "tag": "a", "owner": "alice"},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithVariablesFromStructMerge(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(`[$a, $min_value]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, p, nil,
+		jqyaml.WithVariables(map[string]interface{}{"a": 1, "min_value": 0}),
+		jqyaml.WithVariablesFromStruct(struct {
+			MinValue int `json:"min_value"`
+		}{MinValue: 2}),
+	)
+	if diff := cmp.Diff([]interface{}{[]interface{}{1, 2}}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithVariablesFromStructError(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		wantErr string
+	}{
+		{name: "not a struct", v: map[string]interface{}{"a": 1}, wantErr: "must be a struct"},
+		{name: "nil pointer", v: (*filterParams)(nil), wantErr: "must not be nil"},
+		{name: "invalid name", v: struct {
+			A int `json:"a-b"`
+		}{}, wantErr: "not a valid jq variable name"},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Execute(context.Background(), nil,
+				jqyaml.WithVariablesFromStruct(tt.v),
+				jqyaml.WithCallback(func(interface{}) error { return nil }),
+			)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+type money struct {
+	Cents int
+}
+
+type lineItem struct {
+	Name  string `json:"name"`
+	Price money  `json:"price"`
+}
+
+func TestVariablesCustomMarshaler(t *testing.T) {
+	formatMoney := yaml.CustomMarshaler[money](func(m money) ([]byte, error) {
+		return []byte(fmt.Sprintf(`"$%d.%02d"`, m.Cents/100, m.Cents%100)), nil
+	})
+	input := []lineItem{{Name: "a", Price: money{Cents: 150}}}
+	vars := map[string]interface{}{
+		"item":  lineItem{Name: "b", Price: money{Cents: 205}},
+		"items": []lineItem{{Name: "c", Price: money{Cents: 1}}},
+		"price": money{Cents: 99},
+	}
+	want := []interface{}{[]interface{}{"$1.50", "$2.05", "$0.01", "$0.99", "$0.07"}}
+	query := `[.[0].price, $item.price, $items[0].price, $price, $fields.price]`
+	fields := struct {
+		Fields lineItem `json:"fields"`
+	}{Fields: lineItem{Price: money{Cents: 7}}}
+
+	t.Run("default encode options", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(query), jqyaml.WithDefaultEncodeOptions(formatMoney))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, input, jqyaml.WithVariables(vars), jqyaml.WithVariablesFromStruct(fields))
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("execute encode options", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery(query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, p, input, jqyaml.WithEncodeOptions(formatMoney), jqyaml.WithVariables(vars), jqyaml.WithVariablesFromStruct(fields))
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestWithVariableNames(t *testing.T) {
+	run := func(p jqyaml.Pipeline, opts ...jqyaml.ExecuteOption) ([]interface{}, error) {
+		var got []interface{}
+		err := p.Execute(context.Background(), nil, append(opts, jqyaml.WithCallback(func(v interface{}) error {
+			got = append(got, v)
+			return nil
+		}))...)
+		return got, err
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("[$a, $b]"), jqyaml.WithVariableNames("a", "$b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("values", func(t *testing.T) {
+		got, err := run(p, jqyaml.WithVariables(map[string]interface{}{"a": 1, "b": "x"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]interface{}{[]interface{}{1, "x"}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("unset variables are null", func(t *testing.T) {
+		got, err := run(p, jqyaml.WithArgString("a", "s"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]interface{}{[]interface{}{"s", nil}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("undeclared variables", func(t *testing.T) {
+		_, err := run(p, jqyaml.WithVariables(map[string]interface{}{"c": 1}))
+		if err == nil || !strings.Contains(err.Error(), "$c is not declared") {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("undefined variables are reported by New", func(t *testing.T) {
+		_, err := jqyaml.New(jqyaml.WithQuery("$a + $c"), jqyaml.WithVariableNames("a"))
+		var queryErr *jqyaml.QueryError
+		if !errors.As(err, &queryErr) || !strings.Contains(queryErr.Err.Error(), "variable not defined: $c") {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("implicit variables", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("[$ARGS.named.a, ($__pipeline | length)]"),
+			jqyaml.WithVariableNames("a"), jqyaml.WithExecutionMetadata())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := run(p, jqyaml.WithVariables(map[string]interface{}{"a": 1}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]interface{}{[]interface{}{1, 64}}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("functions registered per execution", func(t *testing.T) {
+		p, err := jqyaml.New(jqyaml.WithQuery("f($a)"), jqyaml.WithVariableNames("a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := jqyaml.WithExecFunction("f", 1, 1, func(_ interface{}, args []interface{}) interface{} { return args[0] })
+		got, err := run(p, f, jqyaml.WithVariables(map[string]interface{}{"a": 2}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]interface{}{2}, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid names", func(t *testing.T) {
+		if _, err := jqyaml.New(jqyaml.WithVariableNames("a-b")); err == nil {
+			t.Error("expected an error for an invalid name")
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package jqyaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"syscall"
+)
+
+// writeTracker counts bytes written to the output writer and remembers the first write error
+type writeTracker struct {
+	w    io.Writer
+	n    int64
+	err  error
+	hash hash.Hash // Hashes the bytes written under WithDigest
+}
+
+func (t *writeTracker) Write(b []byte) (int, error) {
+	// Refuse further writes once the writer has failed
+	if t.err != nil {
+		return 0, t.err
+	}
+	n, err := t.w.Write(b)
+	t.n += int64(n)
+	if t.hash != nil {
+		t.hash.Write(b[:n])
+	}
+	if err != nil {
+		t.err = err
+	}
+	return n, err
+}
+
+// wrap reports a failed writer as WriteError after each encode. The check
+// does not rely on the encoder's return value because some encoders drop
+// write errors silently.
+func (t *writeTracker) wrap(encode func(interface{}) error) func(interface{}) error {
+	return func(v interface{}) error {
+		err := encode(v)
+		if t.err != nil {
+			return &WriteError{BytesWritten: t.n, Err: t.err}
+		}
+		return err
+	}
+}
+
+// flush flushes writers implementing Flush() error, such as *bufio.Writer,
+// reporting a failure as WriteError
+func (t *writeTracker) flush() error {
+	f, ok := t.w.(interface{ Flush() error })
+	if !ok || t.err != nil {
+		return nil
+	}
+	if err := f.Flush(); err != nil {
+		t.err = err
+		return &WriteError{BytesWritten: t.n, Err: err}
+	}
+	return nil
+}
+
+// NewlineStyle selects the line endings of WithWriter output
+type NewlineStyle int
+
+const (
+	// NewlineLF ends lines with "\n" (default)
+	NewlineLF NewlineStyle = iota
+	// NewlineCRLF ends lines with "\r\n" for Windows tooling
+	NewlineCRLF
+)
+
+func (s NewlineStyle) String() string {
+	switch s {
+	case NewlineLF:
+		return "lf"
+	case NewlineCRLF:
+		return "crlf"
+	default:
+		return fmt.Sprintf("NewlineStyle(%d)", int(s))
+	}
+}
+
+// crlfWriter translates "\n" to "\r\n", leaving existing "\r\n" unchanged
+type crlfWriter struct {
+	w      io.Writer
+	prevCR bool // Whether the last byte written was '\r'
+}
+
+func (c *crlfWriter) Write(b []byte) (int, error) {
+	out := make([]byte, 0, len(b)+bytes.Count(b, []byte("\n")))
+	prevCR := c.prevCR
+	for _, ch := range b {
+		if ch == '\n' && !prevCR {
+			out = append(out, '\r')
+		}
+		out = append(out, ch)
+		prevCR = ch == '\r'
+	}
+	if _, err := c.w.Write(out); err != nil {
+		return 0, err
+	}
+	c.prevCR = prevCR
+	return len(b), nil
+}
+
+// bomWriter writes a UTF-8 byte order mark before the first output byte
+type bomWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (b *bomWriter) Write(p []byte) (int, error) {
+	if !b.written && len(p) > 0 {
+		if _, err := io.WriteString(b.w, utf8BOM); err != nil {
+			return 0, err
+		}
+		b.written = true
+	}
+	return b.w.Write(p)
+}
+
+// isBrokenPipe reports whether err is a WriteError caused by the reader of a pipe going away
+func isBrokenPipe(err error) bool {
+	var writeErr *WriteError
+	return errors.As(err, &writeErr) && errors.Is(writeErr.Err, syscall.EPIPE)
+}
@@ -0,0 +1,167 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// failingWriter accepts up to limit bytes and then fails every write
+type failingWriter struct {
+	buf   bytes.Buffer
+	limit int
+	err   error
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.buf.Len()+len(b) > w.limit {
+		return 0, w.err
+	}
+	return w.buf.Write(b)
+}
+
+func TestWriteError(t *testing.T) {
+	diskFull := errors.New("disk full")
+
+	tests := []struct {
+		name   string
+		format jqyaml.Format
+		opts   []jqyaml.ExecuteOption
+	}{
+		{name: "yaml", format: jqyaml.FormatYAML},
+		{name: "json", format: jqyaml.FormatJSON},
+		{name: "compact json", format: jqyaml.FormatJSON, opts: []jqyaml.ExecuteOption{jqyaml.WithCompactJSONOutput()}},
+		{name: "raw json", format: jqyaml.FormatJSON, opts: []jqyaml.ExecuteOption{jqyaml.WithRawJSONOutput()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery("range(1000000)"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := &failingWriter{limit: 10, err: diskFull}
+			err = p.Execute(context.Background(), nil,
+				append([]jqyaml.ExecuteOption{jqyaml.WithWriter(w, tt.format)}, tt.opts...)...,
+			)
+
+			var writeErr *jqyaml.WriteError
+			if !errors.As(err, &writeErr) {
+				t.Fatalf("expected WriteError, got %T: %v", err, err)
+			}
+			if !errors.Is(err, diskFull) {
+				t.Errorf("expected error to wrap %v, got %v", diskFull, err)
+			}
+			if writeErr.BytesWritten != int64(w.buf.Len()) {
+				t.Errorf("BytesWritten = %d, want %d", writeErr.BytesWritten, w.buf.Len())
+			}
+		})
+	}
+}
+
+func TestIgnoreBrokenPipe(t *testing.T) {
+	brokenPipe := &os.PathError{Op: "write", Path: "|1", Err: syscall.EPIPE}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("range(1000000)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("EPIPE is ignored", func(t *testing.T) {
+		w := &failingWriter{limit: 10, err: brokenPipe}
+		err := p.Execute(context.Background(), nil,
+			jqyaml.WithWriter(w, jqyaml.FormatYAML),
+			jqyaml.WithIgnoreBrokenPipe(),
+		)
+		if err != nil {
+			t.Errorf("expected nil error, got %v", err)
+		}
+		if w.buf.Len() == 0 {
+			t.Error("expected output before the pipe was closed")
+		}
+	})
+
+	t.Run("EPIPE is reported without the option", func(t *testing.T) {
+		w := &failingWriter{limit: 10, err: brokenPipe}
+		err := p.Execute(context.Background(), nil,
+			jqyaml.WithWriter(w, jqyaml.FormatYAML),
+		)
+		if !errors.Is(err, syscall.EPIPE) {
+			t.Errorf("expected EPIPE, got %v", err)
+		}
+	})
+
+	t.Run("other write errors are still reported", func(t *testing.T) {
+		w := &failingWriter{limit: 10, err: errors.New("disk full")}
+		err := p.Execute(context.Background(), nil,
+			jqyaml.WithWriter(w, jqyaml.FormatYAML),
+			jqyaml.WithIgnoreBrokenPipe(),
+		)
+		var writeErr *jqyaml.WriteError
+		if !errors.As(err, &writeErr) {
+			t.Errorf("expected WriteError, got %T: %v", err, err)
+		}
+	})
+}
+
+func TestWithNewlineStyle(t *testing.T) {
+	input := map[string]interface{}{"a": []interface{}{1, 2}, "s": "x\r\ny"}
+	tests := []struct {
+		name   string
+		query  string
+		format jqyaml.Format
+		opts   []jqyaml.ExecuteOption
+		want   string
+	}{
+		{name: "yaml", query: ".a", format: jqyaml.FormatYAML, want: "- 1\r\n- 2\r\n"},
+		{name: "pretty json", query: ".a", format: jqyaml.FormatJSON, opts: []jqyaml.ExecuteOption{jqyaml.WithPrettyJSONOutput()}, want: "[\r\n  1,\r\n  2\r\n]\r\n"},
+		{name: "json", query: ".a", format: jqyaml.FormatJSON, want: "[1, 2]\r\n"},
+		{name: "jsonl", query: ".a[]", format: jqyaml.FormatJSON, opts: []jqyaml.ExecuteOption{jqyaml.WithCompactJSONOutput()}, want: "1\r\n2\r\n"},
+		{name: "raw keeps existing crlf", query: ".s", format: jqyaml.FormatJSON, opts: []jqyaml.ExecuteOption{jqyaml.WithRawJSONOutput()}, want: "x\r\ny\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			opts := append([]jqyaml.ExecuteOption{
+				jqyaml.WithWriter(&buf, tt.format),
+				jqyaml.WithNewlineStyle(jqyaml.NewlineCRLF),
+			}, tt.opts...)
+			if err := p.Execute(context.Background(), input, opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithBOM(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = p.Execute(context.Background(), []interface{}{"a", "b"},
+		jqyaml.WithWriter(&buf, jqyaml.FormatJSON),
+		jqyaml.WithRawJSONOutput(),
+		jqyaml.WithBOM(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\xef\xbb\xbfa\nb\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
@@ -0,0 +1,329 @@
+// Package xlsx writes query results as Excel workbooks, registering the
+// "xlsx" output format of jqyaml when imported:
+//
+//	import _ "github.com/apstndb/go-jq-yamlformat/xlsx"
+//
+//	err := p.Execute(ctx, input, jqyaml.WithWriter(f, xlsx.Format))
+//
+// Each object result is a row, and array results contribute a row per
+// element. The first row of each sheet is a header of the fields of its rows.
+// Numbers and booleans become cells of those types, strings text cells, and
+// nested objects and arrays their JSON text.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+)
+
+// Format is the name the xlsx format is registered under
+const Format jqyaml.Format = "xlsx"
+
+// DefaultSheetName is the name of the sheet of ungrouped rows
+const DefaultSheetName = "Sheet1"
+
+// maxSheetName is the longest sheet name Excel accepts
+const maxSheetName = 31
+
+func init() {
+	jqyaml.RegisterOutputProvider(Format, Provider{})
+}
+
+// Provider implements jqyaml.OutputProvider. Registering a Provider with its
+// own Options under another name writes workbooks with fixed columns or
+// grouping.
+type Provider struct {
+	Options EncoderOptions
+}
+
+// NewEncoder implements jqyaml.OutputProvider
+func (p Provider) NewEncoder(w io.Writer) (jqyaml.Encoder, error) {
+	return NewEncoder(w, p.Options), nil
+}
+
+// EncoderOptions configure an Encoder
+type EncoderOptions struct {
+	// SheetName names the single sheet when rows are not grouped;
+	// DefaultSheetName if empty
+	SheetName string
+	// GroupBy puts the rows on one sheet per value of this field, named after
+	// the value, in the order the values first appear
+	GroupBy string
+	// Columns are the header of every sheet, in order. If empty, each sheet's
+	// header lists the fields of its rows sorted by name. Fields that are not
+	// columns are left out.
+	Columns []string
+}
+
+// Encoder writes rows to a workbook. The workbook is written by Close, which
+// must be called after the last row.
+type Encoder struct {
+	w      io.Writer
+	opts   EncoderOptions
+	sheets []*sheet
+	byName map[string]*sheet
+	err    error
+}
+
+type sheet struct {
+	name string
+	rows []map[string]interface{}
+}
+
+// NewEncoder returns an Encoder writing to w
+func NewEncoder(w io.Writer, opts EncoderOptions) *Encoder {
+	if opts.SheetName == "" {
+		opts.SheetName = DefaultSheetName
+	}
+	return &Encoder{w: w, opts: opts, byName: make(map[string]*sheet)}
+}
+
+// Encode adds v, an object or an array of objects, as rows
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	rows, ok := v.([]interface{})
+	if !ok {
+		rows = []interface{}{v}
+	}
+	for _, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			e.err = fmt.Errorf("xlsx: rows must be objects, got %T", r)
+			return e.err
+		}
+		name := e.opts.SheetName
+		if e.opts.GroupBy != "" {
+			name = sheetName(cellText(row[e.opts.GroupBy]))
+		}
+		s, ok := e.byName[strings.ToLower(name)]
+		if !ok {
+			// Excel compares sheet names case-insensitively
+			s = &sheet{name: name}
+			e.byName[strings.ToLower(name)] = s
+			e.sheets = append(e.sheets, s)
+		}
+		s.rows = append(s.rows, row)
+	}
+	return nil
+}
+
+// sheetName replaces the characters Excel rejects in sheet names and
+// shortens the name to the maximum length
+func sheetName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	if s = strings.Trim(s, "'"); s == "" {
+		s = "(empty)"
+	}
+	if r := []rune(s); len(r) > maxSheetName {
+		s = string(r[:maxSheetName])
+	}
+	return s
+}
+
+// Close writes the workbook; it does not close the writer
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.sheets) == 0 {
+		// A workbook has at least one sheet
+		e.sheets = append(e.sheets, &sheet{name: e.opts.SheetName})
+	}
+	e.err = e.writeWorkbook()
+	if e.err != nil {
+		return e.err
+	}
+	e.err = errors.New("xlsx: encoder closed")
+	return nil
+}
+
+func (e *Encoder) writeWorkbook() error {
+	zw := zip.NewWriter(e.w)
+	parts := []part{
+		{"[Content_Types].xml", e.contentTypes},
+		{"_rels/.rels", constant(packageRels)},
+		{"xl/workbook.xml", e.workbook},
+		{"xl/_rels/workbook.xml.rels", e.workbookRels},
+	}
+	for i, s := range e.sheets {
+		parts = append(parts, part{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), func(w io.Writer) error { return e.worksheet(w, s) }})
+	}
+	for _, f := range parts {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if err := f.content(w); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// part is a file of the workbook package
+type part struct {
+	name    string
+	content func(io.Writer) error
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const packageRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func constant(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func (e *Encoder) contentTypes(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	for i := range e.sheets {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	b.WriteString(`</Types>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (e *Encoder) workbook(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, s := range e.sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(s.name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (e *Encoder) workbookRels(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range e.sheets {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	b.WriteString(`</Relationships>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// columns returns the header of s
+func (e *Encoder) columns(s *sheet) []string {
+	if len(e.opts.Columns) > 0 {
+		return e.opts.Columns
+	}
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range s.rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func (e *Encoder) worksheet(w io.Writer, s *sheet) error {
+	columns := e.columns(s)
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	b.WriteString(`<row r="1">`)
+	for i, c := range columns {
+		writeCell(&b, cellRef(i, 1), c)
+	}
+	b.WriteString(`</row>`)
+	for r, row := range s.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+2)
+		for i, c := range columns {
+			if v := row[c]; v != nil {
+				writeCell(&b, cellRef(i, r+2), v)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cellRef returns the A1 reference of the 0-based column col in row
+func cellRef(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+func writeCell(b *strings.Builder, ref string, v interface{}) {
+	switch v := v.(type) {
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		fmt.Fprintf(b, `<c r="%s" t="b"><v>%d</v></c>`, ref, n)
+	case int, int64, float64, *big.Int, json.Number:
+		fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, cellText(v))
+	default:
+		fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(cellText(v)))
+	}
+}
+
+// cellText returns the text of a cell value, or of a group
+func cellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int, int64, *big.Int, json.Number, bool:
+		return fmt.Sprint(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,154 @@
+package xlsx_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/apstndb/go-jq-yamlformat/xlsx"
+	"github.com/google/go-cmp/cmp"
+)
+
+type cell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+type worksheet struct {
+	Rows []struct {
+		Cells []cell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readWorkbook returns the sheet names and the cells of each sheet as text,
+// with the type of non-string cells appended
+func readWorkbook(t *testing.T, data []byte) ([]string, [][][]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parts[f.Name], err = io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &wb); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var sheets [][][]string
+	for i, s := range wb.Sheets {
+		names = append(names, s.Name)
+		var ws worksheet
+		if err := xml.Unmarshal(parts["xl/worksheets/sheet"+string(rune('1'+i))+".xml"], &ws); err != nil {
+			t.Fatal(err)
+		}
+		var rows [][]string
+		for _, r := range ws.Rows {
+			var cells []string
+			for _, c := range r.Cells {
+				text := c.Ref + "=" + c.Inline
+				if c.Type != "inlineStr" {
+					text = c.Ref + "=" + c.Value + ":" + c.Type
+				}
+				cells = append(cells, text)
+			}
+			rows = append(rows, cells)
+		}
+		sheets = append(sheets, rows)
+	}
+	return names, sheets
+}
+
+func TestEncoder(t *testing.T) {
+	p, err := jqyaml.New(jqyaml.WithQuery(".items"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"name": "a & b", "count": 1, "ok": true},
+		map[string]interface{}{"name": "c", "price": 2.5, "tags": []interface{}{"x"}},
+	}}
+	var buf bytes.Buffer
+	if err := p.Execute(context.Background(), input, jqyaml.WithWriter(&buf, xlsx.Format)); err != nil {
+		t.Fatal(err)
+	}
+	names, sheets := readWorkbook(t, buf.Bytes())
+	if diff := cmp.Diff([]string{"Sheet1"}, names); diff != "" {
+		t.Errorf("sheets mismatch (-want +got):\n%s", diff)
+	}
+	want := [][][]string{{
+		{"A1=count", "B1=name", "C1=ok", "D1=price", "E1=tags"},
+		{"A2=1:", "B2=a & b", "C2=1:b"},
+		{"B3=c", "D3=2.5:", `E3=["x"]`},
+	}}
+	if diff := cmp.Diff(want, sheets); diff != "" {
+		t.Errorf("cells mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEncoderGroupBy(t *testing.T) {
+	var buf bytes.Buffer
+	enc := xlsx.NewEncoder(&buf, xlsx.EncoderOptions{GroupBy: "team", Columns: []string{"name", "team"}})
+	for _, row := range []interface{}{
+		map[string]interface{}{"name": "a", "team": "ops/infra"},
+		map[string]interface{}{"name": "b", "team": "dev", "extra": 1},
+		map[string]interface{}{"name": "c", "team": "Ops/Infra"},
+	} {
+		if err := enc.Encode(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	names, sheets := readWorkbook(t, buf.Bytes())
+	if diff := cmp.Diff([]string{"ops_infra", "dev"}, names); diff != "" {
+		t.Errorf("sheets mismatch (-want +got):\n%s", diff)
+	}
+	want := [][][]string{
+		{{"A1=name", "B1=team"}, {"A2=a", "B2=ops/infra"}, {"A3=c", "B3=Ops/Infra"}},
+		{{"A1=name", "B1=team"}, {"A2=b", "B2=dev"}},
+	}
+	if diff := cmp.Diff(want, sheets); diff != "" {
+		t.Errorf("cells mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	enc := xlsx.NewEncoder(io.Discard, xlsx.EncoderOptions{})
+	if err := enc.Encode([]interface{}{1}); err == nil {
+		t.Error("expected an error for a row that is not an object")
+	}
+
+	var buf bytes.Buffer
+	enc = xlsx.NewEncoder(&buf, xlsx.EncoderOptions{})
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := readWorkbook(t, buf.Bytes()); len(names) != 1 {
+		t.Errorf("got sheets %v, want one empty sheet", names)
+	}
+}
@@ -0,0 +1,59 @@
+package jqyaml
+
+import (
+	"io"
+
+	"github.com/goccy/go-yaml"
+)
+
+// StreamingYAMLEncoder writes each value as a YAML document, separating
+// consecutive documents with "---" so the output is a valid YAML stream.
+// It can be used with WithEncoder, or from a WithCallback function that
+// writes some of the results.
+type StreamingYAMLEncoder struct {
+	w       io.Writer
+	options []yaml.EncodeOption
+	started bool
+}
+
+// NewStreamingYAMLEncoder creates a StreamingYAMLEncoder writing to w
+func NewStreamingYAMLEncoder(w io.Writer, opts ...yaml.EncodeOption) *StreamingYAMLEncoder {
+	return &StreamingYAMLEncoder{w: w, options: opts}
+}
+
+// Encode writes v as the next document of the stream
+func (e *StreamingYAMLEncoder) Encode(v interface{}) error {
+	if e.started {
+		if _, err := io.WriteString(e.w, "---\n"); err != nil {
+			return err
+		}
+	}
+	e.started = true
+	return FormatYAML.NewEncoder(e.w, e.options...).Encode(v)
+}
+
+// rawYAMLEncoder writes string results as-is, like gojq --yaml-output --raw-output,
+// and other results as YAML
+type rawYAMLEncoder struct {
+	*encoderWrapper
+	separator *string // Written after each string instead of a newline when set
+}
+
+func (e *rawYAMLEncoder) Encode(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return e.encoderWrapper.Encode(v)
+	}
+	sep := "\n"
+	if e.separator != nil {
+		sep = *e.separator
+	}
+	_, err := io.WriteString(e.writer, s+sep)
+	return err
+}
+
+// SetOptions adds encode options, such as the pipeline's default encode
+// options when the encoder is passed to WithEncoder
+func (e *StreamingYAMLEncoder) SetOptions(opts ...yaml.EncodeOption) {
+	e.options = append(e.options, opts...)
+}
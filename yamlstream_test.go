@@ -0,0 +1,87 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/goccy/go-yaml"
+)
+
+func TestStreamingYAMLEncoder(t *testing.T) {
+	p, err := jqyaml.New(
+		jqyaml.WithQuery(".[]"),
+		jqyaml.WithDefaultEncodeOptions(yaml.Indent(4)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []interface{}{
+		map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+		2,
+		"three",
+	}
+
+	t.Run("encoder", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := p.Execute(context.Background(), input, jqyaml.WithEncoder(jqyaml.NewStreamingYAMLEncoder(&buf)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The pipeline's default encode options apply
+		want := "a:\n    b: 1\n---\n2\n---\nthree\n"
+		if got := buf.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		var buf bytes.Buffer
+		encoder := jqyaml.NewStreamingYAMLEncoder(&buf)
+		err := p.Execute(context.Background(), input, jqyaml.WithCallback(func(v interface{}) error {
+			if _, ok := v.(string); ok {
+				return nil
+			}
+			return encoder.Encode(v)
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "a:\n  b: 1\n---\n2\n"
+		if got := buf.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+}
+
+func TestWithRawYAMLOutput(t *testing.T) {
+	input := map[string]interface{}{"multi": "line1\nline2\n", "plain": "text", "obj": map[string]interface{}{"a": 1}}
+	tests := []struct {
+		name  string
+		query string
+		opts  []jqyaml.ExecuteOption
+		want  string
+	}{
+		{name: "multi-line string", query: ".multi", want: "line1\nline2\n\n"},
+		{name: "plain string", query: ".plain", want: "text\n"},
+		{name: "non-string stays yaml", query: ".obj, .plain", want: "a: 1\ntext\n"},
+		{name: "join", query: ".plain, .plain", opts: []jqyaml.ExecuteOption{jqyaml.WithOutputSeparator("")}, want: "texttext"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := jqyaml.New(jqyaml.WithQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			opts := append([]jqyaml.ExecuteOption{jqyaml.WithWriter(&buf, jqyaml.FormatYAML), jqyaml.WithRawYAMLOutput()}, tt.opts...)
+			if err := p.Execute(context.Background(), input, opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package jqyaml
+
+import "github.com/goccy/go-yaml"
+
+// yamlStyle holds the YAML output presets set by WithYAMLIndentSequence,
+// WithYAMLSingleQuote and WithYAMLEmptyMapNull
+type yamlStyle struct {
+	indentSequence bool
+	singleQuote    bool
+	emptyMapNull   bool
+}
+
+// encodeOptions returns the go-yaml encode options implementing the presets
+func (s yamlStyle) encodeOptions() []yaml.EncodeOption {
+	var opts []yaml.EncodeOption
+	if s.indentSequence {
+		opts = append(opts, yaml.IndentSequence(true))
+	}
+	if s.singleQuote {
+		opts = append(opts, yaml.UseSingleQuote(true))
+	}
+	return opts
+}
+
+// names describes the enabled presets
+func (s yamlStyle) names() []string {
+	var names []string
+	if s.indentSequence {
+		names = append(names, "indented sequences")
+	}
+	if s.singleQuote {
+		names = append(names, "single quotes")
+	}
+	if s.emptyMapNull {
+		names = append(names, "empty maps as null")
+	}
+	return names
+}
+
+// nullEmptyMaps returns v with its empty objects replaced by nil and whether
+// anything changed, copying only the containers that change
+func nullEmptyMaps(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil, true
+		}
+		var m map[string]interface{}
+		for k, e := range v {
+			if r, changed := nullEmptyMaps(e); changed {
+				if m == nil {
+					m = make(map[string]interface{}, len(v))
+					for k, e := range v {
+						m[k] = e
+					}
+				}
+				m[k] = r
+			}
+		}
+		if m == nil {
+			return v, false
+		}
+		return m, true
+	case yaml.MapSlice:
+		if len(v) == 0 {
+			return nil, true
+		}
+		var m yaml.MapSlice
+		for i, item := range v {
+			if r, changed := nullEmptyMaps(item.Value); changed {
+				if m == nil {
+					m = append(yaml.MapSlice{}, v...)
+				}
+				m[i].Value = r
+			}
+		}
+		if m == nil {
+			return v, false
+		}
+		return m, true
+	case []interface{}:
+		var arr []interface{}
+		for i, e := range v {
+			if r, changed := nullEmptyMaps(e); changed {
+				if arr == nil {
+					arr = append([]interface{}{}, v...)
+				}
+				arr[i] = r
+			}
+		}
+		if arr == nil {
+			return v, false
+		}
+		return arr, true
+	default:
+		return v, false
+	}
+}
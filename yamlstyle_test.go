@@ -0,0 +1,89 @@
+package jqyaml_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	jqyaml "github.com/apstndb/go-jq-yamlformat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestYAMLStylePresets(t *testing.T) {
+	input := map[string]interface{}{
+		"items": []interface{}{"a", "b: c"},
+		"empty": map[string]interface{}{},
+		"list":  []interface{}{map[string]interface{}{}, map[string]interface{}{"x": 1}},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []jqyaml.ExecuteOption
+		expected string
+	}{
+		{
+			name: "default",
+			expected: `empty: {}
+items:
+- a
+- "b: c"
+list:
+- {}
+- x: 1
+`,
+		},
+		{
+			name: "indent sequence",
+			opts: []jqyaml.ExecuteOption{jqyaml.WithYAMLIndentSequence()},
+			expected: `empty: {}
+items:
+  - a
+  - "b: c"
+list:
+  - {}
+  - x: 1
+`,
+		},
+		{
+			name: "single quote",
+			opts: []jqyaml.ExecuteOption{jqyaml.WithYAMLSingleQuote()},
+			expected: `empty: {}
+items:
+- a
+- 'b: c'
+list:
+- {}
+- x: 1
+`,
+		},
+		{
+			name: "empty map null",
+			opts: []jqyaml.ExecuteOption{jqyaml.WithYAMLEmptyMapNull()},
+			expected: `empty: null
+items:
+- a
+- "b: c"
+list:
+- null
+- x: 1
+`,
+		},
+	}
+
+	p, err := jqyaml.New(jqyaml.WithQuery("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := append([]jqyaml.ExecuteOption{jqyaml.WithWriter(&buf, jqyaml.FormatYAML)}, tt.opts...)
+			if err := p.Execute(context.Background(), input, opts...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}